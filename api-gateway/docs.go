@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	inventoryapi "go-microservices/inventory-service/api"
+	notificationapi "go-microservices/notification-service/api"
+	orderapi "go-microservices/order-service/api"
+	paymentapi "go-microservices/payment-service/api"
+	productapi "go-microservices/product-service/api"
+)
+
+// serviceSpec is the OpenAPI document of a service proxied by the gateway
+type serviceSpec struct {
+	Name string
+	Spec []byte
+}
+
+// serviceSpecs lists the documents merged into the gateway's /api/docs
+var serviceSpecs = []serviceSpec{
+	{Name: "product", Spec: productapi.Spec},
+	{Name: "order", Spec: orderapi.Spec},
+	{Name: "inventory", Spec: inventoryapi.Spec},
+	{Name: "notification", Spec: notificationapi.Spec},
+	{Name: "payment", Spec: paymentapi.Spec},
+}
+
+// unroutedPaths are the service paths left out of the merged document,
+// as the gateway does not proxy them
+var unroutedPaths = map[string]bool{"/health": true, "/ready": true}
+
+// mergeSpecs combines the service documents into a single OpenAPI document
+// describing the gateway. Paths get the /api/v1 prefix they are proxied
+// under, and components that clash between services are renamed with the
+// service name as a prefix.
+func mergeSpecs(specs []serviceSpec, prefix string) ([]byte, error) {
+	paths := make(map[string]interface{})
+	components := map[string]map[string]interface{}{
+		"schemas":   {},
+		"responses": {},
+	}
+	var tags []interface{}
+	seenTags := make(map[string]bool)
+
+	for _, s := range specs {
+		var doc map[string]interface{}
+		if err := json.Unmarshal(s.Spec, &doc); err != nil {
+			return nil, fmt.Errorf("%s spec: %w", s.Name, err)
+		}
+
+		// Pick a name for every component and remember the renames
+		renames := make(map[string]string)
+		docComponents, _ := doc["components"].(map[string]interface{})
+		for kind, merged := range components {
+			own, _ := docComponents[kind].(map[string]interface{})
+			for name, def := range own {
+				target := name
+				if existing, ok := merged[name]; ok && !sameJSON(existing, def) {
+					target = strings.ToUpper(s.Name[:1]) + s.Name[1:] + name
+				}
+				renames["#/components/"+kind+"/"+name] = "#/components/" + kind + "/" + target
+			}
+		}
+		rewriteRefs(doc, renames)
+
+		docComponents, _ = doc["components"].(map[string]interface{})
+		for kind, merged := range components {
+			own, _ := docComponents[kind].(map[string]interface{})
+			for name, def := range own {
+				target := renames["#/components/"+kind+"/"+name]
+				merged[strings.TrimPrefix(target, "#/components/"+kind+"/")] = def
+			}
+		}
+
+		docPaths, _ := doc["paths"].(map[string]interface{})
+		for path, item := range docPaths {
+			// Only resource routes are proxied; the gateway has its own
+			// /health, and the probes of each service are not routed
+			if unroutedPaths[path] {
+				continue
+			}
+			paths[prefix+path] = item
+		}
+
+		docTags, _ := doc["tags"].([]interface{})
+		for _, t := range docTags {
+			name, _ := t.(map[string]interface{})["name"].(string)
+			if !seenTags[name] {
+				seenTags[name] = true
+				tags = append(tags, t)
+			}
+		}
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].(map[string]interface{})["name"].(string) < tags[j].(map[string]interface{})["name"].(string)
+	})
+
+	merged := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "Go Microservices API Gateway",
+			"version":     "1.0.0",
+			"description": "Merged API of every service behind the gateway.",
+		},
+		"servers":    []interface{}{map[string]interface{}{"url": "/"}},
+		"tags":       tags,
+		"paths":      paths,
+		"components": components,
+	}
+	return json.MarshalIndent(merged, "", "  ")
+}
+
+// rewriteRefs replaces every $ref found in renames
+func rewriteRefs(node interface{}, renames map[string]string) {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if ref, ok := child.(string); ok && key == "$ref" {
+				if target, ok := renames[ref]; ok {
+					v[key] = target
+				}
+				continue
+			}
+			rewriteRefs(child, renames)
+		}
+	case []interface{}:
+		for _, child := range v {
+			rewriteRefs(child, renames)
+		}
+	}
+}
+
+// sameJSON reports whether two decoded JSON values are identical
+func sameJSON(a, b interface{}) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(ja, jb)
+}
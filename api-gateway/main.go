@@ -7,6 +7,9 @@ import (
 	"net/url"
 	"os"
 
+	"go-microservices/pkg/actor"
+	"go-microservices/pkg/apidocs"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -78,6 +81,10 @@ func main() {
 	apiV1.Any("/orders", createReverseProxy(orderServiceURL, "/orders"))
 	apiV1.Any("/orders/*path", createReverseProxy(orderServiceURL, "/orders"))
 
+	// Coupons, served by the order service
+	apiV1.Any("/coupons", createReverseProxy(orderServiceURL, "/coupons"))
+	apiV1.Any("/coupons/*path", createReverseProxy(orderServiceURL, "/coupons"))
+
 	// Inventory
 	apiV1.Any("/inventory", createReverseProxy(inventoryServiceURL, "/inventory"))
 	apiV1.Any("/inventory/*path", createReverseProxy(inventoryServiceURL, "/inventory"))
@@ -90,6 +97,14 @@ func main() {
 	apiV1.Any("/payments", createReverseProxy(paymentServiceURL, "/payments"))
 	apiV1.Any("/payments/*path", createReverseProxy(paymentServiceURL, "/payments"))
 
+	// Merged OpenAPI document of every service, with a Swagger UI for it
+	docs, err := mergeSpecs(serviceSpecs, "/api/v1")
+	if err != nil {
+		log.Fatal("Failed to merge service API specs: ", err)
+	}
+	r.GET("/api/docs", apidocs.SpecHandler(docs))
+	apidocs.RegisterUI(r, "/api/docs/ui", "/api/docs", "Go Microservices API Gateway")
+
 	// API Documentation endpoint
 	r.GET("/api", func(c *gin.Context) {
 		// List available endpoints
@@ -109,6 +124,11 @@ func main() {
 				"DELETE /api/v1/orders/:id - Delete order",
 				"PATCH /api/v1/orders/:id/status - Update order status",
 			},
+			"coupons": {
+				"GET /api/v1/coupons - List all coupons",
+				"GET /api/v1/coupons/:code - Get coupon details",
+				"POST /api/v1/coupons - Create new coupon",
+			},
 			"inventory": {
 				"GET /api/v1/inventory - List all inventory items",
 				"GET /api/v1/inventory/:id - Get inventory item details",
@@ -137,6 +157,7 @@ func main() {
 			"name":      "Go Microservices API Gateway",
 			"version":   "1.0",
 			"endpoints": endpoints,
+			"docs":      "/api/docs",
 		})
 	})
 
@@ -169,6 +190,10 @@ func createReverseProxy(serviceURL, stripPrefix string) gin.HandlerFunc {
 		// Create the reverse proxy
 		proxy := httputil.NewSingleHostReverseProxy(remote)
 
+		// Only services name the caller of a request, clients cannot
+		// claim to be one
+		actor.Strip(c.Request.Header)
+
 		// Update the headers to allow for SSL redirection
 		c.Request.URL.Host = remote.Host
 		c.Request.URL.Scheme = remote.Scheme
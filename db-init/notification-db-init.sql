@@ -5,5 +5,9 @@ CREATE TABLE IF NOT EXISTS notifications (
     message TEXT NOT NULL,
     status VARCHAR(50) NOT NULL,
     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-    delivered_at TIMESTAMP
+    delivered_at TIMESTAMP,
+    event_id VARCHAR(64)
 );
+
+-- At most one notification per event, so redelivered events are skipped
+CREATE UNIQUE INDEX IF NOT EXISTS notifications_event_id_key ON notifications (event_id);
@@ -3,8 +3,33 @@ CREATE TABLE IF NOT EXISTS orders (
     customer_id INT NOT NULL,
     product_id INT NOT NULL,
     quantity INT NOT NULL,
-    total_price DECIMAL(10, 2) NOT NULL,
+    total_price_minor BIGINT NOT NULL,
+    currency VARCHAR(3) NOT NULL DEFAULT 'USD',
     status VARCHAR(50) NOT NULL,
+    coupon_code VARCHAR(64),
+    region VARCHAR(16),
+    subtotal_minor BIGINT,
+    discount_minor BIGINT,
+    shipping_minor BIGINT,
+    tax_minor BIGINT,
+    tax_rate NUMERIC(7, 4),
+    base_total_price_minor BIGINT,
+    base_currency VARCHAR(3),
+    exchange_rate NUMERIC(24, 10),
+    exchange_rate_as_of TIMESTAMP,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
+
+CREATE TABLE IF NOT EXISTS coupons (
+    code VARCHAR(64) PRIMARY KEY,
+    type VARCHAR(16) NOT NULL,
+    percent_off NUMERIC(5, 2),
+    amount_off_minor BIGINT,
+    currency VARCHAR(3),
+    valid_from TIMESTAMP,
+    valid_until TIMESTAMP,
+    max_uses INT NOT NULL DEFAULT 0,
+    uses INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
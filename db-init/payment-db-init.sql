@@ -2,12 +2,15 @@ CREATE TABLE IF NOT EXISTS payments (
     id SERIAL PRIMARY KEY,
     order_id INTEGER NOT NULL,
     customer_id INTEGER NOT NULL,
-    amount DECIMAL(10, 2) NOT NULL,
+    amount_minor BIGINT NOT NULL,
     currency VARCHAR(3) NOT NULL DEFAULT 'USD',
     status VARCHAR(50) NOT NULL,
     stripe_payment_id VARCHAR(255),
-    stripe_client_secret VARCHAR(255),
-    payment_method VARCHAR(50),
+    payment_method TEXT,
+    capture_method VARCHAR(20) NOT NULL DEFAULT 'automatic',
+    amount_captured_minor BIGINT NOT NULL DEFAULT 0,
+    amount_refunded_minor BIGINT NOT NULL DEFAULT 0,
+    expiry_flagged_at TIMESTAMP,
     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
@@ -16,3 +19,6 @@ CREATE INDEX IF NOT EXISTS idx_payments_order_id ON payments(order_id);
 CREATE INDEX IF NOT EXISTS idx_payments_customer_id ON payments(customer_id);
 CREATE INDEX IF NOT EXISTS idx_payments_status ON payments(status);
 CREATE INDEX IF NOT EXISTS idx_payments_stripe_payment_id ON payments(stripe_payment_id);
+CREATE INDEX IF NOT EXISTS idx_payments_uncaptured ON payments(created_at)
+    WHERE capture_method = 'manual' AND status IN ('pending', 'requires_capture');
+CREATE INDEX IF NOT EXISTS idx_payments_created_at ON payments(created_at);
@@ -1,23 +1,35 @@
 package controller
 
 import (
-	"database/sql"
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"go-microservices/inventory-service/model"
+	"go-microservices/inventory-service/service"
 
 	"github.com/gin-gonic/gin"
 )
 
+// InventoryServiceInterface defines the inventory operations used by the controller
+type InventoryServiceInterface interface {
+	GetInventories() ([]model.Inventory, error)
+	GetInventory(id int) (*model.Inventory, error)
+	CreateInventory(inventory *model.Inventory) error
+	UpdateInventory(inventory *model.Inventory) error
+	DeleteInventory(id int) error
+	CheckInventory(check model.InventoryCheck) (*model.InventoryResponse, error)
+}
+
 // InventoryController handles inventory-related requests
 type InventoryController struct {
-	DB *sql.DB
+	Service InventoryServiceInterface
 }
 
 // NewInventoryController creates a new inventory controller
-func NewInventoryController(db *sql.DB) *InventoryController {
-	return &InventoryController{DB: db}
+func NewInventoryController(inventoryService InventoryServiceInterface) *InventoryController {
+	return &InventoryController{Service: inventoryService}
 }
 
 // CreateInventory handles creation of a new inventory item
@@ -28,56 +40,36 @@ func (ic *InventoryController) CreateInventory(c *gin.Context) {
 		return
 	}
 
-	var id int
-	err := ic.DB.QueryRow(
-		"INSERT INTO inventory (product_id, quantity, sku, location) VALUES ($1, $2, $3, $4) RETURNING id",
-		inventory.ProductID, inventory.Quantity, inventory.SKU, inventory.Location).Scan(&id)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := ic.Service.CreateInventory(&inventory); err != nil {
+		respondError(c, err)
 		return
 	}
 
-	inventory.ID = id
 	c.JSON(http.StatusCreated, inventory)
 }
 
 // GetInventories returns all inventory items
 func (ic *InventoryController) GetInventories(c *gin.Context) {
-	rows, err := ic.DB.Query("SELECT id, product_id, quantity, sku, location FROM inventory")
+	inventories, err := ic.Service.GetInventories()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
-	defer rows.Close()
-
-	var inventories []model.Inventory
-	for rows.Next() {
-		var i model.Inventory
-		if err := rows.Scan(&i.ID, &i.ProductID, &i.Quantity, &i.SKU, &i.Location); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		inventories = append(inventories, i)
-	}
 
 	c.JSON(http.StatusOK, inventories)
 }
 
 // GetInventory returns a specific inventory item by ID
 func (ic *InventoryController) GetInventory(c *gin.Context) {
-	id := c.Param("id")
-	var inventory model.Inventory
-
-	err := ic.DB.QueryRow("SELECT id, product_id, quantity, sku, location FROM inventory WHERE id = $1", id).
-		Scan(&inventory.ID, &inventory.ProductID, &inventory.Quantity, &inventory.SKU, &inventory.Location)
-
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Inventory item not found"})
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
 		return
 	}
+
+	inventory, err := ic.Service.GetInventory(id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
@@ -98,37 +90,25 @@ func (ic *InventoryController) UpdateInventory(c *gin.Context) {
 		return
 	}
 
-	result, err := ic.DB.Exec(
-		"UPDATE inventory SET product_id = $1, quantity = $2, sku = $3, location = $4 WHERE id = $5",
-		inventory.ProductID, inventory.Quantity, inventory.SKU, inventory.Location, id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Inventory item not found"})
+	inventory.ID = id
+	if err := ic.Service.UpdateInventory(&inventory); err != nil {
+		respondError(c, err)
 		return
 	}
 
-	inventory.ID = id
 	c.JSON(http.StatusOK, inventory)
 }
 
 // DeleteInventory deletes an inventory item
 func (ic *InventoryController) DeleteInventory(c *gin.Context) {
-	id := c.Param("id")
-
-	result, err := ic.DB.Exec("DELETE FROM inventory WHERE id = $1", id)
+	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Inventory item not found"})
+	if err := ic.Service.DeleteInventory(id); err != nil {
+		respondError(c, err)
 		return
 	}
 
@@ -143,29 +123,32 @@ func (ic *InventoryController) CheckInventory(c *gin.Context) {
 		return
 	}
 
-	var quantity int
-	err := ic.DB.QueryRow("SELECT quantity FROM inventory WHERE product_id = $1", check.ProductID).Scan(&quantity)
-
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusOK, model.InventoryResponse{
-			Available: false,
-			Message:   "Product not found in inventory",
-		})
-		return
-	}
+	response, err := ic.Service.CheckInventory(check)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	available := quantity >= check.Quantity
-	message := ""
-	if !available {
-		message = "Not enough inventory"
-	}
+	c.JSON(http.StatusOK, response)
+}
 
-	c.JSON(http.StatusOK, model.InventoryResponse{
-		Available: available,
-		Message:   message,
+// HealthCheck returns the health status of the inventory service
+func (ic *InventoryController) HealthCheck(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"service": "inventory-service",
+		"time":    time.Now().UTC(),
 	})
 }
+
+// respondError maps a service error to its HTTP response
+func respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInventoryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Inventory item not found"})
+	case errors.Is(err, service.ErrInvalidInventory):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
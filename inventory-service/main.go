@@ -5,7 +5,9 @@ import (
 
 	"go-microservices/inventory-service/controller"
 	"go-microservices/inventory-service/db"
+	"go-microservices/inventory-service/repository"
 	"go-microservices/inventory-service/routes"
+	"go-microservices/inventory-service/service"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -20,7 +22,7 @@ func main() {
 	db.InitSchema(database)
 
 	// Create inventory controller
-	inventoryController := controller.NewInventoryController(database)
+	inventoryController := controller.NewInventoryController(service.NewInventoryService(repository.NewDBInventoryRepository(database)))
 
 	// Initialize router
 	router := gin.Default()
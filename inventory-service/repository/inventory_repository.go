@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"database/sql"
+
+	"go-microservices/inventory-service/model"
+)
+
+// InventoryRepository defines the storage operations on inventory items.
+// Lookups, updates and deletes of a missing item return sql.ErrNoRows.
+type InventoryRepository interface {
+	GetAll() ([]model.Inventory, error)
+	GetByID(id int) (*model.Inventory, error)
+	// GetQuantity returns the units in stock of a product
+	GetQuantity(productID int) (int, error)
+	Create(inventory *model.Inventory) error
+	Update(inventory *model.Inventory) error
+	Delete(id int) error
+}
+
+// DBInventoryRepository implements InventoryRepository using the inventory table
+type DBInventoryRepository struct {
+	DB *sql.DB
+}
+
+// NewDBInventoryRepository creates a repository backed by the given database
+func NewDBInventoryRepository(db *sql.DB) *DBInventoryRepository {
+	return &DBInventoryRepository{DB: db}
+}
+
+// GetAll returns every inventory item
+func (r *DBInventoryRepository) GetAll() ([]model.Inventory, error) {
+	rows, err := r.DB.Query("SELECT id, product_id, quantity, sku, location FROM inventory")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var inventories []model.Inventory
+	for rows.Next() {
+		var i model.Inventory
+		if err := rows.Scan(&i.ID, &i.ProductID, &i.Quantity, &i.SKU, &i.Location); err != nil {
+			return nil, err
+		}
+		inventories = append(inventories, i)
+	}
+
+	return inventories, rows.Err()
+}
+
+// GetByID returns an inventory item by ID
+func (r *DBInventoryRepository) GetByID(id int) (*model.Inventory, error) {
+	var inventory model.Inventory
+	err := r.DB.QueryRow("SELECT id, product_id, quantity, sku, location FROM inventory WHERE id = $1", id).
+		Scan(&inventory.ID, &inventory.ProductID, &inventory.Quantity, &inventory.SKU, &inventory.Location)
+	if err != nil {
+		return nil, err
+	}
+
+	return &inventory, nil
+}
+
+// GetQuantity returns the units in stock of a product
+func (r *DBInventoryRepository) GetQuantity(productID int) (int, error) {
+	var quantity int
+	err := r.DB.QueryRow("SELECT quantity FROM inventory WHERE product_id = $1", productID).Scan(&quantity)
+	return quantity, err
+}
+
+// Create inserts an inventory item and sets its ID
+func (r *DBInventoryRepository) Create(inventory *model.Inventory) error {
+	return r.DB.QueryRow(
+		"INSERT INTO inventory (product_id, quantity, sku, location) VALUES ($1, $2, $3, $4) RETURNING id",
+		inventory.ProductID, inventory.Quantity, inventory.SKU, inventory.Location).Scan(&inventory.ID)
+}
+
+// Update replaces an inventory item
+func (r *DBInventoryRepository) Update(inventory *model.Inventory) error {
+	result, err := r.DB.Exec(
+		"UPDATE inventory SET product_id = $1, quantity = $2, sku = $3, location = $4 WHERE id = $5",
+		inventory.ProductID, inventory.Quantity, inventory.SKU, inventory.Location, inventory.ID)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(result)
+}
+
+// Delete removes an inventory item
+func (r *DBInventoryRepository) Delete(id int) error {
+	result, err := r.DB.Exec("DELETE FROM inventory WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(result)
+}
+
+// checkAffected returns sql.ErrNoRows when a statement touched no rows
+func checkAffected(result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"database/sql"
+	"sort"
+	"sync"
+
+	"go-microservices/inventory-service/model"
+)
+
+// MemoryInventoryRepository implements InventoryRepository in memory, so
+// the service can run in tests without a database
+type MemoryInventoryRepository struct {
+	mu          sync.RWMutex
+	nextID      int
+	inventories map[int]model.Inventory
+}
+
+// NewMemoryInventoryRepository creates an empty in-memory repository
+func NewMemoryInventoryRepository() *MemoryInventoryRepository {
+	return &MemoryInventoryRepository{
+		nextID:      1,
+		inventories: make(map[int]model.Inventory),
+	}
+}
+
+// GetAll returns every inventory item ordered by ID
+func (r *MemoryInventoryRepository) GetAll() ([]model.Inventory, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var inventories []model.Inventory
+	for _, i := range r.inventories {
+		inventories = append(inventories, i)
+	}
+	sort.Slice(inventories, func(i, j int) bool { return inventories[i].ID < inventories[j].ID })
+
+	return inventories, nil
+}
+
+// GetByID returns an inventory item by ID
+func (r *MemoryInventoryRepository) GetByID(id int) (*model.Inventory, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	inventory, ok := r.inventories[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	return &inventory, nil
+}
+
+// GetQuantity returns the units in stock of a product
+func (r *MemoryInventoryRepository) GetQuantity(productID int) (int, error) {
+	inventories, _ := r.GetAll()
+	for _, i := range inventories {
+		if i.ProductID == productID {
+			return i.Quantity, nil
+		}
+	}
+
+	return 0, sql.ErrNoRows
+}
+
+// Create stores an inventory item under the next free ID
+func (r *MemoryInventoryRepository) Create(inventory *model.Inventory) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	inventory.ID = r.nextID
+	r.nextID++
+	r.inventories[inventory.ID] = *inventory
+
+	return nil
+}
+
+// Update replaces an inventory item
+func (r *MemoryInventoryRepository) Update(inventory *model.Inventory) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.inventories[inventory.ID]; !ok {
+		return sql.ErrNoRows
+	}
+	r.inventories[inventory.ID] = *inventory
+
+	return nil
+}
+
+// Delete removes an inventory item
+func (r *MemoryInventoryRepository) Delete(id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.inventories[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(r.inventories, id)
+
+	return nil
+}
@@ -1,20 +1,19 @@
 package routes
 
 import (
+	"go-microservices/inventory-service/api"
 	"go-microservices/inventory-service/controller"
+	"go-microservices/pkg/apidocs"
 
 	"github.com/gin-gonic/gin"
 )
 
 // SetupRoutes configures the API routes for the inventory service
 func SetupRoutes(router *gin.Engine, inventoryController *controller.InventoryController) {
-	// Inventory routes
-	router.POST("/inventory", inventoryController.CreateInventory)
-	router.GET("/inventory", inventoryController.GetInventories)
-	router.GET("/inventory/:id", inventoryController.GetInventory)
-	router.PUT("/inventory/:id", inventoryController.UpdateInventory)
-	router.DELETE("/inventory/:id", inventoryController.DeleteInventory)
+	// Health check and inventory routes, including the check route used
+	// by order service, as declared in api/openapi.json
+	api.RegisterHandlers(router, inventoryController)
 
-	// Inventory check route for order service
-	router.POST("/inventory/check", inventoryController.CheckInventory)
+	// OpenAPI document and Swagger UI
+	apidocs.Register(router, api.Spec)
 }
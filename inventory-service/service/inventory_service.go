@@ -0,0 +1,109 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"go-microservices/inventory-service/model"
+	"go-microservices/inventory-service/repository"
+)
+
+var (
+	// ErrInventoryNotFound is returned when no inventory item has the requested ID
+	ErrInventoryNotFound = errors.New("inventory item not found")
+	// ErrInvalidInventory is wrapped by every validation error
+	ErrInvalidInventory = errors.New("invalid inventory")
+)
+
+// InventoryService implements the stock rules on top of a repository
+type InventoryService struct {
+	repo repository.InventoryRepository
+}
+
+// NewInventoryService creates an inventory service storing items in repo
+func NewInventoryService(repo repository.InventoryRepository) *InventoryService {
+	return &InventoryService{repo: repo}
+}
+
+// GetInventories returns every inventory item
+func (s *InventoryService) GetInventories() ([]model.Inventory, error) {
+	return s.repo.GetAll()
+}
+
+// GetInventory returns an inventory item by ID
+func (s *InventoryService) GetInventory(id int) (*model.Inventory, error) {
+	inventory, err := s.repo.GetByID(id)
+	if err == sql.ErrNoRows {
+		return nil, ErrInventoryNotFound
+	}
+	return inventory, err
+}
+
+// CreateInventory validates and stores a new inventory item
+func (s *InventoryService) CreateInventory(inventory *model.Inventory) error {
+	if err := validate(inventory); err != nil {
+		return err
+	}
+	return s.repo.Create(inventory)
+}
+
+// UpdateInventory validates and replaces an inventory item
+func (s *InventoryService) UpdateInventory(inventory *model.Inventory) error {
+	if err := validate(inventory); err != nil {
+		return err
+	}
+	return notFound(s.repo.Update(inventory))
+}
+
+// DeleteInventory removes an inventory item
+func (s *InventoryService) DeleteInventory(id int) error {
+	return notFound(s.repo.Delete(id))
+}
+
+// CheckInventory reports whether the requested quantity of a product is in
+// stock. A product without inventory is unavailable rather than an error.
+func (s *InventoryService) CheckInventory(check model.InventoryCheck) (*model.InventoryResponse, error) {
+	if check.Quantity <= 0 {
+		return nil, fmt.Errorf("%w: quantity must be positive", ErrInvalidInventory)
+	}
+
+	quantity, err := s.repo.GetQuantity(check.ProductID)
+	if err == sql.ErrNoRows {
+		return &model.InventoryResponse{
+			Available: false,
+			Message:   "Product not found in inventory",
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if quantity < check.Quantity {
+		return &model.InventoryResponse{
+			Available: false,
+			Message:   "Not enough inventory",
+		}, nil
+	}
+
+	return &model.InventoryResponse{Available: true}, nil
+}
+
+// validate checks the fields every stored inventory item must have
+func validate(inventory *model.Inventory) error {
+	if inventory.Quantity < 0 {
+		return fmt.Errorf("%w: quantity must not be negative", ErrInvalidInventory)
+	}
+	if inventory.SKU == "" {
+		return fmt.Errorf("%w: sku is required", ErrInvalidInventory)
+	}
+	return nil
+}
+
+// notFound translates the repository's missing-row error
+func notFound(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrInventoryNotFound
+	}
+	return err
+}
@@ -0,0 +1,36 @@
+package contract
+
+import (
+	"net/http"
+	"testing"
+
+	"go-microservices/inventory-service/controller"
+	"go-microservices/inventory-service/model"
+	"go-microservices/inventory-service/repository"
+	"go-microservices/inventory-service/routes"
+	"go-microservices/inventory-service/service"
+	"go-microservices/pkg/contract"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestConsumerContracts replays the contracts of every consumer of
+// inventory-service against its router
+func TestConsumerContracts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	contract.Verify(t, contract.Load(t, "../../../contracts", "inventory-service"), func(t *testing.T, state string) http.Handler {
+		repo := repository.NewMemoryInventoryRepository()
+
+		switch state {
+		case "product 1 has 10 units in stock":
+			repo.Create(&model.Inventory{ProductID: 1, Quantity: 10, SKU: "LAPTOP001", Location: "Warehouse A"})
+		default:
+			t.Fatalf("unknown provider state %q", state)
+		}
+
+		router := gin.New()
+		routes.SetupRoutes(router, controller.NewInventoryController(service.NewInventoryService(repo)))
+		return router
+	})
+}
@@ -0,0 +1,55 @@
+package unit
+
+import (
+	"testing"
+
+	"go-microservices/inventory-service/model"
+	"go-microservices/inventory-service/repository"
+	"go-microservices/inventory-service/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckInventory(t *testing.T) {
+	repo := repository.NewMemoryInventoryRepository()
+	require.NoError(t, repo.Create(&model.Inventory{ProductID: 1, Quantity: 10, SKU: "LAPTOP001"}))
+	inventoryService := service.NewInventoryService(repo)
+
+	tests := []struct {
+		name      string
+		check     model.InventoryCheck
+		available bool
+		message   string
+	}{
+		{"in stock", model.InventoryCheck{ProductID: 1, Quantity: 2}, true, ""},
+		{"all remaining stock", model.InventoryCheck{ProductID: 1, Quantity: 10}, true, ""},
+		{"not enough stock", model.InventoryCheck{ProductID: 1, Quantity: 11}, false, "Not enough inventory"},
+		{"unknown product", model.InventoryCheck{ProductID: 99, Quantity: 1}, false, "Product not found in inventory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := inventoryService.CheckInventory(tt.check)
+			require.NoError(t, err)
+			assert.Equal(t, tt.available, response.Available)
+			assert.Equal(t, tt.message, response.Message)
+		})
+	}
+}
+
+func TestCheckInventory_InvalidQuantity(t *testing.T) {
+	inventoryService := service.NewInventoryService(repository.NewMemoryInventoryRepository())
+
+	_, err := inventoryService.CheckInventory(model.InventoryCheck{ProductID: 1, Quantity: 0})
+	assert.ErrorIs(t, err, service.ErrInvalidInventory)
+}
+
+func TestUpdateInventory_Validation(t *testing.T) {
+	inventoryService := service.NewInventoryService(repository.NewMemoryInventoryRepository())
+
+	err := inventoryService.UpdateInventory(&model.Inventory{ID: 1, ProductID: 1, Quantity: -1, SKU: "LAPTOP001"})
+	assert.ErrorIs(t, err, service.ErrInvalidInventory)
+
+	err = inventoryService.UpdateInventory(&model.Inventory{ID: 1, ProductID: 1, Quantity: 1, SKU: "LAPTOP001"})
+	assert.ErrorIs(t, err, service.ErrInventoryNotFound)
+}
@@ -0,0 +1,12 @@
+// Package api holds the OpenAPI spec of the notification service together with
+// the types, client and gin server interface generated from it.
+package api
+
+import _ "embed"
+
+//go:generate go run ../../tools/apigen -spec openapi.json -package api -o api.gen.go
+
+// Spec is the OpenAPI document of the service, served at /openapi.json
+//
+//go:embed openapi.json
+var Spec []byte
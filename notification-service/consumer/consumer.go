@@ -0,0 +1,73 @@
+// Package consumer turns the order and payment events published on the
+// broker into customer notifications. The HTTP endpoints of the service
+// remain for publishers that could not reach the broker.
+package consumer
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"go-microservices/notification-service/model"
+	"go-microservices/notification-service/service"
+	"go-microservices/pkg/broker"
+	"go-microservices/pkg/events"
+)
+
+// SubscriptionName is the subscription notification-service receives
+// events on
+const SubscriptionName = "notifications"
+
+// Notifier creates the notification for an event
+type Notifier interface {
+	NotifyEvent(envelope events.Envelope, event events.Event) (*model.Notification, error)
+}
+
+// Subscription receives every order and payment event. Failed events are
+// retried with the broker's default retry policy before being
+// dead-lettered.
+func Subscription(concurrency int) broker.Subscription {
+	return broker.Subscription{
+		Name: SubscriptionName,
+		Bindings: []broker.Binding{
+			{Topic: events.TopicOrders, Pattern: "order.#"},
+			{Topic: events.TopicPayments, Pattern: "payment.#"},
+		},
+		Concurrency: concurrency,
+	}
+}
+
+// Start subscribes to the order and payment events with concurrency
+// handlers
+func Start(b broker.Broker, notifier Notifier, concurrency int) (broker.Subscriber, error) {
+	return b.Subscribe(Subscription(concurrency), Handler(notifier))
+}
+
+// Handler decodes an event envelope and notifies the customer of it.
+// Events of unknown types, events no notification is sent for and events
+// already notified are acked without a notification. Other failures are
+// returned so the event is retried.
+func Handler(notifier Notifier) broker.Handler {
+	return func(_ context.Context, body []byte) error {
+		envelope, event, err := events.Decode(body)
+		if errors.Is(err, events.ErrUnknownType) {
+			log.Printf("Skipping event: %v\n", err)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		notification, err := notifier.NotifyEvent(envelope, event)
+		switch {
+		case errors.Is(err, service.ErrAlreadyNotified), errors.Is(err, service.ErrUnhandledEvent):
+			log.Printf("Skipping %s event %s: %v\n", envelope.Type, envelope.ID, err)
+			return nil
+		case err != nil:
+			return err
+		}
+
+		log.Printf("Created notification %d for %s event %s\n", notification.ID, envelope.Type, envelope.ID)
+		return nil
+	}
+}
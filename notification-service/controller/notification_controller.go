@@ -1,25 +1,35 @@
 package controller
 
 import (
-	"database/sql"
-	"fmt"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
 	"go-microservices/notification-service/model"
+	"go-microservices/notification-service/service"
 
 	"github.com/gin-gonic/gin"
 )
 
+// NotificationServiceInterface defines the notification operations used by the controller
+type NotificationServiceInterface interface {
+	GetNotifications() ([]model.Notification, error)
+	GetNotification(id int) (*model.Notification, error)
+	GetCustomerNotifications(customerID int) ([]model.Notification, error)
+	CreateNotification(notification *model.Notification) error
+	MarkDelivered(id int) (time.Time, error)
+	ProcessOrderStatusUpdate(update model.OrderStatusUpdate) (*model.Notification, error)
+}
+
 // NotificationController handles notification-related requests
 type NotificationController struct {
-	DB *sql.DB
+	Service NotificationServiceInterface
 }
 
 // NewNotificationController creates a new notification controller
-func NewNotificationController(db *sql.DB) *NotificationController {
-	return &NotificationController{DB: db}
+func NewNotificationController(notificationService NotificationServiceInterface) *NotificationController {
+	return &NotificationController{Service: notificationService}
 }
 
 // CreateNotification handles creation of a new notification
@@ -30,68 +40,37 @@ func (nc *NotificationController) CreateNotification(c *gin.Context) {
 		return
 	}
 
-	notification.CreatedAt = time.Now()
-
-	var id int
-	err := nc.DB.QueryRow(
-		"INSERT INTO notifications (order_id, customer_id, message, status, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING id",
-		notification.OrderID, notification.CustomerID, notification.Message, notification.Status, notification.CreatedAt).Scan(&id)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := nc.Service.CreateNotification(&notification); err != nil {
+		respondError(c, err)
 		return
 	}
 
-	notification.ID = id
 	c.JSON(http.StatusCreated, notification)
 }
 
 // GetNotifications returns all notifications
 func (nc *NotificationController) GetNotifications(c *gin.Context) {
-	rows, err := nc.DB.Query("SELECT id, order_id, customer_id, message, status, created_at, delivered_at FROM notifications")
+	notifications, err := nc.Service.GetNotifications()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
-	defer rows.Close()
-
-	var notifications []model.Notification
-	for rows.Next() {
-		var n model.Notification
-		var deliveredAt sql.NullTime
-		if err := rows.Scan(&n.ID, &n.OrderID, &n.CustomerID, &n.Message, &n.Status, &n.CreatedAt, &deliveredAt); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		if deliveredAt.Valid {
-			n.DeliveredAt = deliveredAt.Time
-		}
-		notifications = append(notifications, n)
-	}
 
 	c.JSON(http.StatusOK, notifications)
 }
 
 // GetNotification returns a specific notification by ID
 func (nc *NotificationController) GetNotification(c *gin.Context) {
-	id := c.Param("id")
-	var notification model.Notification
-	var deliveredAt sql.NullTime
-
-	err := nc.DB.QueryRow("SELECT id, order_id, customer_id, message, status, created_at, delivered_at FROM notifications WHERE id = $1", id).
-		Scan(&notification.ID, &notification.OrderID, &notification.CustomerID, &notification.Message, &notification.Status, &notification.CreatedAt, &deliveredAt)
-
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
-		return
-	}
+	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
 		return
 	}
 
-	if deliveredAt.Valid {
-		notification.DeliveredAt = deliveredAt.Time
+	notification, err := nc.Service.GetNotification(id)
+	if err != nil {
+		respondError(c, err)
+		return
 	}
 
 	c.JSON(http.StatusOK, notification)
@@ -99,26 +78,16 @@ func (nc *NotificationController) GetNotification(c *gin.Context) {
 
 // GetCustomerNotifications returns all notifications for a customer
 func (nc *NotificationController) GetCustomerNotifications(c *gin.Context) {
-	customerID := c.Param("customerId")
-	rows, err := nc.DB.Query("SELECT id, order_id, customer_id, message, status, created_at, delivered_at FROM notifications WHERE customer_id = $1", customerID)
+	customerID, err := strconv.Atoi(c.Param("customerId"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid customer ID"})
 		return
 	}
-	defer rows.Close()
-
-	var notifications []model.Notification
-	for rows.Next() {
-		var n model.Notification
-		var deliveredAt sql.NullTime
-		if err := rows.Scan(&n.ID, &n.OrderID, &n.CustomerID, &n.Message, &n.Status, &n.CreatedAt, &deliveredAt); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		if deliveredAt.Valid {
-			n.DeliveredAt = deliveredAt.Time
-		}
-		notifications = append(notifications, n)
+
+	notifications, err := nc.Service.GetCustomerNotifications(customerID)
+	if err != nil {
+		respondError(c, err)
+		return
 	}
 
 	c.JSON(http.StatusOK, notifications)
@@ -132,20 +101,13 @@ func (nc *NotificationController) MarkDelivered(c *gin.Context) {
 		return
 	}
 
-	now := time.Now()
-	result, err := nc.DB.Exec("UPDATE notifications SET delivered_at = $1 WHERE id = $2", now, id)
+	deliveredAt, err := nc.Service.MarkDelivered(id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Notification marked as delivered", "delivered_at": now})
+	c.JSON(http.StatusOK, gin.H{"message": "Notification marked as delivered", "delivered_at": deliveredAt})
 }
 
 // ProcessOrderStatusUpdate processes an order status update and creates a notification
@@ -156,23 +118,34 @@ func (nc *NotificationController) ProcessOrderStatusUpdate(c *gin.Context) {
 		return
 	}
 
-	// Create a notification from the status update
-	message := fmt.Sprintf("Your order #%d status has changed to: %s", update.OrderID, update.Status)
-	now := time.Now()
-
-	var id int
-	err := nc.DB.QueryRow(
-		"INSERT INTO notifications (order_id, customer_id, message, status, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING id",
-		update.OrderID, update.CustomerID, message, update.Status, now).Scan(&id)
-
+	notification, err := nc.Service.ProcessOrderStatusUpdate(update)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
 	// In a real application, you would send the notification through email, SMS, etc.
 	c.JSON(http.StatusOK, gin.H{
 		"message":         "Order status notification created",
-		"notification_id": id,
+		"notification_id": notification.ID,
 	})
 }
+
+// HealthCheck returns the health status of the notification service
+func (nc *NotificationController) HealthCheck(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "UP"})
+}
+
+// respondError maps a service error to its HTTP response
+func respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrNotificationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+	case errors.Is(err, service.ErrInvalidNotification):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrAlreadyNotified):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -68,8 +68,11 @@ func InitSchema(db *sql.DB) {
 		message TEXT NOT NULL,
 		status VARCHAR(50) NOT NULL,
 		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		delivered_at TIMESTAMP
-	);`
+		delivered_at TIMESTAMP,
+		event_id VARCHAR(64)
+	);
+	ALTER TABLE notifications ADD COLUMN IF NOT EXISTS event_id VARCHAR(64);
+	CREATE UNIQUE INDEX IF NOT EXISTS notifications_event_id_key ON notifications (event_id);`
 
 	_, err := db.Exec(createTableSQL)
 	if err != nil {
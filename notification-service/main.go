@@ -3,9 +3,13 @@ package main
 import (
 	"log"
 
+	"go-microservices/notification-service/consumer"
 	"go-microservices/notification-service/controller"
 	"go-microservices/notification-service/db"
+	"go-microservices/notification-service/repository"
 	"go-microservices/notification-service/routes"
+	"go-microservices/notification-service/service"
+	"go-microservices/pkg/broker"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -19,8 +23,27 @@ func main() {
 	// Initialize database schema
 	db.InitSchema(database)
 
+	notificationService := service.NewNotificationService(repository.NewDBNotificationRepository(database))
+
+	// Initialize the message broker selected by BROKER
+	eventBroker, err := broker.NewFromEnv()
+	if eventBroker == nil {
+		log.Fatal("Failed to create message broker: ", err)
+	}
+	if err != nil {
+		log.Printf("Warning: Failed to connect to message broker: %v\n", err)
+	}
+	defer eventBroker.Close()
+
+	// Notify customers of order and payment events. On RabbitMQ the
+	// subscription starts once the broker is reachable; until then
+	// publishers fall back to HTTP.
+	if _, err := consumer.Start(eventBroker, notificationService, 4); err != nil {
+		log.Printf("Warning: Failed to subscribe to events: %v\n", err)
+	}
+
 	// Create notification controller
-	notificationController := controller.NewNotificationController(database)
+	notificationController := controller.NewNotificationController(notificationService)
 
 	// Initialize router
 	router := gin.Default()
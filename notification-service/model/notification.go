@@ -11,6 +11,9 @@ type Notification struct {
 	Status      string    `json:"status"`
 	CreatedAt   time.Time `json:"created_at"`
 	DeliveredAt time.Time `json:"delivered_at,omitempty"`
+	// EventID is the ID of the event the notification was created for,
+	// empty for notifications created over HTTP
+	EventID string `json:"event_id,omitempty"`
 }
 
 // OrderStatusUpdate used to receive order status updates
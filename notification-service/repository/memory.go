@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+
+	"go-microservices/notification-service/model"
+)
+
+// MemoryNotificationRepository implements NotificationRepository in memory,
+// so the service can run in tests without a database
+type MemoryNotificationRepository struct {
+	mu            sync.RWMutex
+	nextID        int
+	notifications map[int]model.Notification
+	events        map[string]int
+}
+
+// NewMemoryNotificationRepository creates an empty in-memory repository
+func NewMemoryNotificationRepository() *MemoryNotificationRepository {
+	return &MemoryNotificationRepository{
+		nextID:        1,
+		notifications: make(map[int]model.Notification),
+		events:        make(map[string]int),
+	}
+}
+
+// GetAll returns every notification ordered by ID
+func (r *MemoryNotificationRepository) GetAll() ([]model.Notification, error) {
+	return r.filter(func(model.Notification) bool { return true }), nil
+}
+
+// GetByID returns a notification by ID
+func (r *MemoryNotificationRepository) GetByID(id int) (*model.Notification, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	notification, ok := r.notifications[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	return &notification, nil
+}
+
+// GetByCustomer returns the notifications of a customer ordered by ID
+func (r *MemoryNotificationRepository) GetByCustomer(customerID int) ([]model.Notification, error) {
+	return r.filter(func(n model.Notification) bool { return n.CustomerID == customerID }), nil
+}
+
+// Create stores a notification under the next free ID, unless one was
+// already stored for its event
+func (r *MemoryNotificationRepository) Create(notification *model.Notification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if notification.EventID != "" {
+		if _, ok := r.events[notification.EventID]; ok {
+			return ErrDuplicateEvent
+		}
+		r.events[notification.EventID] = r.nextID
+	}
+	notification.ID = r.nextID
+	r.nextID++
+	r.notifications[notification.ID] = *notification
+
+	return nil
+}
+
+// MarkDelivered records when a notification was delivered
+func (r *MemoryNotificationRepository) MarkDelivered(id int, deliveredAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	notification, ok := r.notifications[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	notification.DeliveredAt = deliveredAt
+	r.notifications[id] = notification
+
+	return nil
+}
+
+// filter returns the notifications accepted by keep ordered by ID
+func (r *MemoryNotificationRepository) filter(keep func(model.Notification) bool) []model.Notification {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var notifications []model.Notification
+	for _, n := range r.notifications {
+		if keep(n) {
+			notifications = append(notifications, n)
+		}
+	}
+	sort.Slice(notifications, func(i, j int) bool { return notifications[i].ID < notifications[j].ID })
+
+	return notifications
+}
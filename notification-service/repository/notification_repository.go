@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"go-microservices/notification-service/model"
+)
+
+// ErrDuplicateEvent is returned by Create when a notification was already
+// created for the notification's EventID
+var ErrDuplicateEvent = errors.New("notification already created for event")
+
+// NotificationRepository defines the storage operations on notifications.
+// Lookups and updates of a missing notification return sql.ErrNoRows.
+type NotificationRepository interface {
+	GetAll() ([]model.Notification, error)
+	GetByID(id int) (*model.Notification, error)
+	GetByCustomer(customerID int) ([]model.Notification, error)
+	Create(notification *model.Notification) error
+	MarkDelivered(id int, deliveredAt time.Time) error
+}
+
+// DBNotificationRepository implements NotificationRepository using the
+// notifications table
+type DBNotificationRepository struct {
+	DB *sql.DB
+}
+
+// NewDBNotificationRepository creates a repository backed by the given database
+func NewDBNotificationRepository(db *sql.DB) *DBNotificationRepository {
+	return &DBNotificationRepository{DB: db}
+}
+
+const selectNotifications = "SELECT id, order_id, customer_id, message, status, created_at, delivered_at, event_id FROM notifications"
+
+// GetAll returns every notification
+func (r *DBNotificationRepository) GetAll() ([]model.Notification, error) {
+	return r.query(selectNotifications)
+}
+
+// GetByID returns a notification by ID
+func (r *DBNotificationRepository) GetByID(id int) (*model.Notification, error) {
+	notification, err := scanNotification(r.DB.QueryRow(selectNotifications+" WHERE id = $1", id))
+	if err != nil {
+		return nil, err
+	}
+
+	return notification, nil
+}
+
+// GetByCustomer returns the notifications of a customer
+func (r *DBNotificationRepository) GetByCustomer(customerID int) ([]model.Notification, error) {
+	return r.query(selectNotifications+" WHERE customer_id = $1", customerID)
+}
+
+// Create inserts a notification and sets its ID. Notifications without an
+// event ID store NULL, which the unique index on event_id ignores.
+func (r *DBNotificationRepository) Create(notification *model.Notification) error {
+	err := r.DB.QueryRow(
+		"INSERT INTO notifications (order_id, customer_id, message, status, created_at, event_id) VALUES ($1, $2, $3, $4, $5, NULLIF($6, '')) ON CONFLICT (event_id) DO NOTHING RETURNING id",
+		notification.OrderID, notification.CustomerID, notification.Message, notification.Status, notification.CreatedAt, notification.EventID).Scan(&notification.ID)
+	if err == sql.ErrNoRows {
+		return ErrDuplicateEvent
+	}
+	return err
+}
+
+// MarkDelivered records when a notification was delivered
+func (r *DBNotificationRepository) MarkDelivered(id int, deliveredAt time.Time) error {
+	result, err := r.DB.Exec("UPDATE notifications SET delivered_at = $1 WHERE id = $2", deliveredAt, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// query runs a notification query and reads every row
+func (r *DBNotificationRepository) query(query string, args ...interface{}) ([]model.Notification, error) {
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []model.Notification
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, *n)
+	}
+
+	return notifications, rows.Err()
+}
+
+// scanner is satisfied by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanNotification reads a notification selected by selectNotifications
+func scanNotification(s scanner) (*model.Notification, error) {
+	var n model.Notification
+	var deliveredAt sql.NullTime
+	var eventID sql.NullString
+	if err := s.Scan(&n.ID, &n.OrderID, &n.CustomerID, &n.Message, &n.Status, &n.CreatedAt, &deliveredAt, &eventID); err != nil {
+		return nil, err
+	}
+	if deliveredAt.Valid {
+		n.DeliveredAt = deliveredAt.Time
+	}
+	n.EventID = eventID.String
+
+	return &n, nil
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-microservices/notification-service/model"
+	"go-microservices/notification-service/repository"
+	"go-microservices/pkg/events"
+)
+
+var (
+	// ErrNotificationNotFound is returned when no notification has the requested ID
+	ErrNotificationNotFound = errors.New("notification not found")
+	// ErrInvalidNotification is wrapped by every validation error
+	ErrInvalidNotification = errors.New("invalid notification")
+	// ErrAlreadyNotified is returned when a notification was already
+	// created for an event
+	ErrAlreadyNotified = errors.New("event already notified")
+	// ErrUnhandledEvent is returned by NotifyEvent for events customers are
+	// not notified of
+	ErrUnhandledEvent = errors.New("no notification for event")
+)
+
+// Statuses of the notifications about payments
+const (
+	StatusPaymentSucceeded = "payment_succeeded"
+	StatusPaymentFailed    = "payment_failed"
+)
+
+// NotificationService implements the notification rules on top of a repository
+type NotificationService struct {
+	repo repository.NotificationRepository
+	now  func() time.Time
+}
+
+// NewNotificationService creates a notification service storing notifications in repo
+func NewNotificationService(repo repository.NotificationRepository) *NotificationService {
+	return &NotificationService{repo: repo, now: time.Now}
+}
+
+// GetNotifications returns every notification
+func (s *NotificationService) GetNotifications() ([]model.Notification, error) {
+	return s.repo.GetAll()
+}
+
+// GetNotification returns a notification by ID
+func (s *NotificationService) GetNotification(id int) (*model.Notification, error) {
+	notification, err := s.repo.GetByID(id)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotificationNotFound
+	}
+	return notification, err
+}
+
+// GetCustomerNotifications returns the notifications of a customer
+func (s *NotificationService) GetCustomerNotifications(customerID int) ([]model.Notification, error) {
+	return s.repo.GetByCustomer(customerID)
+}
+
+// CreateNotification validates and stores a notification created now
+func (s *NotificationService) CreateNotification(notification *model.Notification) error {
+	if notification.Message == "" {
+		return fmt.Errorf("%w: message is required", ErrInvalidNotification)
+	}
+
+	notification.CreatedAt = s.now()
+	err := s.repo.Create(notification)
+	if err == repository.ErrDuplicateEvent {
+		return fmt.Errorf("%w: %s", ErrAlreadyNotified, notification.EventID)
+	}
+	return err
+}
+
+// MarkDelivered records that a notification was delivered now and returns
+// the delivery time
+func (s *NotificationService) MarkDelivered(id int) (time.Time, error) {
+	now := s.now()
+	err := s.repo.MarkDelivered(id, now)
+	if err == sql.ErrNoRows {
+		return time.Time{}, ErrNotificationNotFound
+	}
+	return now, err
+}
+
+// ProcessOrderStatusUpdate creates the notification telling a customer
+// that their order changed status
+func (s *NotificationService) ProcessOrderStatusUpdate(update model.OrderStatusUpdate) (*model.Notification, error) {
+	if update.Status == "" {
+		return nil, fmt.Errorf("%w: status is required", ErrInvalidNotification)
+	}
+
+	notification := &model.Notification{
+		OrderID:    update.OrderID,
+		CustomerID: update.CustomerID,
+		Message:    StatusMessage(update.OrderID, update.Status),
+		Status:     update.Status,
+	}
+	if err := s.CreateNotification(notification); err != nil {
+		return nil, err
+	}
+
+	return notification, nil
+}
+
+// NotifyEvent creates the notification telling a customer about an order
+// or payment event. Each event is notified at most once, so redeliveries
+// return ErrAlreadyNotified.
+func (s *NotificationService) NotifyEvent(envelope events.Envelope, event events.Event) (*model.Notification, error) {
+	var notification *model.Notification
+	switch e := event.(type) {
+	case *events.OrderCreated:
+		notification = &model.Notification{
+			OrderID:    e.OrderID,
+			CustomerID: e.CustomerID,
+			Message:    fmt.Sprintf("Your order #%d has been placed", e.OrderID),
+			Status:     e.Status,
+		}
+	case *events.OrderStatusChanged:
+		notification = &model.Notification{
+			OrderID:    e.OrderID,
+			CustomerID: e.CustomerID,
+			Message:    StatusMessage(e.OrderID, e.Status),
+			Status:     e.Status,
+		}
+	case *events.PaymentSucceeded:
+		notification = &model.Notification{
+			OrderID:    e.OrderID,
+			CustomerID: e.CustomerID,
+			Message:    fmt.Sprintf("We received your payment of %s for order #%d", e.Amount, e.OrderID),
+			Status:     StatusPaymentSucceeded,
+		}
+	case *events.PaymentFailed:
+		message := fmt.Sprintf("Your payment for order #%d failed", e.OrderID)
+		if e.Reason != "" {
+			message += ": " + e.Reason
+		}
+		notification = &model.Notification{
+			OrderID:    e.OrderID,
+			CustomerID: e.CustomerID,
+			Message:    message,
+			Status:     StatusPaymentFailed,
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnhandledEvent, envelope.Type)
+	}
+
+	notification.EventID = envelope.ID
+	if err := s.CreateNotification(notification); err != nil {
+		return nil, err
+	}
+
+	return notification, nil
+}
+
+// StatusMessage is the text sent to a customer when their order changes status
+func StatusMessage(orderID int, status string) string {
+	return fmt.Sprintf("Your order #%d status has changed to: %s", orderID, status)
+}
@@ -0,0 +1,32 @@
+package contract
+
+import (
+	"net/http"
+	"testing"
+
+	"go-microservices/notification-service/controller"
+	"go-microservices/notification-service/repository"
+	"go-microservices/notification-service/routes"
+	"go-microservices/notification-service/service"
+	"go-microservices/pkg/contract"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestConsumerContracts replays the contracts of every consumer of
+// notification-service against its router
+func TestConsumerContracts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	contract.Verify(t, contract.Load(t, "../../../contracts", "notification-service"), func(t *testing.T, state string) http.Handler {
+		// Notifications are created from the request alone, so no
+		// interaction needs a provider state
+		if state != "" {
+			t.Fatalf("unknown provider state %q", state)
+		}
+
+		router := gin.New()
+		routes.SetupRoutes(router, controller.NewNotificationController(service.NewNotificationService(repository.NewMemoryNotificationRepository())))
+		return router
+	})
+}
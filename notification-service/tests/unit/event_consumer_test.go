@@ -0,0 +1,100 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"go-microservices/notification-service/consumer"
+	"go-microservices/notification-service/model"
+	"go-microservices/notification-service/repository"
+	"go-microservices/notification-service/service"
+	"go-microservices/pkg/broker"
+	"go-microservices/pkg/events"
+	"go-microservices/pkg/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startConsumer consumes events from a memory broker into a memory repository
+func startConsumer(t *testing.T) (*broker.Memory, *repository.MemoryNotificationRepository) {
+	eventBroker := broker.NewMemory()
+	t.Cleanup(func() { eventBroker.Close() })
+	repo := repository.NewMemoryNotificationRepository()
+
+	_, err := consumer.Start(eventBroker, service.NewNotificationService(repo), 1)
+	require.NoError(t, err)
+	return eventBroker, repo
+}
+
+// publish publishes an event the way its producer does
+func publish(t *testing.T, eventBroker *broker.Memory, topic string, envelope events.Envelope) {
+	body, err := json.Marshal(envelope)
+	require.NoError(t, err)
+	require.NoError(t, eventBroker.Publish(context.Background(), broker.Message{
+		Topic: topic,
+		Key:   envelope.Type,
+		ID:    envelope.ID,
+		Body:  body,
+	}))
+}
+
+// notificationsOf waits for a customer to have count notifications
+func notificationsOf(t *testing.T, repo *repository.MemoryNotificationRepository, customerID, count int) []model.Notification {
+	var notifications []model.Notification
+	require.Eventually(t, func() bool {
+		notifications, _ = repo.GetByCustomer(customerID)
+		return len(notifications) == count
+	}, time.Second, 10*time.Millisecond)
+	return notifications
+}
+
+func TestEventConsumer_NotifiesOrderAndPaymentEvents(t *testing.T) {
+	eventBroker, repo := startConsumer(t)
+
+	created, err := events.New("order-service", "", &events.OrderCreated{OrderID: 1, CustomerID: 7, Status: "pending"})
+	require.NoError(t, err)
+	publish(t, eventBroker, events.TopicOrders, created)
+	paid, err := events.New("payment-service", "", &events.PaymentSucceeded{PaymentOutcome: events.PaymentOutcome{OrderID: 1, CustomerID: 7, Amount: money.MustNew(3998, "USD")}})
+	require.NoError(t, err)
+	publish(t, eventBroker, events.TopicPayments, paid)
+
+	notifications := notificationsOf(t, repo, 7, 2)
+	assert.Equal(t, created.ID, notifications[0].EventID)
+	assert.Equal(t, paid.ID, notifications[1].EventID)
+	assert.Equal(t, service.StatusPaymentSucceeded, notifications[1].Status)
+}
+
+func TestEventConsumer_SkipsRedeliveredEvents(t *testing.T) {
+	eventBroker, repo := startConsumer(t)
+
+	shipped, err := events.New("order-service", "", &events.OrderStatusChanged{OrderID: 1, CustomerID: 7, PreviousStatus: "pending", Status: "shipped"})
+	require.NoError(t, err)
+	delivered, err := events.New("order-service", "", &events.OrderStatusChanged{OrderID: 1, CustomerID: 7, PreviousStatus: "shipped", Status: "delivered"})
+	require.NoError(t, err)
+	publish(t, eventBroker, events.TopicOrders, shipped)
+	publish(t, eventBroker, events.TopicOrders, shipped)
+	publish(t, eventBroker, events.TopicOrders, delivered)
+
+	notifications := notificationsOf(t, repo, 7, 2)
+	assert.Equal(t, "shipped", notifications[0].Status)
+	assert.Equal(t, "delivered", notifications[1].Status)
+
+	assert.Empty(t, eventBroker.DeadLetters(consumer.SubscriptionName), "duplicates are acked, not retried")
+}
+
+func TestEventConsumer_AcksUnknownEvents(t *testing.T) {
+	eventBroker, repo := startConsumer(t)
+
+	unknown := events.Envelope{ID: "evt-1", Type: "order.archived", Version: 1, Payload: []byte(`{}`)}
+	publish(t, eventBroker, events.TopicOrders, unknown)
+	created, err := events.New("order-service", "", &events.OrderCreated{OrderID: 1, CustomerID: 7, Status: "pending"})
+	require.NoError(t, err)
+	publish(t, eventBroker, events.TopicOrders, created)
+
+	notifications := notificationsOf(t, repo, 7, 1)
+	assert.Equal(t, created.ID, notifications[0].EventID)
+	assert.Empty(t, eventBroker.DeadLetters(consumer.SubscriptionName))
+}
@@ -0,0 +1,95 @@
+package unit
+
+import (
+	"testing"
+
+	"go-microservices/notification-service/model"
+	"go-microservices/notification-service/repository"
+	"go-microservices/notification-service/service"
+	"go-microservices/pkg/events"
+	"go-microservices/pkg/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessOrderStatusUpdate(t *testing.T) {
+	repo := repository.NewMemoryNotificationRepository()
+	notificationService := service.NewNotificationService(repo)
+
+	notification, err := notificationService.ProcessOrderStatusUpdate(model.OrderStatusUpdate{OrderID: 7, CustomerID: 3, Status: "shipped"})
+	require.NoError(t, err)
+	assert.Equal(t, "Your order #7 status has changed to: shipped", notification.Message)
+	assert.Equal(t, "shipped", notification.Status)
+	assert.False(t, notification.CreatedAt.IsZero())
+
+	stored, err := notificationService.GetCustomerNotifications(3)
+	require.NoError(t, err)
+	require.Len(t, stored, 1)
+	assert.Equal(t, notification.ID, stored[0].ID)
+}
+
+func TestProcessOrderStatusUpdate_MissingStatus(t *testing.T) {
+	notificationService := service.NewNotificationService(repository.NewMemoryNotificationRepository())
+
+	_, err := notificationService.ProcessOrderStatusUpdate(model.OrderStatusUpdate{OrderID: 7, CustomerID: 3})
+	assert.ErrorIs(t, err, service.ErrInvalidNotification)
+}
+
+func TestMarkDelivered(t *testing.T) {
+	notificationService := service.NewNotificationService(repository.NewMemoryNotificationRepository())
+
+	_, err := notificationService.MarkDelivered(1)
+	assert.ErrorIs(t, err, service.ErrNotificationNotFound)
+
+	notification := model.Notification{OrderID: 1, CustomerID: 1, Message: "Your order #1 has been created"}
+	require.NoError(t, notificationService.CreateNotification(&notification))
+	deliveredAt, err := notificationService.MarkDelivered(notification.ID)
+	require.NoError(t, err)
+
+	stored, err := notificationService.GetNotification(notification.ID)
+	require.NoError(t, err)
+	assert.True(t, stored.DeliveredAt.Equal(deliveredAt))
+}
+
+func TestNotifyEvent(t *testing.T) {
+	notificationService := service.NewNotificationService(repository.NewMemoryNotificationRepository())
+
+	tests := []struct {
+		event   events.Event
+		message string
+		status  string
+	}{
+		{&events.OrderCreated{OrderID: 7, CustomerID: 3, Status: "pending"}, "Your order #7 has been placed", "pending"},
+		{&events.OrderStatusChanged{OrderID: 7, CustomerID: 3, PreviousStatus: "pending", Status: "shipped"}, "Your order #7 status has changed to: shipped", "shipped"},
+		{&events.PaymentSucceeded{PaymentOutcome: events.PaymentOutcome{OrderID: 7, CustomerID: 3, Amount: money.MustNew(3998, "USD")}}, "We received your payment of 39.98 USD for order #7", service.StatusPaymentSucceeded},
+		{&events.PaymentFailed{PaymentOutcome: events.PaymentOutcome{OrderID: 7, CustomerID: 3, Reason: "card declined"}}, "Your payment for order #7 failed: card declined", service.StatusPaymentFailed},
+	}
+	for _, tt := range tests {
+		envelope, err := events.New("test", "", tt.event)
+		require.NoError(t, err)
+
+		notification, err := notificationService.NotifyEvent(envelope, tt.event)
+		require.NoError(t, err, tt.event.EventType())
+		assert.Equal(t, tt.message, notification.Message)
+		assert.Equal(t, tt.status, notification.Status)
+		assert.Equal(t, 3, notification.CustomerID)
+		assert.Equal(t, envelope.ID, notification.EventID)
+	}
+}
+
+func TestNotifyEvent_OncePerEvent(t *testing.T) {
+	notificationService := service.NewNotificationService(repository.NewMemoryNotificationRepository())
+	event := &events.OrderStatusChanged{OrderID: 7, CustomerID: 3, Status: "shipped"}
+	envelope, err := events.New("test", "", event)
+	require.NoError(t, err)
+
+	_, err = notificationService.NotifyEvent(envelope, event)
+	require.NoError(t, err)
+	_, err = notificationService.NotifyEvent(envelope, event)
+	assert.ErrorIs(t, err, service.ErrAlreadyNotified)
+
+	stored, err := notificationService.GetCustomerNotifications(3)
+	require.NoError(t, err)
+	assert.Len(t, stored, 1)
+}
@@ -0,0 +1,26 @@
+package unit
+
+import (
+	"testing"
+
+	"go-microservices/notification-service/api"
+	"go-microservices/notification-service/controller"
+	"go-microservices/notification-service/routes"
+	"go-microservices/pkg/apidocs"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRoutesAreDocumented fails when a route is registered without being
+// declared in api/openapi.json
+func TestRoutesAreDocumented(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	routes.SetupRoutes(router, controller.NewNotificationController(nil))
+
+	missing, err := apidocs.MissingRoutes(router.Routes(), api.Spec)
+	require.NoError(t, err)
+	assert.Empty(t, missing, "routes missing from api/openapi.json")
+}
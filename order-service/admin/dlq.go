@@ -0,0 +1,82 @@
+// Package admin implements the administrative subcommands of order-service
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"go-microservices/order-service/consumer"
+	"go-microservices/pkg/queue"
+)
+
+// DeadLetterAdmin is implemented by queue.Connection and queue.MemoryQueue
+type DeadLetterAdmin interface {
+	DeadLetters(queueName string, limit int) ([]queue.DeadLetter, error)
+	ReplayDeadLetters(queueName string, limit int) (int, error)
+	PurgeDeadLetters(queueName string) (int, error)
+}
+
+const dlqUsage = `usage: order-service dlq <command> [-queue name] [-limit n]
+
+The queue defaults to order-service's own queue.
+
+commands:
+  inspect  print dead-lettered messages as JSON lines without removing them
+  replay   republish dead-lettered messages to their original exchange
+  purge    delete every dead-lettered message`
+
+// RunDLQ runs the dlq subcommand, which inspects, replays or purges the
+// messages parked in a queue's dead-letter queue
+func RunDLQ(args []string, admin DeadLetterAdmin, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(dlqUsage)
+	}
+
+	flags := flag.NewFlagSet("dlq "+args[0], flag.ContinueOnError)
+	queueName := flags.String("queue", consumer.SubscriptionName, "queue whose dead letters to manage")
+	limit := flags.Int("limit", 20, "maximum number of messages to inspect or replay")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "inspect":
+		letters, err := admin.DeadLetters(*queueName, *limit)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(out)
+		for _, letter := range letters {
+			var body interface{} = string(letter.Body)
+			if json.Valid(letter.Body) {
+				body = json.RawMessage(letter.Body)
+			}
+			if err := encoder.Encode(struct {
+				queue.DeadLetter
+				Body interface{} `json:"body"`
+			}{letter, body}); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(out, "%d dead-lettered messages shown from %s\n", len(letters), queue.DeadLetterQueueName(*queueName))
+	case "replay":
+		replayed, err := admin.ReplayDeadLetters(*queueName, *limit)
+		if err != nil {
+			return fmt.Errorf("replayed %d messages: %w", replayed, err)
+		}
+		fmt.Fprintf(out, "Replayed %d messages from %s\n", replayed, queue.DeadLetterQueueName(*queueName))
+	case "purge":
+		purged, err := admin.PurgeDeadLetters(*queueName)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Purged %d messages from %s\n", purged, queue.DeadLetterQueueName(*queueName))
+	default:
+		return fmt.Errorf("unknown dlq command %q\n%s", args[0], dlqUsage)
+	}
+
+	return nil
+}
@@ -0,0 +1,450 @@
+// Code generated by apigen from openapi.json. DO NOT EDIT.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	_ = bytes.NewReader
+	_ = strings.NewReader
+	_ = time.Now
+	_ = url.PathEscape
+)
+
+// Order represents an order for a quantity of one product
+type Order struct {
+	// Identifier assigned by the service
+	ID int `json:"id,omitempty"`
+	// The customer who placed the order
+	CustomerID int `json:"customer_id"`
+	// The product being ordered or stocked
+	ProductID int `json:"product_id"`
+	// Number of units ordered
+	Quantity int `json:"quantity"`
+	// Total to pay in major currency units, the total of the price breakdown. Set by the service, totals given in requests are ignored
+	TotalPrice float64 `json:"total_price,omitempty"`
+	// ISO 4217 currency code of the total price, one of the supported currencies; the product price currency when omitted
+	Currency string `json:"currency,omitempty"`
+	// Code of a coupon to apply, case-insensitive
+	CouponCode string `json:"coupon_code,omitempty"`
+	// ISO 3166 country or subdivision code of the region the order is taxed in
+	Region         string          `json:"region,omitempty"`
+	PriceBreakdown *PriceBreakdown `json:"price_breakdown,omitempty"`
+	ExchangeRate   *ExchangeRate   `json:"exchange_rate,omitempty"`
+	Status         OrderStatus     `json:"status,omitempty"`
+	// When the record was created
+	CreatedAt time.Time `json:"created_at,omitempty"`
+}
+
+// ExchangeRate represents the exchange rate the total price of an order was converted at from the currency of its product, recorded when the order was priced
+type ExchangeRate struct {
+	// Quantity times the unit price in major units of the product currency
+	BaseTotalPrice float64 `json:"base_total_price,omitempty"`
+	// ISO 4217 currency code of the product price
+	BaseCurrency string `json:"base_currency,omitempty"`
+	// Units of the order currency per unit of the product currency
+	Rate float64 `json:"rate,omitempty"`
+	// When the rate was published
+	AsOf time.Time `json:"as_of,omitempty"`
+}
+
+// PriceBreakdown represents how the total price of an order adds up in its currency: the subtotal less the discount of its coupon, plus shipping and tax
+type PriceBreakdown struct {
+	// Quantity times the unit price, converted into the order currency
+	Subtotal float64 `json:"subtotal,omitempty"`
+	// Amount taken off by the coupon, at most the subtotal
+	Discount float64 `json:"discount,omitempty"`
+	Shipping float64 `json:"shipping,omitempty"`
+	// Tax rate of the region times the discounted subtotal, and shipping where it is taxed
+	Tax   float64 `json:"tax,omitempty"`
+	Total float64 `json:"total,omitempty"`
+	// ISO 4217 currency code of the amounts
+	Currency string `json:"currency,omitempty"`
+	// Percentage of tax charged in the region of the order
+	TaxRate float64 `json:"tax_rate,omitempty"`
+}
+
+// OrderStatus represents the lifecycle state of an order
+type OrderStatus string
+
+const (
+	OrderStatusPending    OrderStatus = "pending"
+	OrderStatusProcessing OrderStatus = "processing"
+	OrderStatusShipped    OrderStatus = "shipped"
+	OrderStatusDelivered  OrderStatus = "delivered"
+	OrderStatusCompleted  OrderStatus = "completed"
+	OrderStatusCancelled  OrderStatus = "cancelled"
+)
+
+// OrderWithPaymentRequest represents an order to create together with a payment intent in the given currency
+type OrderWithPaymentRequest struct {
+	// The customer who placed the order
+	CustomerID int `json:"customer_id"`
+	// The product being ordered or stocked
+	ProductID int `json:"product_id"`
+	Quantity  int `json:"quantity"`
+	// ISO 4217 currency code to pay in, one of the supported currencies
+	Currency string `json:"currency"`
+	// Code of a coupon to apply, case-insensitive
+	CouponCode string `json:"coupon_code,omitempty"`
+	// ISO 3166 country or subdivision code of the region the order is taxed in
+	Region string `json:"region,omitempty"`
+}
+
+// OrderWithPayment represents an order with the payment created for it, or why payment creation failed
+type OrderWithPayment struct {
+	Order Order `json:"order"`
+	// The payment-service PaymentResponse
+	Payment      map[string]interface{} `json:"payment,omitempty"`
+	PaymentError string                 `json:"payment_error,omitempty"`
+}
+
+// BatchResult represents the outcome of a batch of orders
+type BatchResult struct {
+	TotalOrders  int            `json:"total_orders"`
+	Successful   int            `json:"successful"`
+	Failed       int            `json:"failed"`
+	FailedOrders []BatchFailure `json:"failed_orders"`
+	// Batch timeout in nanoseconds
+	ProcessingTime int64 `json:"processing_time,omitempty"`
+}
+
+// BatchFailure represents an order of a batch that failed
+type BatchFailure struct {
+	// The order this record belongs to
+	OrderID int    `json:"order_id"`
+	Error   string `json:"error"`
+}
+
+// StatusUpdate represents a requested status change
+type StatusUpdate struct {
+	Status OrderStatus `json:"status"`
+}
+
+// StatusUpdateResult represents confirmation of a status change
+type StatusUpdateResult struct {
+	Message string `json:"message"`
+	// The order this record belongs to
+	OrderID int         `json:"order_id"`
+	Status  OrderStatus `json:"status"`
+}
+
+// Coupon represents a code taking a percentage or a fixed amount off the subtotal of orders, within a validity window and a number of uses
+type Coupon struct {
+	// 1 to 64 letters, digits, - or _, stored upper case
+	Code string `json:"code"`
+	Type string `json:"type"`
+	// Percentage taken off by percentage coupons, more than 0 and at most 100 with at most two decimals
+	PercentOff float64 `json:"percent_off,omitempty"`
+	// Amount taken off by fixed coupons in major currency units, converted into the order currency
+	AmountOff float64 `json:"amount_off,omitempty"`
+	// ISO 4217 currency code of amount_off
+	Currency string `json:"currency,omitempty"`
+	// When the coupon can first be applied, included
+	ValidFrom *time.Time `json:"valid_from,omitempty"`
+	// When the coupon expires, excluded
+	ValidUntil *time.Time `json:"valid_until,omitempty"`
+	// Number of orders the coupon can be applied to, any if 0 or omitted
+	MaxUses int `json:"max_uses,omitempty"`
+	// Number of orders the coupon was applied to
+	Uses int `json:"uses,omitempty"`
+	// When the record was created
+	CreatedAt time.Time `json:"created_at,omitempty"`
+}
+
+// Message represents a plain confirmation message
+type Message struct {
+	Message string `json:"message"`
+}
+
+// Health represents the health of the service
+type Health struct {
+	Status  string    `json:"status"`
+	Service string    `json:"service,omitempty"`
+	Time    time.Time `json:"time,omitempty"`
+}
+
+// Readiness represents the state of every dependency of the service
+type Readiness struct {
+	Status string `json:"status"`
+	// The state of each dependency, "ok" or the reason it is unavailable
+	Checks map[string]string `json:"checks"`
+}
+
+// Error represents the body of every error response
+type Error struct {
+	Error string `json:"error"`
+}
+
+// APIError is returned by Client when the server answers with a non-2xx status
+type APIError struct {
+	StatusCode int
+	Message    string
+	Body       []byte
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("Order Service API returned status %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("Order Service API returned status %d", e.StatusCode)
+}
+
+// Client calls the Order Service API over HTTP
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// NewClient creates a client for the service at baseURL. A nil httpClient
+// uses a client with a ten second timeout.
+func NewClient(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/"), HTTPClient: httpClient}
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	target := c.BaseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		apiErr := &APIError{StatusCode: resp.StatusCode, Body: data}
+		var payload struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &payload) == nil {
+			apiErr.Message = payload.Error
+		}
+		return apiErr
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// GetCoupons lists all coupons, newest first
+func (c *Client) GetCoupons(ctx context.Context) ([]Coupon, error) {
+	var out []Coupon
+	if err := c.do(ctx, "GET", "/coupons", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateCoupon creates a coupon
+func (c *Client) CreateCoupon(ctx context.Context, body Coupon) (*Coupon, error) {
+	var out Coupon
+	if err := c.do(ctx, "POST", "/coupons", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetCoupon returns a coupon by code
+func (c *Client) GetCoupon(ctx context.Context, code string) (*Coupon, error) {
+	var out Coupon
+	if err := c.do(ctx, "GET", fmt.Sprintf("/coupons/%s", url.PathEscape(fmt.Sprint(code))), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// HealthCheck reports the health of the order service
+func (c *Client) HealthCheck(ctx context.Context) (*Health, error) {
+	var out Health
+	if err := c.do(ctx, "GET", "/health", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetOrders lists all orders
+func (c *Client) GetOrders(ctx context.Context) ([]Order, error) {
+	var out []Order
+	if err := c.do(ctx, "GET", "/orders", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateOrder creates an order after checking inventory
+func (c *Client) CreateOrder(ctx context.Context, body Order) (*Order, error) {
+	var out Order
+	if err := c.do(ctx, "POST", "/orders", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateBatchOrders processes several orders in parallel
+func (c *Client) CreateBatchOrders(ctx context.Context, body []Order) (*BatchResult, error) {
+	var out BatchResult
+	if err := c.do(ctx, "POST", "/orders/batch", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateOrderWithPayment creates an order together with a payment intent
+func (c *Client) CreateOrderWithPayment(ctx context.Context, body OrderWithPaymentRequest) (*OrderWithPayment, error) {
+	var out OrderWithPayment
+	if err := c.do(ctx, "POST", "/orders/with-payment", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetOrder returns an order by ID
+func (c *Client) GetOrder(ctx context.Context, id int) (*Order, error) {
+	var out Order
+	if err := c.do(ctx, "GET", fmt.Sprintf("/orders/%s", url.PathEscape(fmt.Sprint(id))), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateOrder replaces an order
+func (c *Client) UpdateOrder(ctx context.Context, id int, body Order) (*Order, error) {
+	var out Order
+	if err := c.do(ctx, "PUT", fmt.Sprintf("/orders/%s", url.PathEscape(fmt.Sprint(id))), nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteOrder deletes an order
+func (c *Client) DeleteOrder(ctx context.Context, id int) (*Message, error) {
+	var out Message
+	if err := c.do(ctx, "DELETE", fmt.Sprintf("/orders/%s", url.PathEscape(fmt.Sprint(id))), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateOrderStatus changes the status of an order
+func (c *Client) UpdateOrderStatus(ctx context.Context, id int, body StatusUpdate) (*StatusUpdateResult, error) {
+	var out StatusUpdateResult
+	if err := c.do(ctx, "PATCH", fmt.Sprintf("/orders/%s/status", url.PathEscape(fmt.Sprint(id))), nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ReadinessCheck reports whether the order service can serve traffic
+func (c *Client) ReadinessCheck(ctx context.Context) (*Readiness, error) {
+	var out Readiness
+	if err := c.do(ctx, "GET", "/ready", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ServerInterface is implemented by the handlers serving the Order Service API
+type ServerInterface interface {
+	// GetCoupons handles GET /coupons
+	GetCoupons(c *gin.Context)
+	// CreateCoupon handles POST /coupons
+	CreateCoupon(c *gin.Context)
+	// GetCoupon handles GET /coupons/{code}
+	GetCoupon(c *gin.Context)
+	// HealthCheck handles GET /health
+	HealthCheck(c *gin.Context)
+	// GetOrders handles GET /orders
+	GetOrders(c *gin.Context)
+	// CreateOrder handles POST /orders
+	CreateOrder(c *gin.Context)
+	// CreateBatchOrders handles POST /orders/batch
+	CreateBatchOrders(c *gin.Context)
+	// CreateOrderWithPayment handles POST /orders/with-payment
+	CreateOrderWithPayment(c *gin.Context)
+	// GetOrder handles GET /orders/{id}
+	GetOrder(c *gin.Context)
+	// UpdateOrder handles PUT /orders/{id}
+	UpdateOrder(c *gin.Context)
+	// DeleteOrder handles DELETE /orders/{id}
+	DeleteOrder(c *gin.Context)
+	// UpdateOrderStatus handles PATCH /orders/{id}/status
+	UpdateOrderStatus(c *gin.Context)
+	// ReadinessCheck handles GET /ready
+	ReadinessCheck(c *gin.Context)
+}
+
+// RegisterHandlers registers every operation of the spec on router
+func RegisterHandlers(router gin.IRoutes, si ServerInterface) {
+	router.GET("/coupons", si.GetCoupons)
+	router.POST("/coupons", si.CreateCoupon)
+	router.GET("/coupons/:code", si.GetCoupon)
+	router.GET("/health", si.HealthCheck)
+	router.GET("/orders", si.GetOrders)
+	router.POST("/orders", si.CreateOrder)
+	router.POST("/orders/batch", si.CreateBatchOrders)
+	router.POST("/orders/with-payment", si.CreateOrderWithPayment)
+	router.GET("/orders/:id", si.GetOrder)
+	router.PUT("/orders/:id", si.UpdateOrder)
+	router.DELETE("/orders/:id", si.DeleteOrder)
+	router.PATCH("/orders/:id/status", si.UpdateOrderStatus)
+	router.GET("/ready", si.ReadinessCheck)
+}
+
+// Routes lists the method and gin path of every operation in the spec
+var Routes = []struct{ Method, Path, OperationID string }{
+	{"GET", "/coupons", "GetCoupons"},
+	{"POST", "/coupons", "CreateCoupon"},
+	{"GET", "/coupons/:code", "GetCoupon"},
+	{"GET", "/health", "HealthCheck"},
+	{"GET", "/orders", "GetOrders"},
+	{"POST", "/orders", "CreateOrder"},
+	{"POST", "/orders/batch", "CreateBatchOrders"},
+	{"POST", "/orders/with-payment", "CreateOrderWithPayment"},
+	{"GET", "/orders/:id", "GetOrder"},
+	{"PUT", "/orders/:id", "UpdateOrder"},
+	{"DELETE", "/orders/:id", "DeleteOrder"},
+	{"PATCH", "/orders/:id/status", "UpdateOrderStatus"},
+	{"GET", "/ready", "ReadinessCheck"},
+}
@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// instanceID tells this process's invalidations from other replicas'
+	instanceID = newToken()
+	// invalidations is the subscription to the invalidation channel
+	invalidations *redis.PubSub
+)
+
+// invalidation tells the other replicas that a key was written or deleted,
+// so they drop it from their local tier
+type invalidation struct {
+	Origin string `json:"origin"`
+	Key    string `json:"key"`
+}
+
+// InvalidationChannel returns the Redis channel invalidations are
+// published on
+func InvalidationChannel() string {
+	return Key("invalidations")
+}
+
+// publishInvalidation tells the other replicas that a key changed. Replicas
+// that miss it, while disconnected from Redis, drop the key when their
+// local TTL expires.
+func publishInvalidation(key string) {
+	if redisClient == nil {
+		return
+	}
+
+	data, _ := json.Marshal(invalidation{Origin: instanceID, Key: key})
+	if err := redisClient.Publish(ctx, InvalidationChannel(), data).Err(); err != nil {
+		log.Printf("[Cache] Failed to publish invalidation of %s: %v\n", key, err)
+	}
+}
+
+// subscribeInvalidations drops the keys other replicas invalidate from the
+// local tier. go-redis resubscribes after reconnecting.
+func subscribeInvalidations() {
+	invalidations = redisClient.Subscribe(ctx, InvalidationChannel())
+	go func(messages <-chan *redis.Message) {
+		for msg := range messages {
+			var inv invalidation
+			if err := json.Unmarshal([]byte(msg.Payload), &inv); err != nil {
+				log.Printf("[Cache] Ignoring invalid invalidation %q: %v\n", msg.Payload, err)
+				continue
+			}
+			if inv.Origin == instanceID {
+				continue
+			}
+			invalidationsReceived.Inc()
+			local.Load().delete(inv.Key)
+		}
+	}(invalidations.Channel())
+}
@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// localTier is the optional in-process LRU tier in front of Redis. It holds
+// entries read from or written to Redis for at most Config.LocalTTL, and
+// evicts the least recently used ones beyond Config.LocalMaxEntries entries
+// or Config.LocalMaxBytes bytes. Its methods are no-ops on a nil tier.
+type localTier struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxBytes   int64
+	ttl        time.Duration
+	bytes      int64
+	// recent orders the items from most to least recently used
+	recent *list.List
+	items  map[string]*list.Element
+}
+
+// localItem is an entry held by the local tier
+type localItem struct {
+	key       string
+	entry     *entry
+	size      int64
+	expiresAt time.Time
+}
+
+// newLocalTier creates the local tier of a configuration, nil if disabled
+func newLocalTier(c Config) *localTier {
+	if c.LocalMaxEntries <= 0 || c.LocalTTL <= 0 {
+		return nil
+	}
+	return &localTier{
+		maxEntries: c.LocalMaxEntries,
+		maxBytes:   c.LocalMaxBytes,
+		ttl:        c.LocalTTL,
+		recent:     list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// get returns the entry of a key if it is held and not expired
+func (l *localTier) get(key string) (*entry, bool) {
+	if l == nil {
+		return nil, false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	element, ok := l.items[key]
+	if !ok {
+		lookups.WithLabelValues(tierLocal, resultMiss).Inc()
+		return nil, false
+	}
+	item := element.Value.(*localItem)
+	if time.Now().After(item.expiresAt) {
+		l.remove(element)
+		evictions.WithLabelValues(tierLocal, "expired").Inc()
+		lookups.WithLabelValues(tierLocal, resultMiss).Inc()
+		return nil, false
+	}
+
+	l.recent.MoveToFront(element)
+	lookups.WithLabelValues(tierLocal, resultHit).Inc()
+	return item.entry, true
+}
+
+// set holds an entry until the local TTL or the entry's own expiry, and
+// evicts least recently used entries until the tier is within its limits
+func (l *localTier) set(key string, e *entry) {
+	if l == nil {
+		return
+	}
+
+	expiresAt := time.Now().Add(l.ttl)
+	if !e.Expiry.IsZero() && e.Expiry.Before(expiresAt) {
+		expiresAt = e.Expiry
+	}
+	item := &localItem{key: key, entry: e, size: int64(len(key) + len(e.Value)), expiresAt: expiresAt}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if element, ok := l.items[key]; ok {
+		l.remove(element)
+	}
+	if l.maxBytes > 0 && item.size > l.maxBytes {
+		return
+	}
+	l.items[key] = l.recent.PushFront(item)
+	l.bytes += item.size
+
+	for len(l.items) > l.maxEntries || (l.maxBytes > 0 && l.bytes > l.maxBytes) {
+		l.remove(l.recent.Back())
+		evictions.WithLabelValues(tierLocal, "size").Inc()
+	}
+	l.updateGauges()
+}
+
+// delete drops the entry of a key
+func (l *localTier) delete(key string) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if element, ok := l.items[key]; ok {
+		l.remove(element)
+		l.updateGauges()
+	}
+}
+
+// remove removes an item. The caller holds mu.
+func (l *localTier) remove(element *list.Element) {
+	item := l.recent.Remove(element).(*localItem)
+	delete(l.items, item.key)
+	l.bytes -= item.size
+}
+
+// updateGauges exports the size of the tier. The caller holds mu.
+func (l *localTier) updateGauges() {
+	localEntries.Set(float64(len(l.items)))
+	localBytes.Set(float64(l.bytes))
+}
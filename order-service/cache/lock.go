@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// lockPollInterval is how often a replica waiting for another one to load
+// a key looks the key up
+const lockPollInterval = 50 * time.Millisecond
+
+// unlockScript deletes a lock only if it is still held with the caller's
+// token, so a lock that expired and was taken by another replica is kept
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// lock takes the loading lock of a key for Config.LockTTL. It reports
+// false if another replica holds it. If the lock cannot be taken because
+// Redis fails, the caller loads the key as if it held the lock.
+func lock(key string) (unlock func(), locked bool) {
+	noop := func() {}
+	if redisClient == nil {
+		return noop, true
+	}
+
+	lockKey := Key(key) + ":lock"
+	token := newToken()
+	ok, err := redisClient.SetNX(ctx, lockKey, token, config.LockTTL).Result()
+	if err != nil {
+		log.Printf("[Cache] Failed to lock %s: %v\n", key, err)
+		return noop, true
+	}
+	if !ok {
+		return noop, false
+	}
+
+	return func() {
+		if err := unlockScript.Run(ctx, redisClient, []string{lockKey}, token).Err(); err != nil {
+			log.Printf("[Cache] Failed to unlock %s: %v\n", key, err)
+		}
+	}, true
+}
+
+// waitForEntry waits up to Config.LockTTL for another replica to cache a key
+func waitForEntry(key string) (*entry, bool) {
+	deadline := time.Now().Add(config.LockTTL)
+	for time.Now().Before(deadline) {
+		time.Sleep(lockPollInterval)
+		if e, err := readEntry(key); err == nil {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+// newToken returns a random lock token
+func newToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
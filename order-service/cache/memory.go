@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+// MemoryCache is an in-process cache with the same semantics as the Redis
+// helpers, so the service can run in tests without Redis. Values are stored
+// JSON-encoded, which keeps cached copies independent of the caller's.
+type MemoryCache struct {
+	mu      sync.RWMutex
+	entries map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	data      []byte
+	notFound  bool
+	expiresAt time.Time
+}
+
+// NewMemoryCache creates an empty in-memory cache
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: make(map[string]memoryEntry)}
+}
+
+// Get retrieves a value from cache, or returns ErrCacheMiss, or ErrNotFound
+// for a cached not-found result
+func (m *MemoryCache) Get(key string, value interface{}) error {
+	m.mu.RLock()
+	entry, ok := m.entries[key]
+	m.mu.RUnlock()
+
+	if !ok || (!entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt)) {
+		return ErrCacheMiss
+	}
+	if entry.notFound {
+		return ErrNotFound
+	}
+
+	return json.Unmarshal(entry.data, value)
+}
+
+// Set stores a value in cache with expiration; zero means no expiration
+func (m *MemoryCache) Set(key string, value interface{}, expiration time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	entry := memoryEntry{data: data}
+	if expiration > 0 {
+		entry.expiresAt = time.Now().Add(expiration)
+	}
+
+	m.mu.Lock()
+	m.entries[key] = entry
+	m.mu.Unlock()
+
+	return nil
+}
+
+// Delete removes a key from cache
+func (m *MemoryCache) Delete(key string) error {
+	m.mu.Lock()
+	delete(m.entries, key)
+	m.mu.Unlock()
+
+	return nil
+}
+
+// GetOrSet retrieves value from cache or sets it if not exists. Like the
+// Redis GetOrSet it caches ErrNotFound from fn for the configured
+// NegativeTTL, but it does not coalesce loads.
+func (m *MemoryCache) GetOrSet(key string, value interface{}, expiration time.Duration, fn func() (interface{}, error)) error {
+	if err := m.Get(key, value); err != ErrCacheMiss {
+		return err
+	}
+
+	result, err := fn()
+	if errors.Is(err, ErrNotFound) {
+		m.mu.Lock()
+		m.entries[key] = memoryEntry{notFound: true, expiresAt: time.Now().Add(config.NegativeTTL)}
+		m.mu.Unlock()
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := m.Set(key, result, expiration); err != nil {
+		return err
+	}
+
+	return m.Get(key, value)
+}
+
+// Flush clears all keys
+func (m *MemoryCache) Flush() error {
+	m.mu.Lock()
+	m.entries = make(map[string]memoryEntry)
+	m.mu.Unlock()
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Cache tiers and lookup results, as metric labels
+const (
+	tierLocal = "local"
+	tierRedis = "redis"
+
+	resultHit   = "hit"
+	resultMiss  = "miss"
+	resultError = "error"
+)
+
+var (
+	lookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_lookups_total",
+		Help: "The total number of cache lookups by tier and result (hit, miss or error)",
+	}, []string{"tier", "result"})
+
+	writeErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_write_errors_total",
+		Help: "The total number of failed cache writes by tier and operation (set or delete)",
+	}, []string{"tier", "operation"})
+
+	loaded = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_loads_total",
+		Help: "The total number of values loaded by GetOrSet after coalescing, by outcome (loaded, not_found, error, or waited for another replica)",
+	}, []string{"outcome"})
+
+	earlyRefreshes = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "cache_early_refreshes_total",
+		Help: "The total number of cached values refreshed before they expired",
+	})
+
+	evictions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_evictions_total",
+		Help: "The total number of entries evicted from a tier by reason (size or expired)",
+	}, []string{"tier", "reason"})
+
+	invalidationsReceived = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "cache_invalidations_received_total",
+		Help: "The total number of invalidations received from other replicas",
+	})
+
+	localEntries = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "cache_local_entries",
+		Help: "The number of entries held by the in-process tier",
+	})
+
+	localBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "cache_local_bytes",
+		Help: "The size in bytes of the keys and values held by the in-process tier",
+	})
+)
@@ -1,33 +1,135 @@
+// Package cache is the cache-aside layer of order-service, on Redis.
+//
+// Keys are namespaced and versioned: the key "order:42" is stored as
+// "<CACHE_NAMESPACE>:<CACHE_VERSION>:order:42", so services can share a
+// Redis and bumping the version drops every entry written in an older
+// shape. While Redis is not initialized or unreachable the cache degrades:
+// lookups miss and GetOrSet loads from the source of truth.
+//
+// GetOrSet protects the source of truth from stampedes: concurrent loads
+// of a key are coalesced within the process, optionally across replicas
+// with a Redis lock, entries are refreshed early with a probability that
+// grows as they near expiry, and not-found results are cached briefly.
+//
+// An optional in-process LRU tier answers repeated lookups without a Redis
+// round trip. Writes and deletes are published on a Redis channel, on
+// which every replica drops the key from its own local tier.
 package cache
 
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"math"
+	"math/rand"
 	"os"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
 )
 
 var (
 	redisClient *redis.Client
 	ctx         = context.Background()
+	config      = ConfigFromEnv()
+	loads       singleflight.Group
+	// local is the in-process tier, nil if disabled
+	local atomic.Pointer[localTier]
 )
 
-// InitRedis initializes Redis connection
-func InitRedis() error {
-	redisHost := os.Getenv("REDIS_HOST")
-	if redisHost == "" {
-		redisHost = "redis" // Docker default
+var (
+	// ErrCacheMiss is returned by Get when the key is not cached
+	ErrCacheMiss = errors.New("cache miss")
+	// ErrUnavailable is returned when Redis was not initialized
+	ErrUnavailable = errors.New("cache unavailable")
+	// ErrNotFound is returned by a GetOrSet loader when the value does not
+	// exist. The result is cached for Config.NegativeTTL, during which Get
+	// and GetOrSet return ErrNotFound without loading again.
+	ErrNotFound = errors.New("not found")
+)
+
+// Config configures key naming, expiry and stampede protection
+type Config struct {
+	// Namespace prefixes every key, separating services sharing a Redis
+	Namespace string
+	// Version is part of every key; bump it when a cached type changes
+	// shape to drop the entries written in the old one
+	Version string
+	// OrderTTL is how long an order stays cached
+	OrderTTL time.Duration
+	// NegativeTTL is how long a not-found result stays cached
+	NegativeTTL time.Duration
+	// EarlyRefreshBeta scales how early entries are refreshed before they
+	// expire, 0 disables early refresh
+	EarlyRefreshBeta float64
+	// Lock makes replicas wait for the one loading a key, through a Redis
+	// lock held at most LockTTL, instead of loading it too
+	Lock    bool
+	LockTTL time.Duration
+	// LocalMaxEntries enables the in-process tier, holding up to that many
+	// entries and LocalMaxBytes bytes, if set, each for at most LocalTTL
+	LocalMaxEntries int
+	LocalMaxBytes   int64
+	LocalTTL        time.Duration
+	// ProductPriceTTL is how long a product price stays cached
+	ProductPriceTTL time.Duration
+}
+
+// ConfigFromEnv reads CACHE_NAMESPACE (default "order-service"),
+// CACHE_VERSION (default "v2"), CACHE_ORDER_TTL (default 30m),
+// CACHE_NEGATIVE_TTL (default 30s), CACHE_EARLY_REFRESH_BETA (default 1),
+// CACHE_LOCK (default false), CACHE_LOCK_TTL (default 5s),
+// CACHE_LOCAL_MAX_ENTRIES (default 0, disabled), CACHE_LOCAL_MAX_BYTES
+// (default 64 MiB), CACHE_LOCAL_TTL (default 1m) and
+// CACHE_PRODUCT_PRICE_TTL (default 5m)
+func ConfigFromEnv() Config {
+	return Config{
+		Namespace:        getEnv("CACHE_NAMESPACE", "order-service"),
+		Version:          getEnv("CACHE_VERSION", "v2"),
+		OrderTTL:         getDurationEnv("CACHE_ORDER_TTL", 30*time.Minute),
+		NegativeTTL:      getDurationEnv("CACHE_NEGATIVE_TTL", 30*time.Second),
+		EarlyRefreshBeta: getFloatEnv("CACHE_EARLY_REFRESH_BETA", 1),
+		Lock:             getEnv("CACHE_LOCK", "false") == "true",
+		LockTTL:          getDurationEnv("CACHE_LOCK_TTL", 5*time.Second),
+		LocalMaxEntries:  int(getIntEnv("CACHE_LOCAL_MAX_ENTRIES", 0)),
+		LocalMaxBytes:    getIntEnv("CACHE_LOCAL_MAX_BYTES", 64<<20),
+		LocalTTL:         getDurationEnv("CACHE_LOCAL_TTL", time.Minute),
+		ProductPriceTTL:  getDurationEnv("CACHE_PRODUCT_PRICE_TTL", 5*time.Minute),
 	}
+}
+
+// Configure replaces the configuration read from the environment, emptying
+// the local tier
+func Configure(c Config) {
+	config = c
+	local.Store(newLocalTier(c))
+}
 
+// InitRedis initializes Redis connection and reloads the configuration.
+// The client is kept if Redis cannot be reached, it reconnects on its own.
+func InitRedis() error {
+	redisHost := getEnv("REDIS_HOST", "redis") // Docker default
+	redisPort := getEnv("REDIS_PORT", "6379")
+
+	Configure(ConfigFromEnv())
 	redisClient = redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:6379", redisHost),
+		Addr:     fmt.Sprintf("%s:%s", redisHost, redisPort),
 		Password: "", // no password set
 		DB:       0,  // use default DB
+		// Fail fast so an unreachable Redis slows requests down as little
+		// as possible
+		DialTimeout:  2 * time.Second,
+		ReadTimeout:  time.Second,
+		WriteTimeout: time.Second,
 	})
 
+	subscribeInvalidations()
+
 	// Test connection
 	_, err := redisClient.Ping(ctx).Result()
 	if err != nil {
@@ -37,65 +139,255 @@ func InitRedis() error {
 	return nil
 }
 
-// Get retrieves a value from cache
-func Get(key string, value interface{}) error {
-	data, err := redisClient.Get(ctx, key).Result()
+// OrderTTL returns how long an order stays cached
+func OrderTTL() time.Duration {
+	return config.OrderTTL
+}
+
+// ProductPriceTTL returns how long a product price stays cached
+func ProductPriceTTL() time.Duration {
+	return config.ProductPriceTTL
+}
+
+// Key returns the namespaced, versioned Redis key of key
+func Key(key string) string {
+	return config.Namespace + ":" + config.Version + ":" + key
+}
+
+// entry is the stored form of a cached value
+type entry struct {
+	Value    json.RawMessage `json:"value,omitempty"`
+	NotFound bool            `json:"not_found,omitempty"`
+	// Delta is how long loading the value took
+	Delta time.Duration `json:"delta,omitempty"`
+	// Expiry is when the entry expires, zero if it does not
+	Expiry time.Time `json:"expiry,omitempty"`
+}
+
+// decode decodes the cached value into value
+func (e *entry) decode(value interface{}) error {
+	if e.NotFound {
+		return ErrNotFound
+	}
+	return json.Unmarshal(e.Value, value)
+}
+
+// refreshEarly decides whether this lookup refreshes the entry before it
+// expires. The chance grows as the entry nears expiry and with the time it
+// took to load (XFetch), so usually a single caller refreshes a hot key.
+func (e *entry) refreshEarly() bool {
+	if config.EarlyRefreshBeta <= 0 || e.Delta <= 0 || e.NotFound || e.Expiry.IsZero() {
+		return false
+	}
+	gap := time.Duration(float64(e.Delta) * config.EarlyRefreshBeta * -math.Log(1-rand.Float64()))
+	return !time.Now().Add(gap).Before(e.Expiry)
+}
+
+// readEntry reads the entry of a key. An entry that cannot be decoded is
+// deleted and reported as a miss.
+func readEntry(key string) (*entry, error) {
+	if redisClient == nil {
+		return nil, ErrUnavailable
+	}
+
+	data, err := redisClient.Get(ctx, Key(key)).Bytes()
 	if err == redis.Nil {
-		return fmt.Errorf("key does not exist")
+		return nil, ErrCacheMiss
 	} else if err != nil {
+		return nil, err
+	}
+
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		log.Printf("[Cache] Dropping undecodable entry %s: %v\n", key, err)
+		redisClient.Del(ctx, Key(key))
+		return nil, ErrCacheMiss
+	}
+	return &e, nil
+}
+
+// lookup reads the entry of a key and counts the lookup
+func lookup(key string) (*entry, error) {
+	e, err := readEntry(key)
+	switch err {
+	case nil:
+		lookups.WithLabelValues(tierRedis, resultHit).Inc()
+	case ErrCacheMiss:
+		lookups.WithLabelValues(tierRedis, resultMiss).Inc()
+	default:
+		lookups.WithLabelValues(tierRedis, resultError).Inc()
+	}
+	return e, err
+}
+
+// writeEntry stores an entry with expiration, zero meaning none
+func writeEntry(key string, e *entry, expiration time.Duration) error {
+	if redisClient == nil {
+		return ErrUnavailable
+	}
+
+	if expiration > 0 {
+		e.Expiry = time.Now().Add(expiration)
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
 		return err
 	}
 
-	return json.Unmarshal([]byte(data), value)
+	if err := redisClient.Set(ctx, Key(key), data, expiration).Err(); err != nil {
+		writeErrors.WithLabelValues(tierRedis, "set").Inc()
+		return err
+	}
+	local.Load().set(key, e)
+	publishInvalidation(key)
+	return nil
+}
+
+// Get retrieves a value from cache. It returns ErrCacheMiss if the key is
+// not cached, ErrNotFound if a not-found result is, and ErrUnavailable if
+// Redis was not initialized.
+func Get(key string, value interface{}) error {
+	if e, ok := local.Load().get(key); ok {
+		return e.decode(value)
+	}
+
+	e, err := lookup(key)
+	if err != nil {
+		return err
+	}
+	local.Load().set(key, e)
+	return e.decode(value)
 }
 
 // Set stores a value in cache with expiration
 func Set(key string, value interface{}, expiration time.Duration) error {
+	if redisClient == nil {
+		return ErrUnavailable
+	}
+
 	data, err := json.Marshal(value)
 	if err != nil {
 		return err
 	}
-
-	return redisClient.Set(ctx, key, data, expiration).Err()
+	return writeEntry(key, &entry{Value: data}, expiration)
 }
 
-// Delete removes a key from cache
+// Delete removes a key from cache. Without Redis there is nothing to remove.
+// Callers missing the key afterwards load it again rather than wait for a
+// load that started before it changed.
 func Delete(key string) error {
-	return redisClient.Del(ctx, key).Err()
+	loads.Forget(key)
+	local.Load().delete(key)
+	if redisClient == nil {
+		return nil
+	}
+
+	if err := redisClient.Del(ctx, Key(key)).Err(); err != nil {
+		writeErrors.WithLabelValues(tierRedis, "delete").Inc()
+		return err
+	}
+	publishInvalidation(key)
+	return nil
 }
 
-// GetOrSet retrieves value from cache or sets it if not exists
+// GetOrSet retrieves value from cache or loads it with fn, caching the
+// result for expiration. fn returns ErrNotFound for values that do not
+// exist. Only fn's errors are returned: cache errors are logged, and a
+// loaded value is only cached after a miss or an early refresh, so a
+// failing Redis is not written to.
 func GetOrSet(key string, value interface{}, expiration time.Duration, fn func() (interface{}, error)) error {
-	// Try to get from cache first
-	err := Get(key, value)
-	if err == nil {
-		return nil
+	// Try to get from cache first, the local tier, then Redis
+	if e, ok := local.Load().get(key); ok {
+		return e.decode(value)
+	}
+	cached, cacheErr := lookup(key)
+	if cacheErr == nil {
+		if !cached.refreshEarly() {
+			local.Load().set(key, cached)
+			return cached.decode(value)
+		}
+		earlyRefreshes.Inc()
+	} else if cacheErr != ErrCacheMiss && cacheErr != ErrUnavailable {
+		log.Printf("[Cache] Failed to get %s, loading it: %v\n", key, cacheErr)
 	}
 
-	// If not in cache, call the function
-	result, err := fn()
+	// Load it once however many callers of this process miss it
+	result, err, _ := loads.Do(key, func() (interface{}, error) {
+		return load(key, expiration, fn, cached, cacheErr)
+	})
 	if err != nil {
 		return err
 	}
+	return result.(*entry).decode(value)
+}
 
-	// Store result in cache
-	if err := Set(key, result, expiration); err != nil {
-		return err
+// load loads a value with fn and caches it if the lookup missed or the
+// entry is refreshed early. An early refresh that fails keeps serving the
+// cached entry.
+func load(key string, expiration time.Duration, fn func() (interface{}, error), cached *entry, cacheErr error) (*entry, error) {
+	write := cacheErr == nil || cacheErr == ErrCacheMiss
+	if write && config.Lock {
+		unlock, locked := lock(key)
+		if !locked {
+			// Another replica is loading the key
+			if cached != nil {
+				return cached, nil
+			}
+			if e, ok := waitForEntry(key); ok {
+				loaded.WithLabelValues("waited").Inc()
+				return e, nil
+			}
+		}
+		defer unlock()
 	}
 
-	// Update the value reference
+	start := time.Now()
+	result, err := fn()
+	if errors.Is(err, ErrNotFound) {
+		loaded.WithLabelValues("not_found").Inc()
+		if write {
+			if err := writeEntry(key, &entry{NotFound: true}, config.NegativeTTL); err != nil {
+				log.Printf("[Cache] Failed to set %s: %v\n", key, err)
+			}
+		}
+		return nil, err
+	}
+	if err != nil {
+		loaded.WithLabelValues("error").Inc()
+		if cached != nil {
+			log.Printf("[Cache] Failed to refresh %s, serving the cached value: %v\n", key, err)
+			return cached, nil
+		}
+		return nil, err
+	}
+	loaded.WithLabelValues("loaded").Inc()
+
 	data, err := json.Marshal(result)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	e := &entry{Value: data, Delta: time.Since(start)}
 
-	return json.Unmarshal(data, value)
+	// Store result in cache
+	if write {
+		if err := writeEntry(key, e, expiration); err != nil {
+			log.Printf("[Cache] Failed to set %s: %v\n", key, err)
+		}
+	}
+	return e, nil
 }
 
-
+// Close closes the Redis connection, the cache is unavailable afterwards
 func Close() error {
+	if invalidations != nil {
+		invalidations.Close()
+		invalidations = nil
+	}
+	local.Store(nil)
 	if redisClient != nil {
-		return redisClient.Close()
+		client := redisClient
+		redisClient = nil
+		return client.Close()
 	}
 	return nil
 }
@@ -106,4 +398,43 @@ func Flush() error {
 		return redisClient.FlushDB(ctx).Err()
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// getEnv gets an environment variable or returns a default value
+func getEnv(key, defaultValue string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+// getDurationEnv gets a duration such as "30m" from an environment variable
+// or returns a default value
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+// getIntEnv gets a non-negative integer from an environment variable or
+// returns a default value
+func getIntEnv(key string, defaultValue int64) int64 {
+	value, err := strconv.ParseInt(os.Getenv(key), 10, 64)
+	if err != nil || value < 0 {
+		return defaultValue
+	}
+	return value
+}
+
+// getFloatEnv gets a non-negative number from an environment variable or
+// returns a default value
+func getFloatEnv(key string, defaultValue float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil || value < 0 {
+		return defaultValue
+	}
+	return value
+}
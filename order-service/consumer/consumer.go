@@ -0,0 +1,71 @@
+// Package consumer advances orders on the payment events published on the
+// broker by payment-service.
+package consumer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+
+	"go-microservices/order-service/controller"
+	"go-microservices/pkg/broker"
+	"go-microservices/pkg/events"
+)
+
+// SubscriptionName is the subscription order-service receives events on
+const SubscriptionName = "order-service"
+
+// PaymentEventHandler applies a payment event to its order
+type PaymentEventHandler interface {
+	ApplyPaymentEvent(ctx context.Context, envelope events.Envelope, event events.Event) error
+}
+
+// Subscription receives every payment event. Failed events are retried
+// with the broker's default retry policy before being dead-lettered.
+func Subscription(concurrency int) broker.Subscription {
+	return broker.Subscription{
+		Name: SubscriptionName,
+		Bindings: []broker.Binding{
+			{Topic: events.TopicPayments, Pattern: "payment.#"},
+		},
+		Concurrency: concurrency,
+	}
+}
+
+// Start subscribes to the payment events with concurrency handlers
+func Start(b broker.Broker, orders PaymentEventHandler, concurrency int) (broker.Subscriber, error) {
+	return b.Subscribe(Subscription(concurrency), Handler(orders))
+}
+
+// Handler decodes an event envelope and applies it to its order. Events of
+// unknown types, events that do not advance orders, events about missing
+// orders and payments that do not cover their order are acked without
+// changes. Other failures are returned so the event is retried.
+func Handler(orders PaymentEventHandler) broker.Handler {
+	return func(ctx context.Context, body []byte) error {
+		envelope, event, err := events.Decode(body)
+		if errors.Is(err, events.ErrUnknownType) {
+			log.Printf("Skipping event: %v\n", err)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		err = orders.ApplyPaymentEvent(ctx, envelope, event)
+		switch {
+		case errors.Is(err, controller.ErrUnhandledEvent), errors.Is(err, sql.ErrNoRows):
+			log.Printf("Skipping %s event %s: %v\n", envelope.Type, envelope.ID, err)
+			return nil
+		case errors.Is(err, controller.ErrPaymentMismatch):
+			// Retrying cannot change the amount, the order waits for
+			// someone to look at it
+			log.Printf("Warning: Order left pending after %s event %s: %v\n", envelope.Type, envelope.ID, err)
+			return nil
+		case err != nil:
+			return err
+		}
+		return nil
+	}
+}
@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"go-microservices/order-service/model"
+	"go-microservices/order-service/pricing"
+	"go-microservices/order-service/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateCoupon creates a coupon orders can be placed with
+func (oc *OrderController) CreateCoupon(c *gin.Context) {
+	var coupon model.Coupon
+	if err := c.ShouldBindJSON(&coupon); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := pricing.ValidateCoupon(&coupon); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := oc.Coupons.CreateCoupon(&coupon)
+	if errors.Is(err, repository.ErrDuplicateCoupon) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Coupon " + coupon.Code + " already exists"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create coupon: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, coupon)
+}
+
+// GetCoupons returns all coupons, newest first
+func (oc *OrderController) GetCoupons(c *gin.Context) {
+	coupons, err := oc.Coupons.GetCoupons()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, coupons)
+}
+
+// GetCoupon returns a coupon by code, with the number of orders it was
+// applied to
+func (oc *OrderController) GetCoupon(c *gin.Context) {
+	coupon, err := oc.Coupons.GetCoupon(pricing.NormalizeCouponCode(c.Param("code")))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Coupon not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get coupon: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, coupon)
+}
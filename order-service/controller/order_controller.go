@@ -1,22 +1,31 @@
 package controller
 
 import (
-	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"go-microservices/order-service/cache"
 	"go-microservices/order-service/metrics"
 	"go-microservices/order-service/model"
-	"go-microservices/order-service/queue"
+	"go-microservices/order-service/pricing"
+	"go-microservices/order-service/repository"
 	"go-microservices/order-service/service"
 	"go-microservices/order-service/worker"
+	paymentapi "go-microservices/payment-service/api"
+	"go-microservices/pkg/broker"
+	"go-microservices/pkg/events"
+	"go-microservices/pkg/exchange"
+	"go-microservices/pkg/money"
 
 	"github.com/gin-gonic/gin"
 )
@@ -28,93 +37,69 @@ type InventoryServiceInterface interface {
 
 // NotificationServiceInterface defines the interface for notification service
 type NotificationServiceInterface interface {
-	SendOrderNotification(orderID int) error
+	SendOrderNotification(orderID int, customerID int) error
 	SendOrderStatusUpdate(orderID int, customerID int, status string) error
 }
 
 // PaymentServiceInterface defines the interface for payment service
 type PaymentServiceInterface interface {
-	CreatePayment(orderID int, customerID int, amount float64, currency string) (*service.PaymentResponse, error)
+	CreatePayment(orderID int, customerID int, amount money.Money) (*paymentapi.PaymentResponse, error)
+	RefundOrder(orderID int) ([]paymentapi.Refund, error)
 }
 
-// OrderRepository defines the interface for order database operations
+// ProductServiceInterface defines the interface for product service
+type ProductServiceInterface interface {
+	GetProductPrice(productID int) (money.Money, error)
+}
+
+// OrderRepository defines the interface for order database operations.
+// Lookups, updates and deletes of a missing order return sql.ErrNoRows.
 type OrderRepository interface {
 	InsertOrder(order *model.Order) error
 	GetOrderFromDB(orderID string) (*model.Order, error)
+	GetOrders() ([]model.Order, error)
+	UpdateOrder(order *model.Order) error
+	UpdateOrderStatus(orderID int, status string) error
+	DeleteOrder(orderID int) error
+}
+
+// CouponRepository defines the interface for coupon database operations.
+// Lookups of a missing coupon return sql.ErrNoRows, and so does redeeming a
+// coupon that has been used up.
+type CouponRepository interface {
+	CreateCoupon(coupon *model.Coupon) error
+	GetCoupon(code string) (*model.Coupon, error)
+	GetCoupons() ([]model.Coupon, error)
+	RedeemCoupon(code string) error
+	ReleaseCoupon(code string) error
 }
 
 // Cache defines the interface for cache operations
 type Cache interface {
 	Get(key string, value interface{}) error
 	Set(key string, value interface{}, expiration time.Duration) error
+	Delete(key string) error
 	GetOrSet(key string, value interface{}, expiration time.Duration, fn func() (interface{}, error)) error
 }
 
-// MessageQueue defines the interface for message queue operations
-type MessageQueue interface {
-	PublishMessage(config queue.Config, message interface{}) error
-}
-
 // OrderController handles order-related requests
 type OrderController struct {
 	DB                  *sql.DB
 	OrderRepo           OrderRepository
+	Coupons             CouponRepository
 	Cache               Cache
-	Queue               MessageQueue
+	Broker              broker.Publisher
 	InventoryService    InventoryServiceInterface
 	NotificationService NotificationServiceInterface
 	PaymentService      PaymentServiceInterface
-}
-
-// DBOrderRepository implements OrderRepository interface using SQL database
-type DBOrderRepository struct {
-	DB *sql.DB
-}
-
-// InsertOrder inserts a new order into the database
-func (r *DBOrderRepository) InsertOrder(order *model.Order) error {
-	query := `
-		INSERT INTO orders (customer_id, product_id, quantity, total_price, status, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id`
-
-	order.Status = "pending"
-	order.CreatedAt = time.Now()
-
-	return r.DB.QueryRow(
-		query,
-		order.CustomerID,
-		order.ProductID,
-		order.Quantity,
-		order.TotalPrice,
-		order.Status,
-		order.CreatedAt,
-	).Scan(&order.ID)
-}
-
-// GetOrderFromDB retrieves an order from the database by ID
-func (r *DBOrderRepository) GetOrderFromDB(orderID string) (*model.Order, error) {
-	var order model.Order
-	query := `
-		SELECT id, customer_id, product_id, quantity, total_price, status, created_at
-		FROM orders
-		WHERE id = $1`
-
-	err := r.DB.QueryRow(query, orderID).Scan(
-		&order.ID,
-		&order.CustomerID,
-		&order.ProductID,
-		&order.Quantity,
-		&order.TotalPrice,
-		&order.Status,
-		&order.CreatedAt,
-	)
-
-	if err != nil {
-		return nil, err
-	}
-
-	return &order, nil
+	ProductService      ProductServiceInterface
+	// Pricing prices orders, the zero Engine if nil
+	Pricing *pricing.Engine
+	// Currencies are the currencies orders can be placed in, any if empty
+	Currencies []string
+	// ReadinessChecks are the dependencies reported by ReadinessCheck, by
+	// name. A check returns nil while its dependency is available.
+	ReadinessChecks map[string]func() error
 }
 
 // RedisCache implements Cache interface using Redis
@@ -126,95 +111,68 @@ func (r *RedisCache) Get(key string, value interface{}) error { return cache.Get
 // Set stores a value in cache with expiration
 func (r *RedisCache) Set(key string, value interface{}, expiration time.Duration) error { return cache.Set(key, value, expiration) }
 
+// Delete removes a key from cache
+func (r *RedisCache) Delete(key string) error { return cache.Delete(key) }
+
 // GetOrSet retrieves value from cache or sets it if not exists
 func (r *RedisCache) GetOrSet(key string, value interface{}, expiration time.Duration, fn func() (interface{}, error)) error {
 	return cache.GetOrSet(key, value, expiration, fn)
 }
 
-// RabbitMQQueue implements MessageQueue interface using RabbitMQ
-type RabbitMQQueue struct{}
-
-// PublishMessage publishes a message to RabbitMQ
-func (r *RabbitMQQueue) PublishMessage(config queue.Config, message interface{}) error {
-	return queue.PublishMessage(config, message)
-}
-
-// NewOrderController creates a new order controller
-func NewOrderController(db *sql.DB) *OrderController {
-	return &OrderController{
+// NewOrderController creates a new order controller publishing its events
+// to eventBroker
+func NewOrderController(db *sql.DB, eventBroker broker.Broker) *OrderController {
+	oc := &OrderController{
 		DB:                  db,
-		OrderRepo:           &DBOrderRepository{DB: db},
+		OrderRepo:           repository.NewDBOrderRepository(db),
+		Coupons:             repository.NewDBCouponRepository(db),
 		Cache:               &RedisCache{},
-		Queue:               &RabbitMQQueue{},
+		Pricing:             &pricing.Engine{},
 		InventoryService:    service.NewInventoryService(),
 		NotificationService: service.NewNotificationService(),
 		PaymentService:      service.NewPaymentService(),
+		ProductService:      service.NewProductService(),
+		ReadinessChecks: map[string]func() error{
+			"database": db.Ping,
+		},
 	}
-}
-
-// getProductPrice fetches a product's price from product-service
-func getProductPrice(productID int) (float64, error) {
-	baseURL := os.Getenv("PRODUCT_SERVICE_URL")
-	if baseURL == "" {
-		baseURL = "http://product-service:8080"
-	}
-	url := fmt.Sprintf("%s/products/%d", baseURL, productID)
-
-	resp, err := http.Get(url)
-	if err != nil {
-		return 0, fmt.Errorf("failed to fetch product: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("product service returned status: %d", resp.StatusCode)
-	}
-
-	var product struct {
-		Price float64 `json:"price"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&product); err != nil {
-		return 0, fmt.Errorf("failed to decode product response: %w", err)
-	}
-	return product.Price, nil
-}
-
-// checkInventoryAvailable checks availability via inventory-service POST /inventory/check
-func checkInventoryAvailable(productID int, quantity int) (bool, error) {
-	baseURL := os.Getenv("INVENTORY_SERVICE_URL")
-	if baseURL == "" {
-		baseURL = "http://inventory-service:8082"
+	if eventBroker != nil {
+		oc.Broker = eventBroker
+		oc.ReadinessChecks["broker"] = eventBroker.Ready
 	}
 
-	payload := model.InventoryCheck{ProductID: productID, Quantity: quantity}
-	body, err := json.Marshal(payload)
+	// Orders are placed in the currencies of SUPPORTED_CURRENCIES, or of
+	// the exchange rates file
+	rates, err := exchange.FileProviderFromEnv()
 	if err != nil {
-		return false, fmt.Errorf("failed to marshal inventory check: %w", err)
-	}
-
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/inventory/check", baseURL), bytes.NewBuffer(body))
-	if err != nil {
-		return false, fmt.Errorf("failed to create inventory request: %w", err)
+		log.Printf("Warning: Failed to read exchange rates, orders can only be placed in the currency of their product: %v\n", err)
+	} else {
+		oc.Pricing.Rates = rates
+		oc.Currencies = rates.Currencies()
 	}
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return false, fmt.Errorf("inventory service request failed: %w", err)
+	if list := os.Getenv("SUPPORTED_CURRENCIES"); list != "" {
+		currencies, err := exchange.ParseCurrencies(list)
+		if err != nil {
+			log.Fatal("Invalid SUPPORTED_CURRENCIES: ", err)
+		}
+		oc.Currencies = currencies
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return false, fmt.Errorf("inventory service returned status: %d", resp.StatusCode)
+	// Orders are taxed by the rules of TAX_RULES_FILE and shipped for
+	// SHIPPING_FEE
+	if oc.Pricing.Taxes, err = pricing.TaxRulesFromEnv(); err != nil {
+		log.Printf("Warning: Failed to read tax rules, orders are not taxed: %v\n", err)
 	}
-
-	var invResp model.InventoryResponse
-	if err := json.NewDecoder(resp.Body).Decode(&invResp); err != nil {
-		return false, fmt.Errorf("failed to decode inventory response: %w", err)
+	if fee := os.Getenv("SHIPPING_FEE"); fee != "" {
+		currency := os.Getenv("SHIPPING_CURRENCY")
+		if currency == "" {
+			currency = money.DefaultCurrency
+		}
+		if oc.Pricing.Shipping, err = money.Parse(fee, currency); err != nil {
+			log.Fatal("Invalid SHIPPING_FEE: ", err)
+		}
 	}
-
-	return invResp.Available, nil
+	return oc
 }
 
 // CreateOrder handles creation of a new order
@@ -225,8 +183,8 @@ func (oc *OrderController) CreateOrder(c *gin.Context) {
 		return
 	}
 
-	// Check inventory availability via correct endpoint
-	available, err := checkInventoryAvailable(order.ProductID, order.Quantity)
+	// Check inventory availability using circuit breaker
+	available, err := oc.InventoryService.CheckAvailability(order.ProductID, order.Quantity)
 	if err != nil {
 		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to check inventory: " + err.Error()})
 		return
@@ -236,23 +194,21 @@ func (oc *OrderController) CreateOrder(c *gin.Context) {
 		return
 	}
 
-	// Compute total price from product-service if not provided
-	if order.TotalPrice == 0 {
-		price, err := getProductPrice(order.ProductID)
-		if err != nil {
-			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch product price: " + err.Error()})
-			return
-		}
-		order.TotalPrice = float64(order.Quantity) * price
+	coupon, ok := oc.orderCoupon(c, &order, true)
+	if !ok || !oc.priceOrder(c, &order, coupon) || !oc.redeemCoupon(c, &order) {
+		return
 	}
 
 	// Insert order into database
 	if oc.OrderRepo != nil {
 		err = oc.OrderRepo.InsertOrder(&order)
 		if err != nil {
+			oc.releaseCoupon(&order)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order: " + err.Error()})
 			return
 		}
+		// Drop a not-found result cached for the new ID
+		oc.invalidateOrder(order.ID)
 	} else {
 		// For testing purposes, set a mock ID
 		order.ID = 1
@@ -260,42 +216,35 @@ func (oc *OrderController) CreateOrder(c *gin.Context) {
 		order.CreatedAt = time.Now()
 	}
 
-	// Publish order created event to message queue
-	if oc.Queue != nil {
-		orderMsg, _ := json.Marshal(order)
-		if err := oc.Queue.PublishMessage(queue.Config{
-			QueueName:    "orders",
-			RoutingKey:   "order.created",
-			ExchangeName: "orders",
-		}, orderMsg); err != nil {
-			log.Printf("Warning: Failed to publish order created event: %v\n", err)
-		}
+	// Publish order created event to message queue, notification-service
+	// consumes it. Fall back to notifying over HTTP if it was not published.
+	if !oc.publishEvent(c, orderCreated(&order)) {
+		// Send notification using circuit breaker
+		go func() {
+			if err := oc.NotificationService.SendOrderNotification(order.ID, order.CustomerID); err != nil {
+				log.Printf("Failed to send notification: %v\n", err)
+			}
+		}()
 	}
 
-	// Send notification using circuit breaker
-	go func() {
-		if err := oc.NotificationService.SendOrderNotification(order.ID); err != nil {
-			log.Printf("Failed to send notification: %v\n", err)
-		}
-	}()
-
 	c.JSON(http.StatusCreated, order)
 }
 
 // CreateOrderWithPayment handles creation of a new order with payment intent
 func (oc *OrderController) CreateOrderWithPayment(c *gin.Context) {
-	var orderWithPayment struct {
-		model.Order
-		Currency string `json:"currency" binding:"required"`
-	}
-	
+	var orderWithPayment model.Order
 	if err := c.ShouldBindJSON(&orderWithPayment); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	// The order's currency is the currency it is paid in
+	if orderWithPayment.TotalPrice.Currency() == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "currency is required"})
+		return
+	}
 
 	// Check inventory availability using circuit breaker
-	available, err := checkInventoryAvailable(orderWithPayment.ProductID, orderWithPayment.Quantity)
+	available, err := oc.InventoryService.CheckAvailability(orderWithPayment.ProductID, orderWithPayment.Quantity)
 	if err != nil {
 		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to check inventory: " + err.Error()})
 		return
@@ -305,28 +254,25 @@ func (oc *OrderController) CreateOrderWithPayment(c *gin.Context) {
 		return
 	}
 
-	// Compute total price from product-service if not provided
-	if orderWithPayment.TotalPrice == 0 {
-		price, err := getProductPrice(orderWithPayment.ProductID)
-		if err != nil {
-			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch product price: " + err.Error()})
-			return
-		}
-		orderWithPayment.TotalPrice = float64(orderWithPayment.Quantity) * price
+	coupon, ok := oc.orderCoupon(c, &orderWithPayment, true)
+	if !ok || !oc.priceOrder(c, &orderWithPayment, coupon) || !oc.redeemCoupon(c, &orderWithPayment) {
+		return
 	}
 
 	// Insert order into database
 	if oc.OrderRepo != nil {
-		err = oc.OrderRepo.InsertOrder(&orderWithPayment.Order)
+		err = oc.OrderRepo.InsertOrder(&orderWithPayment)
 		if err != nil {
+			oc.releaseCoupon(&orderWithPayment)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order: " + err.Error()})
 			return
 		}
+		oc.invalidateOrder(orderWithPayment.ID)
 	} else {
 		// For testing purposes, set a mock ID
-		orderWithPayment.Order.ID = 1
-		orderWithPayment.Order.Status = "pending"
-		orderWithPayment.Order.CreatedAt = time.Now()
+		orderWithPayment.ID = 1
+		orderWithPayment.Status = "pending"
+		orderWithPayment.CreatedAt = time.Now()
 	}
 
 	// Create payment intent
@@ -334,61 +280,41 @@ func (oc *OrderController) CreateOrderWithPayment(c *gin.Context) {
 		orderWithPayment.ID,
 		orderWithPayment.CustomerID,
 		orderWithPayment.TotalPrice,
-		orderWithPayment.Currency,
 	)
 	if err != nil {
 		log.Printf("Warning: Failed to create payment intent: %v\n", err)
 		// Still return the order but indicate payment failed
 		c.JSON(http.StatusCreated, gin.H{
-			"order":         orderWithPayment.Order,
+			"order":         orderWithPayment,
 			"payment_error": "Failed to create payment intent: " + err.Error(),
 		})
 		return
 	}
 
-	// Publish order created event to message queue
-	if oc.Queue != nil {
-		orderMsg, _ := json.Marshal(orderWithPayment.Order)
-		if err := oc.Queue.PublishMessage(queue.Config{
-			QueueName:    "orders",
-			RoutingKey:   "order.created",
-			ExchangeName: "orders",
-		}, orderMsg); err != nil {
-			log.Printf("Warning: Failed to publish order created event: %v\n", err)
-		}
+	// Publish order created event to message queue, falling back to
+	// notifying over HTTP
+	if !oc.publishEvent(c, orderCreated(&orderWithPayment)) {
+		// Send notification using circuit breaker
+		go func() {
+			if err := oc.NotificationService.SendOrderNotification(orderWithPayment.ID, orderWithPayment.CustomerID); err != nil {
+				log.Printf("Failed to send notification: %v\n", err)
+			}
+		}()
 	}
 
-	// Send notification using circuit breaker
-	go func() {
-		if err := oc.NotificationService.SendOrderNotification(orderWithPayment.ID); err != nil {
-			log.Printf("Failed to send notification: %v\n", err)
-		}
-	}()
-
 	c.JSON(http.StatusCreated, gin.H{
-		"order":   orderWithPayment.Order,
+		"order":   orderWithPayment,
 		"payment": paymentResp,
 	})
 }
 
 // GetOrders returns all orders
 func (oc *OrderController) GetOrders(c *gin.Context) {
-	rows, err := oc.DB.Query("SELECT id, customer_id, product_id, quantity, total_price, status, created_at FROM orders")
+	orders, err := oc.OrderRepo.GetOrders()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	defer rows.Close()
-
-	var orders []model.Order
-	for rows.Next() {
-		var o model.Order
-		if err := rows.Scan(&o.ID, &o.CustomerID, &o.ProductID, &o.Quantity, &o.TotalPrice, &o.Status, &o.CreatedAt); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		orders = append(orders, o)
-	}
 
 	c.JSON(http.StatusOK, orders)
 }
@@ -399,7 +325,7 @@ func (oc *OrderController) GetOrder(c *gin.Context) {
 
 	// Try to get order from cache first
 	var order model.Order
-	cacheKey := "order:" + orderID
+	cacheKey := orderCacheKey(orderID)
 	
 	// If cache is not available, get directly from database
 	if oc.Cache == nil {
@@ -420,16 +346,21 @@ func (oc *OrderController) GetOrder(c *gin.Context) {
 		return
 	}
 	
-	err := oc.Cache.GetOrSet(cacheKey, &order, 30*time.Minute, func() (interface{}, error) {
-		// If not in cache, get from database
-		if oc.OrderRepo != nil {
-			return oc.OrderRepo.GetOrderFromDB(orderID)
+	err := oc.Cache.GetOrSet(cacheKey, &order, cache.OrderTTL(), func() (interface{}, error) {
+		// If not in cache, get from database. Missing orders are cached
+		// as not found for a short while.
+		if oc.OrderRepo == nil {
+			return nil, cache.ErrNotFound
+		}
+		order, err := oc.OrderRepo.GetOrderFromDB(orderID)
+		if err == sql.ErrNoRows {
+			return nil, cache.ErrNotFound
 		}
-		return nil, sql.ErrNoRows
+		return order, err
 	})
 
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, cache.ErrNotFound) || err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
 			return
 		}
@@ -449,9 +380,7 @@ func (oc *OrderController) UpdateOrder(c *gin.Context) {
 	}
 
 	// Get existing order to compare status change
-	var existingOrder model.Order
-	err = oc.DB.QueryRow("SELECT id, customer_id, product_id, quantity, total_price, status FROM orders WHERE id = $1", id).
-		Scan(&existingOrder.ID, &existingOrder.CustomerID, &existingOrder.ProductID, &existingOrder.Quantity, &existingOrder.TotalPrice, &existingOrder.Status)
+	existingOrder, err := oc.OrderRepo.GetOrderFromDB(strconv.Itoa(id))
 
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
@@ -468,41 +397,60 @@ func (oc *OrderController) UpdateOrder(c *gin.Context) {
 		return
 	}
 
-	result, err := oc.DB.Exec(
-		"UPDATE orders SET customer_id = $1, product_id = $2, quantity = $3, total_price = $4, status = $5 WHERE id = $6",
-		updatedOrder.CustomerID, updatedOrder.ProductID, updatedOrder.Quantity, updatedOrder.TotalPrice, updatedOrder.Status, id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	updatedOrder.ID = id
+	if !oc.repriceOrder(c, existingOrder, &updatedOrder) {
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
+	// Refund a paid order before cancelling it, as UpdateOrderStatus does
+	if updatedOrder.Status == "cancelled" && existingOrder.Status != "cancelled" {
+		if err := oc.refundOrder(existingOrder); err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to refund order: " + err.Error()})
+			return
+		}
+	}
+	err = oc.OrderRepo.UpdateOrder(&updatedOrder)
+	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
 		return
 	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	oc.invalidateOrder(id)
 
-	// If status changed, send notification
+	// If status changed, publish the change, or send the notification if
+	// it could not be published
 	if existingOrder.Status != updatedOrder.Status {
-		err = oc.NotificationService.SendOrderStatusUpdate(id, updatedOrder.CustomerID, updatedOrder.Status)
-		if err != nil {
-			// Log the error but continue (non-blocking)
-			fmt.Printf("Failed to send status update notification: %v\n", err)
+		published := oc.publishEvent(c, &events.OrderStatusChanged{
+			OrderID:        id,
+			CustomerID:     updatedOrder.CustomerID,
+			PreviousStatus: existingOrder.Status,
+			Status:         updatedOrder.Status,
+		})
+		if !published {
+			err = oc.NotificationService.SendOrderStatusUpdate(id, updatedOrder.CustomerID, updatedOrder.Status)
+			if err != nil {
+				// Log the error but continue (non-blocking)
+				fmt.Printf("Failed to send status update notification: %v\n", err)
+			}
 		}
 	}
 
-	updatedOrder.ID = id
 	c.JSON(http.StatusOK, updatedOrder)
 }
 
 // DeleteOrder deletes an order
 func (oc *OrderController) DeleteOrder(c *gin.Context) {
-	id := c.Param("id")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
 
 	// Get the order first
-	var order model.Order
-	err := oc.DB.QueryRow("SELECT id, customer_id, product_id, quantity, total_price, status FROM orders WHERE id = $1", id).
-		Scan(&order.ID, &order.CustomerID, &order.ProductID, &order.Quantity, &order.TotalPrice, &order.Status)
+	order, err := oc.OrderRepo.GetOrderFromDB(strconv.Itoa(id))
 
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
@@ -513,24 +461,38 @@ func (oc *OrderController) DeleteOrder(c *gin.Context) {
 		return
 	}
 
-	// Delete the order
-	result, err := oc.DB.Exec("DELETE FROM orders WHERE id = $1", id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	// Refund the order before it disappears
+	if err := oc.refundOrder(order); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to refund order: " + err.Error()})
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
+	// Delete the order
+	err = oc.OrderRepo.DeleteOrder(id)
+	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
 		return
 	}
-
-	// Send notification that order was deleted/cancelled
-	err = oc.NotificationService.SendOrderStatusUpdate(order.ID, order.CustomerID, "cancelled")
 	if err != nil {
-		// Log the error but continue (non-blocking)
-		fmt.Printf("Failed to send cancellation notification: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	oc.invalidateOrder(id)
+
+	// Announce that the order was deleted/cancelled, notifying over HTTP
+	// if the event could not be published
+	published := oc.publishEvent(c, &events.OrderStatusChanged{
+		OrderID:        order.ID,
+		CustomerID:     order.CustomerID,
+		PreviousStatus: order.Status,
+		Status:         "cancelled",
+	})
+	if !published {
+		err = oc.NotificationService.SendOrderStatusUpdate(order.ID, order.CustomerID, "cancelled")
+		if err != nil {
+			// Log the error but continue (non-blocking)
+			fmt.Printf("Failed to send cancellation notification: %v\n", err)
+		}
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Order deleted successfully"})
@@ -553,9 +515,7 @@ func (oc *OrderController) UpdateOrderStatus(c *gin.Context) {
 	}
 
 	// Get existing order to get customer ID
-	var order model.Order
-	err = oc.DB.QueryRow("SELECT id, customer_id, product_id, quantity, total_price, status FROM orders WHERE id = $1", id).
-		Scan(&order.ID, &order.CustomerID, &order.ProductID, &order.Quantity, &order.TotalPrice, &order.Status)
+	order, err := oc.OrderRepo.GetOrderFromDB(strconv.Itoa(id))
 
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
@@ -566,31 +526,24 @@ func (oc *OrderController) UpdateOrderStatus(c *gin.Context) {
 		return
 	}
 
-	// Update order status
-	result, err := oc.DB.Exec("UPDATE orders SET status = $1 WHERE id = $2", statusUpdate.Status, id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	// Refund a paid order before cancelling it, so a failed refund can be
+	// retried by cancelling again
+	if statusUpdate.Status == "cancelled" {
+		if err := oc.refundOrder(order); err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to refund order: " + err.Error()})
+			return
+		}
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
+	// Update order status
+	err = oc.changeStatus(c.Request.Context(), correlationID(c), order, statusUpdate.Status)
+	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
 		return
 	}
-
-	metrics.OrderStatusUpdated.WithLabelValues(statusUpdate.Status).Inc()
-
-	// Update active orders metric based on status
-	if statusUpdate.Status == "completed" || statusUpdate.Status == "cancelled" {
-		metrics.ActiveOrders.Dec()
-	}
-
-	// Send notification about status change
-	err = oc.NotificationService.SendOrderStatusUpdate(id, order.CustomerID, statusUpdate.Status)
 	if err != nil {
-		// Log the error but continue (non-blocking)
-		fmt.Printf("Failed to send status update notification: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -641,3 +594,302 @@ func (oc *OrderController) CreateBatchOrders(c *gin.Context) {
 		"processing_time": timeout,
 	})
 }
+
+// orderCacheKey returns the cache key of an order
+func orderCacheKey(orderID string) string {
+	return "order:" + orderID
+}
+
+// invalidateOrder drops an order from the cache after it was written, so
+// GetOrder does not serve the old version until it expires
+func (oc *OrderController) invalidateOrder(orderID int) {
+	if oc.Cache == nil {
+		return
+	}
+	if err := oc.Cache.Delete(orderCacheKey(strconv.Itoa(orderID))); err != nil {
+		log.Printf("Failed to invalidate cached order %d: %v\n", orderID, err)
+	}
+}
+
+// refundOrder refunds the captured payments of an order being cancelled.
+// Orders already cancelled were refunded when they were cancelled.
+func (oc *OrderController) refundOrder(order *model.Order) error {
+	if oc.PaymentService == nil || order.Status == "cancelled" {
+		return nil
+	}
+
+	refunds, err := oc.PaymentService.RefundOrder(order.ID)
+	for _, refund := range refunds {
+		amount, err := money.FromMajor(refund.Amount, refund.Currency)
+		if err != nil {
+			log.Printf("Refunded payment %d for order %d, the amount cannot be read: %v\n", refund.PaymentID, order.ID, err)
+			continue
+		}
+		log.Printf("Refunded %s of payment %d for order %d\n", amount, refund.PaymentID, order.ID)
+	}
+	return err
+}
+
+// changeStatus sets the status of an order, drops it from the cache and
+// announces the change to the customer. correlationID ties the change to
+// the request or event that caused it.
+func (oc *OrderController) changeStatus(ctx context.Context, correlationID string, order *model.Order, status string) error {
+	if err := oc.OrderRepo.UpdateOrderStatus(order.ID, status); err != nil {
+		return err
+	}
+	oc.invalidateOrder(order.ID)
+
+	metrics.OrderStatusUpdated.WithLabelValues(status).Inc()
+
+	// Update active orders metric based on status
+	if status == "completed" || status == "cancelled" {
+		metrics.ActiveOrders.Dec()
+	}
+
+	published := oc.publish(ctx, correlationID, &events.OrderStatusChanged{
+		OrderID:        order.ID,
+		CustomerID:     order.CustomerID,
+		PreviousStatus: order.Status,
+		Status:         status,
+	})
+
+	// Send notification about status change if the event did not reach
+	// notification-service
+	if !published {
+		err := oc.NotificationService.SendOrderStatusUpdate(order.ID, order.CustomerID, status)
+		if err != nil {
+			// Log the error but continue (non-blocking)
+			fmt.Printf("Failed to send status update notification: %v\n", err)
+		}
+	}
+	return nil
+}
+
+// publishEvent publishes an order event caused by a request, see publish
+func (oc *OrderController) publishEvent(c *gin.Context, event events.Event) bool {
+	return oc.publish(c.Request.Context(), correlationID(c), event)
+}
+
+// publish publishes an order event to the orders topic, keyed by its
+// type, and reports whether the broker accepted it. Failures are logged,
+// the order has already been written.
+func (oc *OrderController) publish(ctx context.Context, correlationID string, event events.Event) bool {
+	if oc.Broker == nil {
+		return false
+	}
+
+	envelope, err := events.New("order-service", correlationID, event)
+	var body []byte
+	if err == nil {
+		body, err = json.Marshal(envelope)
+	}
+	if err != nil {
+		log.Printf("Warning: Failed to encode %s event: %v\n", event.EventType(), err)
+		return false
+	}
+
+	if err := oc.Broker.Publish(ctx, broker.Message{
+		Topic: events.TopicOrders,
+		Key:   envelope.Type,
+		ID:    envelope.ID,
+		Body:  body,
+	}); err != nil {
+		log.Printf("Warning: Failed to publish %s event: %v\n", event.EventType(), err)
+		return false
+	}
+	return true
+}
+
+// priceOrder sets the total price of an order, and its breakdown, from the
+// unit price of its product, converted into the order's currency, and the
+// coupon applied to it if not nil. Orders without a currency are placed in
+// the currency of their product. Totals given by the request are ignored.
+// It responds with an error and returns false if the order cannot be
+// priced.
+func (oc *OrderController) priceOrder(c *gin.Context, order *model.Order, coupon *model.Coupon) bool {
+	if order.Quantity <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "quantity must be at least 1"})
+		return false
+	}
+	currency := order.TotalPrice.Currency()
+	if currency != "" && !oc.supportsCurrency(c, currency) {
+		return false
+	}
+	region, err := pricing.NormalizeRegion(order.Region)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	order.Region = region
+
+	price, err := oc.ProductService.GetProductPrice(order.ProductID)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch product price: " + err.Error()})
+		return false
+	}
+	if currency == "" && !oc.supportsCurrency(c, price.Currency()) {
+		return false
+	}
+
+	engine := oc.Pricing
+	if engine == nil {
+		engine = &pricing.Engine{}
+	}
+	quote, err := engine.Price(c.Request.Context(), pricing.Request{
+		UnitPrice: price,
+		Quantity:  order.Quantity,
+		Currency:  currency,
+		Coupon:    coupon,
+		Region:    order.Region,
+	})
+	if errors.Is(err, pricing.ErrExchangeRate) {
+		c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("Failed to convert the price of product %d: %v", order.ProductID, err)})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to price order: " + err.Error()})
+		return false
+	}
+
+	order.TotalPrice = quote.Breakdown.Total
+	order.Breakdown = &quote.Breakdown
+	order.ExchangeRate = &quote.ExchangeRate
+	return true
+}
+
+// repriceOrder prices an order being updated. The order keeps the
+// currency, coupon and region it was placed with, and its price unless
+// its product or quantity changed, in which case it is priced again at
+// the current prices. The coupon is applied again without being redeemed
+// or checked, it could be applied when the order was placed.
+func (oc *OrderController) repriceOrder(c *gin.Context, existing, updated *model.Order) bool {
+	updated.TotalPrice = existing.TotalPrice
+	updated.CouponCode, updated.Region = existing.CouponCode, existing.Region
+	updated.Breakdown, updated.ExchangeRate = existing.Breakdown, existing.ExchangeRate
+	if updated.ProductID == existing.ProductID && updated.Quantity == existing.Quantity {
+		return true
+	}
+
+	coupon, ok := oc.orderCoupon(c, updated, false)
+	if !ok {
+		return false
+	}
+	updated.TotalPrice = money.Money{}
+	if currency := existing.TotalPrice.Currency(); currency != "" {
+		updated.TotalPrice = money.MustNew(0, currency)
+	}
+	return oc.priceOrder(c, updated, coupon)
+}
+
+// orderCoupon returns the coupon of an order, nil if it has none. With
+// check, it responds with an error and returns false if the coupon cannot
+// be applied to an order placed now.
+func (oc *OrderController) orderCoupon(c *gin.Context, order *model.Order, check bool) (*model.Coupon, bool) {
+	order.CouponCode = pricing.NormalizeCouponCode(order.CouponCode)
+	if order.CouponCode == "" {
+		return nil, true
+	}
+	if oc.Coupons == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Coupons are not available"})
+		return nil, false
+	}
+
+	coupon, err := oc.Coupons.GetCoupon(order.CouponCode)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Coupon %s does not exist", order.CouponCode)})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get coupon: " + err.Error()})
+		return nil, false
+	}
+	if check {
+		if err := pricing.CheckCoupon(coupon, time.Now()); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Coupon %s cannot be applied: %v", coupon.Code, err)})
+			return nil, false
+		}
+	}
+	return coupon, true
+}
+
+// redeemCoupon counts the use of the coupon of an order being placed. It
+// responds with an error and returns false if the coupon was used up by
+// other orders since it was checked.
+func (oc *OrderController) redeemCoupon(c *gin.Context, order *model.Order) bool {
+	if order.CouponCode == "" {
+		return true
+	}
+	err := oc.Coupons.RedeemCoupon(order.CouponCode)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Coupon %s cannot be applied: %v", order.CouponCode, pricing.ErrCouponUsedUp)})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to redeem coupon: " + err.Error()})
+		return false
+	}
+	return true
+}
+
+// releaseCoupon gives back the use of the coupon of an order that could
+// not be placed
+func (oc *OrderController) releaseCoupon(order *model.Order) {
+	if order.CouponCode == "" {
+		return
+	}
+	if err := oc.Coupons.ReleaseCoupon(order.CouponCode); err != nil {
+		log.Printf("Failed to release coupon %s: %v\n", order.CouponCode, err)
+	}
+}
+
+// supportsCurrency reports whether orders can be placed in a currency,
+// responding with an error if not
+func (oc *OrderController) supportsCurrency(c *gin.Context, currency string) bool {
+	if len(oc.Currencies) == 0 || slices.Contains(oc.Currencies, currency) {
+		return true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Currency %s is not supported, expected one of %s", currency, strings.Join(oc.Currencies, ", "))})
+	return false
+}
+
+// orderCreated builds the event announcing a new order
+func orderCreated(order *model.Order) *events.OrderCreated {
+	return &events.OrderCreated{
+		OrderID:    order.ID,
+		CustomerID: order.CustomerID,
+		ProductID:  order.ProductID,
+		Quantity:   order.Quantity,
+		TotalPrice: order.TotalPrice,
+		Status:     order.Status,
+	}
+}
+
+// correlationID returns the ID tying a request to the events it causes
+func correlationID(c *gin.Context) string {
+	if id := c.GetHeader("X-Correlation-ID"); id != "" {
+		return id
+	}
+	return c.GetHeader("X-Request-ID")
+}
+
+// HealthCheck returns the health status of the order service
+func (oc *OrderController) HealthCheck(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "up"})
+}
+
+// ReadinessCheck reports whether every dependency of the order service is
+// available, so traffic is withheld while e.g. RabbitMQ is reconnecting
+func (oc *OrderController) ReadinessCheck(c *gin.Context) {
+	status, code := "ready", http.StatusOK
+	checks := make(map[string]string, len(oc.ReadinessChecks))
+	for name, check := range oc.ReadinessChecks {
+		if err := check(); err != nil {
+			checks[name] = err.Error()
+			status, code = "not_ready", http.StatusServiceUnavailable
+			continue
+		}
+		checks[name] = "ok"
+	}
+
+	c.JSON(code, gin.H{"status": status, "checks": checks})
+}
@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"go-microservices/pkg/events"
+)
+
+var (
+	// ErrUnhandledEvent is returned by ApplyPaymentEvent for payment events
+	// that do not advance orders
+	ErrUnhandledEvent = errors.New("event does not advance orders")
+	// ErrPaymentMismatch is returned by ApplyPaymentEvent for succeeded
+	// payments that do not cover the total of their order
+	ErrPaymentMismatch = errors.New("payment does not cover the order total")
+)
+
+// ApplyPaymentEvent advances the order a payment event is about: a
+// succeeded payment moves a pending order to processing and a canceled
+// payment cancels it. Orders that are no longer pending are left alone, so
+// redelivered and late events change nothing. Failed payments keep the
+// order pending, as the customer may retry. A succeeded payment captured
+// for less than the order total, or in another currency, also keeps the
+// order pending and returns ErrPaymentMismatch. A missing order returns
+// sql.ErrNoRows.
+func (oc *OrderController) ApplyPaymentEvent(ctx context.Context, envelope events.Envelope, event events.Event) error {
+	var outcome events.PaymentOutcome
+	var status string
+	switch e := event.(type) {
+	case *events.PaymentSucceeded:
+		outcome, status = e.PaymentOutcome, "processing"
+	case *events.PaymentCanceled:
+		outcome, status = e.PaymentOutcome, "cancelled"
+	default:
+		return fmt.Errorf("%w: %s", ErrUnhandledEvent, envelope.Type)
+	}
+
+	order, err := oc.OrderRepo.GetOrderFromDB(strconv.Itoa(outcome.OrderID))
+	if err != nil {
+		return fmt.Errorf("failed to get order %d: %w", outcome.OrderID, err)
+	}
+	if order.Status != "pending" {
+		log.Printf("Order %d is already %s, ignoring %s event %s\n", order.ID, order.Status, envelope.Type, envelope.ID)
+		return nil
+	}
+	if status == "processing" {
+		if covered, err := outcome.Amount.Cmp(order.TotalPrice); err != nil || covered < 0 {
+			return fmt.Errorf("%w: payment %d captured %s of order %d totalling %s", ErrPaymentMismatch, outcome.PaymentID, outcome.Amount, order.ID, order.TotalPrice)
+		}
+	}
+
+	if err := oc.changeStatus(ctx, envelope.CorrelationID, order, status); err != nil {
+		return fmt.Errorf("failed to update order %d: %w", order.ID, err)
+	}
+
+	log.Printf("Order %d is now %s after %s event %s\n", order.ID, status, envelope.Type, envelope.ID)
+	return nil
+}
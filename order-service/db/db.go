@@ -66,8 +66,52 @@ func InitSchema(db *sql.DB) {
 		customer_id INT NOT NULL,
 		product_id INT NOT NULL,
 		quantity INT NOT NULL,
-		total_price DECIMAL(10, 2) NOT NULL,
+		total_price_minor BIGINT NOT NULL,
+		currency VARCHAR(3) NOT NULL DEFAULT 'USD',
 		status VARCHAR(50) NOT NULL
+	);
+
+	-- Total prices used to be decimal US dollars. They are integers in
+	-- minor units of their currency now.
+	DO $$
+	BEGIN
+		IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'orders' AND column_name = 'total_price') THEN
+			ALTER TABLE orders ALTER COLUMN total_price TYPE BIGINT USING ROUND(total_price * 100);
+			ALTER TABLE orders RENAME COLUMN total_price TO total_price_minor;
+			ALTER TABLE orders ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'USD';
+		END IF;
+	END $$;
+
+	-- Orders priced by the service record the exchange rate their total
+	-- was converted at from the currency of their product
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS base_total_price_minor BIGINT;
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS base_currency VARCHAR(3);
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS exchange_rate NUMERIC(24, 10);
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS exchange_rate_as_of TIMESTAMP;
+
+	-- Orders priced by the service also record how their total adds up,
+	-- in the currency of the order
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS coupon_code VARCHAR(64);
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS region VARCHAR(16);
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS subtotal_minor BIGINT;
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS discount_minor BIGINT;
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS shipping_minor BIGINT;
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS tax_minor BIGINT;
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS tax_rate NUMERIC(7, 4);
+
+	-- Coupons take a percentage or a fixed amount off orders. Uses are
+	-- counted when an order is placed.
+	CREATE TABLE IF NOT EXISTS coupons (
+		code VARCHAR(64) PRIMARY KEY,
+		type VARCHAR(16) NOT NULL,
+		percent_off NUMERIC(5, 2),
+		amount_off_minor BIGINT,
+		currency VARCHAR(3),
+		valid_from TIMESTAMP,
+		valid_until TIMESTAMP,
+		max_uses INT NOT NULL DEFAULT 0,
+		uses INT NOT NULL DEFAULT 0,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
 	);`
 
 	_, err := db.Exec(createTableSQL)
@@ -75,5 +119,5 @@ func InitSchema(db *sql.DB) {
 		log.Fatal(err)
 	}
 
-	log.Println("Order table created or already exists")
+	log.Println("Order and coupon tables created or already exist")
 }
@@ -1,19 +1,38 @@
 package main
 
 import (
+	"fmt"
 	"log"
+	"os"
 
+	"go-microservices/order-service/admin"
 	"go-microservices/order-service/cache"
+	"go-microservices/order-service/consumer"
 	"go-microservices/order-service/controller"
 	"go-microservices/order-service/db"
-	"go-microservices/order-service/queue"
 	"go-microservices/order-service/routes"
+	"go-microservices/pkg/broker"
+	"go-microservices/pkg/queue"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
+	// order-service dlq <command> manages dead-lettered messages and exits
+	if len(os.Args) > 1 && os.Args[1] == "dlq" {
+		err := queue.InitRabbitMQ()
+		if err == nil {
+			err = admin.RunDLQ(os.Args[2:], queue.DefaultConnection(), os.Stdout)
+		}
+		queue.Close()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Initialize database connection
 	database := db.GetDB()
 	defer database.Close()
@@ -26,25 +45,26 @@ func main() {
 		log.Printf("Warning: Failed to initialize Redis: %v\n", err)
 	}
 
-	// Initialize RabbitMQ
-	if err := queue.InitRabbitMQ(); err != nil {
-		log.Printf("Warning: Failed to initialize RabbitMQ: %v\n", err)
-	}
-	defer queue.Close()
-
-	// Declare queues
-	orderQueue := queue.Config{
-		QueueName:    "orders",
-		RoutingKey:   "order.#",
-		ExchangeName: "orders",
-		ExchangeType: "topic",
+	// Initialize the message broker selected by BROKER. Order events are
+	// consumed by the subscriptions of the other services, payment events
+	// by the order-service subscription.
+	eventBroker, err := broker.NewFromEnv()
+	if eventBroker == nil {
+		log.Fatal("Failed to create message broker: ", err)
 	}
-	if err := queue.DeclareQueue(orderQueue); err != nil {
-		log.Printf("Warning: Failed to declare order queue: %v\n", err)
+	if err != nil {
+		log.Printf("Warning: Failed to connect to message broker: %v\n", err)
 	}
+	defer eventBroker.Close()
 
 	// Create order controller
-	orderController := controller.NewOrderController(database)
+	orderController := controller.NewOrderController(database, eventBroker)
+
+	// Advance orders on payment events. On RabbitMQ the subscription starts
+	// once the broker is reachable.
+	if _, err := consumer.Start(eventBroker, orderController, 4); err != nil {
+		log.Printf("Warning: Failed to subscribe to payment events: %v\n", err)
+	}
 
 	// Initialize router
 	router := gin.Default()
@@ -0,0 +1,80 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"go-microservices/pkg/money"
+)
+
+// Coupon types
+const (
+	CouponPercentage = "percentage"
+	CouponFixed      = "fixed"
+)
+
+// Coupon is a code taking a percentage or a fixed amount off the subtotal
+// of the orders it is applied to, between ValidFrom and ValidUntil and at
+// most MaxUses times. The fixed amount of a coupon is encoded in JSON as a
+// decimal number in major units next to its currency.
+type Coupon struct {
+	Code string `json:"code"`
+	Type string `json:"type"`
+	// PercentOff is the percentage taken off by percentage coupons
+	PercentOff json.Number `json:"percent_off,omitempty"`
+	// AmountOff is the amount taken off by fixed coupons
+	AmountOff money.Money `json:"-"`
+	// ValidFrom is included and ValidUntil excluded, either may be left
+	// out
+	ValidFrom  *time.Time `json:"valid_from,omitempty"`
+	ValidUntil *time.Time `json:"valid_until,omitempty"`
+	// MaxUses is the number of orders the coupon can be applied to, any
+	// if 0
+	MaxUses   int       `json:"max_uses,omitempty"`
+	Uses      int       `json:"uses"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// couponFields is Coupon without its JSON methods
+type couponFields Coupon
+
+// couponJSON is the JSON form of a Coupon
+type couponJSON struct {
+	couponFields
+	AmountOff json.Number `json:"amount_off,omitempty"`
+	Currency  string      `json:"currency,omitempty"`
+}
+
+// MarshalJSON encodes the coupon with its fixed amount in major units
+func (c Coupon) MarshalJSON() ([]byte, error) {
+	v := couponJSON{couponFields: couponFields(c)}
+	if c.AmountOff.Currency() != "" {
+		v.AmountOff, v.Currency = c.AmountOff.Number(), c.AmountOff.Currency()
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes a coupon encoded by MarshalJSON. A fixed amount
+// without a currency is in USD.
+func (c *Coupon) UnmarshalJSON(data []byte) error {
+	var v couponJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	var amountOff money.Money
+	if v.AmountOff != "" || v.Currency != "" {
+		if v.AmountOff == "" {
+			v.AmountOff = "0"
+		}
+		if v.Currency == "" {
+			v.Currency = money.DefaultCurrency
+		}
+		var err error
+		if amountOff, err = money.Parse(v.AmountOff.String(), v.Currency); err != nil {
+			return err
+		}
+	}
+	*c = Coupon(v.couponFields)
+	c.AmountOff = amountOff
+	return nil
+}
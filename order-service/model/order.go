@@ -1,33 +1,172 @@
 package model
 
-import "time"
+import (
+	"encoding/json"
+	"time"
 
-// Order represents an order entity
+	"go-microservices/pkg/money"
+)
+
+// Order represents an order entity. Its total price is encoded in JSON as
+// a decimal number in major units next to its currency.
 type Order struct {
-	ID         int       `json:"id"`
-	CustomerID int       `json:"customer_id"`
-	ProductID  int       `json:"product_id"`
-	Quantity   int       `json:"quantity"`
-	TotalPrice float64   `json:"total_price"`
-	Status     string    `json:"status"` // pending, processing, shipped, delivered, cancelled
-	CreatedAt  time.Time `json:"created_at"`
+	ID         int         `json:"id"`
+	CustomerID int         `json:"customer_id"`
+	ProductID  int         `json:"product_id"`
+	Quantity   int         `json:"quantity"`
+	TotalPrice money.Money `json:"-"`
+	// CouponCode and Region are given when placing the order, the coupon
+	// applied to it and the region it is taxed in
+	CouponCode string `json:"coupon_code,omitempty"`
+	Region     string `json:"region,omitempty"`
+	// Breakdown records how the service priced the order, and
+	// ExchangeRate how the subtotal was converted from the currency of
+	// the product's price
+	Breakdown    *PriceBreakdown `json:"price_breakdown,omitempty"`
+	ExchangeRate *ExchangeRate   `json:"exchange_rate,omitempty"`
+	Status       string          `json:"status"` // pending, processing, shipped, delivered, cancelled
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+// ExchangeRate is the snapshot of the rate an order's total was converted
+// at. BaseTotal, the total in the currency of the product's price, is
+// encoded in JSON as a decimal number in major units next to its currency.
+type ExchangeRate struct {
+	BaseTotal money.Money `json:"-"`
+	// Rate is the price of one unit of the base currency in the order's
+	// currency, as a decimal
+	Rate json.Number `json:"rate"`
+	AsOf time.Time   `json:"as_of"`
+}
+
+// PriceBreakdown is how the total price of an order adds up, in the
+// currency of the order: the subtotal less the discount of its coupon,
+// plus shipping and tax. Its amounts are encoded in JSON as decimal
+// numbers in major units next to the currency.
+type PriceBreakdown struct {
+	Subtotal money.Money `json:"-"`
+	Discount money.Money `json:"-"`
+	Shipping money.Money `json:"-"`
+	Tax      money.Money `json:"-"`
+	Total    money.Money `json:"-"`
+	// TaxRate is the percentage of tax charged in the order's region
+	TaxRate json.Number `json:"tax_rate"`
+}
+
+// priceBreakdownFields is PriceBreakdown without its JSON methods
+type priceBreakdownFields PriceBreakdown
+
+// priceBreakdownJSON is the JSON form of a PriceBreakdown
+type priceBreakdownJSON struct {
+	priceBreakdownFields
+	Subtotal json.Number `json:"subtotal"`
+	Discount json.Number `json:"discount"`
+	Shipping json.Number `json:"shipping"`
+	Tax      json.Number `json:"tax"`
+	Total    json.Number `json:"total"`
+	Currency string      `json:"currency"`
+}
+
+// MarshalJSON encodes the breakdown with its amounts in major units
+func (b PriceBreakdown) MarshalJSON() ([]byte, error) {
+	return json.Marshal(priceBreakdownJSON{
+		priceBreakdownFields: priceBreakdownFields(b),
+		Subtotal:             b.Subtotal.Number(),
+		Discount:             b.Discount.Number(),
+		Shipping:             b.Shipping.Number(),
+		Tax:                  b.Tax.Number(),
+		Total:                b.Total.Number(),
+		Currency:             b.Total.Currency(),
+	})
 }
 
-// InventoryCheck is used to check inventory availability
-type InventoryCheck struct {
-	ProductID int `json:"product_id"`
-	Quantity  int `json:"quantity"`
+// UnmarshalJSON decodes a breakdown encoded by MarshalJSON
+func (b *PriceBreakdown) UnmarshalJSON(data []byte) error {
+	var v priceBreakdownJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	amounts := make([]money.Money, 5)
+	for i, amount := range []json.Number{v.Subtotal, v.Discount, v.Shipping, v.Tax, v.Total} {
+		var err error
+		if amounts[i], err = money.Parse(amount.String(), v.Currency); err != nil {
+			return err
+		}
+	}
+	*b = PriceBreakdown(v.priceBreakdownFields)
+	b.Subtotal, b.Discount, b.Shipping, b.Tax, b.Total = amounts[0], amounts[1], amounts[2], amounts[3], amounts[4]
+	return nil
+}
+
+// exchangeRateFields is ExchangeRate without its JSON methods
+type exchangeRateFields ExchangeRate
+
+// exchangeRateJSON is the JSON form of an ExchangeRate
+type exchangeRateJSON struct {
+	exchangeRateFields
+	BaseTotal    json.Number `json:"base_total_price"`
+	BaseCurrency string      `json:"base_currency"`
+}
+
+// MarshalJSON encodes the rate with the base total in major units
+func (r ExchangeRate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(exchangeRateJSON{
+		exchangeRateFields: exchangeRateFields(r),
+		BaseTotal:          r.BaseTotal.Number(),
+		BaseCurrency:       r.BaseTotal.Currency(),
+	})
+}
+
+// UnmarshalJSON decodes a rate encoded by MarshalJSON
+func (r *ExchangeRate) UnmarshalJSON(data []byte) error {
+	var v exchangeRateJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	baseTotal, err := money.Parse(v.BaseTotal.String(), v.BaseCurrency)
+	if err != nil {
+		return err
+	}
+	*r = ExchangeRate(v.exchangeRateFields)
+	r.BaseTotal = baseTotal
+	return nil
+}
+
+// orderFields is Order without its JSON methods
+type orderFields Order
+
+// orderJSON is the JSON form of an Order
+type orderJSON struct {
+	orderFields
+	TotalPrice json.Number `json:"total_price"`
+	Currency   string      `json:"currency"`
 }
 
-// InventoryResponse is the response from inventory service
-type InventoryResponse struct {
-	Available bool   `json:"available"`
-	Message   string `json:"message,omitempty"`
+// MarshalJSON encodes the order with its total price in major units
+func (o Order) MarshalJSON() ([]byte, error) {
+	return json.Marshal(orderJSON{orderFields: orderFields(o), TotalPrice: o.TotalPrice.Number(), Currency: o.TotalPrice.Currency()})
 }
 
-// OrderStatusUpdate is used to notify about order status updates
-type OrderStatusUpdate struct {
-	OrderID    int    `json:"order_id"`
-	CustomerID int    `json:"customer_id"`
-	Status     string `json:"status"`
+// UnmarshalJSON decodes an order encoded by MarshalJSON. A request for an
+// order may leave out the total price, which is then a zero of the
+// currency, or the currency, which leaves the zero Money. The service
+// prices orders itself, so a total price without a currency is dropped.
+func (o *Order) UnmarshalJSON(data []byte) error {
+	var v orderJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	var total money.Money
+	if v.Currency != "" {
+		if v.TotalPrice == "" {
+			v.TotalPrice = "0"
+		}
+		var err error
+		if total, err = money.Parse(v.TotalPrice.String(), v.Currency); err != nil {
+			return err
+		}
+	}
+	*o = Order(v.orderFields)
+	o.TotalPrice = total
+	return nil
 }
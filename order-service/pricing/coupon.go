@@ -0,0 +1,75 @@
+package pricing
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+
+	"go-microservices/order-service/model"
+)
+
+// couponCode is the form of coupon codes, once upper-cased
+var couponCode = regexp.MustCompile(`^[A-Z0-9_-]{1,64}$`)
+
+// NormalizeCouponCode returns a coupon code as it is stored, upper case
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// ValidateCoupon checks a new coupon and normalizes its code. Percentage
+// coupons take off more than 0 and at most 100 percent with at most two
+// decimals, fixed coupons a positive amount.
+func ValidateCoupon(coupon *model.Coupon) error {
+	coupon.Code = NormalizeCouponCode(coupon.Code)
+	if !couponCode.MatchString(coupon.Code) {
+		return fmt.Errorf("%w: code %q must be 1 to 64 letters, digits, - or _", ErrInvalidCoupon, coupon.Code)
+	}
+
+	switch coupon.Type {
+	case model.CouponPercentage:
+		percent, ok := new(big.Rat).SetString(coupon.PercentOff.String())
+		if !ok || percent.Sign() <= 0 || percent.Cmp(big.NewRat(100, 1)) > 0 {
+			return fmt.Errorf("%w: percent_off %q must be more than 0 and at most 100", ErrInvalidCoupon, coupon.PercentOff)
+		}
+		if !new(big.Rat).Mul(percent, big.NewRat(100, 1)).IsInt() {
+			return fmt.Errorf("%w: percent_off %q has more than two decimals", ErrInvalidCoupon, coupon.PercentOff)
+		}
+		if coupon.AmountOff.Currency() != "" {
+			return fmt.Errorf("%w: percentage coupons take no amount_off", ErrInvalidCoupon)
+		}
+	case model.CouponFixed:
+		if !coupon.AmountOff.IsPositive() {
+			return fmt.Errorf("%w: amount_off must be positive", ErrInvalidCoupon)
+		}
+		if coupon.PercentOff != "" {
+			return fmt.Errorf("%w: fixed coupons take no percent_off", ErrInvalidCoupon)
+		}
+	default:
+		return fmt.Errorf("%w: type %q must be %s or %s", ErrInvalidCoupon, coupon.Type, model.CouponPercentage, model.CouponFixed)
+	}
+
+	if coupon.ValidFrom != nil && coupon.ValidUntil != nil && !coupon.ValidFrom.Before(*coupon.ValidUntil) {
+		return fmt.Errorf("%w: valid_from must be before valid_until", ErrInvalidCoupon)
+	}
+	if coupon.MaxUses < 0 {
+		return fmt.Errorf("%w: max_uses must not be negative", ErrInvalidCoupon)
+	}
+	return nil
+}
+
+// CheckCoupon returns an error if a coupon cannot be applied to an order
+// placed at a time
+func CheckCoupon(coupon *model.Coupon, at time.Time) error {
+	if coupon.ValidFrom != nil && at.Before(*coupon.ValidFrom) {
+		return ErrCouponNotYetValid
+	}
+	if coupon.ValidUntil != nil && !at.Before(*coupon.ValidUntil) {
+		return ErrCouponExpired
+	}
+	if coupon.MaxUses > 0 && coupon.Uses >= coupon.MaxUses {
+		return ErrCouponUsedUp
+	}
+	return nil
+}
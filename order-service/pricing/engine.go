@@ -0,0 +1,221 @@
+// Package pricing prices orders: the unit price of their product times
+// their quantity, converted into the currency of the order, less the
+// discount of a coupon, plus shipping and the tax of their region. Totals
+// given by clients are never used.
+package pricing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"go-microservices/order-service/model"
+	"go-microservices/pkg/exchange"
+	"go-microservices/pkg/money"
+)
+
+var (
+	// ErrInvalidCoupon is returned when creating a coupon that is not valid
+	ErrInvalidCoupon = errors.New("invalid coupon")
+	// ErrCouponNotYetValid, ErrCouponExpired and ErrCouponUsedUp are
+	// returned for coupons that cannot be applied to an order
+	ErrCouponNotYetValid = errors.New("coupon is not valid yet")
+	ErrCouponExpired     = errors.New("coupon has expired")
+	ErrCouponUsedUp      = errors.New("coupon has been used up")
+	// ErrInvalidQuantity is returned when pricing an order of no or a
+	// negative quantity
+	ErrInvalidQuantity = errors.New("quantity must be at least 1")
+	// ErrNoTaxRule is returned when no tax rule matches the region of an
+	// order
+	ErrNoTaxRule = errors.New("no tax rule")
+	// ErrExchangeRate is returned when the rate an order is converted at
+	// cannot be read
+	ErrExchangeRate = errors.New("exchange rate unavailable")
+)
+
+// Engine prices orders. Its zero value prices orders in the currency of
+// their product, without shipping or tax.
+type Engine struct {
+	// Rates converts prices into the currency of orders. Without it
+	// orders can only be priced in the currency of their product.
+	Rates exchange.RateProvider
+	// Taxes are the tax rules of the regions orders are placed in.
+	// Without them orders are not taxed.
+	Taxes *TaxRules
+	// Shipping is the shipping fee of an order, converted into its
+	// currency. The zero Money ships for free.
+	Shipping money.Money
+}
+
+// Request is an order to price
+type Request struct {
+	UnitPrice money.Money
+	Quantity  int
+	// Currency is the currency of the order, the currency of UnitPrice if
+	// empty
+	Currency string
+	// Coupon is applied to the order if not nil. Whether it can be is
+	// checked by CheckCoupon.
+	Coupon *model.Coupon
+	Region string
+}
+
+// Quote is the price of an order, and the rate its subtotal was converted
+// at
+type Quote struct {
+	Breakdown    model.PriceBreakdown
+	ExchangeRate model.ExchangeRate
+}
+
+// Price prices an order
+func (e *Engine) Price(ctx context.Context, req Request) (*Quote, error) {
+	if req.Quantity <= 0 {
+		return nil, fmt.Errorf("%w, got %d", ErrInvalidQuantity, req.Quantity)
+	}
+	baseTotal, err := req.UnitPrice.Mul(int64(req.Quantity))
+	if err != nil {
+		return nil, err
+	}
+	currency := req.Currency
+	if currency == "" {
+		currency = baseTotal.Currency()
+	}
+
+	rate, err := e.rate(ctx, baseTotal.Currency(), currency)
+	if err != nil {
+		return nil, err
+	}
+	subtotal, err := rate.Convert(baseTotal)
+	if err != nil {
+		return nil, err
+	}
+
+	discount, err := e.discount(ctx, req.Coupon, subtotal)
+	if err != nil {
+		return nil, err
+	}
+	shipping, err := e.convert(ctx, e.Shipping, currency)
+	if err != nil {
+		return nil, err
+	}
+	tax, taxRate, err := e.tax(req.Region, subtotal, discount, shipping)
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := subtotal.Sub(discount)
+	if err == nil {
+		total, err = total.Add(shipping)
+	}
+	if err == nil {
+		total, err = total.Add(tax)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Quote{
+		Breakdown: model.PriceBreakdown{
+			Subtotal: subtotal,
+			Discount: discount,
+			Shipping: shipping,
+			Tax:      tax,
+			Total:    total,
+			TaxRate:  taxRate,
+		},
+		ExchangeRate: model.ExchangeRate{BaseTotal: baseTotal, Rate: json.Number(rate.Decimal()), AsOf: rate.AsOf},
+	}, nil
+}
+
+// discount returns the amount a coupon takes off a subtotal, at most the
+// subtotal
+func (e *Engine) discount(ctx context.Context, coupon *model.Coupon, subtotal money.Money) (money.Money, error) {
+	zero := money.MustNew(0, subtotal.Currency())
+	if coupon == nil {
+		return zero, nil
+	}
+
+	var discount money.Money
+	var err error
+	switch coupon.Type {
+	case model.CouponPercentage:
+		discount, err = percentOf(subtotal, coupon.PercentOff)
+	case model.CouponFixed:
+		discount, err = e.convert(ctx, coupon.AmountOff, subtotal.Currency())
+	default:
+		err = fmt.Errorf("%w: unknown type %q", ErrInvalidCoupon, coupon.Type)
+	}
+	if err != nil {
+		return money.Money{}, err
+	}
+
+	if over, _ := discount.Cmp(subtotal); over > 0 {
+		return subtotal, nil
+	}
+	return discount, nil
+}
+
+// tax returns the tax of an order of a region, and its rate
+func (e *Engine) tax(region string, subtotal, discount, shipping money.Money) (money.Money, json.Number, error) {
+	if e.Taxes == nil {
+		return money.MustNew(0, subtotal.Currency()), "0", nil
+	}
+	rule, err := e.Taxes.Lookup(region)
+	if err != nil {
+		return money.Money{}, "", err
+	}
+
+	taxed, err := subtotal.Sub(discount)
+	if err == nil && rule.ShippingTaxable {
+		taxed, err = taxed.Add(shipping)
+	}
+	if err != nil {
+		return money.Money{}, "", err
+	}
+	tax, err := percentOf(taxed, rule.Rate)
+	return tax, rule.Rate, err
+}
+
+// convert converts an amount into a currency at the current rate. The
+// zero Money is converted to a zero of the currency.
+func (e *Engine) convert(ctx context.Context, m money.Money, currency string) (money.Money, error) {
+	if m.Currency() == "" {
+		return money.New(0, currency)
+	}
+	rate, err := e.rate(ctx, m.Currency(), currency)
+	if err != nil {
+		return money.Money{}, err
+	}
+	return rate.Convert(m)
+}
+
+// rate returns the current rate from one currency to another
+func (e *Engine) rate(ctx context.Context, from, to string) (exchange.Rate, error) {
+	if e.Rates == nil {
+		if from == to {
+			return exchange.Identity(from, time.Now().UTC()), nil
+		}
+		return exchange.Rate{}, fmt.Errorf("%w: %w from %s to %s", ErrExchangeRate, exchange.ErrNoRate, from, to)
+	}
+	rate, err := e.Rates.Rate(ctx, from, to)
+	if err != nil {
+		return exchange.Rate{}, fmt.Errorf("%w: %w", ErrExchangeRate, err)
+	}
+	return rate, nil
+}
+
+// percentOf returns a percentage of an amount, rounded half away from zero
+// to the minor unit of its currency. The amount is converted to its own
+// currency at a rate of the percentage, which rounds the same way.
+func percentOf(m money.Money, percent json.Number) (money.Money, error) {
+	p, ok := new(big.Rat).SetString(strings.TrimSpace(percent.String()))
+	if !ok {
+		return money.Money{}, fmt.Errorf("invalid percentage %q", percent)
+	}
+	rate := exchange.Rate{From: m.Currency(), To: m.Currency(), Value: p.Quo(p, big.NewRat(100, 1))}
+	return rate.Convert(m)
+}
@@ -0,0 +1,128 @@
+package pricing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// DefaultTaxRulesFile is read by TaxRulesFromEnv when TAX_RULES_FILE is not
+// set
+const DefaultTaxRulesFile = "config/tax-rules.json"
+
+// regionCode is the form of regions, once upper-cased: an ISO 3166-1 country
+// code, optionally followed by an ISO 3166-2 subdivision code
+var regionCode = regexp.MustCompile(`^[A-Z]{2}(-[A-Z0-9]{1,3})?$`)
+
+// AnyRegion is the region of the rule taxing orders no other rule matches
+const AnyRegion = "*"
+
+// TaxRule is the tax charged on orders of a region: an ISO 3166 country
+// code such as "DE", a country subdivision such as "US-CA", or AnyRegion.
+// Rate is a percentage of the discounted subtotal, and of shipping if
+// ShippingTaxable.
+type TaxRule struct {
+	Region          string      `json:"region"`
+	Rate            json.Number `json:"rate"`
+	ShippingTaxable bool        `json:"shipping_taxable,omitempty"`
+}
+
+// TaxRules finds the tax rule of a region. Orders of a subdivision without
+// a rule are taxed by the rule of their country, and orders of a country
+// without a rule by the AnyRegion rule.
+type TaxRules struct {
+	rules map[string]TaxRule
+}
+
+// NewTaxRules validates a table of tax rules
+func NewTaxRules(rules []TaxRule) (*TaxRules, error) {
+	t := &TaxRules{rules: make(map[string]TaxRule, len(rules))}
+	for _, rule := range rules {
+		rule.Region = strings.ToUpper(strings.TrimSpace(rule.Region))
+		if rule.Region == "" {
+			return nil, fmt.Errorf("tax rule without a region")
+		}
+		if _, ok := t.rules[rule.Region]; ok {
+			return nil, fmt.Errorf("tax rule of %s given twice", rule.Region)
+		}
+		rate, ok := new(big.Rat).SetString(rule.Rate.String())
+		if !ok || rate.Sign() < 0 || rate.Cmp(big.NewRat(100, 1)) > 0 {
+			return nil, fmt.Errorf("tax rule of %s: invalid rate %q, expected a percentage", rule.Region, rule.Rate)
+		}
+		if !rate.Mul(rate, big.NewRat(10000, 1)).IsInt() {
+			return nil, fmt.Errorf("tax rule of %s: rate %q has more than four decimals", rule.Region, rule.Rate)
+		}
+		if rule.Region != AnyRegion {
+			if _, err := NormalizeRegion(rule.Region); err != nil {
+				return nil, err
+			}
+		}
+		t.rules[rule.Region] = rule
+	}
+	return t, nil
+}
+
+// ReadTaxRules reads a JSON file of tax rules, such as
+//
+//	{"rules": [{"region": "US-CA", "rate": 7.25}, {"region": "DE", "rate": 19, "shipping_taxable": true}]}
+func ReadTaxRules(path string) (*TaxRules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var file struct {
+		Rules []TaxRule `json:"rules"`
+	}
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("tax rules file %s: %w", path, err)
+	}
+	rules, err := NewTaxRules(file.Rules)
+	if err != nil {
+		return nil, fmt.Errorf("tax rules file %s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// TaxRulesFromEnv reads the tax rules of the file named by TAX_RULES_FILE,
+// or of DefaultTaxRulesFile
+func TaxRulesFromEnv() (*TaxRules, error) {
+	path := os.Getenv("TAX_RULES_FILE")
+	if path == "" {
+		path = DefaultTaxRulesFile
+	}
+	return ReadTaxRules(path)
+}
+
+// NormalizeRegion returns a region upper case, or an error if it is not a
+// country or subdivision code. The empty region is left empty.
+func NormalizeRegion(r string) (string, error) {
+	r = strings.ToUpper(strings.TrimSpace(r))
+	if r != "" && !regionCode.MatchString(r) {
+		return "", fmt.Errorf("invalid region %q, expected a country code such as DE or a subdivision code such as US-CA", r)
+	}
+	return r, nil
+}
+
+// Lookup returns the rule taxing orders of a region
+func (t *TaxRules) Lookup(region string) (TaxRule, error) {
+	region = strings.ToUpper(strings.TrimSpace(region))
+	candidates := []string{region}
+	if country, _, ok := strings.Cut(region, "-"); ok {
+		candidates = append(candidates, country)
+	}
+	for _, candidate := range append(candidates, AnyRegion) {
+		if rule, ok := t.rules[candidate]; ok && candidate != "" {
+			return rule, nil
+		}
+	}
+	if region == "" {
+		return TaxRule{}, fmt.Errorf("%w: the region of the order is required", ErrNoTaxRule)
+	}
+	return TaxRule{}, fmt.Errorf("%w for region %s", ErrNoTaxRule, region)
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"go-microservices/order-service/model"
+	"go-microservices/pkg/money"
+)
+
+// ErrDuplicateCoupon is returned by CreateCoupon when a coupon with the
+// same code exists
+var ErrDuplicateCoupon = errors.New("coupon already exists")
+
+// selectCoupons selects the coupon columns read by scanCoupon. Fixed
+// amounts are stored in minor units of their currency.
+const selectCoupons = `SELECT code, type, percent_off::TEXT, amount_off_minor, currency, valid_from, valid_until,
+	max_uses, uses, created_at FROM coupons`
+
+// DBCouponRepository implements the order controller's CouponRepository
+// using the coupons table
+type DBCouponRepository struct {
+	DB *sql.DB
+}
+
+// NewDBCouponRepository creates a repository backed by the given database
+func NewDBCouponRepository(db *sql.DB) *DBCouponRepository {
+	return &DBCouponRepository{DB: db}
+}
+
+// CreateCoupon inserts a new coupon, unused
+func (r *DBCouponRepository) CreateCoupon(coupon *model.Coupon) error {
+	var percentOff sql.NullString
+	var amountOff sql.NullInt64
+	var currency sql.NullString
+	if coupon.PercentOff != "" {
+		percentOff = sql.NullString{String: coupon.PercentOff.String(), Valid: true}
+	}
+	if coupon.AmountOff.Currency() != "" {
+		amountOff = sql.NullInt64{Int64: coupon.AmountOff.Minor(), Valid: true}
+		currency = sql.NullString{String: coupon.AmountOff.Currency(), Valid: true}
+	}
+
+	coupon.Uses = 0
+	coupon.CreatedAt = time.Now()
+	err := r.DB.QueryRow(`
+		INSERT INTO coupons (code, type, percent_off, amount_off_minor, currency, valid_from, valid_until, max_uses, uses, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, 0, $9)
+		ON CONFLICT (code) DO NOTHING
+		RETURNING code`,
+		coupon.Code, coupon.Type, percentOff, amountOff, currency, coupon.ValidFrom, coupon.ValidUntil, coupon.MaxUses, coupon.CreatedAt,
+	).Scan(&coupon.Code)
+	if err == sql.ErrNoRows {
+		return ErrDuplicateCoupon
+	}
+	return err
+}
+
+// GetCoupon returns a coupon by code, or sql.ErrNoRows
+func (r *DBCouponRepository) GetCoupon(code string) (*model.Coupon, error) {
+	return scanCoupon(r.DB.QueryRow(selectCoupons+" WHERE code = $1", code))
+}
+
+// GetCoupons returns every coupon, newest first
+func (r *DBCouponRepository) GetCoupons() ([]model.Coupon, error) {
+	rows, err := r.DB.Query(selectCoupons + " ORDER BY created_at DESC, code")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	coupons := []model.Coupon{}
+	for rows.Next() {
+		coupon, err := scanCoupon(rows)
+		if err != nil {
+			return nil, err
+		}
+		coupons = append(coupons, *coupon)
+	}
+	return coupons, rows.Err()
+}
+
+// RedeemCoupon counts a use of a coupon, returning sql.ErrNoRows when it
+// does not exist or has been used up. Concurrent orders cannot use a
+// coupon more than its maximum number of uses.
+func (r *DBCouponRepository) RedeemCoupon(code string) error {
+	result, err := r.DB.Exec(
+		"UPDATE coupons SET uses = uses + 1 WHERE code = $1 AND (max_uses = 0 OR uses < max_uses)", code)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+// ReleaseCoupon gives back a use of a coupon counted by RedeemCoupon, for
+// an order that was not placed
+func (r *DBCouponRepository) ReleaseCoupon(code string) error {
+	result, err := r.DB.Exec("UPDATE coupons SET uses = uses - 1 WHERE code = $1 AND uses > 0", code)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+// scanCoupon reads the coupon columns selected by selectCoupons
+func scanCoupon(s scanner) (*model.Coupon, error) {
+	var coupon model.Coupon
+	var percentOff, currency sql.NullString
+	var amountOff sql.NullInt64
+	var validFrom, validUntil sql.NullTime
+	err := s.Scan(&coupon.Code, &coupon.Type, &percentOff, &amountOff, &currency, &validFrom, &validUntil,
+		&coupon.MaxUses, &coupon.Uses, &coupon.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if percentOff.Valid {
+		coupon.PercentOff = numeric(percentOff.String)
+	}
+	if amountOff.Valid {
+		if coupon.AmountOff, err = money.New(amountOff.Int64, currency.String); err != nil {
+			return nil, err
+		}
+	}
+	if validFrom.Valid {
+		coupon.ValidFrom = &validFrom.Time
+	}
+	if validUntil.Valid {
+		coupon.ValidUntil = &validUntil.Time
+	}
+	return &coupon, nil
+}
+
+// numeric returns the text of a NUMERIC column without the trailing zeros
+// it is read with
+func numeric(s string) json.Number {
+	if strings.Contains(s, ".") {
+		s = strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+	}
+	return json.Number(s)
+}
@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"database/sql"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-microservices/order-service/model"
+)
+
+// MemoryOrderRepository implements the order controller's OrderRepository
+// in memory, so the service can run in tests without a database
+type MemoryOrderRepository struct {
+	mu     sync.RWMutex
+	nextID int
+	orders map[int]model.Order
+}
+
+// NewMemoryOrderRepository creates an empty in-memory repository
+func NewMemoryOrderRepository() *MemoryOrderRepository {
+	return &MemoryOrderRepository{
+		nextID: 1,
+		orders: make(map[int]model.Order),
+	}
+}
+
+// InsertOrder stores a new pending order under the next free ID
+func (r *MemoryOrderRepository) InsertOrder(order *model.Order) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	order.ID = r.nextID
+	order.Status = "pending"
+	order.CreatedAt = time.Now()
+	r.nextID++
+	r.orders[order.ID] = *order
+
+	return nil
+}
+
+// GetOrderFromDB returns an order by ID
+func (r *MemoryOrderRepository) GetOrderFromDB(orderID string) (*model.Order, error) {
+	id, err := strconv.Atoi(orderID)
+	if err != nil {
+		return nil, sql.ErrNoRows
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	order, ok := r.orders[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	return &order, nil
+}
+
+// GetOrders returns every order ordered by ID
+func (r *MemoryOrderRepository) GetOrders() ([]model.Order, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var orders []model.Order
+	for _, o := range r.orders {
+		orders = append(orders, o)
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].ID < orders[j].ID })
+
+	return orders, nil
+}
+
+// UpdateOrder replaces the fields of an order, keeping its creation time
+func (r *MemoryOrderRepository) UpdateOrder(order *model.Order) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.orders[order.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	updated := *order
+	updated.CreatedAt = existing.CreatedAt
+	r.orders[order.ID] = updated
+
+	return nil
+}
+
+// UpdateOrderStatus sets the status of an order
+func (r *MemoryOrderRepository) UpdateOrderStatus(orderID int, status string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	order, ok := r.orders[orderID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	order.Status = status
+	r.orders[orderID] = order
+
+	return nil
+}
+
+// DeleteOrder removes an order
+func (r *MemoryOrderRepository) DeleteOrder(orderID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.orders[orderID]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(r.orders, orderID)
+
+	return nil
+}
+
+// MemoryCouponRepository implements the order controller's
+// CouponRepository in memory
+type MemoryCouponRepository struct {
+	mu      sync.Mutex
+	coupons map[string]model.Coupon
+}
+
+// NewMemoryCouponRepository creates an empty in-memory repository
+func NewMemoryCouponRepository() *MemoryCouponRepository {
+	return &MemoryCouponRepository{coupons: make(map[string]model.Coupon)}
+}
+
+// CreateCoupon stores a new coupon, unused
+func (r *MemoryCouponRepository) CreateCoupon(coupon *model.Coupon) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.coupons[coupon.Code]; ok {
+		return ErrDuplicateCoupon
+	}
+	coupon.Uses = 0
+	coupon.CreatedAt = time.Now()
+	r.coupons[coupon.Code] = *coupon
+
+	return nil
+}
+
+// GetCoupon returns a coupon by code
+func (r *MemoryCouponRepository) GetCoupon(code string) (*model.Coupon, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	coupon, ok := r.coupons[code]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &coupon, nil
+}
+
+// GetCoupons returns every coupon, newest first
+func (r *MemoryCouponRepository) GetCoupons() ([]model.Coupon, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	coupons := []model.Coupon{}
+	for _, coupon := range r.coupons {
+		coupons = append(coupons, coupon)
+	}
+	sort.Slice(coupons, func(i, j int) bool {
+		if !coupons[i].CreatedAt.Equal(coupons[j].CreatedAt) {
+			return coupons[i].CreatedAt.After(coupons[j].CreatedAt)
+		}
+		return coupons[i].Code < coupons[j].Code
+	})
+	return coupons, nil
+}
+
+// RedeemCoupon counts a use of a coupon unless it has been used up
+func (r *MemoryCouponRepository) RedeemCoupon(code string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	coupon, ok := r.coupons[code]
+	if !ok || (coupon.MaxUses > 0 && coupon.Uses >= coupon.MaxUses) {
+		return sql.ErrNoRows
+	}
+	coupon.Uses++
+	r.coupons[code] = coupon
+
+	return nil
+}
+
+// ReleaseCoupon gives back a use of a coupon
+func (r *MemoryCouponRepository) ReleaseCoupon(code string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	coupon, ok := r.coupons[code]
+	if !ok || coupon.Uses == 0 {
+		return sql.ErrNoRows
+	}
+	coupon.Uses--
+	r.coupons[code] = coupon
+
+	return nil
+}
@@ -0,0 +1,210 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"go-microservices/order-service/model"
+	"go-microservices/pkg/money"
+)
+
+// selectOrders selects the order columns read by scanOrder. Total prices
+// and the amounts of their breakdown are stored in minor units of their
+// currency.
+const selectOrders = `SELECT id, customer_id, product_id, quantity, total_price_minor, currency, status, created_at,
+	coupon_code, region, subtotal_minor, discount_minor, shipping_minor, tax_minor, tax_rate::TEXT,
+	base_total_price_minor, base_currency, exchange_rate::TEXT, exchange_rate_as_of FROM orders`
+
+// DBOrderRepository implements the order controller's OrderRepository
+// using the orders table
+type DBOrderRepository struct {
+	DB *sql.DB
+}
+
+// NewDBOrderRepository creates a repository backed by the given database
+func NewDBOrderRepository(db *sql.DB) *DBOrderRepository {
+	return &DBOrderRepository{DB: db}
+}
+
+// InsertOrder inserts a new order into the database
+func (r *DBOrderRepository) InsertOrder(order *model.Order) error {
+	query := `
+		INSERT INTO orders (customer_id, product_id, quantity, total_price_minor, currency, status, created_at,
+		                    coupon_code, region, subtotal_minor, discount_minor, shipping_minor, tax_minor, tax_rate,
+		                    base_total_price_minor, base_currency, exchange_rate, exchange_rate_as_of)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+		RETURNING id`
+
+	order.Status = "pending"
+	order.CreatedAt = time.Now()
+
+	args := []interface{}{
+		order.CustomerID,
+		order.ProductID,
+		order.Quantity,
+		order.TotalPrice.Minor(),
+		order.TotalPrice.Currency(),
+		order.Status,
+		order.CreatedAt,
+	}
+	return r.DB.QueryRow(query, append(args, pricingColumns(order)...)...).Scan(&order.ID)
+}
+
+// GetOrderFromDB retrieves an order from the database by ID
+func (r *DBOrderRepository) GetOrderFromDB(orderID string) (*model.Order, error) {
+	return scanOrder(r.DB.QueryRow(selectOrders+" WHERE id = $1", orderID))
+}
+
+// GetOrders retrieves every order from the database
+func (r *DBOrderRepository) GetOrders() ([]model.Order, error) {
+	rows, err := r.DB.Query(selectOrders)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []model.Order
+	for rows.Next() {
+		o, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, *o)
+	}
+
+	return orders, rows.Err()
+}
+
+// UpdateOrder replaces the fields of an order, returning sql.ErrNoRows
+// when it does not exist
+func (r *DBOrderRepository) UpdateOrder(order *model.Order) error {
+	query := `
+		UPDATE orders SET customer_id = $1, product_id = $2, quantity = $3, total_price_minor = $4, currency = $5, status = $6,
+		                  coupon_code = $7, region = $8, subtotal_minor = $9, discount_minor = $10, shipping_minor = $11,
+		                  tax_minor = $12, tax_rate = $13, base_total_price_minor = $14, base_currency = $15,
+		                  exchange_rate = $16, exchange_rate_as_of = $17
+		WHERE id = $18`
+
+	args := []interface{}{
+		order.CustomerID,
+		order.ProductID,
+		order.Quantity,
+		order.TotalPrice.Minor(),
+		order.TotalPrice.Currency(),
+		order.Status,
+	}
+	args = append(args, pricingColumns(order)...)
+	result, err := r.DB.Exec(query, append(args, order.ID)...)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(result)
+}
+
+// UpdateOrderStatus sets the status of an order, returning sql.ErrNoRows
+// when it does not exist
+func (r *DBOrderRepository) UpdateOrderStatus(orderID int, status string) error {
+	result, err := r.DB.Exec("UPDATE orders SET status = $1 WHERE id = $2", status, orderID)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(result)
+}
+
+// DeleteOrder removes an order, returning sql.ErrNoRows when it does not exist
+func (r *DBOrderRepository) DeleteOrder(orderID int) error {
+	result, err := r.DB.Exec("DELETE FROM orders WHERE id = $1", orderID)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(result)
+}
+
+// scanner is satisfied by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// pricingColumns returns the values of the coupon_code to
+// exchange_rate_as_of columns of an order. The breakdown and exchange rate
+// are stored only when the service priced the order.
+func pricingColumns(order *model.Order) []interface{} {
+	var subtotal, discount, shipping, tax, baseTotal sql.NullInt64
+	var taxRate, baseCurrency, rate sql.NullString
+	var rateAsOf sql.NullTime
+	if b := order.Breakdown; b != nil {
+		subtotal = sql.NullInt64{Int64: b.Subtotal.Minor(), Valid: true}
+		discount = sql.NullInt64{Int64: b.Discount.Minor(), Valid: true}
+		shipping = sql.NullInt64{Int64: b.Shipping.Minor(), Valid: true}
+		tax = sql.NullInt64{Int64: b.Tax.Minor(), Valid: true}
+		taxRate = sql.NullString{String: b.TaxRate.String(), Valid: true}
+	}
+	if snapshot := order.ExchangeRate; snapshot != nil {
+		baseTotal = sql.NullInt64{Int64: snapshot.BaseTotal.Minor(), Valid: true}
+		baseCurrency = sql.NullString{String: snapshot.BaseTotal.Currency(), Valid: true}
+		rate = sql.NullString{String: snapshot.Rate.String(), Valid: true}
+		rateAsOf = sql.NullTime{Time: snapshot.AsOf, Valid: true}
+	}
+
+	return []interface{}{
+		sql.NullString{String: order.CouponCode, Valid: order.CouponCode != ""},
+		sql.NullString{String: order.Region, Valid: order.Region != ""},
+		subtotal, discount, shipping, tax, taxRate,
+		baseTotal, baseCurrency, rate, rateAsOf,
+	}
+}
+
+// scanOrder reads the order columns selected by selectOrders
+func scanOrder(s scanner) (*model.Order, error) {
+	var order model.Order
+	var totalPrice int64
+	var currency string
+	var couponCode, region, taxRate sql.NullString
+	var subtotal, discount, shipping, tax sql.NullInt64
+	var baseTotal sql.NullInt64
+	var baseCurrency, rate sql.NullString
+	var rateAsOf sql.NullTime
+	err := s.Scan(&order.ID, &order.CustomerID, &order.ProductID, &order.Quantity, &totalPrice, &currency,
+		&order.Status, &order.CreatedAt, &couponCode, &region, &subtotal, &discount, &shipping, &tax, &taxRate,
+		&baseTotal, &baseCurrency, &rate, &rateAsOf)
+	if err != nil {
+		return nil, err
+	}
+	if order.TotalPrice, err = money.New(totalPrice, currency); err != nil {
+		return nil, err
+	}
+	order.CouponCode, order.Region = couponCode.String, region.String
+	if subtotal.Valid {
+		order.Breakdown = &model.PriceBreakdown{
+			Subtotal: money.MustNew(subtotal.Int64, currency),
+			Discount: money.MustNew(discount.Int64, currency),
+			Shipping: money.MustNew(shipping.Int64, currency),
+			Tax:      money.MustNew(tax.Int64, currency),
+			Total:    order.TotalPrice,
+			TaxRate:  numeric(taxRate.String),
+		}
+	}
+	if rate.Valid {
+		order.ExchangeRate = &model.ExchangeRate{Rate: numeric(rate.String), AsOf: rateAsOf.Time}
+		if order.ExchangeRate.BaseTotal, err = money.New(baseTotal.Int64, baseCurrency.String); err != nil {
+			return nil, err
+		}
+	}
+
+	return &order, nil
+}
+
+// checkAffected returns sql.ErrNoRows when a statement touched no rows
+func checkAffected(result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -1,14 +1,12 @@
 package service
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
+	"context"
 	"net/http"
 	"os"
 	"time"
 
-	"go-microservices/order-service/model"
+	inventoryapi "go-microservices/inventory-service/api"
 	"go-microservices/order-service/resilience"
 
 	"github.com/sony/gobreaker"
@@ -16,9 +14,8 @@ import (
 
 // InventoryService is a client for the inventory service
 type InventoryService struct {
-	BaseURL    string
-	HTTPClient *http.Client
-	cb         *gobreaker.CircuitBreaker
+	client *inventoryapi.Client
+	cb     *gobreaker.CircuitBreaker
 }
 
 // NewInventoryService creates a new inventory service client
@@ -33,89 +30,38 @@ func NewInventoryService() *InventoryService {
 	cb := resilience.NewCircuitBreaker(cbConfig)
 
 	return &InventoryService{
-		BaseURL: baseURL,
-		HTTPClient: &http.Client{
+		client: inventoryapi.NewClient(baseURL, &http.Client{
 			Timeout: time.Second * 10,
-		},
+		}),
 		cb: cb,
 	}
 }
 
 // CheckInventory checks if a product is available in inventory
-func (is *InventoryService) CheckInventory(productID int, quantity int) (*model.InventoryResponse, error) {
-	data := model.InventoryCheck{
+func (is *InventoryService) CheckInventory(productID int, quantity int) (*inventoryapi.InventoryResponse, error) {
+	check := inventoryapi.InventoryCheck{
 		ProductID: productID,
 		Quantity:  quantity,
 	}
 
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal inventory check: %w", err)
-	}
-
 	// Use circuit breaker with retry
 	result, err := resilience.ExecuteWithRetry(is.cb, func() (interface{}, error) {
-		req, err := http.NewRequest("POST", fmt.Sprintf("%s/inventory/check", is.BaseURL), bytes.NewBuffer(jsonData))
-		if err != nil {
-			return nil, fmt.Errorf("failed to create request: %w", err)
-		}
-		req.Header.Set("Content-Type", "application/json")
-
-		resp, err := is.HTTPClient.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("inventory service request failed: %w", err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("inventory service returned non-OK status: %d", resp.StatusCode)
-		}
-
-		var inventoryResponse model.InventoryResponse
-		if err := json.NewDecoder(resp.Body).Decode(&inventoryResponse); err != nil {
-			return nil, fmt.Errorf("failed to decode inventory response: %w", err)
-		}
-
-		return &inventoryResponse, nil
+		return is.client.CheckInventory(context.Background(), check)
 	}, 3) // Maximum 3 retries
 
 	if err != nil {
 		return nil, err
 	}
 
-	return result.(*model.InventoryResponse), nil
+	return result.(*inventoryapi.InventoryResponse), nil
 }
 
-func (s *InventoryService) CheckAvailability(productID int, quantity int) (bool, error) {
-	url := fmt.Sprintf("%s/check/%d?quantity=%d",
-		s.BaseURL,
-		productID,
-		quantity)
-
-	result, err := s.cb.Execute(func() (interface{}, error) {
-		resp, err := s.HTTPClient.Get(url)
-		if err != nil {
-			return nil, err
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("inventory service returned status: %d", resp.StatusCode)
-		}
-
-		var response struct {
-			Available bool `json:"available"`
-		}
-		if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-			return nil, err
-		}
-
-		return response.Available, nil
-	})
-
+// CheckAvailability reports whether quantity units of a product are in stock
+func (is *InventoryService) CheckAvailability(productID int, quantity int) (bool, error) {
+	resp, err := is.CheckInventory(productID, quantity)
 	if err != nil {
 		return false, err
 	}
 
-	return result.(bool), nil
+	return resp.Available, nil
 }
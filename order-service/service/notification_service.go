@@ -1,14 +1,13 @@
 package service
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
 	"net/http"
 	"os"
 	"time"
 
-	"go-microservices/order-service/model"
+	notificationapi "go-microservices/notification-service/api"
 	"go-microservices/order-service/resilience"
 
 	"github.com/sony/gobreaker"
@@ -16,9 +15,8 @@ import (
 
 // NotificationService is a client for the notification service
 type NotificationService struct {
-	BaseURL    string
-	HTTPClient *http.Client
-	cb         *gobreaker.CircuitBreaker
+	client *notificationapi.Client
+	cb     *gobreaker.CircuitBreaker
 }
 
 // NewNotificationService creates a new notification service client
@@ -33,49 +31,24 @@ func NewNotificationService() *NotificationService {
 	cb := resilience.NewCircuitBreaker(cbConfig)
 
 	return &NotificationService{
-		BaseURL: baseURL,
-		HTTPClient: &http.Client{
+		client: notificationapi.NewClient(baseURL, &http.Client{
 			Timeout: time.Second * 10,
-		},
+		}),
 		cb: cb,
 	}
 }
 
-// SendOrderNotification sends an order notification to the notification service
-func (ns *NotificationService) SendOrderNotification(orderID int) error {
-	url := fmt.Sprintf("%s/notify/order/%d", ns.BaseURL, orderID)
-
-	notification := struct {
-		OrderID int    `json:"order_id"`
-		Type    string `json:"type"`
-	}{
-		OrderID: orderID,
-		Type:    "order_created",
-	}
-
-	body, err := json.Marshal(notification)
-	if err != nil {
-		return err
+// SendOrderNotification tells the customer that their order was created
+func (ns *NotificationService) SendOrderNotification(orderID int, customerID int) error {
+	notification := notificationapi.Notification{
+		OrderID:    orderID,
+		CustomerID: customerID,
+		Message:    fmt.Sprintf("Your order #%d has been created", orderID),
+		Status:     "pending",
 	}
 
-	_, err = ns.cb.Execute(func() (interface{}, error) {
-		req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
-		if err != nil {
-			return nil, fmt.Errorf("failed to create request: %w", err)
-		}
-		req.Header.Set("Content-Type", "application/json")
-
-		resp, err := ns.HTTPClient.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("notification service request failed: %w", err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("notification service returned status: %d", resp.StatusCode)
-		}
-
-		return nil, nil
+	_, err := ns.cb.Execute(func() (interface{}, error) {
+		return ns.client.CreateNotification(context.Background(), notification)
 	})
 
 	return err
@@ -83,36 +56,15 @@ func (ns *NotificationService) SendOrderNotification(orderID int) error {
 
 // SendOrderStatusUpdate sends an order status update to the notification service
 func (ns *NotificationService) SendOrderStatusUpdate(orderID int, customerID int, status string) error {
-	data := model.OrderStatusUpdate{
+	update := notificationapi.OrderStatusUpdate{
 		OrderID:    orderID,
 		CustomerID: customerID,
 		Status:     status,
 	}
 
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		return fmt.Errorf("failed to marshal order status update: %w", err)
-	}
-
 	// Use circuit breaker with retry
-	_, err = resilience.ExecuteWithRetry(ns.cb, func() (interface{}, error) {
-		req, err := http.NewRequest("POST", fmt.Sprintf("%s/notify/status", ns.BaseURL), bytes.NewBuffer(jsonData))
-		if err != nil {
-			return nil, fmt.Errorf("failed to create request: %w", err)
-		}
-		req.Header.Set("Content-Type", "application/json")
-
-		resp, err := ns.HTTPClient.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("notification service request failed: %w", err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("notification service returned status: %d", resp.StatusCode)
-		}
-
-		return nil, nil
+	_, err := resilience.ExecuteWithRetry(ns.cb, func() (interface{}, error) {
+		return ns.client.ProcessOrderStatusUpdate(context.Background(), update)
 	}, 3) // Maximum 3 retries
 
 	return err
@@ -1,53 +1,29 @@
 package service
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
 	"net/http"
 	"os"
 	"time"
 
+	paymentapi "go-microservices/payment-service/api"
+	"go-microservices/pkg/actor"
+	"go-microservices/pkg/money"
+
 	"github.com/sony/gobreaker"
 )
 
 // PaymentService handles payment-related operations
 type PaymentService struct {
-	baseURL       string
-	client        *http.Client
+	client         *paymentapi.Client
 	circuitBreaker *gobreaker.CircuitBreaker
 }
 
-// PaymentRequest represents a payment creation request
-type PaymentRequest struct {
-	OrderID    int     `json:"order_id"`
-	CustomerID int     `json:"customer_id"`
-	Amount     float64 `json:"amount"`
-	Currency   string  `json:"currency"`
-}
-
-// PaymentResponse represents a payment response
-type PaymentResponse struct {
-	Payment struct {
-		ID                int     `json:"id"`
-		OrderID           int     `json:"order_id"`
-		CustomerID        int     `json:"customer_id"`
-		Amount            float64 `json:"amount"`
-		Currency          string  `json:"currency"`
-		Status            string  `json:"status"`
-		StripePaymentID   string  `json:"stripe_payment_id"`
-		PaymentMethod     string  `json:"payment_method"`
-		CreatedAt         string  `json:"created_at"`
-		UpdatedAt         string  `json:"updated_at"`
-	} `json:"payment"`
-	ClientSecret string `json:"client_secret,omitempty"`
-	Message      string `json:"message,omitempty"`
-}
-
 // NewPaymentService creates a new payment service instance
 func NewPaymentService() *PaymentService {
 	baseURL := getEnv("PAYMENT_SERVICE_URL", "http://payment-service:8084")
-	
+
 	// Circuit breaker settings
 	settings := gobreaker.Settings{
 		Name:        "PaymentService",
@@ -63,104 +39,90 @@ func NewPaymentService() *PaymentService {
 	}
 
 	return &PaymentService{
-		baseURL: baseURL,
-		client: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+		client: paymentapi.NewClient(baseURL, &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: actorTransport{name: "order-service", secret: actor.SecretFromEnv(), next: http.DefaultTransport},
+		}),
 		circuitBreaker: gobreaker.NewCircuitBreaker(settings),
 	}
 }
 
 // CreatePayment creates a payment intent for an order
-func (ps *PaymentService) CreatePayment(orderID, customerID int, amount float64, currency string) (*PaymentResponse, error) {
-	paymentReq := PaymentRequest{
+func (ps *PaymentService) CreatePayment(orderID, customerID int, amount money.Money) (*paymentapi.PaymentResponse, error) {
+	paymentReq := paymentapi.PaymentRequest{
 		OrderID:    orderID,
 		CustomerID: customerID,
-		Amount:     amount,
-		Currency:   currency,
+		// The float64 nearest the decimal amount is encoded as that decimal
+		Amount:   amount.Float64(),
+		Currency: amount.Currency(),
 	}
 
-	jsonData, err := json.Marshal(paymentReq)
+	result, err := ps.circuitBreaker.Execute(func() (interface{}, error) {
+		return ps.client.CreatePayment(context.Background(), paymentReq)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal payment request: %w", err)
+		return nil, fmt.Errorf("payment service circuit breaker: %w", err)
 	}
 
-	result, err := ps.circuitBreaker.Execute(func() (interface{}, error) {
-		req, err := http.NewRequest("POST", ps.baseURL+"/payments", bytes.NewBuffer(jsonData))
-		if err != nil {
-			return nil, fmt.Errorf("failed to create request: %w", err)
-		}
-
-		req.Header.Set("Content-Type", "application/json")
-
-		resp, err := ps.client.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("failed to make request: %w", err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusCreated {
-			return nil, fmt.Errorf("payment service returned status: %d", resp.StatusCode)
-		}
-
-		var paymentResp PaymentResponse
-		if err := json.NewDecoder(resp.Body).Decode(&paymentResp); err != nil {
-			return nil, fmt.Errorf("failed to decode response: %w", err)
-		}
+	return result.(*paymentapi.PaymentResponse), nil
+}
 
-		return &paymentResp, nil
+// GetPaymentsByOrder retrieves payments for a specific order
+func (ps *PaymentService) GetPaymentsByOrder(orderID int) ([]paymentapi.Payment, error) {
+	result, err := ps.circuitBreaker.Execute(func() (interface{}, error) {
+		return ps.client.GetPaymentsByOrder(context.Background(), orderID)
 	})
-
 	if err != nil {
 		return nil, fmt.Errorf("payment service circuit breaker: %w", err)
 	}
 
-	paymentResp, ok := result.(*PaymentResponse)
-	if !ok {
-		return nil, fmt.Errorf("unexpected response type from payment service")
-	}
-
-	return paymentResp, nil
+	return result.([]paymentapi.Payment), nil
 }
 
-// GetPaymentsByOrder retrieves payments for a specific order
-func (ps *PaymentService) GetPaymentsByOrder(orderID int) ([]PaymentResponse, error) {
-	url := fmt.Sprintf("%s/payments/order/%d", ps.baseURL, orderID)
+// RefundOrder refunds the rest of every captured payment of an order and
+// returns the refunds made. Payments that were not captured or are already
+// refunded in full are skipped.
+func (ps *PaymentService) RefundOrder(orderID int) ([]paymentapi.Refund, error) {
+	payments, err := ps.GetPaymentsByOrder(orderID)
+	if err != nil {
+		return nil, err
+	}
 
-	result, err := ps.circuitBreaker.Execute(func() (interface{}, error) {
-		req, err := http.NewRequest("GET", url, nil)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create request: %w", err)
+	var refunds []paymentapi.Refund
+	for _, payment := range payments {
+		if payment.Status != paymentapi.PaymentStatusSucceeded && payment.Status != paymentapi.PaymentStatusPartiallyRefunded {
+			continue
 		}
 
-		resp, err := ps.client.Do(req)
+		paymentID := payment.ID
+		result, err := ps.circuitBreaker.Execute(func() (interface{}, error) {
+			return ps.client.CreateRefund(context.Background(), paymentID, paymentapi.RefundRequest{
+				Reason: paymentapi.RefundReasonRequestedByCustomer,
+			})
+		})
 		if err != nil {
-			return nil, fmt.Errorf("failed to make request: %w", err)
+			return refunds, fmt.Errorf("payment service circuit breaker: %w", err)
 		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("payment service returned status: %d", resp.StatusCode)
-		}
-
-		var payments []PaymentResponse
-		if err := json.NewDecoder(resp.Body).Decode(&payments); err != nil {
-			return nil, fmt.Errorf("failed to decode response: %w", err)
-		}
-
-		return payments, nil
-	})
-
-	if err != nil {
-		return nil, fmt.Errorf("payment service circuit breaker: %w", err)
+		refunds = append(refunds, result.(*paymentapi.RefundResponse).Refund)
 	}
 
-	payments, ok := result.([]PaymentResponse)
-	if !ok {
-		return nil, fmt.Errorf("unexpected response type from payment service")
-	}
+	return refunds, nil
+}
 
-	return payments, nil
+// actorTransport names order-service in the X-Actor headers of its
+// requests, signed with the shared secret, which payment-service records
+// in the access log of the payments it reads
+type actorTransport struct {
+	name   string
+	secret []byte
+	next   http.RoundTripper
+}
+
+// RoundTrip sends a copy of req with the X-Actor headers
+func (t actorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	actor.Set(req.Header, t.secret, t.name)
+	return t.next.RoundTrip(req)
 }
 
 // getEnv gets an environment variable or returns a default value
@@ -170,4 +132,4 @@ func getEnv(key, defaultValue string) string {
 		return defaultValue
 	}
 	return value
-}
\ No newline at end of file
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"go-microservices/order-service/cache"
+	"go-microservices/order-service/resilience"
+	"go-microservices/pkg/money"
+	productapi "go-microservices/product-service/api"
+
+	"github.com/sony/gobreaker"
+)
+
+// ProductService is a client for the product service
+type ProductService struct {
+	client *productapi.Client
+	cb     *gobreaker.CircuitBreaker
+}
+
+// NewProductService creates a new product service client
+func NewProductService() *ProductService {
+	baseURL := os.Getenv("PRODUCT_SERVICE_URL")
+	if baseURL == "" {
+		baseURL = "http://product-service:8080" // Docker default
+	}
+
+	// Create circuit breaker
+	cbConfig := resilience.DefaultConfig("product-service")
+	cb := resilience.NewCircuitBreaker(cbConfig)
+
+	return &ProductService{
+		client: productapi.NewClient(baseURL, &http.Client{
+			Timeout: time.Second * 10,
+		}),
+		cb: cb,
+	}
+}
+
+// GetProductPrice fetches the unit price of a product, cached for
+// cache.ProductPriceTTL
+func (ps *ProductService) GetProductPrice(productID int) (money.Money, error) {
+	var price money.Money
+	err := cache.GetOrSet(productPriceCacheKey(productID), &price, cache.ProductPriceTTL(), func() (interface{}, error) {
+		result, err := ps.cb.Execute(func() (interface{}, error) {
+			return ps.client.GetProduct(context.Background(), productID)
+		})
+		if err != nil {
+			return nil, err
+		}
+		product := result.(*productapi.Product)
+		currency := product.Currency
+		if currency == "" {
+			currency = money.DefaultCurrency
+		}
+		return money.FromMajor(product.Price, currency)
+	})
+	if err != nil {
+		return money.Money{}, err
+	}
+
+	return price, nil
+}
+
+// productPriceCacheKey returns the cache key of a product's price. Prices
+// are cached with their currency, under a key older bare prices do not use.
+func productPriceCacheKey(productID int) string {
+	return "product:" + strconv.Itoa(productID) + ":unit-price"
+}
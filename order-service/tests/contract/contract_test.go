@@ -0,0 +1,7 @@
+package contract
+
+// contractsDir is where order-service publishes the contracts with its providers
+const contractsDir = "../../../contracts"
+
+// consumer is the name order-service signs its contracts with
+const consumer = "order-service"
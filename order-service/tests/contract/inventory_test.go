@@ -0,0 +1,60 @@
+package contract
+
+import (
+	"testing"
+
+	"go-microservices/order-service/service"
+	"go-microservices/pkg/contract"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInventoryServiceContract(t *testing.T) {
+	c := contract.Contract{
+		Consumer: consumer,
+		Provider: "inventory-service",
+		Interactions: []contract.Interaction{
+			{
+				Description: "a stock check for an available quantity",
+				State:       "product 1 has 10 units in stock",
+				Request: contract.Request{
+					Method: "POST",
+					Path:   "/inventory/check",
+					Body:   contract.Body(map[string]interface{}{"product_id": 1, "quantity": 2}),
+				},
+				Response: contract.Response{
+					Status: 200,
+					Body:   contract.Body(map[string]interface{}{"available": true}),
+				},
+			},
+			{
+				Description: "a stock check exceeding the stock",
+				State:       "product 1 has 10 units in stock",
+				Request: contract.Request{
+					Method: "POST",
+					Path:   "/inventory/check",
+					Body:   contract.Body(map[string]interface{}{"product_id": 1, "quantity": 50}),
+				},
+				Response: contract.Response{
+					Status: 200,
+					Body:   contract.Body(map[string]interface{}{"available": false, "message": "Not enough inventory"}),
+				},
+			},
+		},
+	}
+
+	provider := contract.NewMockProvider(t, c)
+	t.Setenv("INVENTORY_SERVICE_URL", provider.URL)
+	inventory := service.NewInventoryService()
+
+	available, err := inventory.CheckAvailability(1, 2)
+	require.NoError(t, err)
+	assert.True(t, available)
+
+	available, err = inventory.CheckAvailability(1, 50)
+	require.NoError(t, err)
+	assert.False(t, available)
+
+	contract.Record(t, c, contractsDir)
+}
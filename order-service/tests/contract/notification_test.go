@@ -0,0 +1,70 @@
+package contract
+
+import (
+	"testing"
+
+	"go-microservices/order-service/service"
+	"go-microservices/pkg/contract"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotificationServiceContract(t *testing.T) {
+	c := contract.Contract{
+		Consumer: consumer,
+		Provider: "notification-service",
+		Interactions: []contract.Interaction{
+			{
+				Description: "an order created notification",
+				Request: contract.Request{
+					Method: "POST",
+					Path:   "/notifications",
+					Body: contract.Body(map[string]interface{}{
+						"order_id":    1,
+						"customer_id": 1,
+						"message":     "Your order #1 has been created",
+						"status":      "pending",
+					}),
+				},
+				Response: contract.Response{
+					Status: 201,
+					Body: contract.Body(map[string]interface{}{
+						"id":          1,
+						"order_id":    1,
+						"customer_id": 1,
+						"message":     "Your order #1 has been created",
+						"status":      "pending",
+					}),
+				},
+			},
+			{
+				Description: "an order status update",
+				Request: contract.Request{
+					Method: "POST",
+					Path:   "/notifications/order-status",
+					Body: contract.Body(map[string]interface{}{
+						"order_id":    1,
+						"customer_id": 1,
+						"status":      "shipped",
+					}),
+				},
+				Response: contract.Response{
+					Status: 200,
+					Body: contract.Body(map[string]interface{}{
+						"message":         "Order status notification created",
+						"notification_id": 1,
+					}),
+				},
+			},
+		},
+	}
+
+	provider := contract.NewMockProvider(t, c)
+	t.Setenv("NOTIFICATION_SERVICE_URL", provider.URL)
+	notifications := service.NewNotificationService()
+
+	assert.NoError(t, notifications.SendOrderNotification(1, 1))
+	assert.NoError(t, notifications.SendOrderStatusUpdate(1, 1, "shipped"))
+
+	contract.Record(t, c, contractsDir)
+}
@@ -0,0 +1,150 @@
+package contract
+
+import (
+	"testing"
+
+	"go-microservices/order-service/service"
+	"go-microservices/pkg/contract"
+	"go-microservices/pkg/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPaymentServiceContract(t *testing.T) {
+	payment := map[string]interface{}{
+		"id":                1,
+		"order_id":          1,
+		"customer_id":       1,
+		"amount":            39.98,
+		"currency":          "USD",
+		"status":            "pending",
+		"stripe_payment_id": "pi_123",
+		"created_at":        "2024-01-01T00:00:00Z",
+		"updated_at":        "2024-01-01T00:00:00Z",
+	}
+	paid := map[string]interface{}{}
+	for k, v := range payment {
+		paid[k] = v
+	}
+	paid["status"] = "succeeded"
+	refunded := map[string]interface{}{}
+	for k, v := range paid {
+		refunded[k] = v
+	}
+	refunded["status"] = "refunded"
+	refunded["amount_refunded"] = 39.98
+
+	// Cancelling a paid order refunds its payments
+	refundInteractions := []contract.Interaction{
+		{
+			Description: "a request for the payments of a paid order",
+			State:       "order 1 has a succeeded payment",
+			Request: contract.Request{
+				Method: "GET",
+				Path:   "/payments/order/1",
+			},
+			Response: contract.Response{
+				Status:     200,
+				Body:       contract.Body([]interface{}{paid}),
+				MatchTypes: []string{"$[*].created_at", "$[*].updated_at"},
+			},
+		},
+		{
+			Description: "a refund of the rest of a payment",
+			State:       "order 1 has a succeeded payment",
+			Request: contract.Request{
+				Method: "POST",
+				Path:   "/payments/1/refunds",
+				Body:   contract.Body(map[string]interface{}{"reason": "requested_by_customer"}),
+			},
+			Response: contract.Response{
+				Status: 201,
+				Body: contract.Body(map[string]interface{}{
+					"refund": map[string]interface{}{
+						"payment_id": 1,
+						"amount":     39.98,
+						"currency":   "USD",
+						"reason":     "requested_by_customer",
+						"status":     "succeeded",
+					},
+					"payment": refunded,
+				}),
+				MatchTypes: []string{
+					"$.payment.created_at", "$.payment.updated_at",
+				},
+			},
+		},
+	}
+
+	c := contract.Contract{
+		Consumer: consumer,
+		Provider: "payment-service",
+		Interactions: []contract.Interaction{
+			{
+				Description: "a payment intent for an order",
+				State:       "stripe accepts payment intents",
+				Request: contract.Request{
+					Method: "POST",
+					Path:   "/payments",
+					Body: contract.Body(map[string]interface{}{
+						"order_id":    1,
+						"customer_id": 1,
+						"amount":      39.98,
+						"currency":    "USD",
+					}),
+				},
+				Response: contract.Response{
+					Status: 201,
+					Body: contract.Body(map[string]interface{}{
+						"payment":       payment,
+						"client_secret": "pi_123_secret_456",
+						"message":       "Payment intent created successfully",
+					}),
+					MatchTypes: []string{"$.payment.created_at", "$.payment.updated_at"},
+				},
+			},
+			{
+				Description: "a request for the payments of an order",
+				State:       "order 1 has a payment",
+				Request: contract.Request{
+					Method: "GET",
+					Path:   "/payments/order/1",
+				},
+				Response: contract.Response{
+					Status:     200,
+					Body:       contract.Body([]interface{}{payment}),
+					MatchTypes: []string{"$[*].created_at", "$[*].updated_at"},
+				},
+			},
+		},
+	}
+
+	provider := contract.NewMockProvider(t, c)
+	t.Setenv("PAYMENT_SERVICE_URL", provider.URL)
+	payments := service.NewPaymentService()
+
+	resp, err := payments.CreatePayment(1, 1, money.MustNew(3998, "USD"))
+	require.NoError(t, err)
+	assert.Equal(t, "pi_123_secret_456", resp.ClientSecret)
+	assert.Equal(t, "pi_123", resp.Payment.StripePaymentID)
+
+	list, err := payments.GetPaymentsByOrder(1)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, 1, list[0].OrderID)
+
+	refundProvider := contract.NewMockProvider(t, contract.Contract{
+		Consumer:     consumer,
+		Provider:     "payment-service",
+		Interactions: refundInteractions,
+	})
+	t.Setenv("PAYMENT_SERVICE_URL", refundProvider.URL)
+	refunds, err := service.NewPaymentService().RefundOrder(1)
+	require.NoError(t, err)
+	require.Len(t, refunds, 1)
+	assert.Equal(t, 39.98, refunds[0].Amount)
+
+	c.Interactions = append(c.Interactions, refundInteractions...)
+	contract.Record(t, c, contractsDir)
+}
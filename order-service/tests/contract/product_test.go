@@ -0,0 +1,58 @@
+package contract
+
+import (
+	"testing"
+
+	"go-microservices/order-service/service"
+	"go-microservices/pkg/contract"
+	"go-microservices/pkg/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductServiceContract(t *testing.T) {
+	c := contract.Contract{
+		Consumer: consumer,
+		Provider: "product-service",
+		Interactions: []contract.Interaction{
+			{
+				Description: "a request for an existing product",
+				State:       "product 1 exists",
+				Request: contract.Request{
+					Method: "GET",
+					Path:   "/products/1",
+				},
+				Response: contract.Response{
+					Status: 200,
+					Body:   contract.Body(map[string]interface{}{"id": 1, "name": "Laptop", "price": 19.99}),
+				},
+			},
+			{
+				Description: "a request for a missing product",
+				State:       "product 99 does not exist",
+				Request: contract.Request{
+					Method: "GET",
+					Path:   "/products/99",
+				},
+				Response: contract.Response{
+					Status: 404,
+					Body:   contract.Body(map[string]interface{}{"error": "Product not found"}),
+				},
+			},
+		},
+	}
+
+	provider := contract.NewMockProvider(t, c)
+	t.Setenv("PRODUCT_SERVICE_URL", provider.URL)
+	products := service.NewProductService()
+
+	price, err := products.GetProductPrice(1)
+	require.NoError(t, err)
+	assert.Equal(t, money.MustNew(1999, "USD"), price)
+
+	_, err = products.GetProductPrice(99)
+	assert.Error(t, err)
+
+	contract.Record(t, c, contractsDir)
+}
@@ -0,0 +1,344 @@
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"go-microservices/order-service/cache"
+	"go-microservices/order-service/model"
+	"go-microservices/pkg/broker"
+	"go-microservices/pkg/events"
+	"go-microservices/pkg/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stripe/stripe-go/v76/webhook"
+)
+
+// do sends a JSON request to the order-service router
+func (env *environment) do(method, path string, body interface{}) *httptest.ResponseRecorder {
+	var reader *bytes.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	env.Router.ServeHTTP(w, req)
+	return w
+}
+
+func TestOrderLifecycle(t *testing.T) {
+	env := setupEnvironment(t)
+
+	published := make(chan []byte, 2)
+	_, err := env.Broker.Subscribe(broker.Subscription{
+		Name:     "e2e",
+		Bindings: []broker.Binding{{Topic: events.TopicOrders, Pattern: "order.#"}},
+	}, func(_ context.Context, body []byte) error {
+		published <- body
+		return nil
+	})
+	require.NoError(t, err)
+
+	// Create the order; the price comes from product-service
+	w := env.do("POST", "/orders", model.Order{CustomerID: 7, ProductID: 1, Quantity: 2})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var created model.Order
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, 1, created.ID)
+	assert.Equal(t, "pending", created.Status)
+	assert.Equal(t, money.MustNew(3998, "USD"), created.TotalPrice)
+
+	select {
+	case body := <-published:
+		// The event is a versioned envelope, encoded once
+		envelope, event, err := events.Decode(body)
+		require.NoError(t, err)
+		assert.Equal(t, events.TypeOrderCreated, envelope.Type)
+		assert.Equal(t, "order-service", envelope.Source)
+		assert.Equal(t, &events.OrderCreated{OrderID: 1, CustomerID: 7, ProductID: 1, Quantity: 2, TotalPrice: money.MustNew(3998, "USD"), Status: "pending"}, event)
+	case <-time.After(time.Second):
+		t.Fatal("order created event was not published")
+	}
+
+	// notification-service is told asynchronously, through the event
+	assert.Eventually(t, func() bool {
+		notifications, _ := env.Notifications.GetByCustomer(7)
+		return len(notifications) == 1 && notifications[0].EventID != ""
+	}, time.Second, 10*time.Millisecond)
+
+	// Reads go through the cache
+	w = env.do("GET", "/orders/1", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var cached model.Order
+	require.NoError(t, env.Cache.Get("order:1", &cached))
+	assert.Equal(t, created.ID, cached.ID)
+
+	// Status changes are stored and notified
+	w = env.do("PATCH", "/orders/1/status", map[string]string{"status": "shipped"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	stored, err := env.Orders.GetOrderFromDB("1")
+	require.NoError(t, err)
+	assert.Equal(t, "shipped", stored.Status)
+
+	// The write invalidated the cached order, so reads see the new status
+	assert.ErrorIs(t, env.Cache.Get("order:1", &cached), cache.ErrCacheMiss)
+	w = env.do("GET", "/orders/1", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &cached))
+	assert.Equal(t, "shipped", cached.Status)
+	assert.Eventually(t, func() bool {
+		notifications, _ := env.Notifications.GetByCustomer(7)
+		return len(notifications) == 2 && notifications[1].Status == "shipped"
+	}, time.Second, 10*time.Millisecond)
+
+	select {
+	case body := <-published:
+		_, event, err := events.Decode(body)
+		require.NoError(t, err)
+		assert.Equal(t, &events.OrderStatusChanged{OrderID: 1, CustomerID: 7, PreviousStatus: "pending", Status: "shipped"}, event)
+	case <-time.After(time.Second):
+		t.Fatal("order status changed event was not published")
+	}
+
+	w = env.do("GET", "/orders", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var orders []model.Order
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &orders))
+	assert.Len(t, orders, 1)
+
+	w = env.do("DELETE", "/orders/1", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	_, err = env.Orders.GetOrderFromDB("1")
+	assert.Error(t, err)
+}
+
+func TestCreateOrder_OutOfStock(t *testing.T) {
+	env := setupEnvironment(t)
+
+	w := env.do("POST", "/orders", model.Order{CustomerID: 7, ProductID: 1, Quantity: 50})
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	orders, _ := env.Orders.GetOrders()
+	assert.Empty(t, orders)
+}
+
+func TestCreateOrderWithPayment(t *testing.T) {
+	env := setupEnvironment(t)
+
+	w := env.do("POST", "/orders/with-payment", map[string]interface{}{
+		"customer_id": 7,
+		"product_id":  1,
+		"quantity":    2,
+		"currency":    "usd",
+	})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var resp struct {
+		Order   model.Order `json:"order"`
+		Payment struct {
+			ClientSecret string `json:"client_secret"`
+		} `json:"payment"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "pi_e2e_secret", resp.Payment.ClientSecret)
+
+	payments, err := env.Payments.GetByOrder(resp.Order.ID)
+	require.NoError(t, err)
+	require.Len(t, payments, 1)
+	assert.Equal(t, money.MustNew(3998, "USD"), payments[0].Amount)
+	assert.Equal(t, "pi_e2e", payments[0].StripePaymentID)
+}
+
+func TestCreateOrderWithPayment_CouponAndTax(t *testing.T) {
+	env := setupEnvironment(t)
+
+	w := env.do("POST", "/coupons", map[string]interface{}{"code": "spring10", "type": "percentage", "percent_off": 10, "max_uses": 1})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	// The total given by the client is ignored
+	order := map[string]interface{}{
+		"customer_id": 7,
+		"product_id":  1,
+		"quantity":    2,
+		"currency":    "USD",
+		"total_price": 0.01,
+		"coupon_code": "SPRING10",
+		"region":      "us-ca",
+	}
+	w = env.do("POST", "/orders/with-payment", order)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var resp struct {
+		Order model.Order `json:"order"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "US-CA", resp.Order.Region)
+	assert.Equal(t, &model.PriceBreakdown{
+		Subtotal: money.MustNew(3998, "USD"),
+		Discount: money.MustNew(400, "USD"),
+		Shipping: money.MustNew(0, "USD"),
+		Tax:      money.MustNew(261, "USD"), // 7.25% of 35.98
+		Total:    money.MustNew(3859, "USD"),
+		TaxRate:  "7.25",
+	}, resp.Order.Breakdown)
+
+	// The total is charged and stored with its breakdown
+	payments, err := env.Payments.GetByOrder(resp.Order.ID)
+	require.NoError(t, err)
+	require.Len(t, payments, 1)
+	assert.Equal(t, money.MustNew(3859, "USD"), payments[0].Amount)
+	stored, err := env.Orders.GetOrderFromDB(strconv.Itoa(resp.Order.ID))
+	require.NoError(t, err)
+	assert.Equal(t, resp.Order.Breakdown, stored.Breakdown)
+
+	// The coupon could be used once
+	coupon, err := env.Coupons.GetCoupon("SPRING10")
+	require.NoError(t, err)
+	assert.Equal(t, 1, coupon.Uses)
+	w = env.do("POST", "/orders/with-payment", order)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "coupon has been used up")
+}
+
+// sendStripeEvent posts a Stripe event signed with the webhook secret to
+// payment-service
+func (env *environment) sendStripeEvent(t *testing.T, event map[string]interface{}) *http.Response {
+	payload, err := json.Marshal(event)
+	require.NoError(t, err)
+	signed := webhook.GenerateTestSignedPayload(&webhook.UnsignedPayload{Payload: payload, Secret: webhookSecret})
+
+	req, err := http.NewRequest("POST", env.PaymentURL+"/payments/webhook", bytes.NewReader(payload))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Stripe-Signature", signed.Header)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestPaymentWebhookAdvancesOrder(t *testing.T) {
+	env := setupEnvironment(t)
+
+	w := env.do("POST", "/orders/with-payment", map[string]interface{}{
+		"customer_id": 7,
+		"product_id":  1,
+		"quantity":    2,
+		"currency":    "usd",
+	})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	// Stripe tells payment-service the customer paid
+	succeeded := map[string]interface{}{
+		"id":     "evt_e2e",
+		"object": "event",
+		"type":   "payment_intent.succeeded",
+		"data": map[string]interface{}{
+			"object": map[string]interface{}{"id": "pi_e2e", "object": "payment_intent", "status": "succeeded"},
+		},
+	}
+	resp := env.sendStripeEvent(t, succeeded)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	payments, err := env.Payments.GetByOrder(1)
+	require.NoError(t, err)
+	require.Len(t, payments, 1)
+	assert.Equal(t, "succeeded", payments[0].Status)
+
+	// order-service moves the order on through the payment event
+	assert.Eventually(t, func() bool {
+		order, err := env.Orders.GetOrderFromDB("1")
+		return err == nil && order.Status == "processing"
+	}, time.Second, 10*time.Millisecond)
+
+	// The customer hears of the order, the payment and the status change
+	assert.Eventually(t, func() bool {
+		notifications, _ := env.Notifications.GetByCustomer(7)
+		return len(notifications) == 3
+	}, time.Second, 10*time.Millisecond)
+
+	// A redelivery is acknowledged without changes
+	resp = env.sendStripeEvent(t, succeeded)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var ack struct {
+		Result string `json:"result"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&ack))
+	assert.Equal(t, "duplicate", ack.Result)
+}
+
+func TestCancellingPaidOrderRefundsPayment(t *testing.T) {
+	env := setupEnvironment(t)
+
+	w := env.do("POST", "/orders/with-payment", map[string]interface{}{
+		"customer_id": 7,
+		"product_id":  1,
+		"quantity":    2,
+		"currency":    "usd",
+	})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	resp := env.sendStripeEvent(t, map[string]interface{}{
+		"id":     "evt_e2e",
+		"object": "event",
+		"type":   "payment_intent.succeeded",
+		"data": map[string]interface{}{
+			"object": map[string]interface{}{"id": "pi_e2e", "object": "payment_intent", "status": "succeeded"},
+		},
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Eventually(t, func() bool {
+		order, err := env.Orders.GetOrderFromDB("1")
+		return err == nil && order.Status == "processing"
+	}, time.Second, 10*time.Millisecond)
+
+	w = env.do("PATCH", "/orders/1/status", map[string]string{"status": "cancelled"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	payments, err := env.Payments.GetByOrder(1)
+	require.NoError(t, err)
+	require.Len(t, payments, 1)
+	assert.Equal(t, "refunded", payments[0].Status)
+	assert.Equal(t, money.MustNew(3998, "USD"), payments[0].AmountRefunded)
+
+	refunds, err := env.Payments.GetRefunds(payments[0].ID)
+	require.NoError(t, err)
+	require.Len(t, refunds, 1)
+	assert.Equal(t, "re_e2e", refunds[0].StripeRefundID)
+	assert.Equal(t, "requested_by_customer", refunds[0].Reason)
+
+	// Cancelling again refunds nothing more
+	w = env.do("PATCH", "/orders/1/status", map[string]string{"status": "cancelled"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	refunds, err = env.Payments.GetRefunds(payments[0].ID)
+	require.NoError(t, err)
+	assert.Len(t, refunds, 1)
+}
+
+func TestNotificationFallsBackToHTTP(t *testing.T) {
+	env := setupEnvironment(t)
+
+	// With the broker down the order event cannot be published
+	env.Broker.Close()
+
+	w := env.do("POST", "/orders", model.Order{CustomerID: 7, ProductID: 1, Quantity: 2})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	// notification-service is told over HTTP instead
+	assert.Eventually(t, func() bool {
+		notifications, _ := env.Notifications.GetByCustomer(7)
+		return len(notifications) == 1 && notifications[0].EventID == ""
+	}, time.Second, 10*time.Millisecond)
+}
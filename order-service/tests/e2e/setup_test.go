@@ -0,0 +1,188 @@
+package e2e
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	inventorycontroller "go-microservices/inventory-service/controller"
+	inventorymodel "go-microservices/inventory-service/model"
+	inventoryrepository "go-microservices/inventory-service/repository"
+	inventoryroutes "go-microservices/inventory-service/routes"
+	inventoryservice "go-microservices/inventory-service/service"
+	notificationconsumer "go-microservices/notification-service/consumer"
+	notificationcontroller "go-microservices/notification-service/controller"
+	notificationrepository "go-microservices/notification-service/repository"
+	notificationroutes "go-microservices/notification-service/routes"
+	notificationservice "go-microservices/notification-service/service"
+	"go-microservices/order-service/cache"
+	"go-microservices/order-service/consumer"
+	"go-microservices/order-service/controller"
+	"go-microservices/order-service/pricing"
+	"go-microservices/order-service/repository"
+	"go-microservices/order-service/routes"
+	"go-microservices/order-service/service"
+	paymentcontroller "go-microservices/payment-service/controller"
+	paymentprovider "go-microservices/payment-service/provider"
+	paymentrepository "go-microservices/payment-service/repository"
+	paymentroutes "go-microservices/payment-service/routes"
+	paymentservice "go-microservices/payment-service/service"
+	"go-microservices/pkg/broker"
+	"go-microservices/pkg/money"
+	productcontroller "go-microservices/product-service/controller"
+	productmodel "go-microservices/product-service/model"
+	productrepository "go-microservices/product-service/repository"
+	productroutes "go-microservices/product-service/routes"
+	productservice "go-microservices/product-service/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stripe/stripe-go/v76"
+)
+
+// environment is order-service together with every service it calls, all
+// running in-process on in-memory storage
+type environment struct {
+	Router        *gin.Engine
+	Orders        *repository.MemoryOrderRepository
+	Coupons       *repository.MemoryCouponRepository
+	Cache         *cache.MemoryCache
+	Broker        *broker.Memory
+	Notifications *notificationrepository.MemoryNotificationRepository
+	Payments      *paymentrepository.MemoryPaymentRepository
+	// PaymentURL is the address of payment-service, which Stripe sends
+	// webhook events to
+	PaymentURL string
+}
+
+// webhookSecret is the signing secret of the Stripe webhook events sent
+// to payment-service
+const webhookSecret = "whsec_e2e"
+
+// setupEnvironment boots the services with product 1 priced at 19.99 and
+// 10 units of it in stock. Orders of US-CA are taxed at 7.25%.
+func setupEnvironment(t *testing.T) *environment {
+	gin.SetMode(gin.TestMode)
+
+	products := productrepository.NewMemoryProductRepository()
+	products.Create(&productmodel.Product{Name: "Laptop", Description: "High-performance laptop", Price: money.MustNew(1999, "USD")})
+	productRouter := gin.New()
+	productroutes.SetupRoutes(productRouter, productcontroller.NewProductController(productservice.NewProductService(products)))
+
+	inventory := inventoryrepository.NewMemoryInventoryRepository()
+	inventory.Create(&inventorymodel.Inventory{ProductID: 1, Quantity: 10, SKU: "LAPTOP001", Location: "Warehouse A"})
+	inventoryRouter := gin.New()
+	inventoryroutes.SetupRoutes(inventoryRouter, inventorycontroller.NewInventoryController(inventoryservice.NewInventoryService(inventory)))
+
+	notifications := notificationrepository.NewMemoryNotificationRepository()
+	notificationService := notificationservice.NewNotificationService(notifications)
+	notificationRouter := gin.New()
+	notificationroutes.SetupRoutes(notificationRouter, notificationcontroller.NewNotificationController(notificationService))
+
+	eventBroker := broker.NewMemory()
+	t.Cleanup(func() { eventBroker.Close() })
+
+	fakeStripe(t)
+	t.Setenv("STRIPE_WEBHOOK_SECRET", webhookSecret)
+	payments := paymentrepository.NewMemoryPaymentRepository()
+	paymentRouter := gin.New()
+	paymentroutes.SetupRoutes(paymentRouter, paymentcontroller.NewPaymentController(paymentservice.NewPaymentService(payments, paymentprovider.NewStripe("sk_test_dummy"), eventBroker)))
+
+	t.Setenv("PRODUCT_SERVICE_URL", serve(t, productRouter))
+	t.Setenv("INVENTORY_SERVICE_URL", serve(t, inventoryRouter))
+	t.Setenv("NOTIFICATION_SERVICE_URL", serve(t, notificationRouter))
+	paymentURL := serve(t, paymentRouter)
+	t.Setenv("PAYMENT_SERVICE_URL", paymentURL)
+
+	env := &environment{
+		Router:        gin.New(),
+		Orders:        repository.NewMemoryOrderRepository(),
+		Coupons:       repository.NewMemoryCouponRepository(),
+		Cache:         cache.NewMemoryCache(),
+		Broker:        eventBroker,
+		Notifications: notifications,
+		Payments:      payments,
+		PaymentURL:    paymentURL,
+	}
+
+	// notification-service consumes order and payment events from the
+	// shared broker
+	if _, err := notificationconsumer.Start(env.Broker, notificationService, 1); err != nil {
+		t.Fatalf("failed to start notification consumer: %v", err)
+	}
+
+	// Orders of California are taxed, other orders are not
+	taxes, err := pricing.NewTaxRules([]pricing.TaxRule{
+		{Region: pricing.AnyRegion, Rate: "0"},
+		{Region: "US-CA", Rate: "7.25"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create tax rules: %v", err)
+	}
+
+	orderController := &controller.OrderController{
+		OrderRepo:           env.Orders,
+		Coupons:             env.Coupons,
+		Pricing:             &pricing.Engine{Taxes: taxes},
+		Cache:               env.Cache,
+		Broker:              env.Broker,
+		InventoryService:    service.NewInventoryService(),
+		NotificationService: service.NewNotificationService(),
+		PaymentService:      service.NewPaymentService(),
+		ProductService:      service.NewProductService(),
+	}
+	routes.SetupRoutes(env.Router, orderController)
+
+	// order-service advances orders on payment events
+	if _, err := consumer.Start(env.Broker, orderController, 1); err != nil {
+		t.Fatalf("failed to start order consumer: %v", err)
+	}
+
+	return env
+}
+
+// serve runs a handler on a local port for the duration of the test
+func serve(t *testing.T, handler http.Handler) string {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// fakeStripe points the Stripe client at a server that accepts every
+// payment intent and refund
+func fakeStripe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		amount, _ := strconv.ParseInt(r.Form.Get("amount"), 10, 64)
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/v1/refunds" {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"id":             "re_e2e",
+				"object":         "refund",
+				"amount":         amount,
+				"payment_intent": r.Form.Get("payment_intent"),
+				"status":         "succeeded",
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":            "pi_e2e",
+			"object":        "payment_intent",
+			"amount":        amount,
+			"currency":      r.Form.Get("currency"),
+			"status":        "requires_payment_method",
+			"client_secret": "pi_e2e_secret",
+		})
+	}))
+
+	previous := stripe.GetBackend(stripe.APIBackend)
+	stripe.SetBackend(stripe.APIBackend, stripe.GetBackendWithConfig(stripe.APIBackend, &stripe.BackendConfig{
+		URL:               stripe.String(server.URL),
+		MaxNetworkRetries: stripe.Int64(0),
+	}))
+	t.Cleanup(func() {
+		stripe.SetBackend(stripe.APIBackend, previous)
+		server.Close()
+	})
+}
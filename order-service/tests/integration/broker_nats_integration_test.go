@@ -0,0 +1,115 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-microservices/pkg/broker"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupNATS connects to the NATS server at NATS_URL, localhost by default
+func setupNATS(t *testing.T) *broker.NATS {
+	if os.Getenv("SKIP_INTEGRATION_TESTS") == "true" {
+		t.Skip("Skipping integration test")
+	}
+
+	url := os.Getenv("NATS_URL")
+	if url == "" {
+		url = "nats://localhost:4222"
+	}
+	n, err := broker.NewNATS(url)
+	if err != nil {
+		if n != nil {
+			n.Close()
+		}
+		t.Skipf("Failed to connect to NATS: %v", err)
+	}
+	t.Cleanup(func() { n.Close() })
+	return n
+}
+
+func TestNATSBrokerIntegration(t *testing.T) {
+	n := setupNATS(t)
+
+	// Topics and subscription names are unique per run, as streams and
+	// durable consumers outlive the test
+	topic := "test_orders_" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	received := make(chan string, 10)
+	subscriber, err := n.Subscribe(broker.Subscription{
+		Name:        "test_notifications",
+		Bindings:    []broker.Binding{{Topic: topic, Pattern: "order.#"}},
+		Concurrency: 2,
+	}, func(_ context.Context, body []byte) error {
+		received <- string(body)
+		return nil
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, n.Publish(ctx, broker.Message{Topic: topic, Key: "order.created", ID: "1", Body: []byte("created")}))
+	// Republishing the same ID is dropped by JetStream
+	require.NoError(t, n.Publish(ctx, broker.Message{Topic: topic, Key: "order.created", ID: "1", Body: []byte("created")}))
+	require.NoError(t, n.Publish(ctx, broker.Message{Topic: topic, Key: "order.status.changed", ID: "2", Body: []byte("changed")}))
+
+	var bodies []string
+	for len(bodies) < 2 {
+		select {
+		case body := <-received:
+			bodies = append(bodies, body)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received %d of 2 messages", len(bodies))
+		}
+	}
+	assert.ElementsMatch(t, []string{"created", "changed"}, bodies)
+
+	select {
+	case body := <-received:
+		t.Fatalf("duplicate message delivered: %s", body)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	stopCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	assert.NoError(t, subscriber.Stop(stopCtx))
+}
+
+func TestNATSBrokerRetryIntegration(t *testing.T) {
+	n := setupNATS(t)
+
+	topic := "test_retries_" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	var deliveries atomic.Int32
+	_, err := n.Subscribe(broker.Subscription{
+		Name:     "test_retries",
+		Bindings: []broker.Binding{{Topic: topic, Pattern: "order.#"}},
+		Retry:    &broker.RetryPolicy{MaxAttempts: 2, Delays: []time.Duration{100 * time.Millisecond}},
+	}, func(context.Context, []byte) error {
+		deliveries.Add(1)
+		return fmt.Errorf("poison message")
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, n.Publish(context.Background(), broker.Message{Topic: topic, Key: "order.created", Body: []byte("poison")}))
+
+	// The message is terminated after its second delivery
+	assert.Eventually(t, func() bool { return deliveries.Load() == 2 }, 5*time.Second, 50*time.Millisecond)
+	time.Sleep(500 * time.Millisecond)
+	assert.Equal(t, int32(2), deliveries.Load())
+}
+
+func TestNATSBrokerUnsupportedPattern(t *testing.T) {
+	n := setupNATS(t)
+
+	_, err := n.Subscribe(broker.Subscription{
+		Name:     "test_patterns",
+		Bindings: []broker.Binding{{Topic: "test_patterns", Pattern: "#.created"}},
+	}, func(context.Context, []byte) error { return nil })
+	assert.ErrorIs(t, err, broker.ErrUnsupportedPattern)
+}
@@ -15,7 +15,8 @@ import (
 	"go-microservices/order-service/controller"
 	"go-microservices/order-service/db"
 	"go-microservices/order-service/model"
-	"go-microservices/order-service/queue"
+	"go-microservices/pkg/broker"
+	"go-microservices/pkg/queue"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -52,7 +53,7 @@ func setupIntegrationTestEnvironment(t *testing.T) (*gin.Engine, *sql.DB, func()
 	}
 
 	// Create controller with real dependencies
-	orderController := controller.NewOrderController(database)
+	orderController := controller.NewOrderController(database, broker.NewRabbitMQ(queue.DefaultConnection()))
 
 	// Setup router
 	gin.SetMode(gin.TestMode)
@@ -0,0 +1,85 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"go-microservices/pkg/queue"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rabbitMQURL is the URL of the RabbitMQ the integration tests run against
+func rabbitMQURL() string {
+	host := os.Getenv("RABBITMQ_HOST")
+	if host == "" {
+		host = "localhost"
+	}
+	port := os.Getenv("RABBITMQ_PORT")
+	if port == "" {
+		port = "5672"
+	}
+	return fmt.Sprintf("amqp://guest:guest@%s:%s/", host, port)
+}
+
+// TestChannelReopenIntegration closes the channels of a connection with
+// channel-level errors while the connection stays up, and checks the
+// topology is declared again and the consumer restarted
+func TestChannelReopenIntegration(t *testing.T) {
+	if os.Getenv("SKIP_INTEGRATION_TESTS") == "true" {
+		t.Skip("Skipping integration test")
+	}
+	conn := queue.NewConnection(rabbitMQURL(), queue.Backoff{Initial: 50 * time.Millisecond, Max: 200 * time.Millisecond})
+	if err := conn.Connect(1); err != nil {
+		conn.Close()
+		t.Skipf("RabbitMQ is not reachable: %v", err)
+	}
+	defer conn.Close()
+
+	testQueue := queue.Config{
+		QueueName:    "test_channel_reopen",
+		RoutingKey:   "test.reopen.#",
+		ExchangeName: "test_channel_reopen",
+		ExchangeType: "topic",
+	}
+	require.NoError(t, conn.DeclareQueue(testQueue))
+	handled := make(chan string, 10)
+	_, err := conn.Consume(testQueue, queue.ConsumerOptions{}, func(_ context.Context, body []byte) error {
+		handled <- string(body)
+		return nil
+	})
+	require.NoError(t, err)
+
+	// Deleting the queue from another connection cancels the consumer,
+	// which cannot be restarted until the queue exists again
+	admin, err := amqp.Dial(rabbitMQURL())
+	require.NoError(t, err)
+	defer admin.Close()
+	adminChannel, err := admin.Channel()
+	require.NoError(t, err)
+	_, err = adminChannel.QueueDelete(testQueue.QueueName, false, false, false)
+	require.NoError(t, err)
+
+	// Redeclaring the exchange with another type closes the shared channel
+	err = conn.DeclareQueue(queue.Config{ExchangeName: testQueue.ExchangeName, ExchangeType: "fanout"})
+	require.Error(t, err)
+
+	// The reopened channel declares the queue again and the consumer gets
+	// its messages
+	publish := queue.Config{ExchangeName: testQueue.ExchangeName, RoutingKey: "test.reopen.created", Confirm: true}
+	require.Eventually(t, func() bool {
+		return conn.Ready() == nil && conn.PublishMessage(publish, "after reopen") == nil
+	}, 5*time.Second, 50*time.Millisecond)
+	select {
+	case body := <-handled:
+		assert.Equal(t, `"after reopen"`, body)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the consumer was not restarted")
+	}
+	assert.Equal(t, queue.StateConnected, conn.State(), "the connection stayed up")
+}
@@ -0,0 +1,349 @@
+package unit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-microservices/order-service/cache"
+	"go-microservices/order-service/model"
+	"go-microservices/order-service/service"
+	"go-microservices/pkg/money"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupRedis points the cache package at an in-process Redis configured
+// with config
+func setupRedis(t *testing.T, config cache.Config) *miniredis.Miniredis {
+	mr := miniredis.RunT(t)
+	t.Setenv("REDIS_HOST", mr.Host())
+	t.Setenv("REDIS_PORT", mr.Port())
+	require.NoError(t, cache.InitRedis())
+	cache.Configure(config)
+	t.Cleanup(func() {
+		cache.Close()
+		cache.Configure(cache.ConfigFromEnv())
+	})
+	return mr
+}
+
+// testConfig is the default configuration without early refresh, so tests
+// are deterministic
+func testConfig() cache.Config {
+	config := cache.ConfigFromEnv()
+	config.EarlyRefreshBeta = 0
+	return config
+}
+
+// countingLoader returns a loader of an order that counts its calls
+func countingLoader(calls *atomic.Int32, status string) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		calls.Add(1)
+		return &model.Order{ID: 1, Status: status}, nil
+	}
+}
+
+// These tests run without InitRedis, as the service does when Redis is down
+// at startup
+
+func TestRedisCache_DegradesWithoutRedis(t *testing.T) {
+	var order model.Order
+	assert.ErrorIs(t, cache.Get("order:1", &order), cache.ErrUnavailable)
+	assert.ErrorIs(t, cache.Set("order:1", order, time.Minute), cache.ErrUnavailable)
+	assert.NoError(t, cache.Delete("order:1"))
+
+	// GetOrSet loads from the source of truth
+	loads := 0
+	err := cache.GetOrSet("order:1", &order, time.Minute, func() (interface{}, error) {
+		loads++
+		return &model.Order{ID: 1, Status: "pending"}, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, loads)
+	assert.Equal(t, "pending", order.Status)
+
+	// and returns its errors unchanged
+	notFound := errors.New("not found")
+	err = cache.GetOrSet("order:2", &order, time.Minute, func() (interface{}, error) {
+		return nil, notFound
+	})
+	assert.Equal(t, notFound, err)
+}
+
+func TestCacheConfigFromEnv(t *testing.T) {
+	t.Setenv("CACHE_NAMESPACE", "orders-test")
+	t.Setenv("CACHE_VERSION", "v2")
+	t.Setenv("CACHE_ORDER_TTL", "5m")
+
+	t.Setenv("CACHE_LOCK", "true")
+
+	config := cache.ConfigFromEnv()
+	assert.Equal(t, "orders-test", config.Namespace)
+	assert.Equal(t, "v2", config.Version)
+	assert.Equal(t, 5*time.Minute, config.OrderTTL)
+	assert.Equal(t, 30*time.Second, config.NegativeTTL)
+	assert.True(t, config.Lock)
+
+	t.Setenv("CACHE_ORDER_TTL", "soon")
+	assert.Equal(t, 30*time.Minute, cache.ConfigFromEnv().OrderTTL)
+	assert.Equal(t, "order-service:v2:order:1", cache.Key("order:1"))
+}
+
+func TestMemoryCache_Miss(t *testing.T) {
+	c := cache.NewMemoryCache()
+	var order model.Order
+	assert.ErrorIs(t, c.Get("order:1", &order), cache.ErrCacheMiss)
+
+	require.NoError(t, c.Set("order:1", model.Order{ID: 1}, time.Millisecond))
+	require.NoError(t, c.Get("order:1", &order))
+	time.Sleep(5 * time.Millisecond)
+	assert.ErrorIs(t, c.Get("order:1", &order), cache.ErrCacheMiss)
+
+	require.NoError(t, c.Set("order:1", model.Order{ID: 1}, time.Minute))
+	require.NoError(t, c.Delete("order:1"))
+	assert.ErrorIs(t, c.Get("order:1", &order), cache.ErrCacheMiss)
+}
+
+func TestGetOrSet_CachesLoadedValue(t *testing.T) {
+	mr := setupRedis(t, testConfig())
+
+	var calls atomic.Int32
+	var order model.Order
+	require.NoError(t, cache.GetOrSet("order:1", &order, time.Minute, countingLoader(&calls, "pending")))
+	require.NoError(t, cache.GetOrSet("order:1", &order, time.Minute, countingLoader(&calls, "pending")))
+
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, "pending", order.Status)
+	assert.True(t, mr.Exists(cache.Key("order:1")))
+	assert.Equal(t, time.Minute, mr.TTL(cache.Key("order:1")))
+
+	// A deleted key is loaded again
+	require.NoError(t, cache.Delete("order:1"))
+	require.NoError(t, cache.GetOrSet("order:1", &order, time.Minute, countingLoader(&calls, "shipped")))
+	assert.Equal(t, int32(2), calls.Load())
+	assert.Equal(t, "shipped", order.Status)
+}
+
+func TestGetOrSet_CoalescesConcurrentLoads(t *testing.T) {
+	setupRedis(t, testConfig())
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	loader := func() (interface{}, error) {
+		calls.Add(1)
+		<-release
+		return &model.Order{ID: 1, Status: "pending"}, nil
+	}
+
+	var wg sync.WaitGroup
+	orders := make([]model.Order, 10)
+	for i := range orders {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, cache.GetOrSet("order:1", &orders[i], time.Minute, loader))
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+	for _, order := range orders {
+		assert.Equal(t, "pending", order.Status)
+	}
+}
+
+func TestGetOrSet_CachesNotFound(t *testing.T) {
+	config := testConfig()
+	config.NegativeTTL = 10 * time.Second
+	mr := setupRedis(t, config)
+
+	var calls atomic.Int32
+	loader := func() (interface{}, error) {
+		calls.Add(1)
+		return nil, cache.ErrNotFound
+	}
+
+	var order model.Order
+	assert.ErrorIs(t, cache.GetOrSet("order:404", &order, time.Minute, loader), cache.ErrNotFound)
+	assert.ErrorIs(t, cache.GetOrSet("order:404", &order, time.Minute, loader), cache.ErrNotFound)
+	assert.ErrorIs(t, cache.Get("order:404", &order), cache.ErrNotFound)
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, 10*time.Second, mr.TTL(cache.Key("order:404")))
+
+	// Once the negative entry expires the loader runs again
+	mr.FastForward(11 * time.Second)
+	assert.ErrorIs(t, cache.GetOrSet("order:404", &order, time.Minute, loader), cache.ErrNotFound)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestGetOrSet_RefreshesEarly(t *testing.T) {
+	config := testConfig()
+	// Large enough for any entry to be refreshed on its next lookup
+	config.EarlyRefreshBeta = 1e12
+	setupRedis(t, config)
+
+	var calls atomic.Int32
+	var order model.Order
+	require.NoError(t, cache.GetOrSet("order:1", &order, time.Minute, countingLoader(&calls, "pending")))
+	require.NoError(t, cache.GetOrSet("order:1", &order, time.Minute, countingLoader(&calls, "shipped")))
+	assert.Equal(t, int32(2), calls.Load())
+	assert.Equal(t, "shipped", order.Status)
+
+	// A failed refresh serves the cached value
+	err := cache.GetOrSet("order:1", &order, time.Minute, func() (interface{}, error) {
+		return nil, errors.New("database is down")
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "shipped", order.Status)
+}
+
+func TestGetOrSet_WaitsForLockHolder(t *testing.T) {
+	config := testConfig()
+	config.Lock = true
+	config.LockTTL = 2 * time.Second
+	mr := setupRedis(t, config)
+
+	// Another replica holds the lock and caches the order
+	lockKey := cache.Key("order:1") + ":lock"
+	require.NoError(t, mr.Set(lockKey, "other-replica"))
+	time.AfterFunc(100*time.Millisecond, func() {
+		cache.Set("order:1", model.Order{ID: 1, Status: "pending"}, time.Minute)
+	})
+
+	var calls atomic.Int32
+	var order model.Order
+	require.NoError(t, cache.GetOrSet("order:1", &order, time.Minute, countingLoader(&calls, "loaded")))
+	assert.Equal(t, int32(0), calls.Load())
+	assert.Equal(t, "pending", order.Status)
+
+	// The lock of another replica is left alone, ours is released
+	assert.True(t, mr.Exists(lockKey))
+	require.NoError(t, cache.GetOrSet("order:2", &order, time.Minute, countingLoader(&calls, "loaded")))
+	assert.Equal(t, int32(1), calls.Load())
+	assert.False(t, mr.Exists(cache.Key("order:2")+":lock"))
+}
+
+func TestMemoryCache_CachesNotFound(t *testing.T) {
+	c := cache.NewMemoryCache()
+
+	var calls atomic.Int32
+	loader := func() (interface{}, error) {
+		calls.Add(1)
+		return nil, cache.ErrNotFound
+	}
+
+	var order model.Order
+	assert.ErrorIs(t, c.GetOrSet("order:404", &order, time.Minute, loader), cache.ErrNotFound)
+	assert.ErrorIs(t, c.GetOrSet("order:404", &order, time.Minute, loader), cache.ErrNotFound)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+// localConfig is testConfig with an in-process tier of maxEntries entries
+func localConfig(maxEntries int) cache.Config {
+	config := testConfig()
+	config.LocalMaxEntries = maxEntries
+	config.LocalTTL = time.Minute
+	return config
+}
+
+func TestLocalTier_InvalidatedByOtherReplicas(t *testing.T) {
+	mr := setupRedis(t, localConfig(10))
+	channel := cache.InvalidationChannel()
+	require.Eventually(t, func() bool { return mr.PubSubNumSub(channel)[channel] == 1 }, time.Second, 10*time.Millisecond)
+
+	var calls atomic.Int32
+	var order model.Order
+	require.NoError(t, cache.GetOrSet("order:1", &order, time.Minute, countingLoader(&calls, "pending")))
+
+	// Lookups are answered by the local tier, without Redis
+	mr.FlushAll()
+	require.NoError(t, cache.GetOrSet("order:1", &order, time.Minute, countingLoader(&calls, "shipped")))
+	assert.Equal(t, "pending", order.Status)
+	assert.Equal(t, int32(1), calls.Load())
+
+	// Our own invalidations are ignored, another replica's drop the key
+	mr.Publish(channel, `{"origin":"other-replica","key":"order:1"}`)
+	assert.Eventually(t, func() bool {
+		return errors.Is(cache.Get("order:1", &order), cache.ErrCacheMiss)
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestLocalTier_PublishesWritesAndDeletes(t *testing.T) {
+	mr := setupRedis(t, localConfig(10))
+	subscriber := mr.NewSubscriber()
+	subscriber.Subscribe(cache.InvalidationChannel())
+	// miniredis delivers synchronously, so messages are read while publishing
+	messages := make(chan string, 10)
+	go func() {
+		for msg := range subscriber.Messages() {
+			messages <- msg.Message
+		}
+	}()
+
+	require.NoError(t, cache.Set("order:1", model.Order{ID: 1}, time.Minute))
+	require.NoError(t, cache.Delete("order:1"))
+
+	for i := 0; i < 2; i++ {
+		select {
+		case msg := <-messages:
+			assert.Contains(t, msg, `"key":"order:1"`)
+		case <-time.After(time.Second):
+			t.Fatal("invalidation was not published")
+		}
+	}
+
+	// The deleted key is gone from both tiers
+	var order model.Order
+	assert.ErrorIs(t, cache.Get("order:1", &order), cache.ErrCacheMiss)
+}
+
+func TestLocalTier_EvictsLeastRecentlyUsed(t *testing.T) {
+	config := localConfig(2)
+	config.LocalMaxBytes = 2000
+	mr := setupRedis(t, config)
+
+	var order model.Order
+	require.NoError(t, cache.Set("order:1", model.Order{ID: 1}, time.Minute))
+	require.NoError(t, cache.Set("order:2", model.Order{ID: 2}, time.Minute))
+	require.NoError(t, cache.Get("order:1", &order))
+	require.NoError(t, cache.Set("order:3", model.Order{ID: 3}, time.Minute))
+	// Larger than the whole tier
+	require.NoError(t, cache.Set("order:4", model.Order{ID: 4, Status: string(make([]byte, 3000))}, time.Minute))
+
+	// Without Redis only what the local tier holds is found
+	mr.FlushAll()
+	assert.NoError(t, cache.Get("order:1", &order))
+	assert.NoError(t, cache.Get("order:3", &order))
+	assert.ErrorIs(t, cache.Get("order:2", &order), cache.ErrCacheMiss)
+	assert.ErrorIs(t, cache.Get("order:4", &order), cache.ErrCacheMiss)
+}
+
+func TestProductService_CachesPrices(t *testing.T) {
+	setupRedis(t, localConfig(10))
+
+	var requests atomic.Int32
+	products := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":1,"name":"Widget","price":19.99,"stock":10}`))
+	}))
+	defer products.Close()
+	t.Setenv("PRODUCT_SERVICE_URL", products.URL)
+	productService := service.NewProductService()
+
+	for i := 0; i < 3; i++ {
+		price, err := productService.GetProductPrice(1)
+		require.NoError(t, err)
+		assert.Equal(t, money.MustNew(1999, "USD"), price)
+	}
+	assert.Equal(t, int32(1), requests.Load())
+}
@@ -0,0 +1,122 @@
+package unit
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-microservices/order-service/admin"
+	"go-microservices/order-service/consumer"
+	"go-microservices/pkg/queue"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parkedQueue returns a memory queue with messages parked in the
+// dead-letter queue of order-service's queue, consumed by a handler that
+// fails while failing is set and counts its deliveries
+func parkedQueue(t *testing.T, messages ...interface{}) (q *queue.MemoryQueue, failing *atomic.Bool, deliveries *atomic.Int32) {
+	q = queue.NewMemoryQueue()
+	t.Cleanup(q.Close)
+	failing, deliveries = new(atomic.Bool), new(atomic.Int32)
+	failing.Store(true)
+
+	config := queue.Config{
+		QueueName:    consumer.SubscriptionName,
+		RoutingKey:   "payment.#",
+		ExchangeName: "payments",
+		ExchangeType: "topic",
+		Retry:        &queue.RetryPolicy{MaxAttempts: 1},
+	}
+	require.NoError(t, q.DeclareQueue(config))
+	require.NoError(t, q.ConsumeMessages(config, func([]byte) error {
+		deliveries.Add(1)
+		if failing.Load() {
+			return errors.New("handler failed")
+		}
+		return nil
+	}))
+	for _, message := range messages {
+		require.NoError(t, q.PublishMessage(queue.Config{ExchangeName: "payments", RoutingKey: "payment.succeeded"}, message))
+	}
+	require.Eventually(t, func() bool {
+		letters, _ := q.DeadLetters(consumer.SubscriptionName, 10)
+		return len(letters) == len(messages)
+	}, time.Second, 5*time.Millisecond)
+	return q, failing, deliveries
+}
+
+// runDLQ runs the dlq subcommand and returns its output
+func runDLQ(q *queue.MemoryQueue, args ...string) (string, error) {
+	var out bytes.Buffer
+	err := admin.RunDLQ(args, q, &out)
+	return out.String(), err
+}
+
+func TestDLQ_Inspect(t *testing.T) {
+	q, _, _ := parkedQueue(t, map[string]int{"id": 1}, "not an object")
+
+	out, err := runDLQ(q, "inspect")
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 3)
+	assert.Contains(t, lines[0], `"body":{"id":1}`, "JSON bodies are shown as JSON")
+	assert.Contains(t, lines[0], `"last_error":"handler failed"`)
+	assert.Contains(t, lines[1], `"body":"not an object"`)
+	assert.Equal(t, "2 dead-lettered messages shown from order-service.dlq", lines[2], "the queue defaults to order-service's own")
+
+	out, err = runDLQ(q, "inspect", "-limit", "1")
+	require.NoError(t, err)
+	assert.Contains(t, out, "1 dead-lettered messages shown")
+
+	out, err = runDLQ(q, "inspect", "-queue", "notifications")
+	require.NoError(t, err)
+	assert.Equal(t, "0 dead-lettered messages shown from notifications.dlq\n", out)
+
+	letters, err := q.DeadLetters(consumer.SubscriptionName, 10)
+	require.NoError(t, err)
+	assert.Len(t, letters, 2, "inspecting removes nothing")
+}
+
+func TestDLQ_Replay(t *testing.T) {
+	q, failing, deliveries := parkedQueue(t, map[string]int{"id": 1}, map[string]int{"id": 2})
+	failing.Store(false)
+
+	out, err := runDLQ(q, "replay", "-limit", "1")
+	require.NoError(t, err)
+	assert.Equal(t, "Replayed 1 messages from order-service.dlq\n", out)
+	assert.Eventually(t, func() bool { return deliveries.Load() == 3 }, time.Second, 5*time.Millisecond)
+
+	letters, err := q.DeadLetters(consumer.SubscriptionName, 10)
+	require.NoError(t, err)
+	assert.Len(t, letters, 1, "messages past the limit stay parked")
+}
+
+func TestDLQ_Purge(t *testing.T) {
+	q, _, _ := parkedQueue(t, map[string]int{"id": 1}, map[string]int{"id": 2})
+
+	out, err := runDLQ(q, "purge")
+	require.NoError(t, err)
+	assert.Equal(t, "Purged 2 messages from order-service.dlq\n", out)
+
+	letters, err := q.DeadLetters(consumer.SubscriptionName, 10)
+	require.NoError(t, err)
+	assert.Empty(t, letters)
+}
+
+func TestDLQ_InvalidArguments(t *testing.T) {
+	q, _, _ := parkedQueue(t)
+
+	_, err := runDLQ(q)
+	assert.ErrorContains(t, err, "usage: order-service dlq")
+
+	_, err = runDLQ(q, "drop")
+	assert.ErrorContains(t, err, `unknown dlq command "drop"`)
+
+	_, err = runDLQ(q, "inspect", "-limit", "many")
+	assert.Error(t, err)
+}
@@ -0,0 +1,119 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-microservices/order-service/controller"
+	"go-microservices/order-service/model"
+	"go-microservices/order-service/pricing"
+	"go-microservices/pkg/exchange"
+	"go-microservices/pkg/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// ratesAsOf is when the rates of writeRates were published
+var ratesAsOf = time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)
+
+// writeRates writes a rates file with USD as base
+func writeRates(t *testing.T, path string, rates string) {
+	t.Helper()
+	data := `{"base": "USD", "as_of": "2024-06-03T00:00:00Z", "rates": ` + rates + `}`
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+}
+
+// createOrder posts an order to a controller pricing it from a USD price
+// of 19.99 at the rates of a file, and returns the response
+func createOrder(t *testing.T, order string, currencies ...string) *httptest.ResponseRecorder {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	writeRates(t, path, `{"EUR": 0.9215, "JPY": 157.23}`)
+	rates, err := exchange.NewFileProvider(path)
+	require.NoError(t, err)
+	if len(currencies) == 0 {
+		currencies = rates.Currencies()
+	}
+
+	mockOrderRepo := new(MockOrderRepository)
+	mockInventory := new(MockInventoryService)
+	mockQueue := new(MockPublisher)
+	mockCache := new(MockCache)
+	mockProduct := new(MockProductService)
+	orderController := &controller.OrderController{
+		OrderRepo:           mockOrderRepo,
+		InventoryService:    mockInventory,
+		NotificationService: new(MockNotificationService),
+		Broker:              mockQueue,
+		Cache:               mockCache,
+		ProductService:      mockProduct,
+		Pricing:             &pricing.Engine{Rates: rates},
+		Currencies:          currencies,
+	}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/orders", orderController.CreateOrder)
+
+	mockOrderRepo.On("InsertOrder", mock.AnythingOfType("*model.Order")).Return(nil)
+	mockInventory.On("CheckAvailability", 1, 2).Return(true, nil)
+	mockProduct.On("GetProductPrice", 1).Return(money.MustNew(1999, "USD"), nil)
+	mockQueue.On("Publish", mock.Anything, mock.AnythingOfType("broker.Message")).Return(nil)
+	mockCache.On("Delete", mock.Anything).Return(nil)
+
+	req := httptest.NewRequest("POST", "/orders", bytes.NewBufferString(order))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCreateOrder_ConvertsPrice(t *testing.T) {
+	w := createOrder(t, `{"customer_id": 1, "product_id": 1, "quantity": 2, "currency": "EUR"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var order model.Order
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+	assert.Equal(t, money.MustNew(3684, "EUR"), order.TotalPrice, "39.98 USD at 0.9215")
+	require.NotNil(t, order.ExchangeRate)
+	assert.Equal(t, money.MustNew(3998, "USD"), order.ExchangeRate.BaseTotal)
+	assert.Equal(t, json.Number("0.9215"), order.ExchangeRate.Rate)
+	assert.Equal(t, ratesAsOf, order.ExchangeRate.AsOf)
+
+	w = createOrder(t, `{"customer_id": 1, "product_id": 1, "quantity": 2, "currency": "jpy"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+	assert.Equal(t, money.MustNew(6286, "JPY"), order.TotalPrice, "39.98 USD at 157.23")
+}
+
+func TestCreateOrder_ProductCurrencyByDefault(t *testing.T) {
+	w := createOrder(t, `{"customer_id": 1, "product_id": 1, "quantity": 2}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var order model.Order
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+	assert.Equal(t, money.MustNew(3998, "USD"), order.TotalPrice)
+	require.NotNil(t, order.ExchangeRate)
+	assert.Equal(t, json.Number("1"), order.ExchangeRate.Rate)
+}
+
+func TestCreateOrder_UnsupportedCurrency(t *testing.T) {
+	w := createOrder(t, `{"customer_id": 1, "product_id": 1, "quantity": 2, "currency": "GBP"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Currency GBP is not supported, expected one of EUR, JPY, USD")
+
+	// Supported currencies need a rate too
+	w = createOrder(t, `{"customer_id": 1, "product_id": 1, "quantity": 2, "currency": "GBP"}`, "USD", "GBP")
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+
+	// Products are only sold in supported currencies
+	w = createOrder(t, `{"customer_id": 1, "product_id": 1, "quantity": 2}`, "EUR")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -2,7 +2,10 @@ package unit
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -10,7 +13,10 @@ import (
 
 	"go-microservices/order-service/controller"
 	"go-microservices/order-service/model"
-	"go-microservices/order-service/queue"
+	paymentapi "go-microservices/payment-service/api"
+	"go-microservices/pkg/broker"
+	"go-microservices/pkg/money"
+	"go-microservices/pkg/queue"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -31,8 +37,8 @@ type MockNotificationService struct {
 	mock.Mock
 }
 
-func (m *MockNotificationService) SendOrderNotification(orderID int) error {
-	args := m.Called(orderID)
+func (m *MockNotificationService) SendOrderNotification(orderID int, customerID int) error {
+	args := m.Called(orderID, customerID)
 	return args.Error(0)
 }
 
@@ -41,6 +47,31 @@ func (m *MockNotificationService) SendOrderStatusUpdate(orderID int, customerID
 	return args.Error(0)
 }
 
+type MockProductService struct {
+	mock.Mock
+}
+
+func (m *MockProductService) GetProductPrice(productID int) (money.Money, error) {
+	args := m.Called(productID)
+	return args.Get(0).(money.Money), args.Error(1)
+}
+
+type MockPaymentService struct {
+	mock.Mock
+}
+
+func (m *MockPaymentService) CreatePayment(orderID int, customerID int, amount money.Money) (*paymentapi.PaymentResponse, error) {
+	args := m.Called(orderID, customerID, amount)
+	payment, _ := args.Get(0).(*paymentapi.PaymentResponse)
+	return payment, args.Error(1)
+}
+
+func (m *MockPaymentService) RefundOrder(orderID int) ([]paymentapi.Refund, error) {
+	args := m.Called(orderID)
+	refunds, _ := args.Get(0).([]paymentapi.Refund)
+	return refunds, args.Error(1)
+}
+
 type MockOrderRepository struct {
 	mock.Mock
 }
@@ -59,12 +90,33 @@ func (m *MockOrderRepository) GetOrderFromDB(orderID string) (*model.Order, erro
 	return order, args.Error(1)
 }
 
-type MockMessageQueue struct {
+func (m *MockOrderRepository) GetOrders() ([]model.Order, error) {
+	args := m.Called()
+	orders, _ := args.Get(0).([]model.Order)
+	return orders, args.Error(1)
+}
+
+func (m *MockOrderRepository) UpdateOrder(order *model.Order) error {
+	args := m.Called(order)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) UpdateOrderStatus(orderID int, status string) error {
+	args := m.Called(orderID, status)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) DeleteOrder(orderID int) error {
+	args := m.Called(orderID)
+	return args.Error(0)
+}
+
+type MockPublisher struct {
 	mock.Mock
 }
 
-func (m *MockMessageQueue) PublishMessage(config queue.Config, message interface{}) error {
-	args := m.Called(config, message)
+func (m *MockPublisher) Publish(ctx context.Context, msg broker.Message) error {
+	args := m.Called(ctx, msg)
 	return args.Error(0)
 }
 
@@ -82,13 +134,18 @@ func (m *MockCache) Set(key string, value interface{}, expiration time.Duration)
 	return args.Error(0)
 }
 
+func (m *MockCache) Delete(key string) error {
+	args := m.Called(key)
+	return args.Error(0)
+}
+
 func (m *MockCache) GetOrSet(key string, value interface{}, expiration time.Duration, fn func() (interface{}, error)) error {
 	args := m.Called(key, value, expiration, fn)
 	return args.Error(0)
 }
 
 // setupTestEnvironment creates a test environment with mock dependencies
-func setupTestEnvironment() (*gin.Engine, *MockOrderRepository, *MockInventoryService, *MockNotificationService, *MockMessageQueue, *MockCache) {
+func setupTestEnvironment() (*gin.Engine, *MockOrderRepository, *MockInventoryService, *MockNotificationService, *MockPublisher, *MockCache, *MockProductService) {
 	// Setup Gin
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
@@ -97,28 +154,33 @@ func setupTestEnvironment() (*gin.Engine, *MockOrderRepository, *MockInventorySe
 	mockOrderRepo := new(MockOrderRepository)
 	mockInventory := new(MockInventoryService)
 	mockNotification := new(MockNotificationService)
-	mockQueue := new(MockMessageQueue)
+	mockQueue := new(MockPublisher)
 	mockCache := new(MockCache)
+	mockProduct := new(MockProductService)
 
 	// Create controller with mocks
 	orderController := &controller.OrderController{
 		OrderRepo:           mockOrderRepo,
 		InventoryService:    mockInventory,
 		NotificationService: mockNotification,
-		Queue:               mockQueue,
+		Broker:              mockQueue,
 		Cache:               mockCache,
+		ProductService:      mockProduct,
 	}
 
 	// Setup routes
 	router.POST("/orders", orderController.CreateOrder)
 	router.GET("/orders/:id", orderController.GetOrder)
+	router.PUT("/orders/:id", orderController.UpdateOrder)
+	router.PATCH("/orders/:id/status", orderController.UpdateOrderStatus)
+	router.DELETE("/orders/:id", orderController.DeleteOrder)
 
-	return router, mockOrderRepo, mockInventory, mockNotification, mockQueue, mockCache
+	return router, mockOrderRepo, mockInventory, mockNotification, mockQueue, mockCache, mockProduct
 }
 
 func TestCreateOrder_Success(t *testing.T) {
 	// Setup
-	router, mockOrderRepo, mockInventory, mockNotification, mockQueue, _ := setupTestEnvironment()
+	router, mockOrderRepo, mockInventory, mockNotification, mockQueue, mockCache, mockProduct := setupTestEnvironment()
 
 	// Prepare test data
 	order := model.Order{
@@ -130,8 +192,9 @@ func TestCreateOrder_Success(t *testing.T) {
 	// Set up mock expectations
 	mockOrderRepo.On("InsertOrder", mock.AnythingOfType("*model.Order")).Return(nil)
 	mockInventory.On("CheckAvailability", 1, 2).Return(true, nil)
-	mockNotification.On("SendOrderNotification", mock.AnythingOfType("int")).Return(nil)
-	mockQueue.On("PublishMessage", mock.AnythingOfType("queue.Config"), mock.Anything).Return(nil)
+	mockProduct.On("GetProductPrice", 1).Return(money.MustNew(1999, "USD"), nil)
+	mockQueue.On("Publish", mock.Anything, mock.AnythingOfType("broker.Message")).Return(nil)
+	mockCache.On("Delete", "order:0").Return(nil)
 
 	// Create request
 	orderJSON, _ := json.Marshal(order)
@@ -148,20 +211,53 @@ func TestCreateOrder_Success(t *testing.T) {
 	assert.Equal(t, http.StatusCreated, w.Code)
 
 	// Verify all mocks were called as expected
+	mockProduct.AssertExpectations(t)
 	mockOrderRepo.AssertExpectations(t)
 	mockInventory.AssertExpectations(t)
-	mockNotification.AssertExpectations(t)
 	mockQueue.AssertExpectations(t)
 
 	// Specifically verify that InsertOrder was called exactly once
 	mockOrderRepo.AssertNumberOfCalls(t, "InsertOrder", 1)
-	// Specifically verify that SendOrderNotification was called exactly once
+	// notification-service consumes the published event, so it is not
+	// called over HTTP
+	mockNotification.AssertNotCalled(t, "SendOrderNotification")
+}
+
+func TestCreateOrder_NotifiesOverHTTPWhenPublishFails(t *testing.T) {
+	// Setup
+	router, mockOrderRepo, mockInventory, mockNotification, mockQueue, mockCache, mockProduct := setupTestEnvironment()
+
+	// Set up mock expectations
+	mockOrderRepo.On("InsertOrder", mock.AnythingOfType("*model.Order")).Return(nil)
+	mockInventory.On("CheckAvailability", 1, 2).Return(true, nil)
+	mockProduct.On("GetProductPrice", 1).Return(money.MustNew(1999, "USD"), nil)
+	mockQueue.On("Publish", mock.Anything, mock.AnythingOfType("broker.Message")).Return(queue.ErrNotConnected)
+	mockCache.On("Delete", mock.Anything).Return(nil)
+	notified := make(chan struct{})
+	mockNotification.On("SendOrderNotification", mock.AnythingOfType("int"), 1).Return(nil).
+		Run(func(mock.Arguments) { close(notified) })
+
+	orderJSON, _ := json.Marshal(model.Order{ProductID: 1, CustomerID: 1, Quantity: 2})
+	req := httptest.NewRequest("POST", "/orders", bytes.NewBuffer(orderJSON))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// The order is still created
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	// The notification is sent asynchronously
+	select {
+	case <-notified:
+	case <-time.After(time.Second):
+		t.Fatal("order notification was not sent")
+	}
 	mockNotification.AssertNumberOfCalls(t, "SendOrderNotification", 1)
 }
 
 func TestCreateOrder_ProductNotAvailable(t *testing.T) {
 	// Setup
-	router, mockOrderRepo, mockInventory, mockNotification, mockQueue, _ := setupTestEnvironment()
+	router, mockOrderRepo, mockInventory, mockNotification, mockQueue, _, _ := setupTestEnvironment()
 
 	// Prepare test data
 	order := model.Order{
@@ -193,5 +289,151 @@ func TestCreateOrder_ProductNotAvailable(t *testing.T) {
 	// Verify that other mocks were not called
 	mockOrderRepo.AssertNotCalled(t, "InsertOrder")
 	mockNotification.AssertNotCalled(t, "SendOrderNotification")
-	mockQueue.AssertNotCalled(t, "PublishMessage")
-}
\ No newline at end of file
+	mockQueue.AssertNotCalled(t, "Publish")
+}
+func TestWritesInvalidateCachedOrder(t *testing.T) {
+	requests := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+		setup  func(repo *MockOrderRepository)
+	}{
+		{
+			name:   "update",
+			method: "PUT",
+			path:   "/orders/1",
+			body:   model.Order{CustomerID: 1, ProductID: 1, Quantity: 3, Status: "pending"},
+			setup: func(repo *MockOrderRepository) {
+				repo.On("UpdateOrder", mock.AnythingOfType("*model.Order")).Return(nil)
+			},
+		},
+		{
+			name:   "status update",
+			method: "PATCH",
+			path:   "/orders/1/status",
+			body:   map[string]string{"status": "shipped"},
+			setup: func(repo *MockOrderRepository) {
+				repo.On("UpdateOrderStatus", 1, "shipped").Return(nil)
+			},
+		},
+		{
+			name:   "delete",
+			method: "DELETE",
+			path:   "/orders/1",
+			setup: func(repo *MockOrderRepository) {
+				repo.On("DeleteOrder", 1).Return(nil)
+			},
+		},
+	}
+
+	for _, tt := range requests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockOrderRepo, _, _, mockQueue, mockCache, mockProduct := setupTestEnvironment()
+			mockOrderRepo.On("GetOrderFromDB", "1").Return(&model.Order{ID: 1, CustomerID: 1, ProductID: 1, Quantity: 2, Status: "pending"}, nil)
+			// A new quantity prices the order again
+			mockProduct.On("GetProductPrice", 1).Return(money.MustNew(1999, "USD"), nil)
+			tt.setup(mockOrderRepo)
+			mockQueue.On("Publish", mock.Anything, mock.AnythingOfType("broker.Message")).Return(nil)
+			mockCache.On("Delete", "order:1").Return(nil)
+
+			body, _ := json.Marshal(tt.body)
+			req := httptest.NewRequest(tt.method, tt.path, bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			mockCache.AssertCalled(t, "Delete", "order:1")
+		})
+	}
+}
+
+func TestUpdateOrder_CancellingRefundsPayment(t *testing.T) {
+	requests := []struct {
+		name       string
+		refunds    []paymentapi.Refund
+		refundErr  error
+		wantStatus int
+	}{
+		{name: "refunded", refunds: []paymentapi.Refund{{ID: 1, PaymentID: 1, Amount: 39.98, Currency: "USD"}}, wantStatus: http.StatusOK},
+		{name: "refund failed", refundErr: errors.New("payment service unavailable"), wantStatus: http.StatusBadGateway},
+	}
+
+	for _, tt := range requests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			mockOrderRepo := new(MockOrderRepository)
+			mockPayment := new(MockPaymentService)
+			mockQueue := new(MockPublisher)
+			orderController := &controller.OrderController{
+				OrderRepo:      mockOrderRepo,
+				PaymentService: mockPayment,
+				Broker:         mockQueue,
+			}
+			router := gin.New()
+			router.PUT("/orders/:id", orderController.UpdateOrder)
+
+			mockOrderRepo.On("GetOrderFromDB", "1").Return(&model.Order{ID: 1, CustomerID: 1, ProductID: 1, Quantity: 2, Status: "paid"}, nil)
+			mockOrderRepo.On("UpdateOrder", mock.AnythingOfType("*model.Order")).Return(nil)
+			mockPayment.On("RefundOrder", 1).Return(tt.refunds, tt.refundErr)
+			mockQueue.On("Publish", mock.Anything, mock.AnythingOfType("broker.Message")).Return(nil)
+
+			body, _ := json.Marshal(model.Order{CustomerID: 1, ProductID: 1, Quantity: 2, Status: "cancelled"})
+			req := httptest.NewRequest("PUT", "/orders/1", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			mockPayment.AssertCalled(t, "RefundOrder", 1)
+			if tt.refundErr != nil {
+				// The order stays paid so cancelling it again retries the refund
+				mockOrderRepo.AssertNotCalled(t, "UpdateOrder", mock.Anything)
+			} else {
+				mockOrderRepo.AssertCalled(t, "UpdateOrder", mock.AnythingOfType("*model.Order"))
+			}
+		})
+	}
+}
+
+func TestUpdateOrderStatus_NotFoundKeepsCache(t *testing.T) {
+	router, mockOrderRepo, _, _, _, mockCache, _ := setupTestEnvironment()
+	mockOrderRepo.On("GetOrderFromDB", "1").Return(nil, sql.ErrNoRows)
+
+	req := httptest.NewRequest("PATCH", "/orders/1/status", bytes.NewBufferString(`{"status":"shipped"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockCache.AssertNotCalled(t, "Delete", mock.Anything)
+}
+
+func TestReadinessCheck(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	eventBroker := broker.NewMemory()
+	eventBroker.Close()
+	orderController := &controller.OrderController{
+		ReadinessChecks: map[string]func() error{
+			"database": func() error { return nil },
+			"broker":   eventBroker.Ready,
+		},
+	}
+	router := gin.New()
+	router.GET("/ready", orderController.ReadinessCheck)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/ready", nil))
+
+	// The broker is closed
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	var body struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "not_ready", body.Status)
+	assert.Equal(t, "ok", body.Checks["database"])
+	assert.Equal(t, broker.ErrClosed.Error(), body.Checks["broker"])
+}
@@ -0,0 +1,145 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"go-microservices/order-service/cache"
+	"go-microservices/order-service/consumer"
+	"go-microservices/order-service/controller"
+	"go-microservices/order-service/model"
+	"go-microservices/order-service/repository"
+	"go-microservices/pkg/broker"
+	"go-microservices/pkg/events"
+	"go-microservices/pkg/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startPaymentConsumer consumes payment events from a memory broker into
+// the orders of a memory repository holding one pending order. The order
+// events published in turn are sent to the returned channel.
+func startPaymentConsumer(t *testing.T) (*broker.Memory, *repository.MemoryOrderRepository, <-chan events.Event) {
+	eventBroker := broker.NewMemory()
+	t.Cleanup(func() { eventBroker.Close() })
+
+	orders := repository.NewMemoryOrderRepository()
+	require.NoError(t, orders.InsertOrder(&model.Order{CustomerID: 7, ProductID: 1, Quantity: 2, TotalPrice: money.MustNew(3998, "USD")}))
+
+	orderEvents := make(chan events.Event, 10)
+	_, err := eventBroker.Subscribe(broker.Subscription{
+		Name:     "test",
+		Bindings: []broker.Binding{{Topic: events.TopicOrders, Pattern: "order.#"}},
+	}, func(_ context.Context, body []byte) error {
+		_, event, err := events.Decode(body)
+		require.NoError(t, err)
+		orderEvents <- event
+		return nil
+	})
+	require.NoError(t, err)
+
+	orderController := &controller.OrderController{
+		OrderRepo:           orders,
+		Cache:               cache.NewMemoryCache(),
+		Broker:              eventBroker,
+		NotificationService: new(MockNotificationService),
+	}
+	_, err = consumer.Start(eventBroker, orderController, 1)
+	require.NoError(t, err)
+	return eventBroker, orders, orderEvents
+}
+
+// publishPaymentEvent publishes a payment event the way payment-service does
+func publishPaymentEvent(t *testing.T, eventBroker *broker.Memory, event events.Event) {
+	envelope, err := events.New("payment-service", "", event)
+	require.NoError(t, err)
+	body, err := json.Marshal(envelope)
+	require.NoError(t, err)
+	require.NoError(t, eventBroker.Publish(context.Background(), broker.Message{
+		Topic: events.TopicPayments,
+		Key:   envelope.Type,
+		ID:    envelope.ID,
+		Body:  body,
+	}))
+}
+
+// waitForStatus waits for order 1 to have a status
+func waitForStatus(t *testing.T, orders *repository.MemoryOrderRepository, status string) {
+	require.Eventually(t, func() bool {
+		order, err := orders.GetOrderFromDB("1")
+		return err == nil && order.Status == status
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestPaymentConsumer_SucceededPaymentAdvancesOrder(t *testing.T) {
+	eventBroker, orders, orderEvents := startPaymentConsumer(t)
+	outcome := events.PaymentOutcome{PaymentID: 1, OrderID: 1, CustomerID: 7, Amount: money.MustNew(3998, "USD")}
+
+	publishPaymentEvent(t, eventBroker, &events.PaymentSucceeded{PaymentOutcome: outcome})
+	waitForStatus(t, orders, "processing")
+
+	select {
+	case event := <-orderEvents:
+		assert.Equal(t, &events.OrderStatusChanged{OrderID: 1, CustomerID: 7, PreviousStatus: "pending", Status: "processing"}, event)
+	case <-time.After(time.Second):
+		t.Fatal("order status changed event was not published")
+	}
+
+	// Redelivered and late events leave the order alone
+	publishPaymentEvent(t, eventBroker, &events.PaymentSucceeded{PaymentOutcome: outcome})
+	publishPaymentEvent(t, eventBroker, &events.PaymentCanceled{PaymentOutcome: outcome})
+	time.Sleep(50 * time.Millisecond)
+	order, err := orders.GetOrderFromDB("1")
+	require.NoError(t, err)
+	assert.Equal(t, "processing", order.Status)
+	assert.Empty(t, orderEvents)
+}
+
+func TestPaymentConsumer_PaymentShortOfTotalKeepsOrderPending(t *testing.T) {
+	eventBroker, orders, orderEvents := startPaymentConsumer(t)
+
+	// A partial capture, then a capture of the full amount in another currency
+	publishPaymentEvent(t, eventBroker, &events.PaymentSucceeded{PaymentOutcome: events.PaymentOutcome{
+		PaymentID: 1, OrderID: 1, CustomerID: 7, Amount: money.MustNew(2500, "USD"),
+	}})
+	publishPaymentEvent(t, eventBroker, &events.PaymentSucceeded{PaymentOutcome: events.PaymentOutcome{
+		PaymentID: 2, OrderID: 1, CustomerID: 7, Amount: money.MustNew(3998, "EUR"),
+	}})
+	time.Sleep(50 * time.Millisecond)
+	order, err := orders.GetOrderFromDB("1")
+	require.NoError(t, err)
+	assert.Equal(t, "pending", order.Status)
+	assert.Empty(t, orderEvents)
+	assert.Empty(t, eventBroker.DeadLetters(consumer.SubscriptionName), "retrying cannot change the amount")
+
+	// A capture of the total still advances the order
+	publishPaymentEvent(t, eventBroker, &events.PaymentSucceeded{PaymentOutcome: events.PaymentOutcome{
+		PaymentID: 3, OrderID: 1, CustomerID: 7, Amount: money.MustNew(3998, "USD"),
+	}})
+	waitForStatus(t, orders, "processing")
+}
+
+func TestPaymentConsumer_CanceledPaymentCancelsOrder(t *testing.T) {
+	eventBroker, orders, _ := startPaymentConsumer(t)
+	outcome := events.PaymentOutcome{PaymentID: 1, OrderID: 1, CustomerID: 7}
+
+	// A declined payment can be retried, the order stays pending
+	publishPaymentEvent(t, eventBroker, &events.PaymentFailed{PaymentOutcome: outcome})
+	publishPaymentEvent(t, eventBroker, &events.PaymentCanceled{PaymentOutcome: outcome})
+
+	waitForStatus(t, orders, "cancelled")
+	assert.Empty(t, eventBroker.DeadLetters(consumer.SubscriptionName))
+}
+
+func TestPaymentConsumer_AcksEventsOfUnknownOrders(t *testing.T) {
+	eventBroker, _, _ := startPaymentConsumer(t)
+
+	publishPaymentEvent(t, eventBroker, &events.PaymentSucceeded{PaymentOutcome: events.PaymentOutcome{OrderID: 42}})
+	publishPaymentEvent(t, eventBroker, &events.PaymentRefunded{PaymentOutcome: events.PaymentOutcome{OrderID: 1}})
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, eventBroker.DeadLetters(consumer.SubscriptionName))
+}
@@ -0,0 +1,301 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-microservices/order-service/controller"
+	"go-microservices/order-service/model"
+	"go-microservices/order-service/pricing"
+	"go-microservices/order-service/repository"
+	"go-microservices/pkg/exchange"
+	"go-microservices/pkg/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// pricingTaxes taxes California at 7.25%, the rest of the US at nothing,
+// Germany at 19% shipping included and every other region at 10%
+func pricingTaxes(t *testing.T) *pricing.TaxRules {
+	taxes, err := pricing.NewTaxRules([]pricing.TaxRule{
+		{Region: "US", Rate: "0"},
+		{Region: "us-ca", Rate: "7.25"},
+		{Region: "DE", Rate: "19", ShippingTaxable: true},
+		{Region: pricing.AnyRegion, Rate: "10"},
+	})
+	require.NoError(t, err)
+	return taxes
+}
+
+func TestEngine_Price(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	writeRates(t, path, `{"EUR": 0.9215}`)
+	rates, err := exchange.NewFileProvider(path)
+	require.NoError(t, err)
+	engine := &pricing.Engine{Rates: rates, Taxes: pricingTaxes(t), Shipping: money.MustNew(499, "USD")}
+
+	percentage := &model.Coupon{Code: "TEN", Type: model.CouponPercentage, PercentOff: "10"}
+	fixed := &model.Coupon{Code: "FIVE", Type: model.CouponFixed, AmountOff: money.MustNew(500, "USD")}
+	huge := &model.Coupon{Code: "ALL", Type: model.CouponFixed, AmountOff: money.MustNew(100000, "USD")}
+
+	tests := []struct {
+		name     string
+		currency string
+		coupon   *model.Coupon
+		region   string
+		want     [5]int64 // subtotal, discount, shipping, tax, total
+		taxRate  string
+	}{
+		{"no coupon", "USD", nil, "US-NY", [5]int64{3998, 0, 499, 0, 4497}, "0"},
+		{"percentage", "USD", percentage, "US-CA", [5]int64{3998, 400, 499, 261, 4358}, "7.25"},
+		{"fixed", "USD", fixed, "US-CA", [5]int64{3998, 500, 499, 254, 4251}, "7.25"},
+		{"discount at most the subtotal", "USD", huge, "US", [5]int64{3998, 3998, 499, 0, 499}, "0"},
+		{"shipping taxed", "USD", nil, "de", [5]int64{3998, 0, 499, 854, 5351}, "19"},
+		{"any other region", "USD", nil, "FR", [5]int64{3998, 0, 499, 400, 4897}, "10"},
+		// 39.98 USD, 5.00 USD and 4.99 USD at 0.9215
+		{"converted", "EUR", fixed, "DE", [5]int64{3684, 461, 460, 700, 4383}, "19"},
+	}
+	for _, tt := range tests {
+		quote, err := engine.Price(context.Background(), pricing.Request{
+			UnitPrice: money.MustNew(1999, "USD"),
+			Quantity:  2,
+			Currency:  tt.currency,
+			Coupon:    tt.coupon,
+			Region:    tt.region,
+		})
+		require.NoError(t, err, tt.name)
+		b := quote.Breakdown
+		assert.Equal(t, tt.want, [5]int64{b.Subtotal.Minor(), b.Discount.Minor(), b.Shipping.Minor(), b.Tax.Minor(), b.Total.Minor()}, tt.name)
+		assert.Equal(t, tt.currency, b.Total.Currency(), tt.name)
+		assert.Equal(t, json.Number(tt.taxRate), b.TaxRate, tt.name)
+		assert.Equal(t, money.MustNew(3998, "USD"), quote.ExchangeRate.BaseTotal, tt.name)
+	}
+}
+
+func TestEngine_PriceErrors(t *testing.T) {
+	taxes, err := pricing.NewTaxRules([]pricing.TaxRule{{Region: "US", Rate: "0"}})
+	require.NoError(t, err)
+	engine := &pricing.Engine{Taxes: taxes}
+	price := func(currency, region string) error {
+		_, err := engine.Price(context.Background(), pricing.Request{UnitPrice: money.MustNew(1999, "USD"), Quantity: 1, Currency: currency, Region: region})
+		return err
+	}
+
+	assert.NoError(t, price("", "US-CA"), "subdivisions fall back to their country")
+	assert.ErrorIs(t, price("USD", "DE"), pricing.ErrNoTaxRule)
+	assert.ErrorIs(t, price("USD", ""), pricing.ErrNoTaxRule)
+	assert.ErrorIs(t, price("EUR", "US"), pricing.ErrExchangeRate, "no rates, no conversion")
+
+	for _, quantity := range []int{0, -2} {
+		_, err := engine.Price(context.Background(), pricing.Request{UnitPrice: money.MustNew(1999, "USD"), Quantity: quantity, Region: "US"})
+		assert.ErrorIs(t, err, pricing.ErrInvalidQuantity, quantity)
+	}
+
+	// Without tax rules orders are not taxed
+	quote, err := (&pricing.Engine{}).Price(context.Background(), pricing.Request{UnitPrice: money.MustNew(1999, "USD"), Quantity: 1})
+	require.NoError(t, err)
+	assert.Equal(t, money.MustNew(1999, "USD"), quote.Breakdown.Total)
+}
+
+func TestNewTaxRules_Invalid(t *testing.T) {
+	for name, rules := range map[string][]pricing.TaxRule{
+		"no region": {{Rate: "5"}},
+		"region":    {{Region: "California", Rate: "5"}},
+		"twice":     {{Region: "DE", Rate: "19"}, {Region: "de", Rate: "7"}},
+		"negative":  {{Region: "DE", Rate: "-1"}},
+		"over 100":  {{Region: "DE", Rate: "101"}},
+		"decimals":  {{Region: "DE", Rate: "19.00001"}},
+	} {
+		_, err := pricing.NewTaxRules(rules)
+		assert.Error(t, err, name)
+	}
+
+	path := filepath.Join(t.TempDir(), "taxes.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"rules": [{"region": "US-NY", "rate": 8.875, "shipping_taxable": true}]}`), 0o644))
+	taxes, err := pricing.ReadTaxRules(path)
+	require.NoError(t, err)
+	rule, err := taxes.Lookup("us-ny")
+	require.NoError(t, err)
+	assert.Equal(t, pricing.TaxRule{Region: "US-NY", Rate: "8.875", ShippingTaxable: true}, rule)
+}
+
+func TestValidateCoupon(t *testing.T) {
+	coupon := &model.Coupon{Code: " spring-10 ", Type: model.CouponPercentage, PercentOff: "12.5"}
+	require.NoError(t, pricing.ValidateCoupon(coupon))
+	assert.Equal(t, "SPRING-10", coupon.Code)
+
+	from, until := time.Now(), time.Now().Add(-time.Hour)
+	for name, coupon := range map[string]model.Coupon{
+		"code":               {Code: "spring 10", Type: model.CouponPercentage, PercentOff: "10"},
+		"type":               {Code: "X", Type: "bogo"},
+		"no percentage":      {Code: "X", Type: model.CouponPercentage},
+		"percentage over":    {Code: "X", Type: model.CouponPercentage, PercentOff: "100.01"},
+		"percentage decimal": {Code: "X", Type: model.CouponPercentage, PercentOff: "10.005"},
+		"percentage amount":  {Code: "X", Type: model.CouponPercentage, PercentOff: "10", AmountOff: money.MustNew(500, "USD")},
+		"no amount":          {Code: "X", Type: model.CouponFixed},
+		"negative amount":    {Code: "X", Type: model.CouponFixed, AmountOff: money.MustNew(-500, "USD")},
+		"window":             {Code: "X", Type: model.CouponFixed, AmountOff: money.MustNew(500, "USD"), ValidFrom: &from, ValidUntil: &until},
+		"max uses":           {Code: "X", Type: model.CouponFixed, AmountOff: money.MustNew(500, "USD"), MaxUses: -1},
+	} {
+		assert.ErrorIs(t, pricing.ValidateCoupon(&coupon), pricing.ErrInvalidCoupon, name)
+	}
+}
+
+func TestCheckCoupon(t *testing.T) {
+	now := time.Date(2024, 6, 3, 12, 0, 0, 0, time.UTC)
+	from, until := now.Add(-time.Hour), now.Add(time.Hour)
+	coupon := &model.Coupon{Code: "X", Type: model.CouponPercentage, PercentOff: "10", ValidFrom: &from, ValidUntil: &until, MaxUses: 2, Uses: 1}
+
+	assert.NoError(t, pricing.CheckCoupon(coupon, now))
+	assert.NoError(t, pricing.CheckCoupon(coupon, from), "valid from is included")
+	assert.ErrorIs(t, pricing.CheckCoupon(coupon, from.Add(-time.Second)), pricing.ErrCouponNotYetValid)
+	assert.ErrorIs(t, pricing.CheckCoupon(coupon, until), pricing.ErrCouponExpired, "valid until is excluded")
+
+	coupon.Uses = 2
+	assert.ErrorIs(t, pricing.CheckCoupon(coupon, now), pricing.ErrCouponUsedUp)
+	coupon.MaxUses = 0
+	assert.NoError(t, pricing.CheckCoupon(coupon, now), "no maximum")
+}
+
+// setupCoupons returns a router creating orders of product 1, priced at
+// 19.99 USD, with the coupons of the repository
+func setupCoupons(t *testing.T) (*gin.Engine, *repository.MemoryCouponRepository, *MockOrderRepository) {
+	gin.SetMode(gin.TestMode)
+	coupons := repository.NewMemoryCouponRepository()
+	mockOrderRepo := new(MockOrderRepository)
+	mockInventory := new(MockInventoryService)
+	mockQueue := new(MockPublisher)
+	mockCache := new(MockCache)
+	mockProduct := new(MockProductService)
+	mockInventory.On("CheckAvailability", 1, mock.Anything).Return(true, nil)
+	mockProduct.On("GetProductPrice", 1).Return(money.MustNew(1999, "USD"), nil)
+	mockQueue.On("Publish", mock.Anything, mock.AnythingOfType("broker.Message")).Return(nil)
+	mockCache.On("Delete", mock.Anything).Return(nil)
+
+	orderController := &controller.OrderController{
+		OrderRepo:           mockOrderRepo,
+		Coupons:             coupons,
+		InventoryService:    mockInventory,
+		NotificationService: new(MockNotificationService),
+		Broker:              mockQueue,
+		Cache:               mockCache,
+		ProductService:      mockProduct,
+		Pricing:             &pricing.Engine{Taxes: pricingTaxes(t)},
+	}
+	router := gin.New()
+	router.POST("/orders", orderController.CreateOrder)
+	router.POST("/coupons", orderController.CreateCoupon)
+	router.GET("/coupons", orderController.GetCoupons)
+	router.GET("/coupons/:code", orderController.GetCoupon)
+	return router, coupons, mockOrderRepo
+}
+
+// post sends a JSON body to a router
+func post(router *gin.Engine, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCoupons(t *testing.T) {
+	router, _, _ := setupCoupons(t)
+
+	w := post(router, "/coupons", `{"code": "five", "type": "fixed", "amount_off": 5, "currency": "usd", "max_uses": 100}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var coupon model.Coupon
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &coupon))
+	assert.Equal(t, "FIVE", coupon.Code)
+	assert.Equal(t, money.MustNew(500, "USD"), coupon.AmountOff)
+
+	assert.Equal(t, http.StatusConflict, post(router, "/coupons", `{"code": "Five", "type": "percentage", "percent_off": 5}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post(router, "/coupons", `{"code": "TEN", "type": "percentage", "percent_off": 150}`).Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/coupons/five", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"code": "FIVE", "type": "fixed", "amount_off": 5, "currency": "USD", "max_uses": 100, "uses": 0, "created_at": "`+
+		coupon.CreatedAt.Format(time.RFC3339Nano)+`"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/coupons/TEN", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/coupons", nil))
+	var coupons []model.Coupon
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &coupons))
+	assert.Len(t, coupons, 1)
+}
+
+func TestCreateOrder_IgnoresClientTotal(t *testing.T) {
+	router, _, mockOrderRepo := setupCoupons(t)
+	mockOrderRepo.On("InsertOrder", mock.AnythingOfType("*model.Order")).Return(nil)
+
+	w := post(router, "/orders", `{"customer_id": 1, "product_id": 1, "quantity": 2, "total_price": 0.01, "currency": "USD", "region": "US-CA"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var order model.Order
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+	assert.Equal(t, money.MustNew(4288, "USD"), order.TotalPrice, "39.98 USD and 7.25% tax")
+	assert.Equal(t, money.MustNew(290, "USD"), order.Breakdown.Tax)
+
+	assert.Equal(t, http.StatusBadRequest, post(router, "/orders", `{"customer_id": 1, "product_id": 1, "quantity": 2, "region": "California"}`).Code)
+
+	for _, quantity := range []string{"0", "-2"} {
+		w := post(router, "/orders", `{"customer_id": 1, "product_id": 1, "quantity": `+quantity+`, "currency": "USD", "region": "US"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code, quantity)
+		assert.Contains(t, w.Body.String(), "quantity must be at least 1")
+	}
+	mockOrderRepo.AssertNumberOfCalls(t, "InsertOrder", 1)
+}
+
+func TestCreateOrder_Coupon(t *testing.T) {
+	router, coupons, mockOrderRepo := setupCoupons(t)
+	mockOrderRepo.On("InsertOrder", mock.AnythingOfType("*model.Order")).Return(nil)
+	past := time.Now().Add(-time.Hour)
+	require.NoError(t, coupons.CreateCoupon(&model.Coupon{Code: "TEN", Type: model.CouponPercentage, PercentOff: "10", MaxUses: 1}))
+	require.NoError(t, coupons.CreateCoupon(&model.Coupon{Code: "OLD", Type: model.CouponPercentage, PercentOff: "10", ValidUntil: &past}))
+
+	w := post(router, "/orders", `{"customer_id": 1, "product_id": 1, "quantity": 2, "region": "US", "coupon_code": "ten"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var order model.Order
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+	assert.Equal(t, "TEN", order.CouponCode)
+	assert.Equal(t, money.MustNew(400, "USD"), order.Breakdown.Discount)
+	assert.Equal(t, money.MustNew(3598, "USD"), order.TotalPrice)
+
+	for code, message := range map[string]string{
+		"TEN":  "Coupon TEN cannot be applied: coupon has been used up",
+		"OLD":  "Coupon OLD cannot be applied: coupon has expired",
+		"NONE": "Coupon NONE does not exist",
+	} {
+		w := post(router, "/orders", `{"customer_id": 1, "product_id": 1, "quantity": 2, "region": "US", "coupon_code": "`+code+`"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code, code)
+		assert.Contains(t, w.Body.String(), message)
+	}
+}
+
+func TestCreateOrder_ReleasesCouponOfFailedOrder(t *testing.T) {
+	router, coupons, mockOrderRepo := setupCoupons(t)
+	mockOrderRepo.On("InsertOrder", mock.AnythingOfType("*model.Order")).Return(errors.New("connection refused"))
+	require.NoError(t, coupons.CreateCoupon(&model.Coupon{Code: "TEN", Type: model.CouponPercentage, PercentOff: "10", MaxUses: 1}))
+
+	w := post(router, "/orders", `{"customer_id": 1, "product_id": 1, "quantity": 2, "region": "US", "coupon_code": "TEN"}`)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	coupon, err := coupons.GetCoupon("TEN")
+	require.NoError(t, err)
+	assert.Equal(t, 0, coupon.Uses)
+}
@@ -0,0 +1,635 @@
+// Code generated by apigen from openapi.json. DO NOT EDIT.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	_ = bytes.NewReader
+	_ = strings.NewReader
+	_ = time.Now
+	_ = url.PathEscape
+)
+
+// Payment represents a payment transaction for an order
+type Payment struct {
+	// Identifier assigned by the service
+	ID int `json:"id"`
+	// The order this record belongs to
+	OrderID int `json:"order_id"`
+	// The customer who placed the order
+	CustomerID int `json:"customer_id"`
+	// Amount in major currency units
+	Amount float64 `json:"amount"`
+	// ISO 4217 currency code, upper case
+	Currency string        `json:"currency"`
+	Status   PaymentStatus `json:"status"`
+	// ID of the Stripe payment intent
+	StripePaymentID string `json:"stripe_payment_id,omitempty"`
+	// Stripe payment method type once confirmed, encrypted at rest
+	PaymentMethod string        `json:"payment_method,omitempty"`
+	CaptureMethod CaptureMethod `json:"capture_method,omitempty"`
+	// Amount actually charged, in major currency units
+	AmountCaptured float64 `json:"amount_captured,omitempty"`
+	// Total refunded so far, in major currency units
+	AmountRefunded float64 `json:"amount_refunded,omitempty"`
+	// When the uncaptured authorization was flagged as about to expire
+	ExpiryFlaggedAt time.Time `json:"expiry_flagged_at,omitempty"`
+	// When the record was created
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	// When the record was last updated
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
+
+// PaymentStatus represents the lifecycle state of a payment
+type PaymentStatus string
+
+const (
+	PaymentStatusPending           PaymentStatus = "pending"
+	PaymentStatusRequiresCapture   PaymentStatus = "requires_capture"
+	PaymentStatusSucceeded         PaymentStatus = "succeeded"
+	PaymentStatusFailed            PaymentStatus = "failed"
+	PaymentStatusCanceled          PaymentStatus = "canceled"
+	PaymentStatusPartiallyRefunded PaymentStatus = "partially_refunded"
+	PaymentStatusRefunded          PaymentStatus = "refunded"
+)
+
+// CaptureMethod represents when a payment is captured: as soon as it is authorized, or later through the capture endpoint
+type CaptureMethod string
+
+const (
+	CaptureMethodAutomatic CaptureMethod = "automatic"
+	CaptureMethodManual    CaptureMethod = "manual"
+)
+
+// PaymentRequest represents a request to create a payment intent
+type PaymentRequest struct {
+	// The order this record belongs to
+	OrderID int `json:"order_id"`
+	// The customer who placed the order
+	CustomerID int `json:"customer_id"`
+	// Amount in major currency units, with at most as many decimals as the currency has minor digits
+	Amount float64 `json:"amount"`
+	// ISO 4217 currency code, in any case
+	Currency      string        `json:"currency"`
+	CaptureMethod CaptureMethod `json:"capture_method,omitempty"`
+}
+
+// PaymentConfirmRequest represents a request to refresh a payment from Stripe
+type PaymentConfirmRequest struct {
+	PaymentIntentID string `json:"payment_intent_id"`
+}
+
+// PaymentResponse represents a payment together with the Stripe client secret when it was just created
+type PaymentResponse struct {
+	Payment Payment `json:"payment"`
+	// Secret letting the customer's browser confirm the payment, only returned when it is created and never stored
+	ClientSecret string `json:"client_secret,omitempty"`
+	Message      string `json:"message,omitempty"`
+}
+
+// PaymentPage represents a page of a payment listing, newest first
+type PaymentPage struct {
+	Payments []Payment `json:"payments"`
+	// Number of the page, from 1
+	Page int `json:"page"`
+	// Maximum number of payments per page
+	PageSize int `json:"page_size"`
+	// Number of payments matching the filters, on every page
+	Total int `json:"total"`
+}
+
+// PaymentTotal represents sums of the payments of a status in a currency
+type PaymentTotal struct {
+	Status PaymentStatus `json:"status"`
+	// ISO 4217 currency code, upper case
+	Currency string `json:"currency"`
+	// Number of payments
+	Count int `json:"count"`
+	// Sum of the payment amounts in major currency units
+	Amount float64 `json:"amount"`
+	// Sum of the captured amounts in major currency units
+	AmountCaptured float64 `json:"amount_captured"`
+	// Sum of the refunded amounts in major currency units
+	AmountRefunded float64 `json:"amount_refunded"`
+}
+
+// CaptureRequest represents a request to capture an authorized payment
+type CaptureRequest struct {
+	// Amount to capture in major units of the payment currency, the whole authorization if omitted
+	Amount float64 `json:"amount,omitempty"`
+}
+
+// CancelReason represents the reason a payment is canceled, as reported to Stripe
+type CancelReason string
+
+const (
+	CancelReasonDuplicate           CancelReason = "duplicate"
+	CancelReasonFraudulent          CancelReason = "fraudulent"
+	CancelReasonRequestedByCustomer CancelReason = "requested_by_customer"
+	CancelReasonAbandoned           CancelReason = "abandoned"
+)
+
+// CancelRequest represents a request to cancel a payment that was not captured
+type CancelRequest struct {
+	Reason CancelReason `json:"reason,omitempty"`
+}
+
+// Refund represents a full or partial refund of a payment
+type Refund struct {
+	// Identifier assigned by the service
+	ID int `json:"id"`
+	// The refunded payment
+	PaymentID int `json:"payment_id"`
+	// Amount in major currency units
+	Amount float64 `json:"amount"`
+	// ISO 4217 currency code, upper case
+	Currency string       `json:"currency"`
+	Reason   RefundReason `json:"reason,omitempty"`
+	// The state of the refund at Stripe
+	Status string `json:"status"`
+	// ID of the Stripe refund
+	StripeRefundID string `json:"stripe_refund_id,omitempty"`
+	// When the record was created
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	// When the record was last updated
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
+
+// RefundReason represents the reason a payment is refunded, as reported to Stripe
+type RefundReason string
+
+const (
+	RefundReasonDuplicate           RefundReason = "duplicate"
+	RefundReasonFraudulent          RefundReason = "fraudulent"
+	RefundReasonRequestedByCustomer RefundReason = "requested_by_customer"
+)
+
+// RefundRequest represents a request to refund a payment
+type RefundRequest struct {
+	// Amount to refund in major units of the payment currency, the rest of the payment if omitted
+	Amount float64      `json:"amount,omitempty"`
+	Reason RefundReason `json:"reason,omitempty"`
+}
+
+// RefundResponse represents a refund together with the refunded payment
+type RefundResponse struct {
+	Refund  Refund  `json:"refund"`
+	Payment Payment `json:"payment"`
+}
+
+// PaymentAccess represents a read of a payment in the access log
+type PaymentAccess struct {
+	// Identifier assigned by the service
+	ID int `json:"id"`
+	// The payment read
+	PaymentID int `json:"payment_id"`
+	// Service named and signed for in the X-Actor headers, or anonymous
+	Actor string `json:"actor"`
+	// Address of the caller
+	ClientIp string `json:"client_ip"`
+	// Method and route of the request
+	Action string `json:"action"`
+	// X-Request-ID or X-Correlation-ID header of the request
+	RequestID string `json:"request_id,omitempty"`
+	// When the payment was read
+	AccessedAt time.Time `json:"accessed_at"`
+}
+
+// WebhookResponse represents the acknowledgement of a Stripe webhook event
+type WebhookResponse struct {
+	// ID of the Stripe event
+	EventID string `json:"event_id"`
+	// Whether the event updated a payment, was already processed, or changed nothing
+	Result string `json:"result"`
+}
+
+// Health represents the health of the service
+type Health struct {
+	Status  string    `json:"status"`
+	Service string    `json:"service"`
+	Time    time.Time `json:"time,omitempty"`
+}
+
+// Error represents the body of every error response
+type Error struct {
+	Error string `json:"error"`
+}
+
+// ListPaymentsParams holds the query parameters of ListPayments
+type ListPaymentsParams struct {
+	CustomerID  *int           `form:"customer_id" json:"customer_id,omitempty"`
+	Status      *PaymentStatus `form:"status" json:"status,omitempty"`
+	Currency    *string        `form:"currency" json:"currency,omitempty"`
+	CreatedFrom *time.Time     `form:"created_from" json:"created_from,omitempty"`
+	CreatedTo   *time.Time     `form:"created_to" json:"created_to,omitempty"`
+	MinAmount   *float64       `form:"min_amount" json:"min_amount,omitempty"`
+	MaxAmount   *float64       `form:"max_amount" json:"max_amount,omitempty"`
+	Page        *int           `form:"page" json:"page,omitempty"`
+	PageSize    *int           `form:"page_size" json:"page_size,omitempty"`
+}
+
+func (p *ListPaymentsParams) values() url.Values {
+	v := url.Values{}
+	if p == nil {
+		return v
+	}
+	if p.CustomerID != nil {
+		v.Set("customer_id", fmt.Sprint(*p.CustomerID))
+	}
+	if p.Status != nil {
+		v.Set("status", fmt.Sprint(*p.Status))
+	}
+	if p.Currency != nil {
+		v.Set("currency", fmt.Sprint(*p.Currency))
+	}
+	if p.CreatedFrom != nil {
+		v.Set("created_from", p.CreatedFrom.Format(time.RFC3339))
+	}
+	if p.CreatedTo != nil {
+		v.Set("created_to", p.CreatedTo.Format(time.RFC3339))
+	}
+	if p.MinAmount != nil {
+		v.Set("min_amount", fmt.Sprint(*p.MinAmount))
+	}
+	if p.MaxAmount != nil {
+		v.Set("max_amount", fmt.Sprint(*p.MaxAmount))
+	}
+	if p.Page != nil {
+		v.Set("page", fmt.Sprint(*p.Page))
+	}
+	if p.PageSize != nil {
+		v.Set("page_size", fmt.Sprint(*p.PageSize))
+	}
+	return v
+}
+
+// GetPaymentsByCustomerParams holds the query parameters of GetPaymentsByCustomer
+type GetPaymentsByCustomerParams struct {
+	Status      *PaymentStatus `form:"status" json:"status,omitempty"`
+	Currency    *string        `form:"currency" json:"currency,omitempty"`
+	CreatedFrom *time.Time     `form:"created_from" json:"created_from,omitempty"`
+	CreatedTo   *time.Time     `form:"created_to" json:"created_to,omitempty"`
+	MinAmount   *float64       `form:"min_amount" json:"min_amount,omitempty"`
+	MaxAmount   *float64       `form:"max_amount" json:"max_amount,omitempty"`
+	Page        *int           `form:"page" json:"page,omitempty"`
+	PageSize    *int           `form:"page_size" json:"page_size,omitempty"`
+}
+
+func (p *GetPaymentsByCustomerParams) values() url.Values {
+	v := url.Values{}
+	if p == nil {
+		return v
+	}
+	if p.Status != nil {
+		v.Set("status", fmt.Sprint(*p.Status))
+	}
+	if p.Currency != nil {
+		v.Set("currency", fmt.Sprint(*p.Currency))
+	}
+	if p.CreatedFrom != nil {
+		v.Set("created_from", p.CreatedFrom.Format(time.RFC3339))
+	}
+	if p.CreatedTo != nil {
+		v.Set("created_to", p.CreatedTo.Format(time.RFC3339))
+	}
+	if p.MinAmount != nil {
+		v.Set("min_amount", fmt.Sprint(*p.MinAmount))
+	}
+	if p.MaxAmount != nil {
+		v.Set("max_amount", fmt.Sprint(*p.MaxAmount))
+	}
+	if p.Page != nil {
+		v.Set("page", fmt.Sprint(*p.Page))
+	}
+	if p.PageSize != nil {
+		v.Set("page_size", fmt.Sprint(*p.PageSize))
+	}
+	return v
+}
+
+// GetPaymentTotalsParams holds the query parameters of GetPaymentTotals
+type GetPaymentTotalsParams struct {
+	CustomerID  *int           `form:"customer_id" json:"customer_id,omitempty"`
+	Status      *PaymentStatus `form:"status" json:"status,omitempty"`
+	Currency    *string        `form:"currency" json:"currency,omitempty"`
+	CreatedFrom *time.Time     `form:"created_from" json:"created_from,omitempty"`
+	CreatedTo   *time.Time     `form:"created_to" json:"created_to,omitempty"`
+	MinAmount   *float64       `form:"min_amount" json:"min_amount,omitempty"`
+	MaxAmount   *float64       `form:"max_amount" json:"max_amount,omitempty"`
+}
+
+func (p *GetPaymentTotalsParams) values() url.Values {
+	v := url.Values{}
+	if p == nil {
+		return v
+	}
+	if p.CustomerID != nil {
+		v.Set("customer_id", fmt.Sprint(*p.CustomerID))
+	}
+	if p.Status != nil {
+		v.Set("status", fmt.Sprint(*p.Status))
+	}
+	if p.Currency != nil {
+		v.Set("currency", fmt.Sprint(*p.Currency))
+	}
+	if p.CreatedFrom != nil {
+		v.Set("created_from", p.CreatedFrom.Format(time.RFC3339))
+	}
+	if p.CreatedTo != nil {
+		v.Set("created_to", p.CreatedTo.Format(time.RFC3339))
+	}
+	if p.MinAmount != nil {
+		v.Set("min_amount", fmt.Sprint(*p.MinAmount))
+	}
+	if p.MaxAmount != nil {
+		v.Set("max_amount", fmt.Sprint(*p.MaxAmount))
+	}
+	return v
+}
+
+// APIError is returned by Client when the server answers with a non-2xx status
+type APIError struct {
+	StatusCode int
+	Message    string
+	Body       []byte
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("Payment Service API returned status %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("Payment Service API returned status %d", e.StatusCode)
+}
+
+// Client calls the Payment Service API over HTTP
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// NewClient creates a client for the service at baseURL. A nil httpClient
+// uses a client with a ten second timeout.
+func NewClient(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/"), HTTPClient: httpClient}
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	target := c.BaseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		apiErr := &APIError{StatusCode: resp.StatusCode, Body: data}
+		var payload struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &payload) == nil {
+			apiErr.Message = payload.Error
+		}
+		return apiErr
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// HealthCheck reports the health of the payment service
+func (c *Client) HealthCheck(ctx context.Context) (*Health, error) {
+	var out Health
+	if err := c.do(ctx, "GET", "/health", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListPayments lists the payments matching the filters, newest first, a page at a time
+func (c *Client) ListPayments(ctx context.Context, params *ListPaymentsParams) (*PaymentPage, error) {
+	var out PaymentPage
+	if err := c.do(ctx, "GET", "/payments", params.values(), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreatePayment creates a Stripe payment intent for an order
+func (c *Client) CreatePayment(ctx context.Context, body PaymentRequest) (*PaymentResponse, error) {
+	var out PaymentResponse
+	if err := c.do(ctx, "POST", "/payments", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ConfirmPayment refreshes a payment's status from its Stripe payment intent
+func (c *Client) ConfirmPayment(ctx context.Context, body PaymentConfirmRequest) (*PaymentResponse, error) {
+	var out PaymentResponse
+	if err := c.do(ctx, "POST", "/payments/confirm", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetPaymentsByCustomer lists the payment history of a customer, newest first, a page at a time
+func (c *Client) GetPaymentsByCustomer(ctx context.Context, customerID int, params *GetPaymentsByCustomerParams) (*PaymentPage, error) {
+	var out PaymentPage
+	if err := c.do(ctx, "GET", fmt.Sprintf("/payments/customer/%s", url.PathEscape(fmt.Sprint(customerID))), params.values(), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetPaymentsByOrder lists the payments of an order, newest first
+func (c *Client) GetPaymentsByOrder(ctx context.Context, orderID int) ([]Payment, error) {
+	var out []Payment
+	if err := c.do(ctx, "GET", fmt.Sprintf("/payments/order/%s", url.PathEscape(fmt.Sprint(orderID))), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetPaymentTotals sums the payments matching the filters by status and currency
+func (c *Client) GetPaymentTotals(ctx context.Context, params *GetPaymentTotalsParams) ([]PaymentTotal, error) {
+	var out []PaymentTotal
+	if err := c.do(ctx, "GET", "/payments/totals", params.values(), nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// HandleStripeWebhook applies a signed Stripe event to its payment and publishes the payment's outcome
+func (c *Client) HandleStripeWebhook(ctx context.Context, body map[string]interface{}) (*WebhookResponse, error) {
+	var out WebhookResponse
+	if err := c.do(ctx, "POST", "/payments/webhook", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetPayment returns a payment by ID
+func (c *Client) GetPayment(ctx context.Context, id int) (*Payment, error) {
+	var out Payment
+	if err := c.do(ctx, "GET", fmt.Sprintf("/payments/%s", url.PathEscape(fmt.Sprint(id))), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetAccessLog lists who read a payment, newest first
+func (c *Client) GetAccessLog(ctx context.Context, id int) ([]PaymentAccess, error) {
+	var out []PaymentAccess
+	if err := c.do(ctx, "GET", fmt.Sprintf("/payments/%s/access-log", url.PathEscape(fmt.Sprint(id))), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CancelPayment cancels a payment that was not captured, releasing its authorization
+func (c *Client) CancelPayment(ctx context.Context, id int, body CancelRequest) (*PaymentResponse, error) {
+	var out PaymentResponse
+	if err := c.do(ctx, "POST", fmt.Sprintf("/payments/%s/cancel", url.PathEscape(fmt.Sprint(id))), nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CapturePayment captures an authorized manual capture payment, in full or in part
+func (c *Client) CapturePayment(ctx context.Context, id int, body CaptureRequest) (*PaymentResponse, error) {
+	var out PaymentResponse
+	if err := c.do(ctx, "POST", fmt.Sprintf("/payments/%s/capture", url.PathEscape(fmt.Sprint(id))), nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetRefunds lists the refunds of a payment, oldest first
+func (c *Client) GetRefunds(ctx context.Context, id int) ([]Refund, error) {
+	var out []Refund
+	if err := c.do(ctx, "GET", fmt.Sprintf("/payments/%s/refunds", url.PathEscape(fmt.Sprint(id))), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateRefund refunds a captured payment through Stripe, in full or in part
+func (c *Client) CreateRefund(ctx context.Context, id int, body RefundRequest) (*RefundResponse, error) {
+	var out RefundResponse
+	if err := c.do(ctx, "POST", fmt.Sprintf("/payments/%s/refunds", url.PathEscape(fmt.Sprint(id))), nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ServerInterface is implemented by the handlers serving the Payment Service API
+type ServerInterface interface {
+	// HealthCheck handles GET /health
+	HealthCheck(c *gin.Context)
+	// ListPayments handles GET /payments
+	ListPayments(c *gin.Context)
+	// CreatePayment handles POST /payments
+	CreatePayment(c *gin.Context)
+	// ConfirmPayment handles POST /payments/confirm
+	ConfirmPayment(c *gin.Context)
+	// GetPaymentsByCustomer handles GET /payments/customer/{customerId}
+	GetPaymentsByCustomer(c *gin.Context)
+	// GetPaymentsByOrder handles GET /payments/order/{orderId}
+	GetPaymentsByOrder(c *gin.Context)
+	// GetPaymentTotals handles GET /payments/totals
+	GetPaymentTotals(c *gin.Context)
+	// HandleStripeWebhook handles POST /payments/webhook
+	HandleStripeWebhook(c *gin.Context)
+	// GetPayment handles GET /payments/{id}
+	GetPayment(c *gin.Context)
+	// GetAccessLog handles GET /payments/{id}/access-log
+	GetAccessLog(c *gin.Context)
+	// CancelPayment handles POST /payments/{id}/cancel
+	CancelPayment(c *gin.Context)
+	// CapturePayment handles POST /payments/{id}/capture
+	CapturePayment(c *gin.Context)
+	// GetRefunds handles GET /payments/{id}/refunds
+	GetRefunds(c *gin.Context)
+	// CreateRefund handles POST /payments/{id}/refunds
+	CreateRefund(c *gin.Context)
+}
+
+// RegisterHandlers registers every operation of the spec on router
+func RegisterHandlers(router gin.IRoutes, si ServerInterface) {
+	router.GET("/health", si.HealthCheck)
+	router.GET("/payments", si.ListPayments)
+	router.POST("/payments", si.CreatePayment)
+	router.POST("/payments/confirm", si.ConfirmPayment)
+	router.GET("/payments/customer/:customerId", si.GetPaymentsByCustomer)
+	router.GET("/payments/order/:orderId", si.GetPaymentsByOrder)
+	router.GET("/payments/totals", si.GetPaymentTotals)
+	router.POST("/payments/webhook", si.HandleStripeWebhook)
+	router.GET("/payments/:id", si.GetPayment)
+	router.GET("/payments/:id/access-log", si.GetAccessLog)
+	router.POST("/payments/:id/cancel", si.CancelPayment)
+	router.POST("/payments/:id/capture", si.CapturePayment)
+	router.GET("/payments/:id/refunds", si.GetRefunds)
+	router.POST("/payments/:id/refunds", si.CreateRefund)
+}
+
+// Routes lists the method and gin path of every operation in the spec
+var Routes = []struct{ Method, Path, OperationID string }{
+	{"GET", "/health", "HealthCheck"},
+	{"GET", "/payments", "ListPayments"},
+	{"POST", "/payments", "CreatePayment"},
+	{"POST", "/payments/confirm", "ConfirmPayment"},
+	{"GET", "/payments/customer/:customerId", "GetPaymentsByCustomer"},
+	{"GET", "/payments/order/:orderId", "GetPaymentsByOrder"},
+	{"GET", "/payments/totals", "GetPaymentTotals"},
+	{"POST", "/payments/webhook", "HandleStripeWebhook"},
+	{"GET", "/payments/:id", "GetPayment"},
+	{"GET", "/payments/:id/access-log", "GetAccessLog"},
+	{"POST", "/payments/:id/cancel", "CancelPayment"},
+	{"POST", "/payments/:id/capture", "CapturePayment"},
+	{"GET", "/payments/:id/refunds", "GetRefunds"},
+	{"POST", "/payments/:id/refunds", "CreateRefund"},
+}
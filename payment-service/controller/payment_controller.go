@@ -1,7 +1,9 @@
 package controller
 
 import (
-	"database/sql"
+	"context"
+	"errors"
+	"io"
 	"log"
 	"net/http"
 	"os"
@@ -9,24 +11,62 @@ import (
 	"time"
 
 	"go-microservices/payment-service/model"
+	"go-microservices/payment-service/service"
+	"go-microservices/pkg/actor"
+	"go-microservices/pkg/events"
+	"go-microservices/pkg/money"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stripe/stripe-go/v76"
-	"github.com/stripe/stripe-go/v76/paymentintent"
+	"github.com/stripe/stripe-go/v76/webhook"
 )
 
+// maxWebhookBytes bounds the size of a webhook request body; Stripe events
+// are far smaller
+const maxWebhookBytes = 64 << 10
+
+// PaymentServiceInterface defines the payment operations used by the controller
+type PaymentServiceInterface interface {
+	CreatePayment(req model.PaymentRequest) (*model.PaymentResponse, error)
+	ConfirmPayment(paymentIntentID string) (*model.PaymentResponse, error)
+	GetPayment(id int) (*model.Payment, error)
+	GetPaymentsByOrder(orderID int) ([]model.Payment, error)
+	ListPayments(filter model.PaymentFilter) (*model.PaymentPage, error)
+	GetPaymentTotals(filter model.PaymentFilter) ([]model.PaymentTotal, error)
+	CapturePayment(paymentID int, req model.CaptureRequest) (*model.PaymentResponse, error)
+	CancelPayment(paymentID int, req model.CancelRequest) (*model.PaymentResponse, error)
+	RefundPayment(paymentID int, req model.RefundRequest) (*model.RefundResponse, error)
+	GetRefunds(paymentID int) ([]model.Refund, error)
+	RecordAccess(accessor model.Accessor, paymentIDs ...int) error
+	GetAccessLog(paymentID int) ([]model.PaymentAccess, error)
+	HandleWebhookEvent(ctx context.Context, event stripe.Event) (*model.Payment, error)
+}
+
+// PaymentController handles payment-related requests
 type PaymentController struct {
-	db *sql.DB
+	Service PaymentServiceInterface
+	// WebhookSecret is the signing secret Stripe webhook events are
+	// verified with
+	WebhookSecret string
+	// ActorSecret verifies the callers named in the access log of payment
+	// reads. Callers without a valid signature are logged as anonymous.
+	ActorSecret []byte
 }
 
-func NewPaymentController(db *sql.DB) *PaymentController {
-	// Initialize Stripe
-	stripe.Key = os.Getenv("STRIPE_SECRET_KEY")
-	if stripe.Key == "" {
-		log.Fatal("STRIPE_SECRET_KEY is required but not set")
-	}	
+// NewPaymentController creates a new payment controller
+func NewPaymentController(paymentService PaymentServiceInterface) *PaymentController {
+	webhookSecret := os.Getenv("STRIPE_WEBHOOK_SECRET")
+	if webhookSecret == "" {
+		log.Println("Warning: STRIPE_WEBHOOK_SECRET is not set, Stripe webhook events will be rejected")
+	}
+	actorSecret := actor.SecretFromEnv()
+	if actorSecret == nil {
+		log.Println("Warning: ACTOR_SECRET is not set, payment reads will be logged as anonymous")
+	}
 	return &PaymentController{
-		db: db,
+		Service:       paymentService,
+		WebhookSecret: webhookSecret,
+		ActorSecret:   actorSecret,
 	}
 }
 
@@ -38,182 +78,286 @@ func (pc *PaymentController) CreatePayment(c *gin.Context) {
 		return
 	}
 
-	// Convert amount to cents for Stripe (Stripe expects amounts in cents)
-	amountCents := int64(req.Amount * 100)
+	response, err := pc.Service.CreatePayment(req)
+	if err != nil {
+		respondError(c, err, "Failed to save payment")
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
 
-	// Create payment intent with Stripe
-	params := &stripe.PaymentIntentParams{
-		Amount:   stripe.Int64(amountCents),
-		Currency: stripe.String(req.Currency),
-		Metadata: map[string]string{
-			"order_id":    strconv.Itoa(req.OrderID),
-			"customer_id": strconv.Itoa(req.CustomerID),
-		},
+// ConfirmPayment confirms a payment and updates the status
+func (pc *PaymentController) ConfirmPayment(c *gin.Context) {
+	var req model.PaymentConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	pi, err := paymentintent.New(params)
+	response, err := pc.Service.ConfirmPayment(req.PaymentIntentID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment intent: " + err.Error()})
+		respondError(c, err, "Failed to update payment")
 		return
 	}
 
-	// Save payment to database
-	payment := model.Payment{
-		OrderID:            req.OrderID,
-		CustomerID:         req.CustomerID,
-		Amount:             req.Amount,
-		Currency:           req.Currency,
-		Status:             model.PaymentStatusPending,
-		StripePaymentID:    pi.ID,
-		StripeClientSecret: pi.ClientSecret,
-		CreatedAt:          time.Now(),
-		UpdatedAt:          time.Now(),
+	c.JSON(http.StatusOK, response)
+}
+
+// GetPayment retrieves a payment by ID
+func (pc *PaymentController) GetPayment(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment ID"})
+		return
 	}
 
-	query := `
-		INSERT INTO payments (order_id, customer_id, amount, currency, status, stripe_payment_id, stripe_client_secret, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-		RETURNING id
-	`
+	payment, err := pc.Service.GetPayment(id)
+	if err != nil {
+		respondError(c, err, "Failed to retrieve payment")
+		return
+	}
+	if !pc.recordAccess(c, payment.ID) {
+		return
+	}
+
+	c.JSON(http.StatusOK, payment)
+}
+
+// GetPaymentsByOrder retrieves all payments for an order
+func (pc *PaymentController) GetPaymentsByOrder(c *gin.Context) {
+	orderIDParam := c.Param("orderId")
+	orderID, err := strconv.Atoi(orderIDParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
 
-	err = pc.db.QueryRow(query, payment.OrderID, payment.CustomerID, payment.Amount, payment.Currency, 
-		payment.Status, payment.StripePaymentID, payment.StripeClientSecret, payment.CreatedAt, payment.UpdatedAt).Scan(&payment.ID)
+	payments, err := pc.Service.GetPaymentsByOrder(orderID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save payment: " + err.Error()})
+		respondError(c, err, "Failed to retrieve payments")
+		return
+	}
+	if !pc.recordAccess(c, paymentIDs(payments)...) {
 		return
 	}
 
-	response := model.PaymentResponse{
-		Payment:      payment,
-		ClientSecret: pi.ClientSecret,
-		Message:      "Payment intent created successfully",
+	c.JSON(http.StatusOK, payments)
+}
+
+// ListPayments retrieves a page of the payments matching the query filters
+func (pc *PaymentController) ListPayments(c *gin.Context) {
+	var filter model.PaymentFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	c.JSON(http.StatusCreated, response)
+	pc.listPayments(c, filter)
 }
 
-// ConfirmPayment confirms a payment and updates the status
-func (pc *PaymentController) ConfirmPayment(c *gin.Context) {
-	var req model.PaymentConfirmRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+// GetPaymentsByCustomer retrieves a page of a customer's payments matching
+// the query filters
+func (pc *PaymentController) GetPaymentsByCustomer(c *gin.Context) {
+	customerID, err := strconv.Atoi(c.Param("customerId"))
+	if err != nil || customerID < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid customer ID"})
+		return
+	}
+
+	var filter model.PaymentFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	filter.CustomerID = customerID
 
-	// Retrieve payment intent from Stripe
-	pi, err := paymentintent.Get(req.PaymentIntentID, nil)
+	pc.listPayments(c, filter)
+}
+
+// GetPaymentTotals sums the payments matching the query filters by status
+// and currency
+func (pc *PaymentController) GetPaymentTotals(c *gin.Context) {
+	var filter model.PaymentFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	totals, err := pc.Service.GetPaymentTotals(filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve payment intent: " + err.Error()})
+		respondError(c, err, "Failed to total payments")
 		return
 	}
 
-	// Update payment status in database
-	status := model.PaymentStatusPending
-	switch pi.Status {
-	case stripe.PaymentIntentStatusSucceeded:
-		status = model.PaymentStatusSucceeded
-	case stripe.PaymentIntentStatusCanceled:
-		status = model.PaymentStatusCanceled
-	case stripe.PaymentIntentStatusProcessing:
-		status = model.PaymentStatusPending
-	default:
-		status = model.PaymentStatusFailed
+	c.JSON(http.StatusOK, totals)
+}
+
+// listPayments answers with a page of the payments matching filter
+func (pc *PaymentController) listPayments(c *gin.Context, filter model.PaymentFilter) {
+	page, err := pc.Service.ListPayments(filter)
+	if err != nil {
+		respondError(c, err, "Failed to list payments")
+		return
+	}
+	if !pc.recordAccess(c, paymentIDs(page.Payments)...) {
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// CapturePayment captures an authorized payment in full or in part. An
+// empty body captures the whole authorization.
+func (pc *PaymentController) CapturePayment(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment ID"})
+		return
 	}
 
-	query := `
-		UPDATE payments 
-		SET status = $1, payment_method = $2, updated_at = $3
-		WHERE stripe_payment_id = $4
-		RETURNING id, order_id, customer_id, amount, currency, status, stripe_payment_id, payment_method, created_at, updated_at
-	`
+	var req model.CaptureRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := pc.Service.CapturePayment(id, req)
+	if err != nil {
+		respondError(c, err, "Failed to capture payment")
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
 
-	var payment model.Payment
-	err = pc.db.QueryRow(query, status, string(pi.PaymentMethod.Type), time.Now(), pi.ID).Scan(
-		&payment.ID, &payment.OrderID, &payment.CustomerID, &payment.Amount, &payment.Currency,
-		&payment.Status, &payment.StripePaymentID, &payment.PaymentMethod, &payment.CreatedAt, &payment.UpdatedAt,
-	)
+// CancelPayment cancels a payment that was not captured, voiding its
+// authorization
+func (pc *PaymentController) CancelPayment(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payment: " + err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment ID"})
+		return
+	}
+
+	var req model.CancelRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	response := model.PaymentResponse{
-		Payment: payment,
-		Message: "Payment status updated successfully",
+	response, err := pc.Service.CancelPayment(id, req)
+	if err != nil {
+		respondError(c, err, "Failed to cancel payment")
+		return
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
-// GetPayment retrieves a payment by ID
-func (pc *PaymentController) GetPayment(c *gin.Context) {
-	idParam := c.Param("id")
-	id, err := strconv.Atoi(idParam)
+// CreateRefund refunds a payment in full or in part. An empty body refunds
+// the rest of the payment.
+func (pc *PaymentController) CreateRefund(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment ID"})
 		return
 	}
 
-	query := `
-		SELECT id, order_id, customer_id, amount, currency, status, stripe_payment_id, 
-		       COALESCE(payment_method, '') as payment_method, created_at, updated_at
-		FROM payments WHERE id = $1
-	`
+	var req model.RefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	var payment model.Payment
-	err = pc.db.QueryRow(query, id).Scan(
-		&payment.ID, &payment.OrderID, &payment.CustomerID, &payment.Amount, &payment.Currency,
-		&payment.Status, &payment.StripePaymentID, &payment.PaymentMethod, &payment.CreatedAt, &payment.UpdatedAt,
-	)
+	response, err := pc.Service.RefundPayment(id, req)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve payment: " + err.Error()})
+		respondError(c, err, "Failed to refund payment")
 		return
 	}
 
-	c.JSON(http.StatusOK, payment)
+	c.JSON(http.StatusCreated, response)
 }
 
-// GetPaymentsByOrder retrieves all payments for an order
-func (pc *PaymentController) GetPaymentsByOrder(c *gin.Context) {
-	orderIDParam := c.Param("orderId")
-	orderID, err := strconv.Atoi(orderIDParam)
+// GetRefunds retrieves the refunds of a payment
+func (pc *PaymentController) GetRefunds(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment ID"})
+		return
+	}
+
+	refunds, err := pc.Service.GetRefunds(id)
+	if err != nil {
+		respondError(c, err, "Failed to retrieve refunds")
+		return
+	}
+	if !pc.recordAccess(c, id) {
+		return
+	}
+
+	c.JSON(http.StatusOK, refunds)
+}
+
+// GetAccessLog retrieves who read a payment
+func (pc *PaymentController) GetAccessLog(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment ID"})
+		return
+	}
+
+	entries, err := pc.Service.GetAccessLog(id)
+	if err != nil {
+		respondError(c, err, "Failed to retrieve access log")
 		return
 	}
+	if entries == nil {
+		entries = []model.PaymentAccess{}
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
 
-	query := `
-		SELECT id, order_id, customer_id, amount, currency, status, stripe_payment_id,
-		       COALESCE(payment_method, '') as payment_method, created_at, updated_at
-		FROM payments WHERE order_id = $1 ORDER BY created_at DESC
-	`
+// HandleStripeWebhook verifies the signature of a Stripe event and applies
+// it to its payment. Events that change nothing are acknowledged so Stripe
+// stops sending them; failures are answered with an error so Stripe
+// retries.
+func (pc *PaymentController) HandleStripeWebhook(c *gin.Context) {
+	if pc.WebhookSecret == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Stripe webhooks are not configured"})
+		return
+	}
 
-	rows, err := pc.db.Query(query, orderID)
+	payload, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookBytes))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve payments: " + err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read webhook body: " + err.Error()})
 		return
 	}
-	defer rows.Close()
 
-	var payments []model.Payment
-	for rows.Next() {
-		var payment model.Payment
-		err := rows.Scan(
-			&payment.ID, &payment.OrderID, &payment.CustomerID, &payment.Amount, &payment.Currency,
-			&payment.Status, &payment.StripePaymentID, &payment.PaymentMethod, &payment.CreatedAt, &payment.UpdatedAt,
-		)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan payment: " + err.Error()})
-			return
-		}
-		payments = append(payments, payment)
+	event, err := webhook.ConstructEventWithOptions(payload, c.GetHeader("Stripe-Signature"), pc.WebhookSecret,
+		webhook.ConstructEventOptions{IgnoreAPIVersionMismatch: true})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook signature: " + err.Error()})
+		return
 	}
 
-	c.JSON(http.StatusOK, payments)
+	response := model.WebhookResponse{EventID: event.ID, Result: model.WebhookResultProcessed}
+	ctx := events.WithCorrelationID(c.Request.Context(), requestID(c))
+	_, err = pc.Service.HandleWebhookEvent(ctx, event)
+	switch {
+	case errors.Is(err, service.ErrDuplicateEvent):
+		response.Result = model.WebhookResultDuplicate
+	case errors.Is(err, service.ErrUnhandledEvent):
+		log.Printf("Ignoring Stripe event %s: %v\n", event.ID, err)
+		response.Result = model.WebhookResultIgnored
+	case err != nil:
+		respondError(c, err, "Failed to process webhook event")
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 // HealthCheck returns the health status of the payment service
@@ -225,6 +369,56 @@ func (pc *PaymentController) HealthCheck(c *gin.Context) {
 	})
 }
 
+// recordAccess adds the read of payments by the caller to the audit log.
+// The caller is the service named in the X-Actor header if its signature
+// matches, anonymous otherwise. Payments are not returned when the read
+// cannot be recorded, so it answers with an error and returns false.
+func (pc *PaymentController) recordAccess(c *gin.Context, paymentIDs ...int) bool {
+	name, ok := actor.Verify(c.Request.Header, pc.ActorSecret)
+	if !ok {
+		name = "anonymous"
+	}
+	accessor := model.Accessor{
+		Actor:     name,
+		ClientIP:  c.ClientIP(),
+		Action:    c.Request.Method + " " + c.FullPath(),
+		RequestID: requestID(c),
+	}
+	if err := pc.Service.RecordAccess(accessor, paymentIDs...); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record payment access: " + err.Error()})
+		return false
+	}
+	return true
+}
+
+// paymentIDs returns the IDs of payments
+func paymentIDs(payments []model.Payment) []int {
+	ids := make([]int, len(payments))
+	for i, payment := range payments {
+		ids[i] = payment.ID
+	}
+	return ids
+}
+
+// respondError maps a service error to its HTTP response, prefixing
+// storage errors with action
+func respondError(c *gin.Context, err error, action string) {
+	var providerErr *service.ProviderError
+	switch {
+	case errors.Is(err, service.ErrPaymentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+	case errors.Is(err, service.ErrNotRefundable), errors.Is(err, service.ErrNotCapturable), errors.Is(err, service.ErrNotCancelable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrRefundExceedsPayment), errors.Is(err, service.ErrCaptureExceedsAuthorization),
+		errors.Is(err, service.ErrInvalidFilter), errors.Is(err, money.ErrInvalidAmount), errors.Is(err, money.ErrUnknownCurrency):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.As(err, &providerErr):
+		c.JSON(http.StatusInternalServerError, gin.H{"error": providerErr.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": action + ": " + err.Error()})
+	}
+}
+
 // getEnv gets an environment variable or returns a default value
 func getEnv(key, defaultValue string) string {
 	value := os.Getenv(key)
@@ -232,4 +426,13 @@ func getEnv(key, defaultValue string) string {
 		return defaultValue
 	}
 	return value
-}
\ No newline at end of file
+}
+
+// requestID returns the ID of a request from its X-Request-ID or
+// X-Correlation-ID header
+func requestID(c *gin.Context) string {
+	if id := c.GetHeader("X-Request-ID"); id != "" {
+		return id
+	}
+	return c.GetHeader("X-Correlation-ID")
+}
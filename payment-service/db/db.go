@@ -60,12 +60,11 @@ func InitSchema(database *sql.DB) {
 		id SERIAL PRIMARY KEY,
 		order_id INTEGER NOT NULL,
 		customer_id INTEGER NOT NULL,
-		amount DECIMAL(10, 2) NOT NULL,
+		amount_minor BIGINT NOT NULL,
 		currency VARCHAR(3) NOT NULL DEFAULT 'USD',
 		status VARCHAR(50) NOT NULL,
 		stripe_payment_id VARCHAR(255),
-		stripe_client_secret VARCHAR(255),
-		payment_method VARCHAR(50),
+		payment_method TEXT,
 		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
 		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 	);
@@ -74,6 +73,100 @@ func InitSchema(database *sql.DB) {
 	CREATE INDEX IF NOT EXISTS idx_payments_customer_id ON payments(customer_id);
 	CREATE INDEX IF NOT EXISTS idx_payments_status ON payments(status);
 	CREATE INDEX IF NOT EXISTS idx_payments_stripe_payment_id ON payments(stripe_payment_id);
+
+	CREATE TABLE IF NOT EXISTS webhook_events (
+		event_id VARCHAR(255) PRIMARY KEY,
+		type VARCHAR(100) NOT NULL,
+		processed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS refunds (
+		id SERIAL PRIMARY KEY,
+		payment_id INTEGER NOT NULL REFERENCES payments(id),
+		amount_minor BIGINT NOT NULL CHECK (amount_minor > 0),
+		currency VARCHAR(3) NOT NULL,
+		reason VARCHAR(50),
+		status VARCHAR(50) NOT NULL,
+		stripe_refund_id VARCHAR(255),
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_refunds_payment_id ON refunds(payment_id);
+
+	-- currency_exponent returns the number of decimals of the minor unit of
+	-- an ISO 4217 currency
+	CREATE OR REPLACE FUNCTION currency_exponent(code TEXT) RETURNS INTEGER AS $$
+		SELECT CASE
+			WHEN UPPER(code) IN ('BIF', 'CLP', 'DJF', 'GNF', 'ISK', 'JPY', 'KMF', 'KRW', 'PYG',
+				'RWF', 'UGX', 'UYI', 'VND', 'VUV', 'XAF', 'XOF', 'XPF') THEN 0
+			WHEN UPPER(code) IN ('BHD', 'IQD', 'JOD', 'KWD', 'LYD', 'OMR', 'TND') THEN 3
+			WHEN UPPER(code) IN ('CLF', 'UYW') THEN 4
+			ELSE 2
+		END
+	$$ LANGUAGE SQL IMMUTABLE;
+
+	-- Amounts used to be decimals in major units. They are integers in
+	-- minor units of upper-case currency codes now.
+	DO $$
+	BEGIN
+		IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'payments' AND column_name = 'amount') THEN
+			ALTER TABLE payments ADD COLUMN IF NOT EXISTS amount_refunded DECIMAL(10, 2) NOT NULL DEFAULT 0;
+			UPDATE payments SET currency = UPPER(currency);
+			ALTER TABLE payments ALTER COLUMN amount TYPE BIGINT
+				USING ROUND(amount * POWER(10::NUMERIC, currency_exponent(currency)));
+			ALTER TABLE payments ALTER COLUMN amount_refunded TYPE BIGINT
+				USING ROUND(amount_refunded * POWER(10::NUMERIC, currency_exponent(currency)));
+			ALTER TABLE payments RENAME COLUMN amount TO amount_minor;
+			ALTER TABLE payments RENAME COLUMN amount_refunded TO amount_refunded_minor;
+		END IF;
+		IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'refunds' AND column_name = 'amount') THEN
+			UPDATE refunds SET currency = UPPER(currency);
+			ALTER TABLE refunds ALTER COLUMN amount TYPE BIGINT
+				USING ROUND(amount * POWER(10::NUMERIC, currency_exponent(currency)));
+			ALTER TABLE refunds RENAME COLUMN amount TO amount_minor;
+		END IF;
+	END $$;
+
+	ALTER TABLE payments ADD COLUMN IF NOT EXISTS amount_refunded_minor BIGINT NOT NULL DEFAULT 0;
+
+	-- Payments used to be captured in full as soon as they were
+	-- authorized, so the captured amount of existing payments is their
+	-- amount once they succeeded
+	DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'payments' AND column_name = 'amount_captured_minor') THEN
+			ALTER TABLE payments ADD COLUMN amount_captured_minor BIGINT NOT NULL DEFAULT 0;
+			UPDATE payments SET amount_captured_minor = amount_minor
+				WHERE status IN ('succeeded', 'partially_refunded', 'refunded');
+		END IF;
+	END $$;
+
+	ALTER TABLE payments ADD COLUMN IF NOT EXISTS capture_method VARCHAR(20) NOT NULL DEFAULT 'automatic';
+	ALTER TABLE payments ADD COLUMN IF NOT EXISTS expiry_flagged_at TIMESTAMP;
+
+	CREATE INDEX IF NOT EXISTS idx_payments_uncaptured ON payments(created_at)
+		WHERE capture_method = 'manual' AND status IN ('pending', 'requires_capture');
+
+	-- Listings filter on customer_id and status and are sorted by creation
+	CREATE INDEX IF NOT EXISTS idx_payments_created_at ON payments(created_at);
+
+	-- Client secrets are only returned when a payment is created. Payment
+	-- methods are encrypted, which needs more room than their type.
+	ALTER TABLE payments DROP COLUMN IF EXISTS stripe_client_secret;
+	ALTER TABLE payments ALTER COLUMN payment_method TYPE TEXT;
+
+	CREATE TABLE IF NOT EXISTS payment_access_log (
+		id BIGSERIAL PRIMARY KEY,
+		payment_id INTEGER NOT NULL REFERENCES payments(id),
+		actor VARCHAR(255) NOT NULL,
+		client_ip VARCHAR(64) NOT NULL,
+		action VARCHAR(255) NOT NULL,
+		request_id VARCHAR(255),
+		accessed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_payment_access_log_payment_id ON payment_access_log(payment_id, accessed_at);
 	`
 
 	_, err := database.Exec(createTableSQL)
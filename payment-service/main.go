@@ -1,11 +1,21 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"os"
+	"time"
 
 	"go-microservices/payment-service/controller"
 	"go-microservices/payment-service/db"
+	"go-microservices/payment-service/model"
+	"go-microservices/payment-service/provider"
+	"go-microservices/payment-service/repository"
 	"go-microservices/payment-service/routes"
+	"go-microservices/payment-service/service"
+	"go-microservices/pkg/broker"
+	"go-microservices/pkg/envelope"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -19,8 +29,83 @@ func main() {
 	// Initialize database schema
 	db.InitSchema(database)
 
+	// Initialize the message broker selected by BROKER. Payment events are
+	// consumed by order-service and notification-service.
+	eventBroker, err := broker.NewFromEnv()
+	if eventBroker == nil {
+		log.Fatal("Failed to create message broker: ", err)
+	}
+	if err != nil {
+		log.Printf("Warning: Failed to connect to message broker: %v\n", err)
+	}
+	defer eventBroker.Close()
+
+	// Initialize the payment provider selected by PAYMENT_PROVIDER
+	paymentProvider, err := provider.NewFromEnv()
+	if err != nil {
+		log.Fatal("Failed to create payment provider: ", err)
+	}
+
+	// Payment methods are encrypted with the keys of ENCRYPTION_KEYS, the
+	// first of which is active
+	keyring, err := envelope.KeyringFromEnv()
+	if err != nil {
+		log.Fatal("Failed to read encryption keys: ", err)
+	}
+	if keyring == nil {
+		log.Println("Warning: ENCRYPTION_KEYS is not set, payment methods will be stored unencrypted")
+	}
+	payments := repository.NewDBPaymentRepository(database, keyring)
+
+	// payment-service rotate-keys re-encrypts the payment methods with the
+	// active key and exits
+	if len(os.Args) > 1 && os.Args[1] == "rotate-keys" {
+		rotated, err := payments.RotateKeys()
+		eventBroker.Close()
+		database.Close()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Re-encrypted %d payment methods with key %s\n", rotated, keyring.ActiveKeyID())
+		return
+	}
+
 	// Create payment controller
-	paymentController := controller.NewPaymentController(database)
+	paymentService := service.NewPaymentService(payments, paymentProvider, eventBroker)
+
+	// payment-service reconcile writes a reconciliation report and exits
+	if len(os.Args) > 1 && os.Args[1] == "reconcile" {
+		err := runReconcile(os.Args[2:], paymentService, os.Stdout, os.Stderr)
+		eventBroker.Close()
+		database.Close()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	paymentController := controller.NewPaymentController(paymentService)
+
+	// Flag manual capture payments whose authorization expires soon, so
+	// they are captured or canceled before Stripe releases them
+	go paymentService.RunExpiryJob(context.Background(),
+		getDurationEnv("AUTHORIZATION_CHECK_INTERVAL", time.Hour),
+		getDurationEnv("AUTHORIZATION_EXPIRY_WARNING", 24*time.Hour))
+
+	// Reconcile the payments of every RECONCILIATION_INTERVAL with the
+	// payment provider, once RECONCILIATION_DELAY passed for webhook events
+	// to arrive
+	reportFormat := os.Getenv("RECONCILIATION_REPORT_FORMAT")
+	if reportFormat != model.ReportFormatCSV {
+		reportFormat = model.ReportFormatJSON
+	}
+	go paymentService.RunReconciliationJob(context.Background(),
+		getDurationEnv("RECONCILIATION_INTERVAL", 24*time.Hour),
+		getDurationEnv("RECONCILIATION_DELAY", time.Hour),
+		os.Getenv("RECONCILIATION_AUTO_CORRECT") == "true",
+		saveReports(os.Getenv("RECONCILIATION_REPORT_DIR"), reportFormat))
 
 	// Initialize router
 	router := gin.Default()
@@ -36,4 +121,14 @@ func main() {
 	if err := router.Run(":8084"); err != nil {
 		log.Fatal("Failed to start server: ", err)
 	}
-}
\ No newline at end of file
+}
+
+// getDurationEnv gets a duration such as "30m" from an environment variable
+// or returns a default value
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,97 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"go-microservices/pkg/money"
+)
+
+// Page sizes of payment listings, and the last page that can be asked
+// for, which keeps the offset of a page within an int
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+	MaxPage         = 1000000
+)
+
+// PaymentFilter selects the payments listed or totaled, from the query
+// string. Empty fields match every payment. Amounts are decimal numbers in
+// major units of Currency, which they require.
+type PaymentFilter struct {
+	CustomerID int    `form:"customer_id" binding:"omitempty,min=1"`
+	Status     string `form:"status" binding:"omitempty,oneof=pending requires_capture succeeded partially_refunded refunded failed canceled"`
+	Currency   string `form:"currency" binding:"omitempty,len=3"`
+	// CreatedFrom is included and CreatedTo excluded
+	CreatedFrom *time.Time   `form:"created_from"`
+	CreatedTo   *time.Time   `form:"created_to"`
+	MinAmount   *json.Number `form:"min_amount"`
+	MaxAmount   *json.Number `form:"max_amount"`
+	Page        int          `form:"page" binding:"omitempty,min=1,max=1000000"`
+	PageSize    int          `form:"page_size" binding:"omitempty,min=1,max=100"`
+}
+
+// PaymentPage is a page of a payment listing, newest first
+type PaymentPage struct {
+	Payments []Payment `json:"payments"`
+	Page     int       `json:"page"`
+	PageSize int       `json:"page_size"`
+	// Total is the number of payments matching the filter, on every page
+	Total int `json:"total"`
+}
+
+// PaymentTotal sums the payments of a status in a currency. Its amounts are
+// encoded in JSON as decimal numbers in major units, next to the currency.
+type PaymentTotal struct {
+	Status         string      `json:"status"`
+	Count          int         `json:"count"`
+	Amount         money.Money `json:"-"`
+	AmountCaptured money.Money `json:"-"`
+	AmountRefunded money.Money `json:"-"`
+}
+
+// paymentTotalFields is PaymentTotal without its JSON methods
+type paymentTotalFields PaymentTotal
+
+// paymentTotalJSON is the JSON form of a PaymentTotal
+type paymentTotalJSON struct {
+	paymentTotalFields
+	Currency       string      `json:"currency"`
+	Amount         json.Number `json:"amount"`
+	AmountCaptured json.Number `json:"amount_captured"`
+	AmountRefunded json.Number `json:"amount_refunded"`
+}
+
+// MarshalJSON encodes the total with its amounts in major units
+func (t PaymentTotal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(paymentTotalJSON{
+		paymentTotalFields: paymentTotalFields(t),
+		Currency:           t.Amount.Currency(),
+		Amount:             t.Amount.Number(),
+		AmountCaptured:     t.AmountCaptured.Number(),
+		AmountRefunded:     t.AmountRefunded.Number(),
+	})
+}
+
+// UnmarshalJSON decodes a total encoded by MarshalJSON
+func (t *PaymentTotal) UnmarshalJSON(data []byte) error {
+	var v paymentTotalJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	amount, err := parseAmount(v.Amount, v.Currency)
+	if err != nil {
+		return err
+	}
+	captured, err := parseAmount(v.AmountCaptured, v.Currency)
+	if err != nil {
+		return err
+	}
+	refunded, err := parseAmount(v.AmountRefunded, v.Currency)
+	if err != nil {
+		return err
+	}
+	*t = PaymentTotal(v.paymentTotalFields)
+	t.Amount, t.AmountCaptured, t.AmountRefunded = amount, captured, refunded
+	return nil
+}
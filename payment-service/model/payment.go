@@ -1,30 +1,109 @@
 package model
 
 import (
+	"encoding/json"
 	"time"
+
+	"go-microservices/pkg/money"
 )
 
-// Payment represents a payment transaction
+// Payment represents a payment transaction. Its amounts are encoded in JSON
+// as decimal numbers in major units, next to their currency. The client
+// secret of its payment intent is not kept: it is only returned by
+// CreatePayment, in the PaymentResponse.
 type Payment struct {
-	ID                int       `json:"id" db:"id"`
-	OrderID           int       `json:"order_id" db:"order_id"`
-	CustomerID        int       `json:"customer_id" db:"customer_id"`
-	Amount            float64   `json:"amount" db:"amount"`
-	Currency          string    `json:"currency" db:"currency"`
-	Status            string    `json:"status" db:"status"`
-	StripePaymentID   string    `json:"stripe_payment_id" db:"stripe_payment_id"`
-	StripeClientSecret string   `json:"stripe_client_secret,omitempty" db:"stripe_client_secret"`
-	PaymentMethod     string    `json:"payment_method" db:"payment_method"`
-	CreatedAt         time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt         time.Time `json:"updated_at" db:"updated_at"`
-}
-
-// PaymentRequest represents a payment creation request
+	ID              int         `json:"id" db:"id"`
+	OrderID         int         `json:"order_id" db:"order_id"`
+	CustomerID      int         `json:"customer_id" db:"customer_id"`
+	Amount          money.Money `json:"-" db:"amount_minor"`
+	Status          string      `json:"status" db:"status"`
+	StripePaymentID string      `json:"stripe_payment_id" db:"stripe_payment_id"`
+	// PaymentMethod is encrypted at rest
+	PaymentMethod string `json:"payment_method" db:"payment_method"`
+	// CaptureMethod tells whether the payment is captured as soon as it is
+	// authorized or later through CapturePayment
+	CaptureMethod string `json:"capture_method" db:"capture_method"`
+	// AmountCaptured is the part of the amount actually charged, which
+	// can be refunded. It is less than Amount after a partial capture.
+	AmountCaptured money.Money `json:"-" db:"amount_captured_minor"`
+	AmountRefunded money.Money `json:"-" db:"amount_refunded_minor"`
+	// ExpiryFlaggedAt is when the authorization was flagged as about to
+	// expire uncaptured
+	ExpiryFlaggedAt *time.Time `json:"expiry_flagged_at,omitempty" db:"expiry_flagged_at"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// paymentFields is Payment without its JSON methods
+type paymentFields Payment
+
+// paymentJSON is the JSON form of a Payment
+type paymentJSON struct {
+	paymentFields
+	Amount         json.Number `json:"amount"`
+	Currency       string      `json:"currency"`
+	AmountCaptured json.Number `json:"amount_captured"`
+	AmountRefunded json.Number `json:"amount_refunded"`
+}
+
+// MarshalJSON encodes the payment with its amounts in major units
+func (p Payment) MarshalJSON() ([]byte, error) {
+	return json.Marshal(paymentJSON{
+		paymentFields:  paymentFields(p),
+		Amount:         p.Amount.Number(),
+		Currency:       p.Amount.Currency(),
+		AmountCaptured: p.AmountCaptured.Number(),
+		AmountRefunded: p.AmountRefunded.Number(),
+	})
+}
+
+// UnmarshalJSON decodes a payment encoded by MarshalJSON
+func (p *Payment) UnmarshalJSON(data []byte) error {
+	var v paymentJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	amount, err := parseAmount(v.Amount, v.Currency)
+	if err != nil {
+		return err
+	}
+	captured, err := parseAmount(v.AmountCaptured, v.Currency)
+	if err != nil {
+		return err
+	}
+	refunded, err := parseAmount(v.AmountRefunded, v.Currency)
+	if err != nil {
+		return err
+	}
+	*p = Payment(v.paymentFields)
+	p.Amount, p.AmountCaptured, p.AmountRefunded = amount, captured, refunded
+	return nil
+}
+
+// PaymentRequest represents a payment creation request. The amount is a
+// decimal number in major units of the ISO 4217 currency, such as 19.99.
+// A manual capture method only authorizes the amount, which is captured
+// later with a CaptureRequest.
 type PaymentRequest struct {
-	OrderID    int     `json:"order_id" binding:"required"`
-	CustomerID int     `json:"customer_id" binding:"required"`
-	Amount     float64 `json:"amount" binding:"required,min=0.01"`
-	Currency   string  `json:"currency" binding:"required"`
+	OrderID       int         `json:"order_id" binding:"required"`
+	CustomerID    int         `json:"customer_id" binding:"required"`
+	Amount        json.Number `json:"amount" binding:"required"`
+	Currency      string      `json:"currency" binding:"required,len=3"`
+	CaptureMethod string      `json:"capture_method" binding:"omitempty,oneof=automatic manual"`
+}
+
+// CaptureRequest represents the capture of an authorized payment. Without
+// an amount the whole authorization is captured; an amount is a decimal
+// number in major units of the payment's currency, and the rest of the
+// authorization is released.
+type CaptureRequest struct {
+	Amount *json.Number `json:"amount"`
+}
+
+// CancelRequest represents the cancellation of a payment that was not
+// captured, releasing its authorization
+type CancelRequest struct {
+	Reason string `json:"reason" binding:"omitempty,oneof=duplicate fraudulent requested_by_customer abandoned"`
 }
 
 // PaymentConfirmRequest represents a payment confirmation request
@@ -32,17 +111,141 @@ type PaymentConfirmRequest struct {
 	PaymentIntentID string `json:"payment_intent_id" binding:"required"`
 }
 
-// PaymentResponse represents a payment response
+// PaymentResponse represents a payment response. ClientSecret is only set
+// when the payment is created.
 type PaymentResponse struct {
 	Payment      Payment `json:"payment"`
 	ClientSecret string  `json:"client_secret,omitempty"`
 	Message      string  `json:"message,omitempty"`
 }
 
+// Capture methods of a payment
+const (
+	CaptureMethodAutomatic = "automatic"
+	CaptureMethodManual    = "manual"
+)
+
 // PaymentStatus constants
 const (
 	PaymentStatusPending   = "pending"
 	PaymentStatusSucceeded = "succeeded"
 	PaymentStatusFailed    = "failed"
 	PaymentStatusCanceled  = "canceled"
-)
\ No newline at end of file
+	PaymentStatusRefunded  = "refunded"
+	// PaymentStatusPartiallyRefunded is a succeeded payment refunded in part
+	PaymentStatusPartiallyRefunded = "partially_refunded"
+	// PaymentStatusRequiresCapture is a manual capture payment authorized
+	// and waiting to be captured or canceled
+	PaymentStatusRequiresCapture = "requires_capture"
+)
+
+// Refund represents a full or partial refund of a payment
+type Refund struct {
+	ID             int         `json:"id" db:"id"`
+	PaymentID      int         `json:"payment_id" db:"payment_id"`
+	Amount         money.Money `json:"-" db:"amount_minor"`
+	Reason         string      `json:"reason,omitempty" db:"reason"`
+	Status         string      `json:"status" db:"status"`
+	StripeRefundID string      `json:"stripe_refund_id,omitempty" db:"stripe_refund_id"`
+	CreatedAt      time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at" db:"updated_at"`
+}
+
+// refundFields is Refund without its JSON methods
+type refundFields Refund
+
+// refundJSON is the JSON form of a Refund
+type refundJSON struct {
+	refundFields
+	Amount   json.Number `json:"amount"`
+	Currency string      `json:"currency"`
+}
+
+// MarshalJSON encodes the refund with its amount in major units
+func (r Refund) MarshalJSON() ([]byte, error) {
+	return json.Marshal(refundJSON{refundFields: refundFields(r), Amount: r.Amount.Number(), Currency: r.Amount.Currency()})
+}
+
+// UnmarshalJSON decodes a refund encoded by MarshalJSON
+func (r *Refund) UnmarshalJSON(data []byte) error {
+	var v refundJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	amount, err := parseAmount(v.Amount, v.Currency)
+	if err != nil {
+		return err
+	}
+	*r = Refund(v.refundFields)
+	r.Amount = amount
+	return nil
+}
+
+// RefundRequest represents a refund creation request. Without an amount
+// the rest of the payment is refunded; an amount is a decimal number in
+// major units of the payment's currency.
+type RefundRequest struct {
+	Amount *json.Number `json:"amount"`
+	Reason string       `json:"reason" binding:"omitempty,oneof=duplicate fraudulent requested_by_customer"`
+}
+
+// RefundResponse represents a refund together with the refunded payment
+type RefundResponse struct {
+	Refund  Refund  `json:"refund"`
+	Payment Payment `json:"payment"`
+}
+
+// RefundStatus constants
+const (
+	RefundStatusPending   = "pending"
+	RefundStatusSucceeded = "succeeded"
+	RefundStatusFailed    = "failed"
+	RefundStatusCanceled  = "canceled"
+)
+
+// Accessor identifies an API caller reading payments. Actor is the service
+// named and signed for in the X-Actor headers, or anonymous.
+type Accessor struct {
+	Actor     string
+	ClientIP  string
+	Action    string
+	RequestID string
+}
+
+// PaymentAccess is an entry of the audit log of payment reads
+type PaymentAccess struct {
+	ID        int    `json:"id" db:"id"`
+	PaymentID int    `json:"payment_id" db:"payment_id"`
+	Actor     string `json:"actor" db:"actor"`
+	ClientIP  string `json:"client_ip" db:"client_ip"`
+	// Action is the method and route of the request, such as
+	// GET /payments/:id
+	Action     string    `json:"action" db:"action"`
+	RequestID  string    `json:"request_id,omitempty" db:"request_id"`
+	AccessedAt time.Time `json:"accessed_at" db:"accessed_at"`
+}
+
+// WebhookResponse acknowledges a Stripe webhook event
+type WebhookResponse struct {
+	EventID string `json:"event_id"`
+	Result  string `json:"result"`
+}
+
+// Webhook results, telling whether an event changed anything
+const (
+	WebhookResultProcessed = "processed"
+	WebhookResultDuplicate = "duplicate"
+	WebhookResultIgnored   = "ignored"
+)
+
+// parseAmount reads an amount encoded in JSON. A missing amount is zero,
+// and a zero amount without a currency is the zero Money.
+func parseAmount(amount json.Number, currency string) (money.Money, error) {
+	if amount == "" {
+		amount = "0"
+	}
+	if currency == "" && amount == "0" {
+		return money.Money{}, nil
+	}
+	return money.Parse(amount.String(), currency)
+}
@@ -0,0 +1,88 @@
+package model
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Discrepancy kinds found by a reconciliation
+const (
+	// DiscrepancyMissingLocally is a provider payment intent without a
+	// payment
+	DiscrepancyMissingLocally = "missing_locally"
+	// DiscrepancyMissingAtProvider is a payment whose intent the provider
+	// does not know
+	DiscrepancyMissingAtProvider = "missing_at_provider"
+	DiscrepancyAmount            = "amount_mismatch"
+	DiscrepancyCurrency          = "currency_mismatch"
+	DiscrepancyStatus            = "status_mismatch"
+)
+
+// Discrepancy is a difference between a payment and its provider payment
+// intent. Local and Provider are the values that differ, as text.
+type Discrepancy struct {
+	Kind            string `json:"kind"`
+	PaymentID       int    `json:"payment_id,omitempty"`
+	OrderID         int    `json:"order_id,omitempty"`
+	StripePaymentID string `json:"stripe_payment_id"`
+	Local           string `json:"local,omitempty"`
+	Provider        string `json:"provider,omitempty"`
+	// Corrected tells whether the payment was updated to match the
+	// provider
+	Corrected bool `json:"corrected"`
+}
+
+// ReconciliationReport lists the discrepancies between the payments and
+// the provider payment intents created between From and To
+type ReconciliationReport struct {
+	From            time.Time     `json:"from"`
+	To              time.Time     `json:"to"`
+	GeneratedAt     time.Time     `json:"generated_at"`
+	IntentsChecked  int           `json:"intents_checked"`
+	PaymentsChecked int           `json:"payments_checked"`
+	Corrected       int           `json:"corrected"`
+	Discrepancies   []Discrepancy `json:"discrepancies"`
+}
+
+// Report formats
+const (
+	ReportFormatJSON = "json"
+	ReportFormatCSV  = "csv"
+)
+
+// WriteJSON writes the report as an indented JSON document
+func (r *ReconciliationReport) WriteJSON(w io.Writer) error {
+	report := *r
+	if report.Discrepancies == nil {
+		report.Discrepancies = []Discrepancy{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// WriteCSV writes the discrepancies of the report as CSV, one row each
+// after a header row
+func (r *ReconciliationReport) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"kind", "payment_id", "order_id", "stripe_payment_id", "local", "provider", "corrected"})
+	for _, d := range r.Discrepancies {
+		writer.Write([]string{
+			d.Kind, optionalID(d.PaymentID), optionalID(d.OrderID), d.StripePaymentID,
+			d.Local, d.Provider, strconv.FormatBool(d.Corrected),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// optionalID formats an ID, leaving unknown IDs empty
+func optionalID(id int) string {
+	if id == 0 {
+		return ""
+	}
+	return strconv.Itoa(id)
+}
@@ -0,0 +1,279 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outcomes of the fake provider, chosen by the last two digits of an
+// intent's amount in minor units
+const (
+	fakeDeclined = 2
+	fake3DS      = 3
+	fakeDelayed  = 4
+)
+
+// DefaultFakeDelay is how long delayed fake payments stay processing
+const DefaultFakeDelay = 5 * time.Second
+
+// Fake is a deterministic PaymentProvider for tests and offline runs. It
+// confirms every intent as soon as it is created, with an outcome chosen by
+// the last two digits of the amount in minor units, the way Stripe's test
+// cards choose theirs:
+//
+//   - 02: declined, the intent requires a new payment method
+//   - 03: 3D Secure authentication required, the intent requires action
+//   - 04: delayed, the intent is processing and succeeds once Delay passed
+//   - anything else succeeds
+//
+// Intents with manual capture are authorized instead of succeeding, until
+// they are captured.
+type Fake struct {
+	// Delay is how long delayed intents stay processing
+	Delay time.Duration
+	// Now returns the current time, time.Now by default
+	Now func() time.Time
+
+	mu          sync.Mutex
+	nextID      int
+	intents     map[string]*Intent
+	readyAt     map[string]time.Time
+	refunded    map[string]int64
+	idempotency map[string]string
+}
+
+// NewFake creates a fake provider with no intents
+func NewFake() *Fake {
+	return &Fake{
+		Delay:       DefaultFakeDelay,
+		Now:         time.Now,
+		intents:     make(map[string]*Intent),
+		readyAt:     make(map[string]time.Time),
+		refunded:    make(map[string]int64),
+		idempotency: make(map[string]string),
+	}
+}
+
+// CreateIntent creates and confirms a payment intent
+func (f *Fake) CreateIntent(_ context.Context, params IntentParams) (*Intent, error) {
+	if params.Amount <= 0 {
+		return nil, fmt.Errorf("amount must be positive, got %d", params.Amount)
+	}
+	if params.Currency == "" {
+		return nil, fmt.Errorf("currency is required")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if id, ok := f.idempotency[params.IdempotencyKey]; ok && params.IdempotencyKey != "" {
+		return f.copy(id), nil
+	}
+
+	f.nextID++
+	id := "pi_fake_" + strconv.Itoa(f.nextID)
+	intent := &Intent{
+		ID:            id,
+		Amount:        params.Amount,
+		Currency:      strings.ToUpper(params.Currency),
+		CaptureMethod: params.CaptureMethod,
+		ClientSecret:  id + "_secret_fake",
+		Metadata:      params.Metadata,
+		CreatedAt:     f.Now(),
+	}
+	if intent.CaptureMethod == "" {
+		intent.CaptureMethod = CaptureAutomatic
+	}
+
+	switch params.Amount % 100 {
+	case fakeDeclined:
+		intent.Status = IntentRequiresPaymentMethod
+		intent.LastError = "Your card was declined."
+	case fake3DS:
+		intent.Status = IntentRequiresAction
+	case fakeDelayed:
+		intent.Status = IntentProcessing
+		intent.PaymentMethod = "card"
+		f.readyAt[id] = intent.CreatedAt.Add(f.Delay)
+	default:
+		f.authorize(intent)
+	}
+
+	f.intents[id] = intent
+	if params.IdempotencyKey != "" {
+		f.idempotency[params.IdempotencyKey] = id
+	}
+	return f.copy(id), nil
+}
+
+// GetIntent retrieves a payment intent, settling delayed intents whose
+// delay passed
+func (f *Fake) GetIntent(_ context.Context, id string) (*Intent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	intent, err := f.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return f.copy(intent.ID), nil
+}
+
+// CancelIntent cancels a payment intent that was not captured
+func (f *Fake) CancelIntent(_ context.Context, id, _ string) (*Intent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	intent, err := f.get(id)
+	if err != nil {
+		return nil, err
+	}
+	switch intent.Status {
+	case IntentRequiresPaymentMethod, IntentRequiresConfirmation, IntentRequiresAction, IntentRequiresCapture:
+	default:
+		return nil, fmt.Errorf("payment intent %s cannot be canceled while %s", id, intent.Status)
+	}
+
+	intent.Status = IntentCanceled
+	return f.copy(id), nil
+}
+
+// CaptureIntent captures an authorized payment intent, in full when amount
+// is 0
+func (f *Fake) CaptureIntent(_ context.Context, id string, amount int64) (*Intent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	intent, err := f.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if intent.Status != IntentRequiresCapture {
+		return nil, fmt.Errorf("payment intent %s cannot be captured while %s", id, intent.Status)
+	}
+	if amount == 0 {
+		amount = intent.Amount
+	}
+	if amount < 0 || amount > intent.Amount {
+		return nil, fmt.Errorf("amount to capture must be between 1 and %d, got %d", intent.Amount, amount)
+	}
+
+	intent.AmountReceived = amount
+	intent.Status = IntentSucceeded
+	return f.copy(id), nil
+}
+
+// Refund refunds part or all of a captured payment intent
+func (f *Fake) Refund(_ context.Context, params RefundParams) (*Refund, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	intent, err := f.get(params.IntentID)
+	if err != nil {
+		return nil, err
+	}
+	if intent.Status != IntentSucceeded {
+		return nil, fmt.Errorf("payment intent %s cannot be refunded while %s", intent.ID, intent.Status)
+	}
+	remaining := intent.AmountReceived - f.refunded[intent.ID]
+	if params.Amount <= 0 || params.Amount > remaining {
+		return nil, fmt.Errorf("amount to refund must be between 1 and %d, got %d", remaining, params.Amount)
+	}
+
+	f.nextID++
+	f.refunded[intent.ID] += params.Amount
+	return &Refund{
+		ID:       "re_fake_" + strconv.Itoa(f.nextID),
+		IntentID: intent.ID,
+		Amount:   params.Amount,
+		Status:   RefundSucceeded,
+	}, nil
+}
+
+// ListIntents returns a page of the payment intents created in a time
+// range, newest first
+func (f *Fake) ListIntents(_ context.Context, params ListParams) (*IntentPage, error) {
+	if params.Limit < 1 || params.Limit > 100 {
+		return nil, fmt.Errorf("limit must be between 1 and 100, got %d", params.Limit)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var matching []*Intent
+	for id, intent := range f.intents {
+		if !intent.CreatedAt.Before(params.CreatedFrom) && intent.CreatedAt.Before(params.CreatedTo) {
+			intent, _ = f.get(id)
+			matching = append(matching, intent)
+		}
+	}
+	// IDs are numbered in creation order, which breaks ties
+	sort.Slice(matching, func(i, j int) bool {
+		if !matching[i].CreatedAt.Equal(matching[j].CreatedAt) {
+			return matching[i].CreatedAt.After(matching[j].CreatedAt)
+		}
+		return fakeSeq(matching[i].ID) > fakeSeq(matching[j].ID)
+	})
+
+	start := 0
+	if params.StartingAfter != "" {
+		for i, intent := range matching {
+			if intent.ID == params.StartingAfter {
+				start = i + 1
+			}
+		}
+	}
+
+	page := &IntentPage{}
+	for _, intent := range matching[start:] {
+		if len(page.Intents) == params.Limit {
+			page.HasMore = true
+			break
+		}
+		page.Intents = append(page.Intents, *f.copy(intent.ID))
+	}
+	return page, nil
+}
+
+// fakeSeq returns the sequence number of a fake intent ID
+func fakeSeq(id string) int {
+	n, _ := strconv.Atoi(strings.TrimPrefix(id, "pi_fake_"))
+	return n
+}
+
+// get returns a stored intent, settling it if its delay passed. The caller
+// holds the lock.
+func (f *Fake) get(id string) (*Intent, error) {
+	intent, ok := f.intents[id]
+	if !ok {
+		return nil, ErrIntentNotFound
+	}
+	if readyAt, ok := f.readyAt[id]; ok && intent.Status == IntentProcessing && !f.Now().Before(readyAt) {
+		delete(f.readyAt, id)
+		f.authorize(intent)
+	}
+	return intent, nil
+}
+
+// authorize completes a successful payment attempt, capturing it unless
+// capture is manual
+func (f *Fake) authorize(intent *Intent) {
+	intent.PaymentMethod = "card"
+	if intent.CaptureMethod == CaptureManual {
+		intent.Status = IntentRequiresCapture
+		return
+	}
+	intent.Status = IntentSucceeded
+	intent.AmountReceived = intent.Amount
+}
+
+// copy returns a copy of a stored intent. The caller holds the lock.
+func (f *Fake) copy(id string) *Intent {
+	intent := *f.intents[id]
+	return &intent
+}
@@ -0,0 +1,180 @@
+// Package provider abstracts the payment processor payment-service charges
+// customers through. Stripe is the production provider; Fake simulates one
+// deterministically for tests and offline runs.
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Provider kinds selected by PAYMENT_PROVIDER
+const (
+	KindStripe = "stripe"
+	KindFake   = "fake"
+)
+
+// ErrIntentNotFound is returned for payment intents the provider does not know
+var ErrIntentNotFound = errors.New("payment intent not found")
+
+// IntentStatus is the state of a payment intent. The values are Stripe's.
+type IntentStatus string
+
+// Payment intent states
+const (
+	IntentRequiresPaymentMethod IntentStatus = "requires_payment_method"
+	IntentRequiresConfirmation  IntentStatus = "requires_confirmation"
+	IntentRequiresAction        IntentStatus = "requires_action"
+	IntentProcessing            IntentStatus = "processing"
+	IntentRequiresCapture       IntentStatus = "requires_capture"
+	IntentCanceled              IntentStatus = "canceled"
+	IntentSucceeded             IntentStatus = "succeeded"
+)
+
+// Capture methods of a payment intent
+const (
+	// CaptureAutomatic captures the payment as soon as it is authorized
+	CaptureAutomatic = "automatic"
+	// CaptureManual only authorizes the payment, which is captured later
+	// with Capture
+	CaptureManual = "manual"
+)
+
+// RefundStatus is the state of a refund at the provider
+type RefundStatus string
+
+// Refund states
+const (
+	RefundPending   RefundStatus = "pending"
+	RefundSucceeded RefundStatus = "succeeded"
+	RefundFailed    RefundStatus = "failed"
+	RefundCanceled  RefundStatus = "canceled"
+)
+
+// Intent is a payment intent, the provider's record of a payment
+type Intent struct {
+	ID string
+	// Amount is the amount to collect in minor currency units
+	Amount int64
+	// AmountReceived is the amount captured in minor currency units
+	AmountReceived int64
+	// Currency is the upper-case ISO 4217 code of the amounts
+	Currency      string
+	Status        IntentStatus
+	CaptureMethod string
+	// ClientSecret lets the customer's browser confirm the intent
+	ClientSecret string
+	// PaymentMethod is the type of payment method once one is attached,
+	// such as card
+	PaymentMethod string
+	// LastError explains why the last payment attempt failed
+	LastError string
+	Metadata  map[string]string
+	CreatedAt time.Time
+}
+
+// IntentParams describes a payment intent to create
+type IntentParams struct {
+	// Amount is the amount to collect in minor currency units
+	Amount int64
+	// Currency is the ISO 4217 code of the amount
+	Currency string
+	// CaptureMethod is CaptureAutomatic when empty
+	CaptureMethod string
+	Metadata      map[string]string
+	// IdempotencyKey makes retries of the request create one intent
+	IdempotencyKey string
+}
+
+// ListParams selects a page of the payment intents created between
+// CreatedFrom, included, and CreatedTo, excluded
+type ListParams struct {
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	// StartingAfter is the ID of the last intent of the previous page
+	StartingAfter string
+	// Limit is the number of intents per page, between 1 and 100
+	Limit int
+}
+
+// IntentPage is a page of payment intents, newest first
+type IntentPage struct {
+	Intents []Intent
+	// HasMore tells whether older intents follow the page
+	HasMore bool
+}
+
+// Refund is a refund of a payment intent
+type Refund struct {
+	ID       string
+	IntentID string
+	// Amount is the refunded amount in minor currency units
+	Amount int64
+	Status RefundStatus
+}
+
+// RefundParams describes a refund to create
+type RefundParams struct {
+	IntentID string
+	// Amount is the amount to refund in minor currency units
+	Amount int64
+	// Reason is duplicate, fraudulent or requested_by_customer, if set
+	Reason   string
+	Metadata map[string]string
+	// IdempotencyKey makes retries of the request create one refund
+	IdempotencyKey string
+}
+
+// PaymentProvider creates and manages payment intents with a payment
+// processor
+type PaymentProvider interface {
+	// CreateIntent creates a payment intent
+	CreateIntent(ctx context.Context, params IntentParams) (*Intent, error)
+	// GetIntent retrieves a payment intent, returning ErrIntentNotFound if
+	// it does not exist
+	GetIntent(ctx context.Context, id string) (*Intent, error)
+	// CancelIntent cancels a payment intent that was not captured
+	CancelIntent(ctx context.Context, id, reason string) (*Intent, error)
+	// CaptureIntent captures an authorized payment intent. An amount of 0
+	// captures the full amount, a smaller one captures part of it and
+	// releases the rest.
+	CaptureIntent(ctx context.Context, id string, amount int64) (*Intent, error)
+	// Refund refunds part or all of a captured payment intent
+	Refund(ctx context.Context, params RefundParams) (*Refund, error)
+	// ListIntents returns a page of the payment intents created in a time
+	// range, newest first
+	ListIntents(ctx context.Context, params ListParams) (*IntentPage, error)
+}
+
+// NewFromEnv creates the provider named by PAYMENT_PROVIDER. Without it,
+// Stripe is used when STRIPE_SECRET_KEY is set and the fake provider
+// otherwise, so the service runs offline. Selecting Stripe without a
+// secret key is an error.
+func NewFromEnv() (PaymentProvider, error) {
+	secretKey := os.Getenv("STRIPE_SECRET_KEY")
+	kind := strings.ToLower(os.Getenv("PAYMENT_PROVIDER"))
+	if kind == "" {
+		kind = KindStripe
+		if secretKey == "" {
+			log.Println("Warning: STRIPE_SECRET_KEY is not set, payments are simulated by the fake provider")
+			kind = KindFake
+		}
+	}
+
+	switch kind {
+	case KindStripe:
+		if secretKey == "" {
+			return nil, errors.New("STRIPE_SECRET_KEY is required by the stripe payment provider")
+		}
+		return NewStripe(secretKey), nil
+	case KindFake:
+		return NewFake(), nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider %q", kind)
+	}
+}
@@ -0,0 +1,176 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/stripe/stripe-go/v76"
+	"github.com/stripe/stripe-go/v76/paymentintent"
+	"github.com/stripe/stripe-go/v76/refund"
+)
+
+// Stripe implements PaymentProvider with the Stripe API
+type Stripe struct {
+	secretKey string
+}
+
+// NewStripe creates a Stripe provider authenticating with secretKey
+func NewStripe(secretKey string) *Stripe {
+	return &Stripe{secretKey: secretKey}
+}
+
+// intents returns a payment intent client on the current API backend, so
+// backends swapped by tests are picked up
+func (s *Stripe) intents() paymentintent.Client {
+	return paymentintent.Client{B: stripe.GetBackend(stripe.APIBackend), Key: s.secretKey}
+}
+
+// CreateIntent creates a payment intent
+func (s *Stripe) CreateIntent(ctx context.Context, params IntentParams) (*Intent, error) {
+	intentParams := &stripe.PaymentIntentParams{
+		Amount: stripe.Int64(params.Amount),
+		// Stripe takes currency codes in lower case
+		Currency: stripe.String(strings.ToLower(params.Currency)),
+		Metadata: params.Metadata,
+	}
+	if params.CaptureMethod != "" {
+		intentParams.CaptureMethod = stripe.String(params.CaptureMethod)
+	}
+	intentParams.Context = ctx
+	if params.IdempotencyKey != "" {
+		intentParams.SetIdempotencyKey(params.IdempotencyKey)
+	}
+
+	pi, err := s.intents().New(intentParams)
+	if err != nil {
+		return nil, err
+	}
+	return fromStripeIntent(pi), nil
+}
+
+// GetIntent retrieves a payment intent
+func (s *Stripe) GetIntent(ctx context.Context, id string) (*Intent, error) {
+	params := &stripe.PaymentIntentParams{}
+	params.Context = ctx
+
+	pi, err := s.intents().Get(id, params)
+	var stripeErr *stripe.Error
+	if errors.As(err, &stripeErr) && stripeErr.Code == stripe.ErrorCodeResourceMissing {
+		return nil, ErrIntentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return fromStripeIntent(pi), nil
+}
+
+// CancelIntent cancels a payment intent that was not captured
+func (s *Stripe) CancelIntent(ctx context.Context, id, reason string) (*Intent, error) {
+	params := &stripe.PaymentIntentCancelParams{}
+	if reason != "" {
+		params.CancellationReason = stripe.String(reason)
+	}
+	params.Context = ctx
+
+	pi, err := s.intents().Cancel(id, params)
+	if err != nil {
+		return nil, err
+	}
+	return fromStripeIntent(pi), nil
+}
+
+// CaptureIntent captures an authorized payment intent, in full when amount
+// is 0
+func (s *Stripe) CaptureIntent(ctx context.Context, id string, amount int64) (*Intent, error) {
+	params := &stripe.PaymentIntentCaptureParams{}
+	if amount > 0 {
+		params.AmountToCapture = stripe.Int64(amount)
+	}
+	params.Context = ctx
+
+	pi, err := s.intents().Capture(id, params)
+	if err != nil {
+		return nil, err
+	}
+	return fromStripeIntent(pi), nil
+}
+
+// Refund refunds part or all of a captured payment intent
+func (s *Stripe) Refund(ctx context.Context, params RefundParams) (*Refund, error) {
+	refundParams := &stripe.RefundParams{
+		PaymentIntent: stripe.String(params.IntentID),
+		Amount:        stripe.Int64(params.Amount),
+		Metadata:      params.Metadata,
+	}
+	if params.Reason != "" {
+		refundParams.Reason = stripe.String(params.Reason)
+	}
+	refundParams.Context = ctx
+	if params.IdempotencyKey != "" {
+		refundParams.SetIdempotencyKey(params.IdempotencyKey)
+	}
+
+	client := refund.Client{B: stripe.GetBackend(stripe.APIBackend), Key: s.secretKey}
+	r, err := client.New(refundParams)
+	if err != nil {
+		return nil, err
+	}
+
+	created := &Refund{ID: r.ID, IntentID: params.IntentID, Amount: r.Amount, Status: RefundStatus(r.Status)}
+	if r.Status == stripe.RefundStatusRequiresAction {
+		created.Status = RefundPending
+	}
+	return created, nil
+}
+
+// ListIntents returns a page of the payment intents created in a time
+// range, newest first. Stripe filters on whole seconds.
+func (s *Stripe) ListIntents(ctx context.Context, params ListParams) (*IntentPage, error) {
+	listParams := &stripe.PaymentIntentListParams{
+		CreatedRange: &stripe.RangeQueryParams{
+			GreaterThanOrEqual: params.CreatedFrom.Unix(),
+			LesserThan:         params.CreatedTo.Unix(),
+		},
+	}
+	listParams.Context = ctx
+	listParams.Limit = stripe.Int64(int64(params.Limit))
+	listParams.Single = true
+	if params.StartingAfter != "" {
+		listParams.StartingAfter = stripe.String(params.StartingAfter)
+	}
+
+	iter := s.intents().List(listParams)
+	page := &IntentPage{}
+	for iter.Next() {
+		page.Intents = append(page.Intents, *fromStripeIntent(iter.PaymentIntent()))
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	page.HasMore = iter.Meta().HasMore
+	return page, nil
+}
+
+// fromStripeIntent converts a Stripe payment intent
+func fromStripeIntent(pi *stripe.PaymentIntent) *Intent {
+	intent := &Intent{
+		ID:             pi.ID,
+		Amount:         pi.Amount,
+		AmountReceived: pi.AmountReceived,
+		Currency:       strings.ToUpper(string(pi.Currency)),
+		Status:         IntentStatus(pi.Status),
+		CaptureMethod:  string(pi.CaptureMethod),
+		ClientSecret:   pi.ClientSecret,
+		Metadata:       pi.Metadata,
+		CreatedAt:      time.Unix(pi.Created, 0),
+	}
+	if pi.PaymentMethod != nil {
+		intent.PaymentMethod = string(pi.PaymentMethod.Type)
+	}
+	if pi.LastPaymentError != nil {
+		intent.LastError = pi.LastPaymentError.Msg
+	}
+	return intent
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go-microservices/payment-service/model"
+)
+
+// reconciler is implemented by service.PaymentService
+type reconciler interface {
+	Reconcile(ctx context.Context, from, to time.Time, autoCorrect bool) (*model.ReconciliationReport, error)
+}
+
+const reconcileUsage = `usage: payment-service reconcile [-from time] [-to time] [-format json|csv] [-output file] [-fix]
+
+Compares the payments with the provider payment intents created between
+-from and -to, RFC 3339 times or dates such as 2024-01-31 in UTC, and
+writes the discrepancies found. The last 24 hours are reconciled by default.`
+
+// runReconcile runs the reconcile subcommand, which writes the
+// reconciliation report of a time range to out or to a file, and prints a
+// summary to summary
+func runReconcile(args []string, r reconciler, out, summary io.Writer) error {
+	flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	flags.SetOutput(summary)
+	flags.Usage = func() { fmt.Fprintln(summary, reconcileUsage) }
+	fromFlag := flags.String("from", "", "start of the range, included")
+	toFlag := flags.String("to", "", "end of the range, excluded (default now)")
+	format := flags.String("format", model.ReportFormatJSON, "report format, json or csv")
+	output := flags.String("output", "-", "file to write the report to, - for standard output")
+	fix := flags.Bool("fix", false, "update payment statuses to match the provider")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *format != model.ReportFormatJSON && *format != model.ReportFormatCSV {
+		return fmt.Errorf("unknown report format %q\n%s", *format, reconcileUsage)
+	}
+
+	to := time.Now().UTC()
+	if *toFlag != "" {
+		t, err := parseTime(*toFlag)
+		if err != nil {
+			return err
+		}
+		to = t
+	}
+	from := to.Add(-24 * time.Hour)
+	if *fromFlag != "" {
+		t, err := parseTime(*fromFlag)
+		if err != nil {
+			return err
+		}
+		from = t
+	}
+	if !from.Before(to) {
+		return fmt.Errorf("-from %s is not before -to %s", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+
+	report, err := r.Reconcile(context.Background(), from, to, *fix)
+	if err != nil {
+		return err
+	}
+
+	if *output == "-" {
+		err = writeReport(out, report, *format)
+	} else {
+		err = writeReportFile(*output, report, *format)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(summary, "Reconciled %d intents and %d payments from %s to %s: %d discrepancies, %d corrected\n",
+		report.IntentsChecked, report.PaymentsChecked, from.Format(time.RFC3339), to.Format(time.RFC3339),
+		len(report.Discrepancies), report.Corrected)
+	return nil
+}
+
+// parseTime parses an RFC 3339 time or a date in UTC
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected RFC 3339 or YYYY-MM-DD", value)
+	}
+	return t, nil
+}
+
+// writeReport writes a report in format
+func writeReport(w io.Writer, report *model.ReconciliationReport, format string) error {
+	if format == model.ReportFormatCSV {
+		return report.WriteCSV(w)
+	}
+	return report.WriteJSON(w)
+}
+
+// writeReportFile writes a report in format to a file, replacing it
+func writeReportFile(path string, report *model.ReconciliationReport, format string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeReport(file, report, format); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// saveReports returns the handler of the scheduled reconciliation reports,
+// which writes them in format to dir, named after their range. Reports are
+// only logged when dir is empty.
+func saveReports(dir, format string) func(*model.ReconciliationReport) {
+	return func(report *model.ReconciliationReport) {
+		if dir == "" {
+			return
+		}
+		const layout = "20060102T150405Z"
+		path := filepath.Join(dir, fmt.Sprintf("reconciliation-%s-%s.%s",
+			report.From.UTC().Format(layout), report.To.UTC().Format(layout), format))
+		if err := writeReportFile(path, report, format); err != nil {
+			log.Printf("Failed to write reconciliation report: %v\n", err)
+		}
+	}
+}
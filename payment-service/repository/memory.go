@@ -0,0 +1,385 @@
+package repository
+
+import (
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+
+	"go-microservices/payment-service/model"
+	"go-microservices/pkg/money"
+)
+
+// MemoryPaymentRepository implements PaymentRepository in memory, so the
+// service can run in tests without a database
+type MemoryPaymentRepository struct {
+	mu       sync.RWMutex
+	nextID   int
+	payments map[int]model.Payment
+	events   map[string]bool
+
+	nextRefundID int
+	refunds      map[int]model.Refund
+
+	accessLog []model.PaymentAccess
+}
+
+// NewMemoryPaymentRepository creates an empty in-memory repository
+func NewMemoryPaymentRepository() *MemoryPaymentRepository {
+	return &MemoryPaymentRepository{
+		nextID:   1,
+		payments: make(map[int]model.Payment),
+		events:   make(map[string]bool),
+
+		nextRefundID: 1,
+		refunds:      make(map[int]model.Refund),
+	}
+}
+
+// Create stores a payment under the next free ID
+func (r *MemoryPaymentRepository) Create(payment *model.Payment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	payment.ID = r.nextID
+	r.nextID++
+	r.payments[payment.ID] = *payment
+
+	return nil
+}
+
+// GetByID returns a payment by ID
+func (r *MemoryPaymentRepository) GetByID(id int) (*model.Payment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	payment, ok := r.payments[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	return stored(payment), nil
+}
+
+// GetByStripeID returns the payment made through a Stripe payment intent
+func (r *MemoryPaymentRepository) GetByStripeID(stripePaymentID string) (*model.Payment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, p := range r.payments {
+		if p.StripePaymentID == stripePaymentID {
+			return stored(p), nil
+		}
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+// GetByOrder returns the payments of an order, newest first
+func (r *MemoryPaymentRepository) GetByOrder(orderID int) ([]model.Payment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var payments []model.Payment
+	for _, p := range r.payments {
+		if p.OrderID == orderID {
+			payments = append(payments, *stored(p))
+		}
+	}
+	sort.Slice(payments, func(i, j int) bool { return payments[i].CreatedAt.After(payments[j].CreatedAt) })
+
+	return payments, nil
+}
+
+// GetUncapturedAuthorizations returns the manual capture payments created
+// before createdBefore that may hold an authorization and were not flagged
+// as expiring yet, oldest first
+func (r *MemoryPaymentRepository) GetUncapturedAuthorizations(createdBefore time.Time) ([]model.Payment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var payments []model.Payment
+	for _, p := range r.payments {
+		if p.CaptureMethod == model.CaptureMethodManual && p.ExpiryFlaggedAt == nil && p.CreatedAt.Before(createdBefore) &&
+			(p.Status == model.PaymentStatusPending || p.Status == model.PaymentStatusRequiresCapture) {
+			payments = append(payments, *stored(p))
+		}
+	}
+	sort.Slice(payments, func(i, j int) bool { return payments[i].CreatedAt.Before(payments[j].CreatedAt) })
+
+	return payments, nil
+}
+
+// GetCreatedBetween returns the payments created between from, included,
+// and to, excluded, oldest first
+func (r *MemoryPaymentRepository) GetCreatedBetween(from, to time.Time) ([]model.Payment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var payments []model.Payment
+	for _, p := range r.payments {
+		if !p.CreatedAt.Before(from) && p.CreatedAt.Before(to) {
+			payments = append(payments, *stored(p))
+		}
+	}
+	sort.Slice(payments, func(i, j int) bool {
+		if !payments[i].CreatedAt.Equal(payments[j].CreatedAt) {
+			return payments[i].CreatedAt.Before(payments[j].CreatedAt)
+		}
+		return payments[i].ID < payments[j].ID
+	})
+
+	return payments, nil
+}
+
+// List returns the payments matching q, newest first, skipping offset of
+// them and returning at most limit, together with the number of payments
+// matching q
+func (r *MemoryPaymentRepository) List(q PaymentQuery, limit, offset int) ([]model.Payment, int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var payments []model.Payment
+	for _, p := range r.payments {
+		if q.matches(&p) {
+			payments = append(payments, *stored(p))
+		}
+	}
+	sort.Slice(payments, func(i, j int) bool {
+		if !payments[i].CreatedAt.Equal(payments[j].CreatedAt) {
+			return payments[i].CreatedAt.After(payments[j].CreatedAt)
+		}
+		return payments[i].ID > payments[j].ID
+	})
+
+	total := len(payments)
+	if offset >= total {
+		return nil, total, nil
+	}
+	payments = payments[offset:]
+	if len(payments) > limit {
+		payments = payments[:limit]
+	}
+	return payments, total, nil
+}
+
+// Totals sums the payments matching q by status and currency
+func (r *MemoryPaymentRepository) Totals(q PaymentQuery) ([]model.PaymentTotal, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	type key struct{ status, currency string }
+	sums := make(map[key]*model.PaymentTotal)
+	for _, p := range r.payments {
+		if !q.matches(&p) {
+			continue
+		}
+		k := key{p.Status, p.Amount.Currency()}
+		total, ok := sums[k]
+		if !ok {
+			total = &model.PaymentTotal{Status: p.Status}
+			sums[k] = total
+		}
+		total.Count++
+		var err error
+		if total.Amount, err = total.Amount.Add(p.Amount); err != nil {
+			return nil, err
+		}
+		if total.AmountCaptured, err = total.AmountCaptured.Add(zeroIn(p.AmountCaptured, p.Amount)); err != nil {
+			return nil, err
+		}
+		if total.AmountRefunded, err = total.AmountRefunded.Add(zeroIn(p.AmountRefunded, p.Amount)); err != nil {
+			return nil, err
+		}
+	}
+
+	totals := make([]model.PaymentTotal, 0, len(sums))
+	for _, total := range sums {
+		totals = append(totals, *total)
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].Status != totals[j].Status {
+			return totals[i].Status < totals[j].Status
+		}
+		return totals[i].Amount.Currency() < totals[j].Amount.Currency()
+	})
+
+	return totals, nil
+}
+
+// matches reports whether a payment matches q
+func (q PaymentQuery) matches(p *model.Payment) bool {
+	switch {
+	case q.CustomerID != 0 && p.CustomerID != q.CustomerID,
+		q.Status != "" && p.Status != q.Status,
+		q.Currency != "" && p.Amount.Currency() != q.Currency,
+		!q.CreatedFrom.IsZero() && p.CreatedAt.Before(q.CreatedFrom),
+		!q.CreatedTo.IsZero() && !p.CreatedAt.Before(q.CreatedTo),
+		q.MinAmount != nil && p.Amount.Minor() < *q.MinAmount,
+		q.MaxAmount != nil && p.Amount.Minor() > *q.MaxAmount:
+		return false
+	}
+	return true
+}
+
+// zeroIn returns m, or zero in the currency of like if m is the zero
+// Money, so totals always carry their currency
+func zeroIn(m, like money.Money) money.Money {
+	if m.Currency() != "" {
+		return m
+	}
+	return money.MustNew(0, like.Currency())
+}
+
+// UpdateByStripeID updates the payment made through a Stripe payment
+// intent with apply
+func (r *MemoryPaymentRepository) UpdateByStripeID(stripePaymentID string, apply func(payment *model.Payment) error) (*model.Payment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, p := range r.payments {
+		if p.StripePaymentID == stripePaymentID {
+			return r.update(id, apply)
+		}
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+// AddRefund updates a payment with apply and stores a refund of it,
+// setting the refund's ID
+func (r *MemoryPaymentRepository) AddRefund(paymentID int, refund *model.Refund, apply func(payment *model.Payment) error) (*model.Payment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	payment, err := r.update(paymentID, apply)
+	if err != nil {
+		return nil, err
+	}
+
+	refund.ID = r.nextRefundID
+	refund.PaymentID = paymentID
+	r.nextRefundID++
+	r.refunds[refund.ID] = *refund
+
+	return payment, nil
+}
+
+// UpdateRefund stores the status and Stripe ID of a refund and updates its
+// payment with apply
+func (r *MemoryPaymentRepository) UpdateRefund(refund *model.Refund, apply func(payment *model.Payment) error) (*model.Payment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.refunds[refund.ID]
+	if !ok || existing.PaymentID != refund.PaymentID {
+		return nil, sql.ErrNoRows
+	}
+
+	payment, err := r.update(refund.PaymentID, apply)
+	if err != nil {
+		return nil, err
+	}
+
+	existing.Status = refund.Status
+	existing.StripeRefundID = refund.StripeRefundID
+	existing.UpdatedAt = refund.UpdatedAt
+	r.refunds[refund.ID] = existing
+
+	return payment, nil
+}
+
+// GetRefunds returns the refunds of a payment, oldest first
+func (r *MemoryPaymentRepository) GetRefunds(paymentID int) ([]model.Refund, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var refunds []model.Refund
+	for _, refund := range r.refunds {
+		if refund.PaymentID == paymentID {
+			refunds = append(refunds, refund)
+		}
+	}
+	sort.Slice(refunds, func(i, j int) bool { return refunds[i].ID < refunds[j].ID })
+
+	return refunds, nil
+}
+
+// EventProcessed reports whether a Stripe webhook event was recorded
+func (r *MemoryPaymentRepository) EventProcessed(eventID string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.events[eventID], nil
+}
+
+// RecordEvent records a processed Stripe webhook event, returning
+// ErrDuplicateEvent if it already was
+func (r *MemoryPaymentRepository) RecordEvent(eventID, eventType string, processedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.events[eventID] {
+		return ErrDuplicateEvent
+	}
+	r.events[eventID] = true
+
+	return nil
+}
+
+// RecordAccess appends entries to the audit log of payment reads
+func (r *MemoryPaymentRepository) RecordAccess(entries []model.PaymentAccess) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range entries {
+		entries[i].ID = len(r.accessLog) + 1
+		r.accessLog = append(r.accessLog, entries[i])
+	}
+
+	return nil
+}
+
+// GetAccessLog returns the audit log of a payment's reads, newest first
+func (r *MemoryPaymentRepository) GetAccessLog(paymentID int) ([]model.PaymentAccess, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var entries []model.PaymentAccess
+	for i := len(r.accessLog) - 1; i >= 0; i-- {
+		if r.accessLog[i].PaymentID == paymentID {
+			entries = append(entries, r.accessLog[i])
+		}
+	}
+
+	return entries, nil
+}
+
+// update applies apply to a copy of a payment and stores the copy if apply
+// succeeds. The caller holds the write lock.
+func (r *MemoryPaymentRepository) update(id int, apply func(payment *model.Payment) error) (*model.Payment, error) {
+	p, ok := r.payments[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	payment := stored(p)
+	if err := apply(payment); err != nil {
+		return nil, err
+	}
+
+	p.Status = payment.Status
+	p.PaymentMethod = payment.PaymentMethod
+	p.AmountCaptured = payment.AmountCaptured
+	p.AmountRefunded = payment.AmountRefunded
+	p.ExpiryFlaggedAt = payment.ExpiryFlaggedAt
+	p.UpdatedAt = payment.UpdatedAt
+	r.payments[id] = p
+
+	return stored(p), nil
+}
+
+// stored returns a copy of a stored payment
+func stored(payment model.Payment) *model.Payment {
+	return &payment
+}
@@ -0,0 +1,571 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-microservices/payment-service/model"
+	"go-microservices/pkg/envelope"
+	"go-microservices/pkg/money"
+)
+
+// ErrDuplicateEvent is returned by RecordEvent when a Stripe event was
+// already recorded
+var ErrDuplicateEvent = errors.New("webhook event already processed")
+
+// PaymentRepository defines the storage operations on payments and their
+// refunds. Lookups and updates of a missing payment return sql.ErrNoRows.
+//
+// The update methods taking an apply function lock the payment, call apply
+// with it and store the status, payment method, captured and refunded
+// amounts, expiry flag and update time apply set. An error returned by apply leaves the payment unchanged
+// and is returned.
+type PaymentRepository interface {
+	Create(payment *model.Payment) error
+	GetByID(id int) (*model.Payment, error)
+	// GetByStripeID returns the payment made through a Stripe payment
+	// intent
+	GetByStripeID(stripePaymentID string) (*model.Payment, error)
+	// GetByOrder returns the payments of an order, newest first
+	GetByOrder(orderID int) ([]model.Payment, error)
+	// GetUncapturedAuthorizations returns the manual capture payments
+	// created before createdBefore that may hold an authorization and were
+	// not flagged as expiring yet, oldest first
+	GetUncapturedAuthorizations(createdBefore time.Time) ([]model.Payment, error)
+	// GetCreatedBetween returns the payments created between from,
+	// included, and to, excluded, oldest first
+	GetCreatedBetween(from, to time.Time) ([]model.Payment, error)
+	// List returns the payments matching q, newest first, skipping offset
+	// of them and returning at most limit, together with the number of
+	// payments matching q
+	List(q PaymentQuery, limit, offset int) ([]model.Payment, int, error)
+	// Totals sums the payments matching q by status and currency
+	Totals(q PaymentQuery) ([]model.PaymentTotal, error)
+	// UpdateByStripeID updates the payment made through a Stripe payment
+	// intent with apply
+	UpdateByStripeID(stripePaymentID string, apply func(payment *model.Payment) error) (*model.Payment, error)
+	// AddRefund updates a payment with apply and stores a refund of it,
+	// setting the refund's ID
+	AddRefund(paymentID int, refund *model.Refund, apply func(payment *model.Payment) error) (*model.Payment, error)
+	// UpdateRefund stores the status and Stripe ID of a refund and updates
+	// its payment with apply
+	UpdateRefund(refund *model.Refund, apply func(payment *model.Payment) error) (*model.Payment, error)
+	// GetRefunds returns the refunds of a payment, oldest first
+	GetRefunds(paymentID int) ([]model.Refund, error)
+	// EventProcessed reports whether a Stripe webhook event was recorded
+	EventProcessed(eventID string) (bool, error)
+	// RecordEvent records a processed Stripe webhook event, returning
+	// ErrDuplicateEvent if it already was
+	RecordEvent(eventID, eventType string, processedAt time.Time) error
+	// RecordAccess appends entries to the audit log of payment reads
+	RecordAccess(entries []model.PaymentAccess) error
+	// GetAccessLog returns the audit log of a payment's reads, newest
+	// first
+	GetAccessLog(paymentID int) ([]model.PaymentAccess, error)
+}
+
+// PaymentQuery selects payments for List and Totals. Zero fields match
+// every payment.
+type PaymentQuery struct {
+	CustomerID int
+	Status     string
+	Currency   string
+	// CreatedFrom is included and CreatedTo excluded
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	// MinAmount and MaxAmount are included, in minor units of Currency
+	MinAmount *int64
+	MaxAmount *int64
+}
+
+// paymentMethodContext binds encrypted payment methods to their column
+const paymentMethodContext = "payments.payment_method"
+
+// selectPayments selects the payment columns read by scanPayment. Amounts
+// are stored in minor units of the payment's currency.
+const selectPayments = `
+		SELECT id, order_id, customer_id, amount_minor, currency, status, stripe_payment_id,
+		       COALESCE(payment_method, '') as payment_method, capture_method, amount_captured_minor,
+		       amount_refunded_minor, expiry_flagged_at, created_at, updated_at
+		FROM payments`
+
+// DBPaymentRepository implements PaymentRepository using the payments table.
+// Payment methods are encrypted with Keyring, or stored in plain text if it
+// is nil; values stored in plain text are still read once it is set.
+type DBPaymentRepository struct {
+	DB      *sql.DB
+	Keyring *envelope.Keyring
+}
+
+// NewDBPaymentRepository creates a repository backed by the given database,
+// encrypting payment methods with keyring
+func NewDBPaymentRepository(db *sql.DB, keyring *envelope.Keyring) *DBPaymentRepository {
+	return &DBPaymentRepository{DB: db, Keyring: keyring}
+}
+
+// Create inserts a payment and sets its ID
+func (r *DBPaymentRepository) Create(payment *model.Payment) error {
+	query := `
+		INSERT INTO payments (order_id, customer_id, amount_minor, currency, status, stripe_payment_id, payment_method,
+		                      capture_method, amount_captured_minor, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9, $10, $11)
+		RETURNING id
+	`
+
+	paymentMethod, err := r.encrypt(payment.PaymentMethod)
+	if err != nil {
+		return err
+	}
+	return r.DB.QueryRow(query, payment.OrderID, payment.CustomerID, payment.Amount.Minor(), payment.Amount.Currency(),
+		payment.Status, payment.StripePaymentID, paymentMethod, payment.CaptureMethod, payment.AmountCaptured.Minor(),
+		payment.CreatedAt, payment.UpdatedAt).Scan(&payment.ID)
+}
+
+// GetByID returns a payment by ID
+func (r *DBPaymentRepository) GetByID(id int) (*model.Payment, error) {
+	return r.scanPayment(r.DB.QueryRow(selectPayments+" WHERE id = $1", id))
+}
+
+// GetByStripeID returns the payment made through a Stripe payment intent
+func (r *DBPaymentRepository) GetByStripeID(stripePaymentID string) (*model.Payment, error) {
+	return r.scanPayment(r.DB.QueryRow(selectPayments+" WHERE stripe_payment_id = $1", stripePaymentID))
+}
+
+// GetByOrder returns the payments of an order, newest first
+func (r *DBPaymentRepository) GetByOrder(orderID int) ([]model.Payment, error) {
+	return r.query(selectPayments+" WHERE order_id = $1 ORDER BY created_at DESC", orderID)
+}
+
+// GetUncapturedAuthorizations returns the manual capture payments created
+// before createdBefore that may hold an authorization and were not flagged
+// as expiring yet, oldest first
+func (r *DBPaymentRepository) GetUncapturedAuthorizations(createdBefore time.Time) ([]model.Payment, error) {
+	return r.query(selectPayments+`
+		WHERE capture_method = $1 AND status IN ($2, $3) AND expiry_flagged_at IS NULL AND created_at < $4
+		ORDER BY created_at`,
+		model.CaptureMethodManual, model.PaymentStatusPending, model.PaymentStatusRequiresCapture, createdBefore)
+}
+
+// GetCreatedBetween returns the payments created between from, included,
+// and to, excluded, oldest first
+func (r *DBPaymentRepository) GetCreatedBetween(from, to time.Time) ([]model.Payment, error) {
+	return r.query(selectPayments+" WHERE created_at >= $1 AND created_at < $2 ORDER BY created_at, id", from, to)
+}
+
+// List returns the payments matching q, newest first, skipping offset of
+// them and returning at most limit, together with the number of payments
+// matching q
+func (r *DBPaymentRepository) List(q PaymentQuery, limit, offset int) ([]model.Payment, int, error) {
+	where, args := q.where()
+
+	var total int
+	if err := r.DB.QueryRow("SELECT COUNT(*) FROM payments"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	if total <= offset {
+		return nil, total, nil
+	}
+
+	payments, err := r.query(selectPayments+where+
+		fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2),
+		append(args, limit, offset)...)
+	return payments, total, err
+}
+
+// Totals sums the payments matching q by status and currency
+func (r *DBPaymentRepository) Totals(q PaymentQuery) ([]model.PaymentTotal, error) {
+	where, args := q.where()
+	rows, err := r.DB.Query(`
+		SELECT status, currency, COUNT(*), SUM(amount_minor), SUM(amount_captured_minor), SUM(amount_refunded_minor)
+		FROM payments`+where+`
+		GROUP BY status, currency ORDER BY status, currency`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var totals []model.PaymentTotal
+	for rows.Next() {
+		var total model.PaymentTotal
+		var currency string
+		var amount, captured, refunded int64
+		if err := rows.Scan(&total.Status, &currency, &total.Count, &amount, &captured, &refunded); err != nil {
+			return nil, err
+		}
+		if total.Amount, err = money.New(amount, currency); err != nil {
+			return nil, err
+		}
+		if total.AmountCaptured, err = money.New(captured, currency); err != nil {
+			return nil, err
+		}
+		if total.AmountRefunded, err = money.New(refunded, currency); err != nil {
+			return nil, err
+		}
+		totals = append(totals, total)
+	}
+
+	return totals, rows.Err()
+}
+
+// where returns the WHERE clause selecting the payments matching q, empty
+// if all do, and its arguments
+func (q PaymentQuery) where() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if q.CustomerID != 0 {
+		add("customer_id = $%d", q.CustomerID)
+	}
+	if q.Status != "" {
+		add("status = $%d", q.Status)
+	}
+	if q.Currency != "" {
+		add("currency = $%d", q.Currency)
+	}
+	if !q.CreatedFrom.IsZero() {
+		add("created_at >= $%d", q.CreatedFrom)
+	}
+	if !q.CreatedTo.IsZero() {
+		add("created_at < $%d", q.CreatedTo)
+	}
+	if q.MinAmount != nil {
+		add("amount_minor >= $%d", *q.MinAmount)
+	}
+	if q.MaxAmount != nil {
+		add("amount_minor <= $%d", *q.MaxAmount)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// query returns the payments selected by a query on selectPayments
+func (r *DBPaymentRepository) query(query string, args ...interface{}) ([]model.Payment, error) {
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payments []model.Payment
+	for rows.Next() {
+		payment, err := r.scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, *payment)
+	}
+
+	return payments, rows.Err()
+}
+
+// UpdateByStripeID updates the payment made through a Stripe payment
+// intent with apply
+func (r *DBPaymentRepository) UpdateByStripeID(stripePaymentID string, apply func(payment *model.Payment) error) (*model.Payment, error) {
+	return r.update("stripe_payment_id = $1", stripePaymentID, apply, nil)
+}
+
+// AddRefund updates a payment with apply and stores a refund of it,
+// setting the refund's ID
+func (r *DBPaymentRepository) AddRefund(paymentID int, refund *model.Refund, apply func(payment *model.Payment) error) (*model.Payment, error) {
+	return r.update("id = $1", paymentID, apply, func(tx *sql.Tx, payment *model.Payment) error {
+		refund.PaymentID = payment.ID
+		return tx.QueryRow(`
+			INSERT INTO refunds (payment_id, amount_minor, currency, reason, status, stripe_refund_id, created_at, updated_at)
+			VALUES ($1, $2, $3, NULLIF($4, ''), $5, NULLIF($6, ''), $7, $8)
+			RETURNING id`,
+			refund.PaymentID, refund.Amount.Minor(), refund.Amount.Currency(), refund.Reason, refund.Status,
+			refund.StripeRefundID, refund.CreatedAt, refund.UpdatedAt).Scan(&refund.ID)
+	})
+}
+
+// UpdateRefund stores the status and Stripe ID of a refund and updates its
+// payment with apply
+func (r *DBPaymentRepository) UpdateRefund(refund *model.Refund, apply func(payment *model.Payment) error) (*model.Payment, error) {
+	return r.update("id = $1", refund.PaymentID, apply, func(tx *sql.Tx, _ *model.Payment) error {
+		result, err := tx.Exec(
+			"UPDATE refunds SET status = $1, stripe_refund_id = NULLIF($2, ''), updated_at = $3 WHERE id = $4",
+			refund.Status, refund.StripeRefundID, refund.UpdatedAt, refund.ID)
+		if err != nil {
+			return err
+		}
+		return checkAffected(result)
+	})
+}
+
+// GetRefunds returns the refunds of a payment, oldest first
+func (r *DBPaymentRepository) GetRefunds(paymentID int) ([]model.Refund, error) {
+	rows, err := r.DB.Query(`
+		SELECT id, payment_id, amount_minor, currency, COALESCE(reason, ''), status, COALESCE(stripe_refund_id, ''), created_at, updated_at
+		FROM refunds WHERE payment_id = $1 ORDER BY created_at, id`, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var refunds []model.Refund
+	for rows.Next() {
+		var refund model.Refund
+		var amount int64
+		var currency string
+		if err := rows.Scan(&refund.ID, &refund.PaymentID, &amount, &currency, &refund.Reason,
+			&refund.Status, &refund.StripeRefundID, &refund.CreatedAt, &refund.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if refund.Amount, err = money.New(amount, currency); err != nil {
+			return nil, err
+		}
+		refunds = append(refunds, refund)
+	}
+
+	return refunds, rows.Err()
+}
+
+// update locks the payment matching where, applies apply to it and stores
+// it together with the writes of store, in one transaction
+func (r *DBPaymentRepository) update(where string, arg interface{}, apply func(payment *model.Payment) error, store func(tx *sql.Tx, payment *model.Payment) error) (*model.Payment, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	payment, err := r.scanPayment(tx.QueryRow(selectPayments+" WHERE "+where+" FOR UPDATE", arg))
+	if err != nil {
+		return nil, err
+	}
+	if err := apply(payment); err != nil {
+		return nil, err
+	}
+	if store != nil {
+		if err := store(tx, payment); err != nil {
+			return nil, err
+		}
+	}
+
+	paymentMethod, err := r.encrypt(payment.PaymentMethod)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(
+		`UPDATE payments SET status = $1, payment_method = NULLIF($2, ''), amount_captured_minor = $3, amount_refunded_minor = $4,
+		 expiry_flagged_at = $5, updated_at = $6 WHERE id = $7`,
+		payment.Status, paymentMethod, payment.AmountCaptured.Minor(), payment.AmountRefunded.Minor(),
+		payment.ExpiryFlaggedAt, payment.UpdatedAt, payment.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return payment, tx.Commit()
+}
+
+// checkAffected returns sql.ErrNoRows when a statement touched no rows
+func checkAffected(result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// EventProcessed reports whether a Stripe webhook event was recorded
+func (r *DBPaymentRepository) EventProcessed(eventID string) (bool, error) {
+	var processed bool
+	err := r.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM webhook_events WHERE event_id = $1)", eventID).Scan(&processed)
+	return processed, err
+}
+
+// RecordEvent records a processed Stripe webhook event, returning
+// ErrDuplicateEvent if it already was
+func (r *DBPaymentRepository) RecordEvent(eventID, eventType string, processedAt time.Time) error {
+	var id string
+	err := r.DB.QueryRow(
+		"INSERT INTO webhook_events (event_id, type, processed_at) VALUES ($1, $2, $3) ON CONFLICT (event_id) DO NOTHING RETURNING event_id",
+		eventID, eventType, processedAt,
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return ErrDuplicateEvent
+	}
+	return err
+}
+
+// RecordAccess appends entries to the audit log of payment reads, in one
+// transaction
+func (r *DBPaymentRepository) RecordAccess(entries []model.PaymentAccess) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i := range entries {
+		entry := &entries[i]
+		err := tx.QueryRow(`
+			INSERT INTO payment_access_log (payment_id, actor, client_ip, action, request_id, accessed_at)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)
+			RETURNING id`,
+			entry.PaymentID, entry.Actor, entry.ClientIP, entry.Action, entry.RequestID, entry.AccessedAt).Scan(&entry.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetAccessLog returns the audit log of a payment's reads, newest first
+func (r *DBPaymentRepository) GetAccessLog(paymentID int) ([]model.PaymentAccess, error) {
+	rows, err := r.DB.Query(`
+		SELECT id, payment_id, actor, client_ip, action, COALESCE(request_id, ''), accessed_at
+		FROM payment_access_log WHERE payment_id = $1 ORDER BY accessed_at DESC, id DESC`, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []model.PaymentAccess
+	for rows.Next() {
+		var entry model.PaymentAccess
+		if err := rows.Scan(&entry.ID, &entry.PaymentID, &entry.Actor, &entry.ClientIP, &entry.Action,
+			&entry.RequestID, &entry.AccessedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// RotateKeys rewraps the payment methods encrypted with an old key of the
+// keyring with its active key, and encrypts those stored in plain text,
+// returning how many were updated. A payment updated concurrently already
+// has its method encrypted with the active key, and is left as is.
+func (r *DBPaymentRepository) RotateKeys() (int, error) {
+	if r.Keyring == nil {
+		return 0, errors.New("no encryption keys are configured")
+	}
+
+	activePrefix := envelope.Prefix + r.Keyring.ActiveKeyID() + ":"
+	rotated := 0
+	for {
+		rows, err := r.DB.Query(`
+			SELECT id, payment_method FROM payments
+			WHERE payment_method IS NOT NULL AND LEFT(payment_method, $1) <> $2
+			ORDER BY id LIMIT 500`, len(activePrefix), activePrefix)
+		if err != nil {
+			return rotated, err
+		}
+		stale := make(map[int]string)
+		for rows.Next() {
+			var id int
+			var value string
+			if err := rows.Scan(&id, &value); err != nil {
+				rows.Close()
+				return rotated, err
+			}
+			stale[id] = value
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return rotated, err
+		}
+		if len(stale) == 0 {
+			return rotated, nil
+		}
+
+		for id, value := range stale {
+			var current string
+			if envelope.IsEncrypted(value) {
+				current, err = r.Keyring.Rewrap(value)
+			} else {
+				current, err = r.Keyring.Encrypt([]byte(value), paymentMethodContext)
+			}
+			if err != nil {
+				return rotated, fmt.Errorf("payment %d: %w", id, err)
+			}
+			result, err := r.DB.Exec("UPDATE payments SET payment_method = $1 WHERE id = $2 AND payment_method = $3", current, id, value)
+			if err != nil {
+				return rotated, err
+			}
+			if n, err := result.RowsAffected(); err == nil && n > 0 {
+				rotated++
+			}
+		}
+	}
+}
+
+// encrypt encrypts a payment method to store, unless it is empty or no
+// keyring is set
+func (r *DBPaymentRepository) encrypt(paymentMethod string) (string, error) {
+	if r.Keyring == nil || paymentMethod == "" {
+		return paymentMethod, nil
+	}
+	return r.Keyring.Encrypt([]byte(paymentMethod), paymentMethodContext)
+}
+
+// decrypt decrypts a stored payment method. Values stored before
+// encryption was enabled are returned as they are.
+func (r *DBPaymentRepository) decrypt(paymentMethod string) (string, error) {
+	if !envelope.IsEncrypted(paymentMethod) {
+		return paymentMethod, nil
+	}
+	if r.Keyring == nil {
+		return "", errors.New("payment method is encrypted but no encryption keys are configured")
+	}
+	plaintext, err := r.Keyring.Decrypt(paymentMethod, paymentMethodContext)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt payment method: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// scanner is satisfied by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanPayment reads the payment columns selected by every query,
+// decrypting the payment method
+func (r *DBPaymentRepository) scanPayment(s scanner) (*model.Payment, error) {
+	var payment model.Payment
+	var amount, amountCaptured, amountRefunded int64
+	var currency string
+	var expiryFlaggedAt sql.NullTime
+	err := s.Scan(
+		&payment.ID, &payment.OrderID, &payment.CustomerID, &amount, &currency,
+		&payment.Status, &payment.StripePaymentID, &payment.PaymentMethod, &payment.CaptureMethod,
+		&amountCaptured, &amountRefunded, &expiryFlaggedAt, &payment.CreatedAt, &payment.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Amount, err = money.New(amount, currency); err != nil {
+		return nil, err
+	}
+	if payment.AmountCaptured, err = money.New(amountCaptured, currency); err != nil {
+		return nil, err
+	}
+	if payment.AmountRefunded, err = money.New(amountRefunded, currency); err != nil {
+		return nil, err
+	}
+	if expiryFlaggedAt.Valid {
+		payment.ExpiryFlaggedAt = &expiryFlaggedAt.Time
+	}
+	if payment.PaymentMethod, err = r.decrypt(payment.PaymentMethod); err != nil {
+		return nil, err
+	}
+
+	return &payment, nil
+}
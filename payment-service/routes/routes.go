@@ -1,22 +1,18 @@
 package routes
 
 import (
+	"go-microservices/payment-service/api"
 	"go-microservices/payment-service/controller"
+	"go-microservices/pkg/apidocs"
 
 	"github.com/gin-gonic/gin"
 )
 
 // SetupRoutes configures the payment service routes
 func SetupRoutes(router *gin.Engine, paymentController *controller.PaymentController) {
-	// Health check
-	router.GET("/health", paymentController.HealthCheck)
+	// Health check and payment routes, as declared in api/openapi.json
+	api.RegisterHandlers(router, paymentController)
 
-	// Payment routes
-	paymentRoutes := router.Group("/payments")
-	{
-		paymentRoutes.POST("/", paymentController.CreatePayment)            // Create payment intent
-		paymentRoutes.POST("/confirm", paymentController.ConfirmPayment)    // Confirm payment
-		paymentRoutes.GET("/:id", paymentController.GetPayment)            // Get payment by ID
-		paymentRoutes.GET("/order/:orderId", paymentController.GetPaymentsByOrder) // Get payments by order ID
-	}
-}
\ No newline at end of file
+	// OpenAPI document and Swagger UI
+	apidocs.Register(router, api.Spec)
+}
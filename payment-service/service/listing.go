@@ -0,0 +1,112 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"go-microservices/payment-service/model"
+	"go-microservices/payment-service/repository"
+	"go-microservices/pkg/money"
+)
+
+// ListPayments returns a page of the payments matching filter, newest
+// first. Pages are numbered from 1 and hold model.DefaultPageSize payments
+// unless filter sets another size.
+func (s *PaymentService) ListPayments(filter model.PaymentFilter) (*model.PaymentPage, error) {
+	q, err := paymentQuery(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &model.PaymentPage{Page: filter.Page, PageSize: filter.PageSize}
+	if page.Page < 1 {
+		page.Page = 1
+	}
+	if page.Page > model.MaxPage {
+		return nil, fmt.Errorf("%w: page is at most %d", ErrInvalidFilter, model.MaxPage)
+	}
+	if page.PageSize < 1 {
+		page.PageSize = model.DefaultPageSize
+	}
+	if page.PageSize > model.MaxPageSize {
+		page.PageSize = model.MaxPageSize
+	}
+
+	page.Payments, page.Total, err = s.repo.List(q, page.PageSize, (page.Page-1)*page.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	if page.Payments == nil {
+		page.Payments = []model.Payment{}
+	}
+	return page, nil
+}
+
+// GetPaymentTotals sums the payments matching filter by status and
+// currency, ignoring its page
+func (s *PaymentService) GetPaymentTotals(filter model.PaymentFilter) ([]model.PaymentTotal, error) {
+	q, err := paymentQuery(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	totals, err := s.repo.Totals(q)
+	if err != nil {
+		return nil, err
+	}
+	if totals == nil {
+		totals = []model.PaymentTotal{}
+	}
+	return totals, nil
+}
+
+// paymentQuery validates filter and converts its amounts to minor units of
+// its currency
+func paymentQuery(filter model.PaymentFilter) (repository.PaymentQuery, error) {
+	q := repository.PaymentQuery{CustomerID: filter.CustomerID, Status: filter.Status}
+	if filter.CreatedFrom != nil {
+		q.CreatedFrom = *filter.CreatedFrom
+	}
+	if filter.CreatedTo != nil {
+		q.CreatedTo = *filter.CreatedTo
+	}
+	if !q.CreatedFrom.IsZero() && !q.CreatedTo.IsZero() && !q.CreatedFrom.Before(q.CreatedTo) {
+		return q, fmt.Errorf("%w: created_from must be before created_to", ErrInvalidFilter)
+	}
+
+	if filter.Currency != "" {
+		currency, err := money.LookupCurrency(filter.Currency)
+		if err != nil {
+			return q, err
+		}
+		q.Currency = currency.Code
+	} else if filter.MinAmount != nil || filter.MaxAmount != nil {
+		return q, fmt.Errorf("%w: min_amount and max_amount need a currency", ErrInvalidFilter)
+	}
+
+	var err error
+	if q.MinAmount, err = minorAmount(filter.MinAmount, q.Currency); err != nil {
+		return q, err
+	}
+	if q.MaxAmount, err = minorAmount(filter.MaxAmount, q.Currency); err != nil {
+		return q, err
+	}
+	if q.MinAmount != nil && q.MaxAmount != nil && *q.MinAmount > *q.MaxAmount {
+		return q, fmt.Errorf("%w: min_amount is more than max_amount", ErrInvalidFilter)
+	}
+	return q, nil
+}
+
+// minorAmount parses an optional decimal amount of a currency into minor
+// units
+func minorAmount(amount *json.Number, currency string) (*int64, error) {
+	if amount == nil {
+		return nil, nil
+	}
+	m, err := money.Parse(amount.String(), currency)
+	if err != nil {
+		return nil, err
+	}
+	minor := m.Minor()
+	return &minor, nil
+}
@@ -0,0 +1,814 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"go-microservices/payment-service/model"
+	"go-microservices/payment-service/provider"
+	"go-microservices/payment-service/repository"
+	"go-microservices/pkg/broker"
+	"go-microservices/pkg/events"
+	"go-microservices/pkg/money"
+
+	"github.com/stripe/stripe-go/v76"
+)
+
+var (
+	// ErrPaymentNotFound is returned when no payment has the requested ID
+	ErrPaymentNotFound = errors.New("payment not found")
+	// ErrDuplicateEvent is returned by HandleWebhookEvent for events it
+	// already processed
+	ErrDuplicateEvent = errors.New("webhook event already processed")
+	// ErrUnhandledEvent is returned by HandleWebhookEvent for events that
+	// do not change a payment, such as event types it does not handle or
+	// events older than the payment's status
+	ErrUnhandledEvent = errors.New("webhook event not handled")
+	// ErrNotRefundable is returned when refunding a payment that was not
+	// captured or is already refunded in full
+	ErrNotRefundable = errors.New("payment cannot be refunded")
+	// ErrRefundExceedsPayment is returned when a refund is larger than the
+	// part of the payment not refunded yet
+	ErrRefundExceedsPayment = errors.New("refund exceeds the refundable amount")
+	// ErrNotCapturable is returned when capturing a payment that is not
+	// an uncaptured authorization
+	ErrNotCapturable = errors.New("payment cannot be captured")
+	// ErrCaptureExceedsAuthorization is returned when a capture is larger
+	// than the authorized amount
+	ErrCaptureExceedsAuthorization = errors.New("capture exceeds the authorized amount")
+	// ErrNotCancelable is returned when canceling a payment that was
+	// already captured or ended
+	ErrNotCancelable = errors.New("payment cannot be canceled")
+	// ErrInvalidFilter is returned when listing payments with a filter
+	// that cannot match, such as amounts without a currency
+	ErrInvalidFilter = errors.New("invalid payment filter")
+)
+
+// AuthorizationValidity is how long Stripe holds an uncaptured card
+// authorization before canceling it
+const AuthorizationValidity = 7 * 24 * time.Hour
+
+// ProviderError wraps a failed call to the payment provider
+type ProviderError struct {
+	Op  string
+	Err error
+}
+
+func (e *ProviderError) Error() string {
+	return "Failed to " + e.Op + ": " + e.Err.Error()
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// PaymentService creates and tracks payment intents with a payment provider
+type PaymentService struct {
+	repo      repository.PaymentRepository
+	provider  provider.PaymentProvider
+	publisher broker.Publisher
+	now       func() time.Time
+}
+
+// NewPaymentService creates a payment service storing payments in repo,
+// charging customers through paymentProvider and publishing payment events
+// to publisher. Events are not published if publisher is nil.
+func NewPaymentService(repo repository.PaymentRepository, paymentProvider provider.PaymentProvider, publisher broker.Publisher) *PaymentService {
+	return &PaymentService{repo: repo, provider: paymentProvider, publisher: publisher, now: time.Now}
+}
+
+// CreatePayment creates a payment intent with the provider and records it
+// as pending. The amount must be positive and no finer than the minor unit
+// of its currency. A manual capture payment is only authorized, and must be
+// captured with CapturePayment or canceled within AuthorizationValidity.
+func (s *PaymentService) CreatePayment(req model.PaymentRequest) (*model.PaymentResponse, error) {
+	amount, err := money.Parse(req.Amount.String(), req.Currency)
+	if err != nil {
+		return nil, err
+	}
+	if !amount.IsPositive() {
+		return nil, fmt.Errorf("%w: %s is not positive", money.ErrInvalidAmount, amount)
+	}
+
+	captureMethod := req.CaptureMethod
+	if captureMethod == "" {
+		captureMethod = model.CaptureMethodAutomatic
+	}
+
+	pi, err := s.provider.CreateIntent(context.Background(), provider.IntentParams{
+		Amount:        amount.Minor(),
+		Currency:      amount.Currency(),
+		CaptureMethod: captureMethod,
+		Metadata: map[string]string{
+			"order_id":    strconv.Itoa(req.OrderID),
+			"customer_id": strconv.Itoa(req.CustomerID),
+		},
+	})
+	if err != nil {
+		return nil, &ProviderError{Op: "create payment intent", Err: err}
+	}
+
+	now := s.now()
+	payment := model.Payment{
+		OrderID:         req.OrderID,
+		CustomerID:      req.CustomerID,
+		Amount:          amount,
+		Status:          model.PaymentStatusPending,
+		StripePaymentID: pi.ID,
+		CaptureMethod:   captureMethod,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+
+	if err := s.repo.Create(&payment); err != nil {
+		return nil, err
+	}
+
+	return &model.PaymentResponse{
+		Payment:      payment,
+		ClientSecret: pi.ClientSecret,
+		Message:      "Payment intent created successfully",
+	}, nil
+}
+
+// ConfirmPayment reads the payment intent from the provider and stores its
+// status
+func (s *PaymentService) ConfirmPayment(paymentIntentID string) (*model.PaymentResponse, error) {
+	pi, err := s.provider.GetIntent(context.Background(), paymentIntentID)
+	if errors.Is(err, provider.ErrIntentNotFound) {
+		return nil, ErrPaymentNotFound
+	}
+	if err != nil {
+		return nil, &ProviderError{Op: "retrieve payment intent", Err: err}
+	}
+
+	paymentMethod := pi.PaymentMethod
+	status := StatusFromIntent(pi.Status)
+	payment, err := s.repo.UpdateByStripeID(pi.ID, func(p *model.Payment) error {
+		// A refunded payment's intent still reads succeeded
+		if !canTransition(p.Status, status) {
+			return nil
+		}
+		p.Status = status
+		if paymentMethod != "" {
+			p.PaymentMethod = paymentMethod
+		}
+		if status == model.PaymentStatusSucceeded {
+			if err := setCaptured(p, pi.AmountReceived); err != nil {
+				return err
+			}
+		}
+		p.UpdatedAt = s.now()
+		return nil
+	})
+	if err == sql.ErrNoRows {
+		return nil, ErrPaymentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.PaymentResponse{
+		Payment: *payment,
+		Message: "Payment status updated successfully",
+	}, nil
+}
+
+// CapturePayment captures an authorized manual capture payment through the
+// provider. Without an amount in req the whole authorization is captured;
+// a smaller amount captures part of it and releases the rest. Pending
+// payments may be authorized without the service having heard of it yet,
+// so they are left for the provider to accept or reject.
+func (s *PaymentService) CapturePayment(paymentID int, req model.CaptureRequest) (*model.PaymentResponse, error) {
+	payment, err := s.GetPayment(paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if payment.CaptureMethod != model.CaptureMethodManual ||
+		(payment.Status != model.PaymentStatusPending && payment.Status != model.PaymentStatusRequiresCapture) {
+		return nil, fmt.Errorf("%w: %s payment is %s", ErrNotCapturable, payment.CaptureMethod, payment.Status)
+	}
+
+	// 0 captures the whole authorization
+	var amount int64
+	if req.Amount != nil {
+		captured, err := money.Parse(req.Amount.String(), payment.Amount.Currency())
+		if err != nil {
+			return nil, err
+		}
+		if !captured.IsPositive() {
+			return nil, fmt.Errorf("%w: capture amount %s is not positive", money.ErrInvalidAmount, captured)
+		}
+		if cmp, err := captured.Cmp(payment.Amount); err != nil {
+			return nil, err
+		} else if cmp > 0 {
+			return nil, fmt.Errorf("%w: %s was authorized", ErrCaptureExceedsAuthorization, payment.Amount)
+		}
+		amount = captured.Minor()
+	}
+
+	pi, err := s.provider.CaptureIntent(context.Background(), payment.StripePaymentID, amount)
+	if err != nil {
+		return nil, &ProviderError{Op: "capture payment intent", Err: err}
+	}
+
+	payment, err = s.updateFromIntent(pi)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.PaymentResponse{
+		Payment: *payment,
+		Message: "Payment captured successfully",
+	}, nil
+}
+
+// CancelPayment cancels a payment that was not captured through the
+// provider, releasing its authorization
+func (s *PaymentService) CancelPayment(paymentID int, req model.CancelRequest) (*model.PaymentResponse, error) {
+	payment, err := s.GetPayment(paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Status != model.PaymentStatusPending && payment.Status != model.PaymentStatusRequiresCapture &&
+		payment.Status != model.PaymentStatusFailed {
+		return nil, fmt.Errorf("%w: payment is %s", ErrNotCancelable, payment.Status)
+	}
+
+	pi, err := s.provider.CancelIntent(context.Background(), payment.StripePaymentID, req.Reason)
+	if err != nil {
+		return nil, &ProviderError{Op: "cancel payment intent", Err: err}
+	}
+
+	payment, err = s.updateFromIntent(pi)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.PaymentResponse{
+		Payment: *payment,
+		Message: "Payment canceled successfully",
+	}, nil
+}
+
+// updateFromIntent stores the status and captured amount of a payment
+// intent the provider returned. The payment's outcome is published when
+// the provider's webhook event announces it.
+func (s *PaymentService) updateFromIntent(pi *provider.Intent) (*model.Payment, error) {
+	status := StatusFromIntent(pi.Status)
+	payment, err := s.repo.UpdateByStripeID(pi.ID, func(p *model.Payment) error {
+		// A webhook event may have moved the payment on already
+		if !canTransition(p.Status, status) {
+			return nil
+		}
+		p.Status = status
+		if status == model.PaymentStatusSucceeded {
+			if err := setCaptured(p, pi.AmountReceived); err != nil {
+				return err
+			}
+		}
+		p.UpdatedAt = s.now()
+		return nil
+	})
+	if err == sql.ErrNoRows {
+		return nil, ErrPaymentNotFound
+	}
+	return payment, err
+}
+
+// FlagExpiringAuthorizations flags the uncaptured authorizations that
+// expire within warning, and publishes a payment.authorization_expiring
+// event for each so they are captured or canceled in time. Each payment is
+// flagged once, even if several instances run the job.
+func (s *PaymentService) FlagExpiringAuthorizations(ctx context.Context, warning time.Duration) ([]model.Payment, error) {
+	now := s.now()
+	payments, err := s.repo.GetUncapturedAuthorizations(now.Add(warning - AuthorizationValidity))
+	if err != nil {
+		return nil, err
+	}
+
+	var flagged []model.Payment
+	for _, p := range payments {
+		payment, err := s.repo.UpdateByStripeID(p.StripePaymentID, func(p *model.Payment) error {
+			if p.ExpiryFlaggedAt != nil {
+				return errAlreadyFlagged
+			}
+			p.ExpiryFlaggedAt = &now
+			p.UpdatedAt = now
+			return nil
+		})
+		if errors.Is(err, errAlreadyFlagged) {
+			continue
+		}
+		if err != nil {
+			return flagged, err
+		}
+
+		expiresAt := payment.CreatedAt.Add(AuthorizationValidity)
+		log.Printf("Authorization of payment %d for order %d expires at %s\n", payment.ID, payment.OrderID, expiresAt.Format(time.RFC3339))
+		if err := s.publishExpiring(ctx, payment, expiresAt); err != nil {
+			return flagged, err
+		}
+		flagged = append(flagged, *payment)
+	}
+
+	return flagged, nil
+}
+
+// errAlreadyFlagged stops flagging a payment another instance flagged
+var errAlreadyFlagged = errors.New("authorization already flagged")
+
+// RunExpiryJob flags the authorizations expiring within warning every
+// interval, until ctx is done
+func (s *PaymentService) RunExpiryJob(ctx context.Context, interval, warning time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.FlagExpiringAuthorizations(ctx, warning); err != nil {
+			log.Printf("Failed to flag expiring authorizations: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// GetPayment returns a payment by ID
+func (s *PaymentService) GetPayment(id int) (*model.Payment, error) {
+	payment, err := s.repo.GetByID(id)
+	if err == sql.ErrNoRows {
+		return nil, ErrPaymentNotFound
+	}
+	return payment, err
+}
+
+// GetPaymentsByOrder returns the payments of an order, newest first
+func (s *PaymentService) GetPaymentsByOrder(orderID int) ([]model.Payment, error) {
+	return s.repo.GetByOrder(orderID)
+}
+
+// RecordAccess adds the reads of payments by accessor to the audit log
+func (s *PaymentService) RecordAccess(accessor model.Accessor, paymentIDs ...int) error {
+	if len(paymentIDs) == 0 {
+		return nil
+	}
+	now := s.now()
+	entries := make([]model.PaymentAccess, len(paymentIDs))
+	for i, id := range paymentIDs {
+		entries[i] = model.PaymentAccess{
+			PaymentID:  id,
+			Actor:      accessor.Actor,
+			ClientIP:   accessor.ClientIP,
+			Action:     accessor.Action,
+			RequestID:  accessor.RequestID,
+			AccessedAt: now,
+		}
+	}
+	return s.repo.RecordAccess(entries)
+}
+
+// GetAccessLog returns who read a payment, newest first
+func (s *PaymentService) GetAccessLog(paymentID int) ([]model.PaymentAccess, error) {
+	if _, err := s.GetPayment(paymentID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetAccessLog(paymentID)
+}
+
+// RefundPayment refunds a captured payment through the provider, in full
+// or in part. Without an amount in req the part of the captured amount not
+// refunded yet is refunded. The refund is reserved against the payment
+// before the provider is called, so concurrent refunds can never exceed the
+// captured amount, and released again if the provider rejects it.
+func (s *PaymentService) RefundPayment(paymentID int, req model.RefundRequest) (*model.RefundResponse, error) {
+	if req.Amount != nil {
+		// The amount is parsed in the payment's currency once it is read
+		if f, err := req.Amount.Float64(); err != nil || f <= 0 {
+			return nil, fmt.Errorf("%w: refund amount %s is not positive", money.ErrInvalidAmount, req.Amount)
+		}
+	}
+
+	now := s.now()
+	refund := model.Refund{
+		Reason:    req.Reason,
+		Status:    model.RefundStatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	payment, err := s.repo.AddRefund(paymentID, &refund, func(p *model.Payment) error {
+		if p.Status != model.PaymentStatusSucceeded && p.Status != model.PaymentStatusPartiallyRefunded {
+			return fmt.Errorf("%w: payment is %s", ErrNotRefundable, p.Status)
+		}
+
+		remaining, err := p.AmountCaptured.Sub(p.AmountRefunded)
+		if err != nil {
+			return err
+		}
+		amount := remaining
+		if req.Amount != nil {
+			if amount, err = money.Parse(req.Amount.String(), p.Amount.Currency()); err != nil {
+				return err
+			}
+		}
+		if cmp, err := amount.Cmp(remaining); err != nil {
+			return err
+		} else if cmp > 0 || !amount.IsPositive() {
+			return fmt.Errorf("%w: %s can still be refunded", ErrRefundExceedsPayment, remaining)
+		}
+
+		refund.Amount = amount
+		if p.AmountRefunded, err = p.AmountRefunded.Add(amount); err != nil {
+			return err
+		}
+		p.Status = refundedStatus(p)
+		p.UpdatedAt = now
+		return nil
+	})
+	if err == sql.ErrNoRows {
+		return nil, ErrPaymentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := s.provider.Refund(context.Background(), provider.RefundParams{
+		IntentID: payment.StripePaymentID,
+		Amount:   refund.Amount.Minor(),
+		Reason:   refund.Reason,
+		Metadata: map[string]string{
+			"payment_id": strconv.Itoa(payment.ID),
+			"refund_id":  strconv.Itoa(refund.ID),
+		},
+		IdempotencyKey: "refund-" + strconv.Itoa(refund.ID),
+	})
+	if err != nil {
+		refund.Status = model.RefundStatusFailed
+		if _, releaseErr := s.updateRefund(&refund); releaseErr != nil {
+			return nil, fmt.Errorf("failed to release refund %d: %w", refund.ID, releaseErr)
+		}
+		return nil, &ProviderError{Op: "create refund", Err: err}
+	}
+
+	refund.StripeRefundID = created.ID
+	refund.Status = refundStatusFromProvider(created.Status)
+	payment, err = s.updateRefund(&refund)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.RefundResponse{Refund: refund, Payment: *payment}, nil
+}
+
+// GetRefunds returns the refunds of a payment, oldest first
+func (s *PaymentService) GetRefunds(paymentID int) ([]model.Refund, error) {
+	if _, err := s.GetPayment(paymentID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetRefunds(paymentID)
+}
+
+// updateRefund stores the status of a refund. A failed or canceled refund
+// releases its amount from the payment.
+func (s *PaymentService) updateRefund(refund *model.Refund) (*model.Payment, error) {
+	refund.UpdatedAt = s.now()
+	return s.repo.UpdateRefund(refund, func(p *model.Payment) error {
+		if refund.Status != model.RefundStatusFailed && refund.Status != model.RefundStatusCanceled {
+			return nil
+		}
+		refunded, err := p.AmountRefunded.Sub(refund.Amount)
+		if err != nil {
+			return err
+		}
+		p.AmountRefunded = refunded
+		p.Status = refundedStatus(p)
+		p.UpdatedAt = refund.UpdatedAt
+		return nil
+	})
+}
+
+// webhookUpdate is the change of a payment announced by a Stripe event
+type webhookUpdate struct {
+	intentID      string
+	status        string
+	paymentMethod string
+	reason        string
+	// amountReceived is the amount captured in minor units of the
+	// payment's currency, announced by payment_intent.succeeded
+	amountReceived int64
+	// amountRefunded is the total refunded in minor units of the payment's
+	// currency, announced by charge.refunded
+	amountRefunded int64
+}
+
+// apply applies the update to a payment, returning ErrUnhandledEvent if
+// the event is older than the payment's status
+func (u *webhookUpdate) apply(p *model.Payment, now time.Time) error {
+	status := u.status
+	amountRefunded := p.AmountRefunded
+	if status == model.PaymentStatusRefunded || status == model.PaymentStatusPartiallyRefunded {
+		// Refunds made through RefundPayment are already counted
+		if u.amountRefunded > amountRefunded.Minor() {
+			var err error
+			if amountRefunded, err = money.New(u.amountRefunded, p.Amount.Currency()); err != nil {
+				return err
+			}
+		}
+		status = refundedStatus(&model.Payment{AmountCaptured: p.AmountCaptured, AmountRefunded: amountRefunded})
+	}
+	if !canTransition(p.Status, status) {
+		return fmt.Errorf("%w: %s payment cannot become %s", ErrUnhandledEvent, p.Status, status)
+	}
+
+	if status == model.PaymentStatusSucceeded {
+		if err := setCaptured(p, u.amountReceived); err != nil {
+			return err
+		}
+	}
+	p.Status = status
+	p.AmountRefunded = amountRefunded
+	if u.paymentMethod != "" {
+		p.PaymentMethod = u.paymentMethod
+	}
+	p.UpdatedAt = now
+	return nil
+}
+
+// HandleWebhookEvent applies a verified Stripe event to the payment it is
+// about and publishes the payment's outcome. Each event is processed once:
+// it is recorded after the payment was updated and its outcome published,
+// so an event whose processing failed is processed again when Stripe
+// retries it. The outcome event has the ID of the Stripe event, so
+// consumers and brokers drop it if two deliveries race.
+func (s *PaymentService) HandleWebhookEvent(ctx context.Context, event stripe.Event) (*model.Payment, error) {
+	update, err := parseWebhookEvent(event)
+	if err != nil {
+		return nil, err
+	}
+
+	processed, err := s.repo.EventProcessed(event.ID)
+	if err != nil {
+		return nil, err
+	}
+	if processed {
+		return nil, ErrDuplicateEvent
+	}
+
+	payment, err := s.repo.UpdateByStripeID(update.intentID, func(p *model.Payment) error {
+		return update.apply(p, s.now())
+	})
+	if err == sql.ErrNoRows {
+		return nil, ErrPaymentNotFound
+	}
+	if errors.Is(err, ErrUnhandledEvent) {
+		if recordErr := s.recordEvent(event); recordErr != nil {
+			return nil, recordErr
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.publishOutcome(ctx, event.ID, payment, update.reason); err != nil {
+		return nil, err
+	}
+	if err := s.recordEvent(event); err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// recordEvent records a processed event. A duplicate means another
+// delivery of the event was processed concurrently, with the same outcome.
+func (s *PaymentService) recordEvent(event stripe.Event) error {
+	err := s.repo.RecordEvent(event.ID, string(event.Type), s.now())
+	if err == repository.ErrDuplicateEvent {
+		return nil
+	}
+	return err
+}
+
+// publishOutcome publishes the event announcing a payment's status to the
+// payments topic, keyed by its type
+func (s *PaymentService) publishOutcome(ctx context.Context, eventID string, payment *model.Payment, reason string) error {
+	outcome := paymentOutcome(payment, reason)
+	var event events.Event
+	switch payment.Status {
+	case model.PaymentStatusSucceeded:
+		event = &events.PaymentSucceeded{PaymentOutcome: outcome}
+	case model.PaymentStatusFailed:
+		event = &events.PaymentFailed{PaymentOutcome: outcome}
+	case model.PaymentStatusCanceled:
+		event = &events.PaymentCanceled{PaymentOutcome: outcome}
+	case model.PaymentStatusPartiallyRefunded, model.PaymentStatusRefunded:
+		event = &events.PaymentRefunded{PaymentOutcome: outcome, AmountRefunded: payment.AmountRefunded}
+	default:
+		return nil
+	}
+
+	return s.publish(ctx, eventID, payment, event)
+}
+
+// publishExpiring publishes the event announcing that a payment's
+// authorization expires at expiresAt
+func (s *PaymentService) publishExpiring(ctx context.Context, payment *model.Payment, expiresAt time.Time) error {
+	return s.publish(ctx, fmt.Sprintf("payment-%d-authorization-expiring", payment.ID), payment,
+		&events.PaymentAuthorizationExpiring{PaymentOutcome: paymentOutcome(payment, ""), ExpiresAt: expiresAt})
+}
+
+// publish publishes an event about a payment with the given ID to the
+// payments topic, keyed by its type. Its correlation ID is the one carried
+// by ctx, or the payment's order when nothing caused the event but a job.
+func (s *PaymentService) publish(ctx context.Context, eventID string, payment *model.Payment, event events.Event) error {
+	if s.publisher == nil {
+		return nil
+	}
+
+	correlationID := events.CorrelationID(ctx)
+	if correlationID == "" {
+		correlationID = fmt.Sprintf("order-%d", payment.OrderID)
+	}
+	envelope, err := events.New("payment-service", correlationID, event)
+	if err != nil {
+		return err
+	}
+	envelope.ID = eventID
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", envelope.Type, err)
+	}
+
+	if err := s.publisher.Publish(ctx, broker.Message{
+		Topic: events.TopicPayments,
+		Key:   envelope.Type,
+		ID:    envelope.ID,
+		Body:  body,
+	}); err != nil {
+		return fmt.Errorf("failed to publish %s event: %w", envelope.Type, err)
+	}
+	return nil
+}
+
+// paymentOutcome returns the payload of a payment's events. The amount is
+// the captured amount once the payment was captured.
+func paymentOutcome(payment *model.Payment, reason string) events.PaymentOutcome {
+	amount := payment.Amount
+	if payment.AmountCaptured.IsPositive() {
+		amount = payment.AmountCaptured
+	}
+	return events.PaymentOutcome{
+		PaymentID:  payment.ID,
+		OrderID:    payment.OrderID,
+		CustomerID: payment.CustomerID,
+		Amount:     amount,
+		Reason:     reason,
+	}
+}
+
+// parseWebhookEvent reads the payment update announced by a Stripe event
+func parseWebhookEvent(event stripe.Event) (*webhookUpdate, error) {
+	if event.Data == nil {
+		return nil, fmt.Errorf("%w: %s has no data", ErrUnhandledEvent, event.Type)
+	}
+
+	switch event.Type {
+	case stripe.EventTypePaymentIntentSucceeded, stripe.EventTypePaymentIntentPaymentFailed, stripe.EventTypePaymentIntentCanceled,
+		stripe.EventTypePaymentIntentAmountCapturableUpdated:
+		var pi stripe.PaymentIntent
+		if err := json.Unmarshal(event.Data.Raw, &pi); err != nil {
+			return nil, fmt.Errorf("failed to decode %s payment intent: %w", event.Type, err)
+		}
+		update := &webhookUpdate{intentID: pi.ID}
+		if pi.PaymentMethod != nil {
+			update.paymentMethod = string(pi.PaymentMethod.Type)
+		}
+		switch event.Type {
+		case stripe.EventTypePaymentIntentSucceeded:
+			update.status = model.PaymentStatusSucceeded
+			update.amountReceived = pi.AmountReceived
+		case stripe.EventTypePaymentIntentAmountCapturableUpdated:
+			update.status = model.PaymentStatusRequiresCapture
+		case stripe.EventTypePaymentIntentPaymentFailed:
+			update.status = model.PaymentStatusFailed
+			if pi.LastPaymentError != nil {
+				update.reason = pi.LastPaymentError.Msg
+			}
+		default:
+			update.status = model.PaymentStatusCanceled
+			update.reason = string(pi.CancellationReason)
+		}
+		return update, nil
+
+	case stripe.EventTypeChargeRefunded:
+		var charge stripe.Charge
+		if err := json.Unmarshal(event.Data.Raw, &charge); err != nil {
+			return nil, fmt.Errorf("failed to decode %s charge: %w", event.Type, err)
+		}
+		if charge.PaymentIntent == nil {
+			return nil, fmt.Errorf("%w: charge %s has no payment intent", ErrUnhandledEvent, charge.ID)
+		}
+		update := &webhookUpdate{
+			intentID:       charge.PaymentIntent.ID,
+			status:         model.PaymentStatusPartiallyRefunded,
+			amountRefunded: charge.AmountRefunded,
+		}
+		if charge.Refunded {
+			update.status = model.PaymentStatusRefunded
+		}
+		return update, nil
+
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnhandledEvent, event.Type)
+	}
+}
+
+// canTransition reports whether a webhook event may move a payment from one
+// status to another. Stripe does not deliver events in order, so events
+// older than a final status are ignored: a succeeded payment can only be
+// refunded, a partially refunded payment can only be refunded further, and
+// canceled and refunded payments do not change. A failed payment can still
+// succeed when the customer retries.
+func canTransition(from, to string) bool {
+	switch from {
+	case model.PaymentStatusSucceeded:
+		return to == model.PaymentStatusSucceeded || to == model.PaymentStatusPartiallyRefunded || to == model.PaymentStatusRefunded
+	case model.PaymentStatusPartiallyRefunded:
+		return to == model.PaymentStatusPartiallyRefunded || to == model.PaymentStatusRefunded
+	case model.PaymentStatusCanceled, model.PaymentStatusRefunded:
+		return to == from
+	default:
+		return true
+	}
+}
+
+// StatusFromIntent maps a payment intent status to a payment status
+func StatusFromIntent(status provider.IntentStatus) string {
+	switch status {
+	case provider.IntentSucceeded:
+		return model.PaymentStatusSucceeded
+	case provider.IntentCanceled:
+		return model.PaymentStatusCanceled
+	case provider.IntentProcessing:
+		return model.PaymentStatusPending
+	case provider.IntentRequiresCapture:
+		return model.PaymentStatusRequiresCapture
+	default:
+		return model.PaymentStatusFailed
+	}
+}
+
+// setCaptured stores the amount captured of a succeeded payment, in minor
+// units. The whole amount was captured when the provider does not tell.
+func setCaptured(p *model.Payment, amountReceived int64) error {
+	if amountReceived <= 0 {
+		if p.AmountCaptured.IsPositive() {
+			return nil
+		}
+		amountReceived = p.Amount.Minor()
+	}
+	captured, err := money.New(amountReceived, p.Amount.Currency())
+	if err != nil {
+		return err
+	}
+	p.AmountCaptured = captured
+	return nil
+}
+
+// refundedStatus returns the status of a captured payment given the amount
+// refunded so far
+func refundedStatus(p *model.Payment) string {
+	switch refunded := p.AmountRefunded.Minor(); {
+	case refunded <= 0:
+		return model.PaymentStatusSucceeded
+	case refunded >= p.AmountCaptured.Minor():
+		return model.PaymentStatusRefunded
+	default:
+		return model.PaymentStatusPartiallyRefunded
+	}
+}
+
+// refundStatusFromProvider maps a provider refund status to a refund status
+func refundStatusFromProvider(status provider.RefundStatus) string {
+	switch status {
+	case provider.RefundSucceeded:
+		return model.RefundStatusSucceeded
+	case provider.RefundFailed:
+		return model.RefundStatusFailed
+	case provider.RefundCanceled:
+		return model.RefundStatusCanceled
+	default:
+		return model.RefundStatusPending
+	}
+}
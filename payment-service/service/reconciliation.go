@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go-microservices/payment-service/model"
+	"go-microservices/payment-service/provider"
+	"go-microservices/pkg/money"
+)
+
+// reconcilePageSize is the number of payment intents read per provider
+// request
+const reconcilePageSize = 100
+
+// errStatusChanged stops correcting a payment whose status changed while it
+// was reconciled
+var errStatusChanged = errors.New("payment status changed during reconciliation")
+
+// Reconcile compares the payments with the provider payment intents created
+// between from, included, and to, excluded: every intent must have a
+// payment of the same amount, currency and status, and every payment an
+// intent. With autoCorrect, payments whose status differs take the status
+// of their intent and their outcome is published, as the webhook event
+// would have done.
+func (s *PaymentService) Reconcile(ctx context.Context, from, to time.Time, autoCorrect bool) (*model.ReconciliationReport, error) {
+	report := &model.ReconciliationReport{From: from, To: to, GeneratedAt: s.now()}
+
+	payments, err := s.repo.GetCreatedBetween(from, to)
+	if err != nil {
+		return nil, err
+	}
+	report.PaymentsChecked = len(payments)
+	unmatched := make(map[string]bool, len(payments))
+	for _, payment := range payments {
+		unmatched[payment.StripePaymentID] = true
+	}
+
+	params := provider.ListParams{CreatedFrom: from, CreatedTo: to, Limit: reconcilePageSize}
+	for {
+		page, err := s.provider.ListIntents(ctx, params)
+		if err != nil {
+			return nil, &ProviderError{Op: "list payment intents", Err: err}
+		}
+
+		for i := range page.Intents {
+			intent := &page.Intents[i]
+			report.IntentsChecked++
+			delete(unmatched, intent.ID)
+
+			// Payments are stored just after their intent is created, so
+			// one may fall after the end of the range
+			payment, err := s.repo.GetByStripeID(intent.ID)
+			if err == sql.ErrNoRows {
+				report.Discrepancies = append(report.Discrepancies, model.Discrepancy{
+					Kind:            model.DiscrepancyMissingLocally,
+					StripePaymentID: intent.ID,
+					Provider:        describeIntent(intent),
+				})
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if err := s.reconcilePayment(ctx, report, payment, intent, autoCorrect); err != nil {
+				return nil, err
+			}
+		}
+
+		if !page.HasMore || len(page.Intents) == 0 {
+			break
+		}
+		params.StartingAfter = page.Intents[len(page.Intents)-1].ID
+	}
+
+	// The intents of the payments left were created before the range, or
+	// are unknown to the provider
+	for i := range payments {
+		payment := &payments[i]
+		if !unmatched[payment.StripePaymentID] {
+			continue
+		}
+		intent, err := s.provider.GetIntent(ctx, payment.StripePaymentID)
+		if errors.Is(err, provider.ErrIntentNotFound) {
+			report.Discrepancies = append(report.Discrepancies, model.Discrepancy{
+				Kind:            model.DiscrepancyMissingAtProvider,
+				PaymentID:       payment.ID,
+				OrderID:         payment.OrderID,
+				StripePaymentID: payment.StripePaymentID,
+				Local:           payment.Amount.String() + " " + payment.Status,
+			})
+			continue
+		}
+		if err != nil {
+			return nil, &ProviderError{Op: "retrieve payment intent", Err: err}
+		}
+		if err := s.reconcilePayment(ctx, report, payment, intent, autoCorrect); err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// reconcilePayment adds the differences between a payment and its intent
+// to report, correcting the payment's status if autoCorrect is set
+func (s *PaymentService) reconcilePayment(ctx context.Context, report *model.ReconciliationReport, payment *model.Payment, intent *provider.Intent, autoCorrect bool) error {
+	discrepancy := model.Discrepancy{PaymentID: payment.ID, OrderID: payment.OrderID, StripePaymentID: payment.StripePaymentID}
+
+	if payment.Amount.Currency() != intent.Currency {
+		d := discrepancy
+		d.Kind, d.Local, d.Provider = model.DiscrepancyCurrency, payment.Amount.Currency(), intent.Currency
+		report.Discrepancies = append(report.Discrepancies, d)
+	} else if payment.Amount.Minor() != intent.Amount {
+		d := discrepancy
+		d.Kind, d.Local, d.Provider = model.DiscrepancyAmount, payment.Amount.Decimal(), describeAmount(intent.Amount, intent.Currency)
+		report.Discrepancies = append(report.Discrepancies, d)
+	}
+
+	if statusMatches(payment.Status, intent.Status) {
+		return nil
+	}
+	d := discrepancy
+	d.Kind, d.Local, d.Provider = model.DiscrepancyStatus, payment.Status, string(intent.Status)
+	if autoCorrect {
+		corrected, err := s.correctStatus(ctx, payment, intent)
+		if err != nil {
+			return err
+		}
+		if corrected {
+			d.Corrected = true
+			report.Corrected++
+		}
+	}
+	report.Discrepancies = append(report.Discrepancies, d)
+	return nil
+}
+
+// correctStatus gives a payment the status of its intent and publishes its
+// outcome, unless its status changed since it was read
+func (s *PaymentService) correctStatus(ctx context.Context, payment *model.Payment, intent *provider.Intent) (bool, error) {
+	status := StatusFromIntent(intent.Status)
+	corrected, err := s.repo.UpdateByStripeID(intent.ID, func(p *model.Payment) error {
+		if p.Status != payment.Status {
+			return errStatusChanged
+		}
+		p.Status = status
+		if intent.PaymentMethod != "" {
+			p.PaymentMethod = intent.PaymentMethod
+		}
+		if status == model.PaymentStatusSucceeded {
+			if err := setCaptured(p, intent.AmountReceived); err != nil {
+				return err
+			}
+		}
+		p.UpdatedAt = s.now()
+		return nil
+	})
+	if errors.Is(err, errStatusChanged) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	log.Printf("Reconciliation moved payment %d from %s to %s\n", corrected.ID, payment.Status, corrected.Status)
+	eventID := fmt.Sprintf("payment-%d-reconciled-%s", corrected.ID, corrected.Status)
+	return true, s.publishOutcome(ctx, eventID, corrected, intent.LastError)
+}
+
+// RunReconciliationJob reconciles the payments created during each
+// interval once delay passed after its end, so that webhook events had
+// time to arrive, until ctx is done. Each report is passed to handle.
+func (s *PaymentService) RunReconciliationJob(ctx context.Context, interval, delay time.Duration, autoCorrect bool, handle func(*model.ReconciliationReport)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	to := s.now().Add(-delay)
+	from := to.Add(-interval)
+	for {
+		report, err := s.Reconcile(ctx, from, to, autoCorrect)
+		if err != nil {
+			log.Printf("Failed to reconcile payments from %s to %s: %v\n", from.Format(time.RFC3339), to.Format(time.RFC3339), err)
+		} else {
+			log.Printf("Reconciled payments from %s to %s: %d intents, %d payments, %d discrepancies, %d corrected\n",
+				from.Format(time.RFC3339), to.Format(time.RFC3339), report.IntentsChecked, report.PaymentsChecked,
+				len(report.Discrepancies), report.Corrected)
+			handle(report)
+			// A failed range is retried with the next one
+			from = to
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		to = s.now().Add(-delay)
+	}
+}
+
+// statusMatches reports whether a payment's status agrees with the status
+// of its intent. Refunds do not change an intent's status, and an intent
+// waiting for the customer is a pending payment, or a failed one if it
+// was confirmed.
+func statusMatches(status string, intentStatus provider.IntentStatus) bool {
+	switch intentStatus {
+	case provider.IntentSucceeded:
+		return status == model.PaymentStatusSucceeded || status == model.PaymentStatusPartiallyRefunded ||
+			status == model.PaymentStatusRefunded
+	case provider.IntentRequiresPaymentMethod, provider.IntentRequiresConfirmation, provider.IntentRequiresAction:
+		return status == model.PaymentStatusPending || status == model.PaymentStatusFailed
+	default:
+		return StatusFromIntent(intentStatus) == status
+	}
+}
+
+// describeIntent describes the amount and status of an intent
+func describeIntent(intent *provider.Intent) string {
+	return describeAmount(intent.Amount, intent.Currency) + " " + intent.Currency + " " + string(intent.Status)
+}
+
+// describeAmount formats an amount in minor units of a currency as a
+// decimal, or as minor units if the currency is unknown
+func describeAmount(amount int64, currency string) string {
+	m, err := money.New(amount, currency)
+	if err != nil {
+		return fmt.Sprintf("%d minor units", amount)
+	}
+	return m.Decimal()
+}
@@ -0,0 +1,106 @@
+package contract
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"go-microservices/payment-service/controller"
+	"go-microservices/payment-service/model"
+	"go-microservices/payment-service/provider"
+	"go-microservices/payment-service/repository"
+	"go-microservices/payment-service/routes"
+	"go-microservices/payment-service/service"
+	"go-microservices/pkg/contract"
+	"go-microservices/pkg/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stripe/stripe-go/v76"
+)
+
+// fakeStripe points the Stripe client at a server that creates every
+// payment intent as pi_123 and accepts every refund
+func fakeStripe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		amount, _ := strconv.ParseInt(r.Form.Get("amount"), 10, 64)
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/payment_intents":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"id":            "pi_123",
+				"object":        "payment_intent",
+				"amount":        amount,
+				"currency":      r.Form.Get("currency"),
+				"status":        "requires_payment_method",
+				"client_secret": "pi_123_secret_456",
+			})
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/refunds":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"id":             "re_123",
+				"object":         "refund",
+				"amount":         amount,
+				"payment_intent": r.Form.Get("payment_intent"),
+				"status":         "succeeded",
+			})
+		default:
+			t.Errorf("unexpected Stripe request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+
+	previous := stripe.GetBackend(stripe.APIBackend)
+	stripe.SetBackend(stripe.APIBackend, stripe.GetBackendWithConfig(stripe.APIBackend, &stripe.BackendConfig{
+		URL:               stripe.String(server.URL),
+		MaxNetworkRetries: stripe.Int64(0),
+	}))
+	t.Cleanup(func() {
+		stripe.SetBackend(stripe.APIBackend, previous)
+		server.Close()
+	})
+}
+
+// createPayment stores a payment of order 1 made through pi_123
+func createPayment(repo *repository.MemoryPaymentRepository, status string) {
+	now := time.Now()
+	repo.Create(&model.Payment{
+		OrderID:         1,
+		CustomerID:      1,
+		Amount:          money.MustNew(3998, "USD"),
+		AmountCaptured:  money.MustNew(3998, "USD"),
+		Status:          status,
+		StripePaymentID: "pi_123",
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	})
+}
+
+// TestConsumerContracts replays the contracts of every consumer of
+// payment-service against its router
+func TestConsumerContracts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	contract.Verify(t, contract.Load(t, "../../../contracts", "payment-service"), func(t *testing.T, state string) http.Handler {
+		repo := repository.NewMemoryPaymentRepository()
+
+		switch state {
+		case "stripe accepts payment intents":
+			fakeStripe(t)
+		case "order 1 has a payment":
+			createPayment(repo, model.PaymentStatusPending)
+		case "order 1 has a succeeded payment":
+			fakeStripe(t)
+			createPayment(repo, model.PaymentStatusSucceeded)
+		default:
+			t.Fatalf("unknown provider state %q", state)
+		}
+
+		router := gin.New()
+		routes.SetupRoutes(router, controller.NewPaymentController(service.NewPaymentService(repo, provider.NewStripe("sk_test_dummy"), nil)))
+		return router
+	})
+}
@@ -0,0 +1,104 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-microservices/payment-service/model"
+	"go-microservices/pkg/actor"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createPayment creates a payment for order 1 through the fake provider
+// and returns the client secret it was created with
+func createPayment(t *testing.T, s *testServer) string {
+	created, err := s.service.CreatePayment(model.PaymentRequest{OrderID: 1, CustomerID: 7, Amount: "10.00", Currency: "USD"})
+	require.NoError(t, err)
+	require.NotEmpty(t, created.ClientSecret, "the client secret is returned when the payment is created")
+	return created.ClientSecret
+}
+
+// get sends a GET request as a service
+func get(router *gin.Engine, path, name string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	if name != "" {
+		actor.Set(req.Header, actorSecret, name)
+	}
+	req.Header.Set("X-Request-ID", "req-1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAccessLog_RecordsReads(t *testing.T) {
+	server := newTestServer(t)
+	createPayment(t, server)
+
+	require.Equal(t, http.StatusOK, get(server.router, "/payments/1", "order-service").Code)
+	require.Equal(t, http.StatusOK, get(server.router, "/payments/order/1", "").Code)
+	require.Equal(t, http.StatusOK, get(server.router, "/payments/1/refunds", "support").Code)
+	require.Equal(t, http.StatusOK, get(server.router, "/payments/order/2", "order-service").Code, "no payment is read")
+
+	w := get(server.router, "/payments/1/access-log", "auditor")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var entries []model.PaymentAccess
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+	require.Len(t, entries, 3, "reading the access log is not recorded in it")
+
+	assert.Equal(t, "support", entries[0].Actor)
+	assert.Equal(t, "GET /payments/:id/refunds", entries[0].Action)
+	assert.Equal(t, "anonymous", entries[1].Actor)
+	assert.Equal(t, "GET /payments/order/:orderId", entries[1].Action)
+	assert.Equal(t, "order-service", entries[2].Actor)
+	assert.Equal(t, "GET /payments/:id", entries[2].Action)
+	assert.Equal(t, "req-1", entries[2].RequestID)
+	assert.NotEmpty(t, entries[2].ClientIP)
+	assert.False(t, entries[2].AccessedAt.IsZero())
+
+	assert.Equal(t, http.StatusNotFound, get(server.router, "/payments/9/access-log", "auditor").Code)
+}
+
+func TestAccessLog_UnverifiedActorsAreAnonymous(t *testing.T) {
+	server := newTestServer(t)
+	createPayment(t, server)
+
+	for _, headers := range []map[string]string{
+		{"X-Actor": "order-service"},
+		{"X-Actor": "order-service", "X-Actor-Signature": actor.Sign([]byte("guessed"), "order-service")},
+		{"X-Actor": "support", "X-Actor-Signature": actor.Sign(actorSecret, "order-service")},
+		{"X-Actor": "order-service", "X-Actor-Signature": "not hex"},
+	} {
+		req := httptest.NewRequest("GET", "/payments/1", nil)
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	w := get(server.router, "/payments/1/access-log", "auditor")
+	var entries []model.PaymentAccess
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+	require.Len(t, entries, 4)
+	for _, entry := range entries {
+		assert.Equal(t, "anonymous", entry.Actor)
+	}
+}
+
+func TestAccessLog_ClientSecretIsNotStored(t *testing.T) {
+	server := newTestServer(t)
+	secret := createPayment(t, server)
+
+	for _, path := range []string{"/payments/1", "/payments/order/1"} {
+		w := get(server.router, path, "order-service")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), secret, path)
+		assert.NotContains(t, w.Body.String(), "client_secret", path)
+	}
+}
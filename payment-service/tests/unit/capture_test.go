@@ -0,0 +1,193 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-microservices/payment-service/controller"
+	"go-microservices/payment-service/model"
+	"go-microservices/payment-service/provider"
+	"go-microservices/payment-service/repository"
+	"go-microservices/payment-service/routes"
+	"go-microservices/payment-service/service"
+	"go-microservices/pkg/broker"
+	"go-microservices/pkg/events"
+	"go-microservices/pkg/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// authorize creates a manual capture payment of 50.00 EUR with the fake
+// provider, which authorizes it at once
+func authorize(t *testing.T, paymentService *service.PaymentService, orderID int) model.Payment {
+	created, err := paymentService.CreatePayment(model.PaymentRequest{
+		OrderID: orderID, CustomerID: 7, Amount: "50.00", Currency: "EUR", CaptureMethod: model.CaptureMethodManual,
+	})
+	require.NoError(t, err)
+	confirmed, err := paymentService.ConfirmPayment(created.Payment.StripePaymentID)
+	require.NoError(t, err)
+	require.Equal(t, model.PaymentStatusRequiresCapture, confirmed.Payment.Status)
+	return confirmed.Payment
+}
+
+func TestCapture_PartialCaptureLimitsRefunds(t *testing.T) {
+	paymentService := service.NewPaymentService(repository.NewMemoryPaymentRepository(), provider.NewFake(), nil)
+	payment := authorize(t, paymentService, 1)
+	assert.True(t, payment.AmountCaptured.IsZero(), "nothing is charged before the capture")
+
+	_, err := paymentService.RefundPayment(payment.ID, model.RefundRequest{})
+	assert.ErrorIs(t, err, service.ErrNotRefundable)
+
+	tooMuch := json.Number("50.01")
+	_, err = paymentService.CapturePayment(payment.ID, model.CaptureRequest{Amount: &tooMuch})
+	assert.ErrorIs(t, err, service.ErrCaptureExceedsAuthorization)
+
+	amount := json.Number("30")
+	captured, err := paymentService.CapturePayment(payment.ID, model.CaptureRequest{Amount: &amount})
+	require.NoError(t, err)
+	assert.Equal(t, model.PaymentStatusSucceeded, captured.Payment.Status)
+	assert.Equal(t, money.MustNew(3000, "EUR"), captured.Payment.AmountCaptured)
+	assert.Equal(t, money.MustNew(5000, "EUR"), captured.Payment.Amount)
+
+	_, err = paymentService.CapturePayment(payment.ID, model.CaptureRequest{})
+	assert.ErrorIs(t, err, service.ErrNotCapturable)
+	_, err = paymentService.CancelPayment(payment.ID, model.CancelRequest{})
+	assert.ErrorIs(t, err, service.ErrNotCancelable)
+
+	refunded, err := paymentService.RefundPayment(payment.ID, model.RefundRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, money.MustNew(3000, "EUR"), refunded.Refund.Amount)
+	assert.Equal(t, model.PaymentStatusRefunded, refunded.Payment.Status)
+}
+
+func TestCapture_FullCaptureAndAutomaticPayments(t *testing.T) {
+	paymentService := service.NewPaymentService(repository.NewMemoryPaymentRepository(), provider.NewFake(), nil)
+	payment := authorize(t, paymentService, 1)
+
+	captured, err := paymentService.CapturePayment(payment.ID, model.CaptureRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, money.MustNew(5000, "EUR"), captured.Payment.AmountCaptured)
+
+	automatic, err := paymentService.CreatePayment(model.PaymentRequest{OrderID: 2, CustomerID: 7, Amount: "50.00", Currency: "EUR"})
+	require.NoError(t, err)
+	assert.Equal(t, model.CaptureMethodAutomatic, automatic.Payment.CaptureMethod)
+	_, err = paymentService.CapturePayment(automatic.Payment.ID, model.CaptureRequest{})
+	assert.ErrorIs(t, err, service.ErrNotCapturable)
+
+	confirmed, err := paymentService.ConfirmPayment(automatic.Payment.StripePaymentID)
+	require.NoError(t, err)
+	assert.Equal(t, money.MustNew(5000, "EUR"), confirmed.Payment.AmountCaptured)
+}
+
+func TestCancel_VoidsAuthorization(t *testing.T) {
+	fake := provider.NewFake()
+	paymentService := service.NewPaymentService(repository.NewMemoryPaymentRepository(), fake, nil)
+	payment := authorize(t, paymentService, 1)
+
+	canceled, err := paymentService.CancelPayment(payment.ID, model.CancelRequest{Reason: "abandoned"})
+	require.NoError(t, err)
+	assert.Equal(t, model.PaymentStatusCanceled, canceled.Payment.Status)
+
+	intent, err := fake.GetIntent(context.Background(), payment.StripePaymentID)
+	require.NoError(t, err)
+	assert.Equal(t, provider.IntentCanceled, intent.Status)
+
+	_, err = paymentService.CapturePayment(payment.ID, model.CaptureRequest{})
+	assert.ErrorIs(t, err, service.ErrNotCapturable)
+	_, err = paymentService.CancelPayment(99, model.CancelRequest{})
+	assert.ErrorIs(t, err, service.ErrPaymentNotFound)
+}
+
+func TestCaptureAndCancel_Routes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	paymentService := service.NewPaymentService(repository.NewMemoryPaymentRepository(), provider.NewFake(), nil)
+	router := gin.New()
+	routes.SetupRoutes(router, controller.NewPaymentController(paymentService))
+	authorize(t, paymentService, 1)
+	authorize(t, paymentService, 2)
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusBadRequest, post("/payments/1/capture", `{"amount": 60}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post("/payments/1/capture", `{"amount": 0.001}`).Code)
+
+	w := post("/payments/1/capture", `{"amount": 12.5}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response model.PaymentResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, money.MustNew(1250, "EUR"), response.Payment.AmountCaptured)
+	assert.Equal(t, http.StatusConflict, post("/payments/1/cancel", "").Code)
+
+	assert.Equal(t, http.StatusBadRequest, post("/payments/2/cancel", `{"reason": "bored"}`).Code)
+	assert.Equal(t, http.StatusOK, post("/payments/2/cancel", "").Code)
+	assert.Equal(t, http.StatusConflict, post("/payments/2/capture", "").Code)
+	assert.Equal(t, http.StatusNotFound, post("/payments/3/capture", "").Code)
+}
+
+func TestExpiryJob_FlagsAuthorizationsOnce(t *testing.T) {
+	payments := repository.NewMemoryPaymentRepository()
+	memory := broker.NewMemory()
+	t.Cleanup(func() { memory.Close() })
+	published := make(chan []byte, 10)
+	_, err := memory.Subscribe(broker.Subscription{
+		Name:     "test",
+		Bindings: []broker.Binding{{Topic: events.TopicPayments, Pattern: "payment.#"}},
+	}, func(_ context.Context, body []byte) error {
+		published <- body
+		return nil
+	})
+	require.NoError(t, err)
+
+	now := time.Now()
+	for i, p := range []model.Payment{
+		// expires in 12 hours
+		{CaptureMethod: model.CaptureMethodManual, Status: model.PaymentStatusRequiresCapture, CreatedAt: now.Add(12*time.Hour - service.AuthorizationValidity)},
+		// expires in 3 days
+		{CaptureMethod: model.CaptureMethodManual, Status: model.PaymentStatusRequiresCapture, CreatedAt: now.Add(-4 * 24 * time.Hour)},
+		// captured already
+		{CaptureMethod: model.CaptureMethodManual, Status: model.PaymentStatusSucceeded, CreatedAt: now.Add(-6 * 24 * time.Hour)},
+		// captured automatically
+		{CaptureMethod: model.CaptureMethodAutomatic, Status: model.PaymentStatusPending, CreatedAt: now.Add(-6 * 24 * time.Hour)},
+	} {
+		p.OrderID, p.CustomerID = i+1, 7
+		p.Amount = money.MustNew(5000, "EUR")
+		p.StripePaymentID = "pi_" + string(rune('a'+i))
+		p.UpdatedAt = p.CreatedAt
+		require.NoError(t, payments.Create(&p))
+	}
+
+	paymentService := service.NewPaymentService(payments, provider.NewFake(), memory)
+	flagged, err := paymentService.FlagExpiringAuthorizations(context.Background(), 24*time.Hour)
+	require.NoError(t, err)
+	require.Len(t, flagged, 1)
+	assert.Equal(t, 1, flagged[0].OrderID)
+	assert.NotNil(t, flagged[0].ExpiryFlaggedAt)
+
+	select {
+	case body := <-published:
+		_, event, err := events.Decode(body)
+		require.NoError(t, err)
+		expiring := event.(*events.PaymentAuthorizationExpiring)
+		assert.Equal(t, 1, expiring.OrderID)
+		assert.WithinDuration(t, flagged[0].CreatedAt.Add(service.AuthorizationValidity), expiring.ExpiresAt, time.Second)
+	case <-time.After(time.Second):
+		t.Fatal("no payment event was published")
+	}
+
+	flagged, err = paymentService.FlagExpiringAuthorizations(context.Background(), 24*time.Hour)
+	require.NoError(t, err)
+	assert.Empty(t, flagged, "authorizations are flagged once")
+}
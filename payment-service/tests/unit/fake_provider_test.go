@@ -0,0 +1,163 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"go-microservices/payment-service/model"
+	"go-microservices/payment-service/provider"
+	"go-microservices/payment-service/repository"
+	"go-microservices/payment-service/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeProvider_OutcomeFollowsAmount(t *testing.T) {
+	fake := provider.NewFake()
+	ctx := context.Background()
+
+	tests := []struct {
+		amount    int64
+		status    provider.IntentStatus
+		lastError string
+	}{
+		{3998, provider.IntentSucceeded, ""},
+		{3902, provider.IntentRequiresPaymentMethod, "Your card was declined."},
+		{3903, provider.IntentRequiresAction, ""},
+		{3904, provider.IntentProcessing, ""},
+	}
+	for _, tt := range tests {
+		intent, err := fake.CreateIntent(ctx, provider.IntentParams{Amount: tt.amount, Currency: "usd"})
+		require.NoError(t, err)
+		assert.Equal(t, tt.status, intent.Status, "amount %d", tt.amount)
+		assert.Equal(t, tt.lastError, intent.LastError)
+		assert.NotEmpty(t, intent.ClientSecret)
+	}
+
+	_, err := fake.GetIntent(ctx, "pi_missing")
+	assert.ErrorIs(t, err, provider.ErrIntentNotFound)
+}
+
+func TestFakeProvider_DelayedIntentSucceeds(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fake := provider.NewFake()
+	fake.Now = func() time.Time { return now }
+	ctx := context.Background()
+
+	intent, err := fake.CreateIntent(ctx, provider.IntentParams{Amount: 1004, Currency: "usd"})
+	require.NoError(t, err)
+
+	now = now.Add(provider.DefaultFakeDelay - time.Second)
+	intent, err = fake.GetIntent(ctx, intent.ID)
+	require.NoError(t, err)
+	assert.Equal(t, provider.IntentProcessing, intent.Status)
+
+	now = now.Add(time.Second)
+	intent, err = fake.GetIntent(ctx, intent.ID)
+	require.NoError(t, err)
+	assert.Equal(t, provider.IntentSucceeded, intent.Status)
+	assert.Equal(t, int64(1004), intent.AmountReceived)
+}
+
+func TestFakeProvider_CaptureCancelAndRefund(t *testing.T) {
+	fake := provider.NewFake()
+	ctx := context.Background()
+
+	authorized, err := fake.CreateIntent(ctx, provider.IntentParams{Amount: 5000, Currency: "eur", CaptureMethod: provider.CaptureManual})
+	require.NoError(t, err)
+	assert.Equal(t, provider.IntentRequiresCapture, authorized.Status)
+
+	_, err = fake.Refund(ctx, provider.RefundParams{IntentID: authorized.ID, Amount: 100})
+	assert.Error(t, err, "authorized intents cannot be refunded")
+
+	captured, err := fake.CaptureIntent(ctx, authorized.ID, 3000)
+	require.NoError(t, err)
+	assert.Equal(t, provider.IntentSucceeded, captured.Status)
+	assert.Equal(t, int64(3000), captured.AmountReceived)
+
+	_, err = fake.CancelIntent(ctx, captured.ID, "")
+	assert.Error(t, err, "captured intents cannot be canceled")
+
+	refund, err := fake.Refund(ctx, provider.RefundParams{IntentID: captured.ID, Amount: 2000})
+	require.NoError(t, err)
+	assert.Equal(t, provider.RefundSucceeded, refund.Status)
+	_, err = fake.Refund(ctx, provider.RefundParams{IntentID: captured.ID, Amount: 1001})
+	assert.Error(t, err, "refunds cannot exceed the captured amount")
+
+	declined, err := fake.CreateIntent(ctx, provider.IntentParams{Amount: 102, Currency: "eur"})
+	require.NoError(t, err)
+	canceled, err := fake.CancelIntent(ctx, declined.ID, "abandoned")
+	require.NoError(t, err)
+	assert.Equal(t, provider.IntentCanceled, canceled.Status)
+}
+
+func TestFakeProvider_IdempotentCreate(t *testing.T) {
+	fake := provider.NewFake()
+	params := provider.IntentParams{Amount: 1000, Currency: "usd", IdempotencyKey: "order-1"}
+
+	first, err := fake.CreateIntent(context.Background(), params)
+	require.NoError(t, err)
+	second, err := fake.CreateIntent(context.Background(), params)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, second.ID)
+}
+
+func TestPaymentService_WithFakeProvider(t *testing.T) {
+	paymentService := service.NewPaymentService(repository.NewMemoryPaymentRepository(), provider.NewFake(), nil)
+
+	paid, err := paymentService.CreatePayment(model.PaymentRequest{OrderID: 1, CustomerID: 7, Amount: "39.98", Currency: "usd"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, paid.ClientSecret)
+	confirmed, err := paymentService.ConfirmPayment(paid.Payment.StripePaymentID)
+	require.NoError(t, err)
+	assert.Equal(t, model.PaymentStatusSucceeded, confirmed.Payment.Status)
+	assert.Equal(t, "card", confirmed.Payment.PaymentMethod)
+
+	declined, err := paymentService.CreatePayment(model.PaymentRequest{OrderID: 2, CustomerID: 7, Amount: "39.02", Currency: "usd"})
+	require.NoError(t, err)
+	confirmed, err = paymentService.ConfirmPayment(declined.Payment.StripePaymentID)
+	require.NoError(t, err)
+	assert.Equal(t, model.PaymentStatusFailed, confirmed.Payment.Status)
+
+	_, err = paymentService.ConfirmPayment("pi_missing")
+	assert.ErrorIs(t, err, service.ErrPaymentNotFound)
+}
+
+func TestPaymentService_ChargesExactMinorUnits(t *testing.T) {
+	fake := provider.NewFake()
+	paymentService := service.NewPaymentService(repository.NewMemoryPaymentRepository(), fake, nil)
+
+	tests := []struct {
+		amount   json.Number
+		currency string
+		minor    int64
+	}{
+		// int64(19.99 * 100) used to charge 1998 cents
+		{"19.99", "usd", 1999},
+		{"1999", "JPY", 1999},
+		{"1.5", "KWD", 1500},
+	}
+	for i, tt := range tests {
+		created, err := paymentService.CreatePayment(model.PaymentRequest{OrderID: i + 1, CustomerID: 7, Amount: tt.amount, Currency: tt.currency})
+		require.NoError(t, err)
+		intent, err := fake.GetIntent(context.Background(), created.Payment.StripePaymentID)
+		require.NoError(t, err)
+		assert.Equal(t, tt.minor, intent.Amount, "%s %s", tt.amount, tt.currency)
+		assert.Equal(t, strings.ToUpper(tt.currency), intent.Currency)
+		assert.Equal(t, tt.minor, created.Payment.Amount.Minor())
+	}
+
+	for _, req := range []model.PaymentRequest{
+		{OrderID: 9, CustomerID: 7, Amount: "19.999", Currency: "USD"},
+		{OrderID: 9, CustomerID: 7, Amount: "19.5", Currency: "JPY"},
+		{OrderID: 9, CustomerID: 7, Amount: "0", Currency: "USD"},
+		{OrderID: 9, CustomerID: 7, Amount: "10", Currency: "ABC"},
+	} {
+		_, err := paymentService.CreatePayment(req)
+		assert.Error(t, err, "%s %s", req.Amount, req.Currency)
+	}
+}
@@ -0,0 +1,143 @@
+package unit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-microservices/payment-service/controller"
+	"go-microservices/payment-service/model"
+	"go-microservices/payment-service/provider"
+	"go-microservices/payment-service/repository"
+	"go-microservices/payment-service/routes"
+	"go-microservices/payment-service/service"
+	"go-microservices/pkg/broker"
+	"go-microservices/pkg/events"
+	"go-microservices/pkg/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookSecret is the secret test Stripe webhook events are signed with
+const webhookSecret = "whsec_test"
+
+// actorSecret is the secret services sign their names with
+var actorSecret = []byte("actor-secret")
+
+// testServer is payment-service over a memory payment repository
+type testServer struct {
+	router   *gin.Engine
+	payments *repository.MemoryPaymentRepository
+	service  *service.PaymentService
+	// broker receives the published payment events, which are also sent to
+	// published; both are nil without withBroker
+	broker    *broker.Memory
+	published chan []byte
+}
+
+// serverConfig is what the options of newTestServer set
+type serverConfig struct {
+	provider provider.PaymentProvider
+	broker   bool
+	payments []model.Payment
+}
+
+// serverOption configures a test server
+type serverOption func(*serverConfig)
+
+// withProvider makes payments through p instead of a fake provider
+func withProvider(p provider.PaymentProvider) serverOption {
+	return func(cfg *serverConfig) { cfg.provider = p }
+}
+
+// withBroker publishes payment events to a memory broker
+func withBroker() serverOption {
+	return func(cfg *serverConfig) { cfg.broker = true }
+}
+
+// withPayments stores payments before the server starts. Payments without
+// timestamps are created now.
+func withPayments(payments ...model.Payment) serverOption {
+	return func(cfg *serverConfig) { cfg.payments = append(cfg.payments, payments...) }
+}
+
+// orderPayment returns the payment of 39.98 USD for order 1 by customer 7
+// made through pi_123
+func orderPayment(status string) model.Payment {
+	return model.Payment{
+		OrderID:         1,
+		CustomerID:      7,
+		Amount:          money.MustNew(3998, "USD"),
+		Status:          status,
+		StripePaymentID: "pi_123",
+	}
+}
+
+// newTestServer starts payment-service with the routes of the service,
+// verifying webhook events with webhookSecret and callers with actorSecret
+func newTestServer(t *testing.T, opts ...serverOption) *testServer {
+	gin.SetMode(gin.TestMode)
+	cfg := serverConfig{provider: provider.NewFake()}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	s := &testServer{
+		router:   gin.New(),
+		payments: repository.NewMemoryPaymentRepository(),
+	}
+	now := time.Now()
+	for _, payment := range cfg.payments {
+		if payment.CreatedAt.IsZero() {
+			payment.CreatedAt = now
+		}
+		if payment.UpdatedAt.IsZero() {
+			payment.UpdatedAt = payment.CreatedAt
+		}
+		require.NoError(t, s.payments.Create(&payment))
+	}
+
+	var publisher broker.Publisher
+	if cfg.broker {
+		s.broker = broker.NewMemory()
+		s.published = make(chan []byte, 100)
+		t.Cleanup(func() { s.broker.Close() })
+		_, err := s.broker.Subscribe(broker.Subscription{
+			Name:     "test",
+			Bindings: []broker.Binding{{Topic: events.TopicPayments, Pattern: "payment.#"}},
+		}, func(_ context.Context, body []byte) error {
+			s.published <- body
+			return nil
+		})
+		require.NoError(t, err)
+		publisher = s.broker
+	}
+
+	s.service = service.NewPaymentService(s.payments, cfg.provider, publisher)
+	paymentController := controller.NewPaymentController(s.service)
+	paymentController.WebhookSecret = webhookSecret
+	paymentController.ActorSecret = actorSecret
+	routes.SetupRoutes(s.router, paymentController)
+	return s
+}
+
+// next decodes the next published payment event
+func (s *testServer) next(t *testing.T) (events.Envelope, events.Event) {
+	select {
+	case body := <-s.published:
+		envelope, event, err := events.Decode(body)
+		require.NoError(t, err)
+		return envelope, event
+	case <-time.After(time.Second):
+		t.Fatal("no payment event was published")
+		return events.Envelope{}, nil
+	}
+}
+
+// status returns the status of the payment of order 1
+func (s *testServer) status(t *testing.T) string {
+	payment, err := s.payments.GetByID(1)
+	require.NoError(t, err)
+	return payment.Status
+}
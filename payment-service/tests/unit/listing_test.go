@@ -0,0 +1,202 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-microservices/payment-service/model"
+	"go-microservices/pkg/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// listingStart is when the first payment of listingPayments was created
+var listingStart = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+// listingPayments returns the payments for orders 1 to 6, created a day
+// apart from listingStart:
+//
+//	order  customer  amount      status
+//	1      7         10.00 USD   succeeded
+//	2      7         25.00 USD   refunded
+//	3      7         40.00 EUR   pending
+//	4      8         15.00 USD   succeeded
+//	5      8         1000 JPY    failed
+//	6      7         60.00 USD   succeeded
+func listingPayments() []model.Payment {
+	var payments []model.Payment
+	for i, p := range []struct {
+		customerID int
+		amount     money.Money
+		status     string
+	}{
+		{7, money.MustNew(1000, "USD"), model.PaymentStatusSucceeded},
+		{7, money.MustNew(2500, "USD"), model.PaymentStatusRefunded},
+		{7, money.MustNew(4000, "EUR"), model.PaymentStatusPending},
+		{8, money.MustNew(1500, "USD"), model.PaymentStatusSucceeded},
+		{8, money.MustNew(1000, "JPY"), model.PaymentStatusFailed},
+		{7, money.MustNew(6000, "USD"), model.PaymentStatusSucceeded},
+	} {
+		payment := model.Payment{
+			OrderID:         i + 1,
+			CustomerID:      p.customerID,
+			Amount:          p.amount,
+			Status:          p.status,
+			StripePaymentID: "pi_" + string(rune('a'+i)),
+			CreatedAt:       listingStart.Add(time.Duration(i) * 24 * time.Hour),
+		}
+		switch p.status {
+		case model.PaymentStatusSucceeded:
+			payment.AmountCaptured = p.amount
+		case model.PaymentStatusRefunded:
+			payment.AmountCaptured, payment.AmountRefunded = p.amount, p.amount
+		}
+		payments = append(payments, payment)
+	}
+	return payments
+}
+
+// listPage gets a page of payments and returns the orders it holds
+func listPage(t *testing.T, router *gin.Engine, path string) (model.PaymentPage, []int) {
+	w := get(router, path, "support")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var page model.PaymentPage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	orders := []int{}
+	for _, payment := range page.Payments {
+		orders = append(orders, payment.OrderID)
+	}
+	return page, orders
+}
+
+func TestListPayments_Filters(t *testing.T) {
+	router := newTestServer(t, withPayments(listingPayments()...)).router
+
+	tests := []struct {
+		query  string
+		orders []int
+	}{
+		{"", []int{6, 5, 4, 3, 2, 1}},
+		{"?customer_id=8", []int{5, 4}},
+		{"?status=succeeded", []int{6, 4, 1}},
+		{"?currency=usd", []int{6, 4, 2, 1}},
+		{"?created_from=2024-03-02T00:00:00Z&created_to=2024-03-04T00:00:00Z", []int{3, 2}},
+		{"?currency=USD&min_amount=15&max_amount=25.00", []int{4, 2}},
+		{"?customer_id=7&status=succeeded&currency=USD&min_amount=50", []int{6}},
+		{"?customer_id=9", []int{}},
+	}
+	for _, tt := range tests {
+		page, orders := listPage(t, router, "/payments"+tt.query)
+		assert.Equal(t, tt.orders, orders, tt.query)
+		assert.Equal(t, len(tt.orders), page.Total, tt.query)
+	}
+}
+
+func TestListPayments_Pages(t *testing.T) {
+	router := newTestServer(t, withPayments(listingPayments()...)).router
+
+	page, orders := listPage(t, router, "/payments?page_size=4")
+	assert.Equal(t, []int{6, 5, 4, 3}, orders)
+	assert.Equal(t, 1, page.Page)
+	assert.Equal(t, 4, page.PageSize)
+	assert.Equal(t, 6, page.Total)
+
+	page, orders = listPage(t, router, "/payments?page_size=4&page=2")
+	assert.Equal(t, []int{2, 1}, orders)
+	assert.Equal(t, 6, page.Total)
+
+	page, orders = listPage(t, router, "/payments?page_size=4&page=3")
+	assert.Empty(t, orders)
+	assert.Equal(t, 6, page.Total)
+
+	page, _ = listPage(t, router, "/payments")
+	assert.Equal(t, model.DefaultPageSize, page.PageSize)
+}
+
+func TestListPayments_InvalidFilters(t *testing.T) {
+	router := newTestServer(t, withPayments(listingPayments()...)).router
+
+	for _, query := range []string{
+		"?page=-1",
+		"?page=1000001",
+		"?page=9223372036854775807",
+		"?page_size=101",
+		"?status=lost",
+		"?customer_id=abc",
+		"?created_from=yesterday",
+		"?created_from=2024-03-04T00:00:00Z&created_to=2024-03-02T00:00:00Z",
+		"?min_amount=10",
+		"?currency=XYZ",
+		"?currency=USD&min_amount=10.001",
+		"?currency=USD&min_amount=20&max_amount=10",
+	} {
+		assert.Equal(t, http.StatusBadRequest, get(router, "/payments"+query, "").Code, query)
+	}
+}
+
+func TestGetPaymentsByCustomer(t *testing.T) {
+	server := newTestServer(t, withPayments(listingPayments()...))
+	router, payments := server.router, server.payments
+
+	page, orders := listPage(t, router, "/payments/customer/7?status=succeeded")
+	assert.Equal(t, []int{6, 1}, orders)
+	assert.Equal(t, 2, page.Total)
+
+	_, orders = listPage(t, router, "/payments/customer/8?customer_id=7")
+	assert.Equal(t, []int{5, 4}, orders, "the customer in the path wins")
+
+	assert.Equal(t, http.StatusBadRequest, get(router, "/payments/customer/abc", "").Code)
+
+	// Every payment listed is recorded in the audit log
+	for _, id := range []int{4, 5} {
+		entries, err := payments.GetAccessLog(id)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "support", entries[0].Actor)
+		assert.Equal(t, "GET /payments/customer/:customerId", entries[0].Action)
+	}
+	entries, err := payments.GetAccessLog(2)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "payments of other pages or filters are not read")
+}
+
+func TestGetPaymentTotals(t *testing.T) {
+	router := newTestServer(t, withPayments(listingPayments()...)).router
+
+	totals := func(query string) []model.PaymentTotal {
+		w := get(router, "/payments/totals"+query, "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var totals []model.PaymentTotal
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &totals))
+		return totals
+	}
+
+	assert.Equal(t, []model.PaymentTotal{
+		{Status: model.PaymentStatusFailed, Count: 1, Amount: money.MustNew(1000, "JPY"),
+			AmountCaptured: money.MustNew(0, "JPY"), AmountRefunded: money.MustNew(0, "JPY")},
+		{Status: model.PaymentStatusPending, Count: 1, Amount: money.MustNew(4000, "EUR"),
+			AmountCaptured: money.MustNew(0, "EUR"), AmountRefunded: money.MustNew(0, "EUR")},
+		{Status: model.PaymentStatusRefunded, Count: 1, Amount: money.MustNew(2500, "USD"),
+			AmountCaptured: money.MustNew(2500, "USD"), AmountRefunded: money.MustNew(2500, "USD")},
+		{Status: model.PaymentStatusSucceeded, Count: 3, Amount: money.MustNew(8500, "USD"),
+			AmountCaptured: money.MustNew(8500, "USD"), AmountRefunded: money.MustNew(0, "USD")},
+	}, totals(""))
+
+	customer := totals("?customer_id=7&currency=USD")
+	require.Len(t, customer, 2)
+	assert.Equal(t, money.MustNew(7000, "USD"), customer[1].Amount)
+	assert.Equal(t, 2, customer[1].Count)
+
+	w := get(router, "/payments/totals?customer_id=9", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[]`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/payments/totals?max_amount=5", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,27 @@
+package unit
+
+import (
+	"testing"
+
+	"go-microservices/payment-service/api"
+	"go-microservices/payment-service/controller"
+	"go-microservices/payment-service/routes"
+	"go-microservices/pkg/apidocs"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRoutesAreDocumented fails when a route is registered without being
+// declared in api/openapi.json
+func TestRoutesAreDocumented(t *testing.T) {
+	t.Setenv("STRIPE_SECRET_KEY", "sk_test_dummy")
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	routes.SetupRoutes(router, controller.NewPaymentController(nil))
+
+	missing, err := apidocs.MissingRoutes(router.Routes(), api.Spec)
+	require.NoError(t, err)
+	assert.Empty(t, missing, "routes missing from api/openapi.json")
+}
@@ -0,0 +1,38 @@
+package unit
+
+import (
+	"testing"
+
+	"go-microservices/payment-service/model"
+	"go-microservices/payment-service/provider"
+	"go-microservices/payment-service/repository"
+	"go-microservices/payment-service/service"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusFromIntent(t *testing.T) {
+	tests := []struct {
+		intent provider.IntentStatus
+		status string
+	}{
+		{provider.IntentSucceeded, model.PaymentStatusSucceeded},
+		{provider.IntentCanceled, model.PaymentStatusCanceled},
+		{provider.IntentProcessing, model.PaymentStatusPending},
+		{provider.IntentRequiresCapture, model.PaymentStatusRequiresCapture},
+		{provider.IntentRequiresPaymentMethod, model.PaymentStatusFailed},
+		{provider.IntentRequiresAction, model.PaymentStatusFailed},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.intent), func(t *testing.T) {
+			assert.Equal(t, tt.status, service.StatusFromIntent(tt.intent))
+		})
+	}
+}
+
+func TestGetPayment_NotFound(t *testing.T) {
+	paymentService := service.NewPaymentService(repository.NewMemoryPaymentRepository(), provider.NewFake(), nil)
+
+	_, err := paymentService.GetPayment(1)
+	assert.ErrorIs(t, err, service.ErrPaymentNotFound)
+}
@@ -0,0 +1,176 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"go-microservices/payment-service/model"
+	"go-microservices/payment-service/provider"
+	"go-microservices/pkg/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// reconcileEnv is payment-service with the fake provider holding one
+// payment of each kind of discrepancy, and one matching payment
+type reconcileEnv struct {
+	*testServer
+	fake *provider.Fake
+}
+
+// newReconcileServer returns payment-service with the discrepancies of
+// reconcileEnv
+func newReconcileServer(t *testing.T) *reconcileEnv {
+	ctx := context.Background()
+	env := &reconcileEnv{fake: provider.NewFake()}
+	env.testServer = newTestServer(t, withProvider(env.fake), withBroker())
+
+	// Order 1 matches: confirmed and refunded in full
+	matching, err := env.service.CreatePayment(model.PaymentRequest{OrderID: 1, CustomerID: 7, Amount: "10.00", Currency: "USD"})
+	require.NoError(t, err)
+	_, err = env.service.ConfirmPayment(matching.Payment.StripePaymentID)
+	require.NoError(t, err)
+	_, err = env.service.RefundPayment(matching.Payment.ID, model.RefundRequest{})
+	require.NoError(t, err)
+
+	// Order 2 succeeded at the provider but its webhook event was lost
+	_, err = env.service.CreatePayment(model.PaymentRequest{OrderID: 2, CustomerID: 7, Amount: "20.00", Currency: "USD"})
+	require.NoError(t, err)
+
+	// Order 3 was stored with the wrong amount
+	intent, err := env.fake.CreateIntent(ctx, provider.IntentParams{Amount: 3000, Currency: "USD"})
+	require.NoError(t, err)
+	now := time.Now()
+	require.NoError(t, env.payments.Create(&model.Payment{
+		OrderID: 3, CustomerID: 7, Amount: money.MustNew(300, "USD"), Status: model.PaymentStatusSucceeded,
+		AmountCaptured: money.MustNew(300, "USD"), StripePaymentID: intent.ID, CreatedAt: now, UpdatedAt: now,
+	}))
+
+	// The provider does not know order 4's intent
+	require.NoError(t, env.payments.Create(&model.Payment{
+		OrderID: 4, CustomerID: 7, Amount: money.MustNew(4000, "USD"), Status: model.PaymentStatusPending,
+		StripePaymentID: "pi_unknown", CreatedAt: now, UpdatedAt: now,
+	}))
+
+	// An intent was created without a payment
+	_, err = env.fake.CreateIntent(ctx, provider.IntentParams{Amount: 5000, Currency: "EUR"})
+	require.NoError(t, err)
+
+	return env
+}
+
+// reconcile reconciles the last hour
+func (env *reconcileEnv) reconcile(t *testing.T, autoCorrect bool) *model.ReconciliationReport {
+	now := time.Now()
+	report, err := env.service.Reconcile(context.Background(), now.Add(-time.Hour), now.Add(time.Minute), autoCorrect)
+	require.NoError(t, err)
+	return report
+}
+
+// kinds returns the discrepancy kinds of a report by order, 0 for intents
+// without a payment
+func kinds(report *model.ReconciliationReport) map[int][]string {
+	found := make(map[int][]string)
+	for _, d := range report.Discrepancies {
+		found[d.OrderID] = append(found[d.OrderID], d.Kind)
+	}
+	return found
+}
+
+func TestReconcile_FindsDiscrepancies(t *testing.T) {
+	env := newReconcileServer(t)
+
+	report := env.reconcile(t, false)
+
+	assert.Equal(t, 4, report.IntentsChecked)
+	assert.Equal(t, 4, report.PaymentsChecked)
+	assert.Equal(t, map[int][]string{
+		0: {model.DiscrepancyMissingLocally},
+		2: {model.DiscrepancyStatus},
+		3: {model.DiscrepancyAmount},
+		4: {model.DiscrepancyMissingAtProvider},
+	}, kinds(report))
+	assert.Zero(t, report.Corrected)
+
+	payment, err := env.payments.GetByID(2)
+	require.NoError(t, err)
+	assert.Equal(t, model.PaymentStatusPending, payment.Status, "statuses are only corrected on request")
+	assert.Empty(t, env.broker.Published())
+}
+
+func TestReconcile_AutoCorrectsStatuses(t *testing.T) {
+	env := newReconcileServer(t)
+
+	report := env.reconcile(t, true)
+
+	assert.Equal(t, 1, report.Corrected)
+	for _, d := range report.Discrepancies {
+		assert.Equal(t, d.Kind == model.DiscrepancyStatus, d.Corrected, d.Kind)
+	}
+	payment, err := env.payments.GetByID(2)
+	require.NoError(t, err)
+	assert.Equal(t, model.PaymentStatusSucceeded, payment.Status)
+	assert.Equal(t, money.MustNew(2000, "USD"), payment.AmountCaptured)
+	assert.Len(t, env.broker.Published(), 1, "the corrected payment's outcome is published")
+
+	assert.NotContains(t, kinds(env.reconcile(t, true)), 2)
+}
+
+func TestReconcile_PagesThroughIntents(t *testing.T) {
+	fake := provider.NewFake()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fake.Now = func() time.Time { return now }
+	for i := 0; i < 5; i++ {
+		_, err := fake.CreateIntent(context.Background(), provider.IntentParams{Amount: 1000, Currency: "USD"})
+		require.NoError(t, err)
+	}
+
+	var ids []string
+	params := provider.ListParams{CreatedFrom: now, CreatedTo: now.Add(time.Second), Limit: 2}
+	for {
+		page, err := fake.ListIntents(context.Background(), params)
+		require.NoError(t, err)
+		for _, intent := range page.Intents {
+			ids = append(ids, intent.ID)
+		}
+		if !page.HasMore {
+			break
+		}
+		params.StartingAfter = page.Intents[len(page.Intents)-1].ID
+	}
+	assert.Equal(t, []string{"pi_fake_5", "pi_fake_4", "pi_fake_3", "pi_fake_2", "pi_fake_1"}, ids)
+
+	page, err := fake.ListIntents(context.Background(), provider.ListParams{CreatedFrom: now.Add(time.Second), CreatedTo: now.Add(time.Hour), Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, page.Intents)
+}
+
+func TestReconciliationReport_Formats(t *testing.T) {
+	report := &model.ReconciliationReport{Discrepancies: []model.Discrepancy{
+		{Kind: model.DiscrepancyMissingLocally, StripePaymentID: "pi_1", Provider: "50.00 EUR succeeded"},
+		{Kind: model.DiscrepancyStatus, PaymentID: 2, OrderID: 2, StripePaymentID: "pi_2", Local: "pending", Provider: "succeeded", Corrected: true},
+	}}
+
+	var csv bytes.Buffer
+	require.NoError(t, report.WriteCSV(&csv))
+	assert.Equal(t, strings.Join([]string{
+		"kind,payment_id,order_id,stripe_payment_id,local,provider,corrected",
+		"missing_locally,,,pi_1,,50.00 EUR succeeded,false",
+		"status_mismatch,2,2,pi_2,pending,succeeded,true",
+	}, "\n")+"\n", csv.String())
+
+	var encoded bytes.Buffer
+	require.NoError(t, report.WriteJSON(&encoded))
+	var decoded model.ReconciliationReport
+	require.NoError(t, json.Unmarshal(encoded.Bytes(), &decoded))
+	assert.Equal(t, report.Discrepancies, decoded.Discrepancies)
+
+	encoded.Reset()
+	require.NoError(t, (&model.ReconciliationReport{}).WriteJSON(&encoded))
+	assert.Contains(t, encoded.String(), `"discrepancies": []`)
+}
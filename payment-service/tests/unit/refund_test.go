@@ -0,0 +1,165 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"go-microservices/payment-service/model"
+	"go-microservices/payment-service/provider"
+	"go-microservices/pkg/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stripe/stripe-go/v76"
+)
+
+// refundEnv is payment-service with a payment of 39.98 USD for order 1,
+// refunding through a fake Stripe that records the refunds it is asked for
+type refundEnv struct {
+	*testServer
+	requests chan url.Values
+	// decline makes Stripe reject refunds
+	decline bool
+}
+
+// newRefundServer returns payment-service with the captured payment of
+// order 1 in a status, refunding through a fake Stripe
+func newRefundServer(t *testing.T, status string) *refundEnv {
+	env := &refundEnv{requests: make(chan url.Values, 10)}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/refunds" {
+			t.Errorf("unexpected Stripe request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		r.ParseForm()
+		env.requests <- r.Form
+		w.Header().Set("Content-Type", "application/json")
+		if env.decline {
+			w.WriteHeader(http.StatusPaymentRequired)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": map[string]interface{}{"type": "invalid_request_error", "message": "Charge has been disputed"},
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":     "re_" + r.Form.Get("metadata[refund_id]"),
+			"object": "refund",
+			"status": "succeeded",
+		})
+	}))
+	previous := stripe.GetBackend(stripe.APIBackend)
+	stripe.SetBackend(stripe.APIBackend, stripe.GetBackendWithConfig(stripe.APIBackend, &stripe.BackendConfig{
+		URL:               stripe.String(server.URL),
+		MaxNetworkRetries: stripe.Int64(0),
+	}))
+	t.Cleanup(func() {
+		stripe.SetBackend(stripe.APIBackend, previous)
+		server.Close()
+	})
+
+	payment := orderPayment(status)
+	payment.AmountCaptured = payment.Amount
+	env.testServer = newTestServer(t, withProvider(provider.NewStripe("sk_test_dummy")), withPayments(payment))
+	return env
+}
+
+// refund posts a refund request body for payment 1
+func (env *refundEnv) refund(t *testing.T, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/payments/1/refunds", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	return w
+}
+
+// created decodes a refund response
+func created(t *testing.T, w *httptest.ResponseRecorder) model.RefundResponse {
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var response model.RefundResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response
+}
+
+func TestRefund_PartialThenRest(t *testing.T) {
+	env := newRefundServer(t, model.PaymentStatusSucceeded)
+
+	response := created(t, env.refund(t, `{"amount": 10.99, "reason": "requested_by_customer"}`))
+	assert.Equal(t, money.MustNew(1099, "USD"), response.Refund.Amount)
+	assert.Equal(t, model.RefundStatusSucceeded, response.Refund.Status)
+	assert.Equal(t, "re_1", response.Refund.StripeRefundID)
+	assert.Equal(t, model.PaymentStatusPartiallyRefunded, response.Payment.Status)
+	assert.Equal(t, money.MustNew(1099, "USD"), response.Payment.AmountRefunded)
+
+	form := <-env.requests
+	assert.Equal(t, "pi_123", form.Get("payment_intent"))
+	assert.Equal(t, "1099", form.Get("amount"))
+	assert.Equal(t, "requested_by_customer", form.Get("reason"))
+
+	// Without an amount the rest of the payment is refunded
+	response = created(t, env.refund(t, ``))
+	assert.Equal(t, money.MustNew(2899, "USD"), response.Refund.Amount)
+	assert.Equal(t, model.PaymentStatusRefunded, response.Payment.Status)
+	assert.Equal(t, money.MustNew(3998, "USD"), response.Payment.AmountRefunded)
+	assert.Equal(t, "2899", (<-env.requests).Get("amount"))
+
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, httptest.NewRequest("GET", "/payments/1/refunds", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var refunds []model.Refund
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &refunds))
+	require.Len(t, refunds, 2)
+	assert.Equal(t, money.MustNew(1099, "USD"), refunds[0].Amount)
+	assert.Equal(t, money.MustNew(2899, "USD"), refunds[1].Amount)
+
+	// A refunded payment cannot be refunded again
+	assert.Equal(t, http.StatusConflict, env.refund(t, `{}`).Code)
+}
+
+func TestRefund_CannotExceedPayment(t *testing.T) {
+	env := newRefundServer(t, model.PaymentStatusSucceeded)
+	created(t, env.refund(t, `{"amount": 30}`))
+	<-env.requests
+
+	w := env.refund(t, `{"amount": 10}`)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "9.98 USD can still be refunded")
+	assert.Empty(t, env.requests, "Stripe is not called")
+	payment, err := env.payments.GetByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, money.MustNew(3000, "USD"), payment.AmountRefunded)
+}
+
+func TestRefund_RequiresCapturedPayment(t *testing.T) {
+	env := newRefundServer(t, model.PaymentStatusPending)
+
+	assert.Equal(t, http.StatusConflict, env.refund(t, `{}`).Code)
+	assert.Equal(t, http.StatusBadRequest, env.refund(t, `{"amount": -1}`).Code)
+	assert.Equal(t, http.StatusBadRequest, env.refund(t, `{"reason": "changed_mind"}`).Code)
+	assert.Empty(t, env.requests)
+}
+
+func TestRefund_DeclinedRefundIsReleased(t *testing.T) {
+	env := newRefundServer(t, model.PaymentStatusSucceeded)
+	env.decline = true
+
+	w := env.refund(t, `{"amount": 10}`)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "Failed to create refund")
+	payment, err := env.payments.GetByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, model.PaymentStatusSucceeded, payment.Status)
+	assert.True(t, payment.AmountRefunded.IsZero())
+
+	refunds, err := env.payments.GetRefunds(1)
+	require.NoError(t, err)
+	require.Len(t, refunds, 1)
+	assert.Equal(t, model.RefundStatusFailed, refunds[0].Status)
+}
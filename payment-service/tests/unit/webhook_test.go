@@ -0,0 +1,246 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-microservices/payment-service/model"
+	"go-microservices/pkg/events"
+	"go-microservices/pkg/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stripe/stripe-go/v76/webhook"
+)
+
+// newWebhookServer returns payment-service with a pending payment for
+// order 1 made through pi_123, publishing to a memory broker
+func newWebhookServer(t *testing.T) *testServer {
+	return newTestServer(t, withBroker(), withPayments(orderPayment(model.PaymentStatusPending)))
+}
+
+// stripeEvent builds a Stripe event about an object
+func stripeEvent(id, eventType string, object map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"id":     id,
+		"object": "event",
+		"type":   eventType,
+		"data":   map[string]interface{}{"object": object},
+	}
+}
+
+// webhookRequest builds the webhook delivery of a Stripe event signed with
+// secret
+func webhookRequest(t *testing.T, event map[string]interface{}, secret string) *http.Request {
+	payload, err := json.Marshal(event)
+	require.NoError(t, err)
+	signed := webhook.GenerateTestSignedPayload(&webhook.UnsignedPayload{Payload: payload, Secret: secret})
+
+	req := httptest.NewRequest("POST", "/payments/webhook", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Stripe-Signature", signed.Header)
+	return req
+}
+
+// send posts a Stripe event signed with secret to the webhook
+func (s *testServer) send(t *testing.T, event map[string]interface{}, secret string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, webhookRequest(t, event, secret))
+	return w
+}
+
+// result returns the result of a webhook response
+func result(t *testing.T, w *httptest.ResponseRecorder) string {
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response model.WebhookResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response.Result
+}
+
+func TestWebhook_RejectsInvalidSignatures(t *testing.T) {
+	env := newWebhookServer(t)
+
+	w := env.send(t, stripeEvent("evt_1", "payment_intent.succeeded", map[string]interface{}{"id": "pi_123"}), "whsec_other")
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, model.PaymentStatusPending, env.status(t))
+	assert.Empty(t, env.broker.Published())
+}
+
+func TestWebhook_PaymentSucceeded(t *testing.T) {
+	env := newWebhookServer(t)
+	event := stripeEvent("evt_1", "payment_intent.succeeded", map[string]interface{}{
+		"id":             "pi_123",
+		"object":         "payment_intent",
+		"status":         "succeeded",
+		"payment_method": map[string]interface{}{"id": "pm_1", "object": "payment_method", "type": "card"},
+	})
+
+	assert.Equal(t, model.WebhookResultProcessed, result(t, env.send(t, event, webhookSecret)))
+
+	payment, err := env.payments.GetByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, model.PaymentStatusSucceeded, payment.Status)
+	assert.Equal(t, "card", payment.PaymentMethod)
+
+	envelope, published := env.next(t)
+	assert.Equal(t, "evt_1", envelope.ID, "the event is published under the Stripe event ID")
+	assert.Equal(t, "order-1", envelope.CorrelationID, "without a request ID the event is tied to the order")
+	assert.Equal(t, &events.PaymentSucceeded{PaymentOutcome: events.PaymentOutcome{
+		PaymentID: 1, OrderID: 1, CustomerID: 7, Amount: money.MustNew(3998, "USD"),
+	}}, published)
+
+	// Redeliveries of the event change nothing
+	assert.Equal(t, model.WebhookResultDuplicate, result(t, env.send(t, event, webhookSecret)))
+	assert.Len(t, env.broker.Published(), 1)
+}
+
+func TestWebhook_CorrelatesEventsWithTheRequest(t *testing.T) {
+	env := newWebhookServer(t)
+	req := webhookRequest(t, stripeEvent("evt_1", "payment_intent.succeeded", map[string]interface{}{
+		"id":     "pi_123",
+		"object": "payment_intent",
+		"status": "succeeded",
+	}), webhookSecret)
+	req.Header.Set("X-Request-ID", "req-42")
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	assert.Equal(t, model.WebhookResultProcessed, result(t, w))
+
+	envelope, _ := env.next(t)
+	assert.Equal(t, "evt_1", envelope.ID)
+	assert.Equal(t, "req-42", envelope.CorrelationID)
+}
+
+func TestWebhook_AuthorizedThenPartiallyCaptured(t *testing.T) {
+	env := newWebhookServer(t)
+
+	authorized := stripeEvent("evt_1", "payment_intent.amount_capturable_updated", map[string]interface{}{
+		"id": "pi_123", "object": "payment_intent", "status": "requires_capture",
+	})
+	assert.Equal(t, model.WebhookResultProcessed, result(t, env.send(t, authorized, webhookSecret)))
+	assert.Equal(t, model.PaymentStatusRequiresCapture, env.status(t))
+	assert.Empty(t, env.broker.Published(), "an authorization is not an outcome")
+
+	captured := stripeEvent("evt_2", "payment_intent.succeeded", map[string]interface{}{
+		"id": "pi_123", "object": "payment_intent", "status": "succeeded", "amount_received": 2500,
+	})
+	assert.Equal(t, model.WebhookResultProcessed, result(t, env.send(t, captured, webhookSecret)))
+
+	payment, err := env.payments.GetByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, model.PaymentStatusSucceeded, payment.Status)
+	assert.Equal(t, money.MustNew(2500, "USD"), payment.AmountCaptured)
+
+	_, published := env.next(t)
+	assert.Equal(t, money.MustNew(2500, "USD"), published.(*events.PaymentSucceeded).Amount, "the captured amount is announced")
+
+	// A late authorization event does not reopen the payment
+	late := stripeEvent("evt_3", "payment_intent.amount_capturable_updated", map[string]interface{}{
+		"id": "pi_123", "object": "payment_intent", "status": "requires_capture",
+	})
+	assert.Equal(t, model.WebhookResultIgnored, result(t, env.send(t, late, webhookSecret)))
+	assert.Equal(t, model.PaymentStatusSucceeded, env.status(t))
+}
+
+func TestWebhook_PaymentFailedAndCanceled(t *testing.T) {
+	env := newWebhookServer(t)
+
+	failed := stripeEvent("evt_1", "payment_intent.payment_failed", map[string]interface{}{
+		"id":                 "pi_123",
+		"last_payment_error": map[string]interface{}{"message": "Your card was declined."},
+	})
+	assert.Equal(t, model.WebhookResultProcessed, result(t, env.send(t, failed, webhookSecret)))
+	assert.Equal(t, model.PaymentStatusFailed, env.status(t))
+	_, published := env.next(t)
+	assert.Equal(t, &events.PaymentFailed{PaymentOutcome: events.PaymentOutcome{
+		PaymentID: 1, OrderID: 1, CustomerID: 7, Amount: money.MustNew(3998, "USD"), Reason: "Your card was declined.",
+	}}, published)
+
+	canceled := stripeEvent("evt_2", "payment_intent.canceled", map[string]interface{}{
+		"id":                  "pi_123",
+		"cancellation_reason": "abandoned",
+	})
+	assert.Equal(t, model.WebhookResultProcessed, result(t, env.send(t, canceled, webhookSecret)))
+	assert.Equal(t, model.PaymentStatusCanceled, env.status(t))
+	_, published = env.next(t)
+	assert.IsType(t, &events.PaymentCanceled{}, published)
+}
+
+func TestWebhook_ChargeRefunded(t *testing.T) {
+	env := newWebhookServer(t)
+	assert.Equal(t, model.WebhookResultProcessed, result(t, env.send(t,
+		stripeEvent("evt_1", "payment_intent.succeeded", map[string]interface{}{"id": "pi_123"}), webhookSecret)))
+	env.next(t)
+
+	partial := stripeEvent("evt_2", "charge.refunded", map[string]interface{}{
+		"id": "ch_1", "payment_intent": "pi_123", "amount": 3998, "amount_refunded": 1000, "refunded": false,
+	})
+	assert.Equal(t, model.WebhookResultProcessed, result(t, env.send(t, partial, webhookSecret)))
+	assert.Equal(t, model.PaymentStatusPartiallyRefunded, env.status(t))
+	_, published := env.next(t)
+	assert.Equal(t, &events.PaymentRefunded{PaymentOutcome: events.PaymentOutcome{
+		PaymentID: 1, OrderID: 1, CustomerID: 7, Amount: money.MustNew(3998, "USD"),
+	}, AmountRefunded: money.MustNew(1000, "USD")}, published)
+
+	full := stripeEvent("evt_3", "charge.refunded", map[string]interface{}{
+		"id": "ch_1", "payment_intent": "pi_123", "amount": 3998, "amount_refunded": 3998, "refunded": true,
+	})
+	assert.Equal(t, model.WebhookResultProcessed, result(t, env.send(t, full, webhookSecret)))
+	assert.Equal(t, model.PaymentStatusRefunded, env.status(t))
+	_, published = env.next(t)
+	assert.Equal(t, money.MustNew(3998, "USD"), published.(*events.PaymentRefunded).AmountRefunded)
+
+	// A partial refund delivered after the full one does not undo it
+	stale := stripeEvent("evt_4", "charge.refunded", map[string]interface{}{
+		"id": "ch_1", "payment_intent": "pi_123", "amount": 3998, "amount_refunded": 1000, "refunded": false,
+	})
+	env.send(t, stale, webhookSecret)
+	payment, err := env.payments.GetByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, model.PaymentStatusRefunded, payment.Status)
+	assert.Equal(t, money.MustNew(3998, "USD"), payment.AmountRefunded)
+}
+
+func TestWebhook_IgnoresStaleAndUnhandledEvents(t *testing.T) {
+	env := newWebhookServer(t)
+	assert.Equal(t, model.WebhookResultProcessed, result(t, env.send(t,
+		stripeEvent("evt_1", "payment_intent.succeeded", map[string]interface{}{"id": "pi_123"}), webhookSecret)))
+	env.next(t)
+
+	// A failure delivered after the success does not undo it
+	stale := stripeEvent("evt_0", "payment_intent.payment_failed", map[string]interface{}{"id": "pi_123"})
+	assert.Equal(t, model.WebhookResultIgnored, result(t, env.send(t, stale, webhookSecret)))
+	assert.Equal(t, model.PaymentStatusSucceeded, env.status(t))
+
+	other := stripeEvent("evt_2", "customer.created", map[string]interface{}{"id": "cus_1"})
+	assert.Equal(t, model.WebhookResultIgnored, result(t, env.send(t, other, webhookSecret)))
+
+	assert.Len(t, env.broker.Published(), 1)
+}
+
+func TestWebhook_UnknownPaymentIsRetried(t *testing.T) {
+	env := newWebhookServer(t)
+
+	w := env.send(t, stripeEvent("evt_1", "payment_intent.succeeded", map[string]interface{}{"id": "pi_unknown"}), webhookSecret)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	processed, err := env.payments.EventProcessed("evt_1")
+	require.NoError(t, err)
+	assert.False(t, processed, "Stripe retries the event")
+}
+
+func TestWebhook_PublishFailureIsRetried(t *testing.T) {
+	env := newWebhookServer(t)
+	event := stripeEvent("evt_1", "payment_intent.succeeded", map[string]interface{}{"id": "pi_123"})
+
+	env.broker.Close()
+	w := env.send(t, event, webhookSecret)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	processed, err := env.payments.EventProcessed("evt_1")
+	require.NoError(t, err)
+	assert.False(t, processed, "Stripe retries the event")
+}
@@ -0,0 +1,69 @@
+// Package actor names the service calling another in a way the callee can
+// verify. The caller sends its name in the X-Actor header and the HMAC-SHA256
+// of the name, under a secret shared by the services, in the
+// X-Actor-Signature header. Clients outside the cluster do not know the
+// secret, so they cannot claim to be a service; the API gateway also drops
+// both headers from the requests it proxies.
+package actor
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+)
+
+const (
+	// Header names the caller
+	Header = "X-Actor"
+	// SignatureHeader proves the caller knows the shared secret
+	SignatureHeader = "X-Actor-Signature"
+)
+
+// SecretFromEnv returns the shared secret of ACTOR_SECRET, nil if it is not
+// set
+func SecretFromEnv() []byte {
+	if secret := os.Getenv("ACTOR_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	return nil
+}
+
+// Sign returns the signature of a caller's name under secret
+func Sign(secret []byte, name string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(name))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Set names the caller in the headers of a request, signed with secret
+func Set(h http.Header, secret []byte, name string) {
+	h.Set(Header, name)
+	h.Set(SignatureHeader, Sign(secret, name))
+}
+
+// Verify returns the caller named in the headers of a request, and false
+// if it is not named or its signature does not match. Without a secret no
+// caller is verified.
+func Verify(h http.Header, secret []byte) (string, bool) {
+	name := h.Get(Header)
+	if name == "" || len(secret) == 0 {
+		return "", false
+	}
+	signature, err := hex.DecodeString(h.Get(SignatureHeader))
+	if err != nil {
+		return "", false
+	}
+	expected, _ := hex.DecodeString(Sign(secret, name))
+	if !hmac.Equal(signature, expected) {
+		return "", false
+	}
+	return name, true
+}
+
+// Strip drops the caller headers of a request received from outside
+func Strip(h http.Header) {
+	h.Del(Header)
+	h.Del(SignatureHeader)
+}
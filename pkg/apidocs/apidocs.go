@@ -0,0 +1,149 @@
+// Package apidocs serves a service's OpenAPI document together with a
+// bundled Swagger UI, so every service exposes the same documentation routes.
+package apidocs
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files/v2"
+)
+
+const (
+	// SpecPath is where the OpenAPI document is served
+	SpecPath = "/openapi.json"
+	// UIPath is where the Swagger UI is served
+	UIPath = "/docs"
+)
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="UTF-8">
+  <title>{{.Title}}</title>
+  <link rel="stylesheet" type="text/css" href="{{.Base}}/swagger-ui.css">
+  <link rel="icon" type="image/png" href="{{.Base}}/favicon-32x32.png" sizes="32x32">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="{{.Base}}/swagger-ui-bundle.js"></script>
+  <script src="{{.Base}}/swagger-ui-standalone-preset.js"></script>
+  <script>
+    window.onload = function () {
+      window.ui = SwaggerUIBundle({
+        url: "{{.SpecURL}}",
+        dom_id: "#swagger-ui",
+        deepLinking: true,
+        presets: [SwaggerUIBundle.presets.apis, SwaggerUIStandalonePreset],
+        layout: "StandaloneLayout"
+      });
+    };
+  </script>
+</body>
+</html>
+`))
+
+// Register serves spec at SpecPath and a Swagger UI for it at UIPath
+func Register(router gin.IRoutes, spec []byte) {
+	router.GET(SpecPath, SpecHandler(spec))
+	RegisterUI(router, UIPath, SpecPath, title(spec))
+}
+
+// SpecHandler returns a handler answering with the given OpenAPI document
+func SpecHandler(spec []byte) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", spec)
+	}
+}
+
+// RegisterUI serves the Swagger UI under base, pointed at specURL
+func RegisterUI(router gin.IRoutes, base, specURL, pageTitle string) {
+	var index strings.Builder
+	if err := indexTemplate.Execute(&index, struct{ Title, Base, SpecURL string }{pageTitle, base, specURL}); err != nil {
+		panic(fmt.Sprintf("apidocs: failed to render index: %v", err))
+	}
+	page := []byte(index.String())
+	files := http.StripPrefix(base, http.FileServer(http.FS(swaggerFiles.FS)))
+
+	router.GET(base, func(c *gin.Context) {
+		c.Redirect(http.StatusMovedPermanently, base+"/")
+	})
+	router.GET(base+"/*filepath", func(c *gin.Context) {
+		switch c.Param("filepath") {
+		case "/", "/index.html":
+			c.Data(http.StatusOK, "text/html; charset=utf-8", page)
+		case "/swagger-initializer.js":
+			// The bundled initializer points at the petstore demo
+			c.Status(http.StatusNotFound)
+		default:
+			files.ServeHTTP(c.Writer, c.Request)
+		}
+	})
+}
+
+// IsDocsRoute reports whether path was registered by Register
+func IsDocsRoute(path string) bool {
+	return path == SpecPath || path == UIPath || strings.HasPrefix(path, UIPath+"/")
+}
+
+// MissingRoutes returns the registered routes that are not declared in spec,
+// formatted as "METHOD /path". Documentation routes are ignored.
+func MissingRoutes(routes gin.RoutesInfo, spec []byte) ([]string, error) {
+	declared, err := Operations(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for _, r := range routes {
+		if IsDocsRoute(r.Path) {
+			continue
+		}
+		key := r.Method + " " + r.Path
+		if !declared[key] {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	return missing, nil
+}
+
+// Operations returns the operations of spec as "METHOD /gin/:path" keys
+func Operations(spec []byte) (map[string]bool, error) {
+	var doc struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse spec: %w", err)
+	}
+
+	ops := make(map[string]bool)
+	for path, item := range doc.Paths {
+		ginPath := strings.NewReplacer("{", ":", "}", "").Replace(path)
+		for method := range item {
+			switch method {
+			case "get", "put", "post", "delete", "patch", "head", "options":
+				ops[strings.ToUpper(method)+" "+ginPath] = true
+			}
+		}
+	}
+	return ops, nil
+}
+
+// title returns info.title of spec
+func title(spec []byte) string {
+	var doc struct {
+		Info struct {
+			Title string `json:"title"`
+		} `json:"info"`
+	}
+	if err := json.Unmarshal(spec, &doc); err != nil || doc.Info.Title == "" {
+		return "API Documentation"
+	}
+	return doc.Info.Title
+}
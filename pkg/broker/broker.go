@@ -0,0 +1,197 @@
+// Package broker is the publish/subscribe interface services use to
+// exchange events, independent of the message broker they run on.
+//
+// Messages are published to a topic with a dot-separated key, such as
+// topic "orders" and key "order.created". A subscription is a named, durable
+// group of subscribers: every subscription receives its own copy of each
+// message matching one of its bindings, and the subscribers of one
+// subscription share its messages. A message whose handler fails is
+// redelivered as the subscription's retry policy allows, then dead-lettered.
+//
+// Three implementations are provided:
+//
+//   - RabbitMQ, on top of the queue package: topics are topic exchanges,
+//     subscriptions are queues
+//   - NATS JetStream: topics are streams, subscriptions are durable consumers
+//   - Memory, an in-process broker for tests and single-binary local runs
+//
+// NewFromEnv selects one with the BROKER environment variable.
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"go-microservices/pkg/queue"
+)
+
+// Broker kinds accepted by NewFromEnv
+const (
+	KindRabbitMQ = "rabbitmq"
+	KindNATS     = "nats"
+	KindMemory   = "memory"
+)
+
+var (
+	// ErrUnroutable is returned by Publish when no subscription receives the
+	// message. NATS stores messages without subscribers and never returns it.
+	ErrUnroutable = queue.ErrUnroutable
+	// ErrClosed is returned when publishing to or subscribing on a closed broker
+	ErrClosed = errors.New("broker is closed")
+	// ErrUnsupportedPattern is returned by Subscribe for binding patterns the
+	// broker cannot express
+	ErrUnsupportedPattern = errors.New("unsupported binding pattern")
+)
+
+// Message is a message published to a topic
+type Message struct {
+	// Topic groups related messages, such as "orders"
+	Topic string
+	// Key routes the message to the bindings matching it, such as
+	// "order.created"
+	Key string
+	// ID identifies the message, optional. NATS drops messages republished
+	// with the same ID.
+	ID string
+	// Body is the encoded message
+	Body []byte
+}
+
+// Handler processes the body of a delivered message. A nil error acks the
+// message. ctx is canceled when the subscriber stops without waiting for
+// its handlers.
+type Handler = queue.Handler
+
+// RetryPolicy bounds how often a failing message is delivered, see
+// queue.RetryPolicy
+type RetryPolicy = queue.RetryPolicy
+
+// Binding selects the messages of a topic whose key matches Pattern. Patterns
+// use the RabbitMQ topic syntax: "*" matches one word and "#" zero or more
+// words, as in "order.#".
+type Binding struct {
+	Topic   string
+	Pattern string
+}
+
+// Subscription declares a durable, named group of subscribers
+type Subscription struct {
+	// Name identifies the subscription; subscribers with the same name
+	// share its messages
+	Name string
+	// Bindings select the messages the subscription receives
+	Bindings []Binding
+	// Concurrency is the number of messages handled at once, 1 if zero
+	Concurrency int
+	// Retry bounds the deliveries of failing messages,
+	// queue.DefaultRetryPolicy if nil
+	Retry *RetryPolicy
+}
+
+// validate checks that a subscription can be declared
+func (s Subscription) validate() error {
+	if s.Name == "" {
+		return errors.New("subscription name is required")
+	}
+	if len(s.Bindings) == 0 {
+		return fmt.Errorf("subscription %s has no bindings", s.Name)
+	}
+	return nil
+}
+
+// retryPolicy returns the retry policy of the subscription
+func (s Subscription) retryPolicy() RetryPolicy {
+	if s.Retry == nil {
+		return queue.DefaultRetryPolicy
+	}
+	return *s.Retry
+}
+
+// concurrency returns the number of handlers the subscription runs
+func (s Subscription) concurrency() int {
+	if s.Concurrency < 1 {
+		return 1
+	}
+	return s.Concurrency
+}
+
+// Subscriber is a running subscription
+type Subscriber interface {
+	// Stop stops receiving messages and waits until the messages being
+	// handled are acked, or ctx is done
+	Stop(ctx context.Context) error
+}
+
+// Publisher publishes messages
+type Publisher interface {
+	Publish(ctx context.Context, msg Message) error
+}
+
+// Broker publishes messages and delivers them to subscriptions
+type Broker interface {
+	Publisher
+	Subscribe(subscription Subscription, handler Handler) (Subscriber, error)
+	// Ready returns nil while the broker can be reached, for readiness checks
+	Ready() error
+	Close() error
+}
+
+// NewFromEnv creates the broker named by the BROKER environment variable,
+// RabbitMQ by default. RabbitMQ is configured as by queue.InitRabbitMQ and
+// NATS with NATS_URL. If RabbitMQ or NATS cannot be reached the error is
+// returned together with the broker, which keeps reconnecting in the
+// background.
+func NewFromEnv() (Broker, error) {
+	switch kind := strings.ToLower(getEnv("BROKER", KindRabbitMQ)); kind {
+	case KindRabbitMQ:
+		err := queue.InitRabbitMQ()
+		return NewRabbitMQ(queue.DefaultConnection()), err
+	case KindNATS:
+		n, err := NewNATS(getEnv("NATS_URL", "nats://nats:4222"))
+		if n == nil {
+			return nil, err
+		}
+		return n, err
+	case KindMemory:
+		return NewMemory(), nil
+	default:
+		return nil, fmt.Errorf("unknown broker %q", kind)
+	}
+}
+
+// Match reports whether a key matches a binding pattern
+func Match(pattern, key string) bool {
+	return match(strings.Split(pattern, "."), strings.Split(key, "."))
+}
+
+// match matches the words of a key against the words of a pattern
+func match(pattern, words []string) bool {
+	if len(pattern) == 0 {
+		return len(words) == 0
+	}
+	switch pattern[0] {
+	case "#":
+		for i := 0; i <= len(words); i++ {
+			if match(pattern[1:], words[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(words) > 0 && match(pattern[1:], words[1:])
+	default:
+		return len(words) > 0 && words[0] == pattern[0] && match(pattern[1:], words[1:])
+	}
+}
+
+// getEnv gets an environment variable or returns a default value
+func getEnv(key, defaultValue string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
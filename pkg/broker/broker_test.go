@@ -0,0 +1,188 @@
+package broker_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-microservices/pkg/broker"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		key     string
+		match   bool
+	}{
+		{"order.created", "order.created", true},
+		{"order.created", "order.updated", false},
+		{"order.*", "order.created", true},
+		{"order.*", "order.status.changed", false},
+		{"order.#", "order.status.changed", true},
+		{"order.#", "order", true},
+		{"#.created", "order.created", true},
+		{"#", "payment.failed", true},
+		{"payment.#", "order.created", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.match, broker.Match(tt.pattern, tt.key), "%s ~ %s", tt.pattern, tt.key)
+	}
+}
+
+// subscribe subscribes to the order topic of a memory broker, sending every
+// delivered body to the returned channel
+func subscribe(t *testing.T, b *broker.Memory, name, pattern string) <-chan string {
+	bodies := make(chan string, 10)
+	_, err := b.Subscribe(broker.Subscription{
+		Name:     name,
+		Bindings: []broker.Binding{{Topic: "orders", Pattern: pattern}},
+	}, func(_ context.Context, body []byte) error {
+		bodies <- string(body)
+		return nil
+	})
+	require.NoError(t, err)
+	return bodies
+}
+
+// receive waits for the next body delivered to a subscription
+func receive(t *testing.T, bodies <-chan string) string {
+	select {
+	case body := <-bodies:
+		return body
+	case <-time.After(time.Second):
+		t.Fatal("no message was delivered")
+		return ""
+	}
+}
+
+func TestMemoryBroker_EverySubscriptionGetsACopy(t *testing.T) {
+	b := broker.NewMemory()
+	defer b.Close()
+
+	all := subscribe(t, b, "audit", "order.#")
+	created := subscribe(t, b, "notifications", "order.created")
+
+	ctx := context.Background()
+	require.NoError(t, b.Publish(ctx, broker.Message{Topic: "orders", Key: "order.created", Body: []byte("1")}))
+	require.NoError(t, b.Publish(ctx, broker.Message{Topic: "orders", Key: "order.status_changed", Body: []byte("2")}))
+
+	assert.Equal(t, "1", receive(t, all))
+	assert.Equal(t, "2", receive(t, all))
+	assert.Equal(t, "1", receive(t, created))
+	assert.Empty(t, created)
+	assert.Len(t, b.Published(), 2)
+}
+
+func TestMemoryBroker_SubscribersShareASubscription(t *testing.T) {
+	b := broker.NewMemory()
+	defer b.Close()
+
+	first := subscribe(t, b, "notifications", "order.#")
+	second := subscribe(t, b, "notifications", "order.#")
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, b.Publish(context.Background(), broker.Message{Topic: "orders", Key: "order.created"}))
+	}
+
+	delivered := 0
+	for delivered < 4 {
+		select {
+		case <-first:
+		case <-second:
+		case <-time.After(time.Second):
+			t.Fatalf("%d of 4 messages delivered", delivered)
+		}
+		delivered++
+	}
+	assert.Empty(t, first)
+	assert.Empty(t, second)
+}
+
+func TestMemoryBroker_Unroutable(t *testing.T) {
+	b := broker.NewMemory()
+	defer b.Close()
+	subscribe(t, b, "notifications", "order.#")
+
+	err := b.Publish(context.Background(), broker.Message{Topic: "payments", Key: "payment.failed"})
+	assert.ErrorIs(t, err, broker.ErrUnroutable)
+}
+
+func TestMemoryBroker_RetriesThenDeadLetters(t *testing.T) {
+	b := broker.NewMemory()
+	defer b.Close()
+
+	var deliveries atomic.Int32
+	_, err := b.Subscribe(broker.Subscription{
+		Name:     "notifications",
+		Bindings: []broker.Binding{{Topic: "orders", Pattern: "order.#"}},
+		Retry:    &broker.RetryPolicy{MaxAttempts: 3, Delays: []time.Duration{time.Millisecond}},
+	}, func(context.Context, []byte) error {
+		deliveries.Add(1)
+		return errors.New("handler failed")
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, b.Publish(context.Background(), broker.Message{Topic: "orders", Key: "order.created", Body: []byte("1")}))
+
+	require.Eventually(t, func() bool {
+		return len(b.DeadLetters("notifications")) == 1
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, int32(3), deliveries.Load())
+	assert.Equal(t, []byte("1"), b.DeadLetters("notifications")[0].Body)
+}
+
+func TestMemoryBroker_StopWaitsForHandlers(t *testing.T) {
+	b := broker.NewMemory()
+	defer b.Close()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var handled atomic.Bool
+	subscriber, err := b.Subscribe(broker.Subscription{
+		Name:     "notifications",
+		Bindings: []broker.Binding{{Topic: "orders", Pattern: "order.#"}},
+	}, func(context.Context, []byte) error {
+		close(started)
+		<-release
+		handled.Store(true)
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, b.Publish(context.Background(), broker.Message{Topic: "orders", Key: "order.created"}))
+	<-started
+
+	time.AfterFunc(20*time.Millisecond, func() { close(release) })
+	require.NoError(t, subscriber.Stop(context.Background()))
+	assert.True(t, handled.Load(), "Stop returned before the handler finished")
+}
+
+func TestMemoryBroker_Close(t *testing.T) {
+	b := broker.NewMemory()
+	require.NoError(t, b.Ready())
+	subscribe(t, b, "notifications", "order.#")
+
+	require.NoError(t, b.Close())
+
+	assert.ErrorIs(t, b.Ready(), broker.ErrClosed)
+	assert.ErrorIs(t, b.Publish(context.Background(), broker.Message{Topic: "orders", Key: "order.created"}), broker.ErrClosed)
+	_, err := b.Subscribe(broker.Subscription{Name: "audit", Bindings: []broker.Binding{{Topic: "orders", Pattern: "#"}}}, nil)
+	assert.ErrorIs(t, err, broker.ErrClosed)
+}
+
+func TestNewFromEnv(t *testing.T) {
+	t.Setenv("BROKER", "memory")
+	b, err := broker.NewFromEnv()
+	require.NoError(t, err)
+	assert.IsType(t, &broker.Memory{}, b)
+	b.Close()
+
+	t.Setenv("BROKER", "kafka")
+	_, err = broker.NewFromEnv()
+	assert.Error(t, err)
+}
@@ -0,0 +1,305 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// memoryBuffer is the number of messages a memory subscription holds before
+// Publish waits for its subscribers
+const memoryBuffer = 1024
+
+// Memory is an in-process Broker. Each subscription buffers its messages in
+// a channel its subscribers read from. Nothing is persisted: messages are
+// only kept while the process runs, and messages no subscription matches
+// are rejected with ErrUnroutable, as RabbitMQ rejects unroutable mandatory
+// messages.
+type Memory struct {
+	mu            sync.Mutex
+	subscriptions map[string]*memorySubscription
+	subscribers   []*memorySubscriber
+	published     []Message
+	done          chan struct{}
+	closeOnce     sync.Once
+}
+
+// memorySubscription is the buffer shared by the subscribers of a subscription
+type memorySubscription struct {
+	bindings   []Binding
+	retry      RetryPolicy
+	deliveries chan memoryDelivery
+	dead       []Message
+}
+
+// memoryDelivery is a message waiting in a subscription
+type memoryDelivery struct {
+	msg      Message
+	attempts int
+}
+
+// NewMemory creates an in-process broker without subscriptions
+func NewMemory() *Memory {
+	return &Memory{
+		subscriptions: make(map[string]*memorySubscription),
+		done:          make(chan struct{}),
+	}
+}
+
+// Publish hands a message to every subscription with a matching binding,
+// waiting while a subscription's buffer is full
+func (m *Memory) Publish(ctx context.Context, msg Message) error {
+	m.mu.Lock()
+	if m.closed() {
+		m.mu.Unlock()
+		return ErrClosed
+	}
+	m.published = append(m.published, msg)
+	var matched []*memorySubscription
+	for _, sub := range m.subscriptions {
+		if sub.matches(msg) {
+			matched = append(matched, sub)
+		}
+	}
+	m.mu.Unlock()
+
+	if len(matched) == 0 {
+		return fmt.Errorf("failed to publish %s to %s: %w", msg.Key, msg.Topic, ErrUnroutable)
+	}
+	for _, sub := range matched {
+		select {
+		case sub.deliveries <- memoryDelivery{msg: msg}:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-m.done:
+			return ErrClosed
+		}
+	}
+
+	return nil
+}
+
+// Subscribe declares a subscription, adding bindings to an existing
+// subscription of the same name, and starts subscription.Concurrency
+// handlers reading from it
+func (m *Memory) Subscribe(subscription Subscription, handler Handler) (Subscriber, error) {
+	if err := subscription.validate(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed() {
+		return nil, ErrClosed
+	}
+
+	sub, ok := m.subscriptions[subscription.Name]
+	if !ok {
+		sub = &memorySubscription{
+			retry:      subscription.retryPolicy(),
+			deliveries: make(chan memoryDelivery, memoryBuffer),
+		}
+		m.subscriptions[subscription.Name] = sub
+	}
+	for _, binding := range subscription.Bindings {
+		if !sub.bound(binding) {
+			sub.bindings = append(sub.bindings, binding)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	subscriber := &memorySubscriber{
+		broker:       m,
+		subscription: sub,
+		name:         subscription.Name,
+		handler:      handler,
+		ctx:          ctx,
+		cancel:       cancel,
+		stopped:      make(chan struct{}),
+	}
+	for i := 0; i < subscription.concurrency(); i++ {
+		subscriber.workers.Add(1)
+		go subscriber.work()
+	}
+	m.subscribers = append(m.subscribers, subscriber)
+
+	return subscriber, nil
+}
+
+// Ready returns ErrClosed once the broker is closed
+func (m *Memory) Ready() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed() {
+		return ErrClosed
+	}
+	return nil
+}
+
+// Close stops every subscriber without waiting for its handlers
+func (m *Memory) Close() error {
+	m.closeOnce.Do(func() {
+		m.mu.Lock()
+		close(m.done)
+		subscribers := m.subscribers
+		m.mu.Unlock()
+
+		for _, subscriber := range subscribers {
+			subscriber.stop()
+			subscriber.cancel()
+		}
+	})
+	return nil
+}
+
+// Published returns every message published so far
+func (m *Memory) Published() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Message(nil), m.published...)
+}
+
+// DeadLetters returns the messages of a subscription that failed as many
+// times as its retry policy allows
+func (m *Memory) DeadLetters(subscription string) []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sub, ok := m.subscriptions[subscription]
+	if !ok {
+		return nil
+	}
+	return append([]Message(nil), sub.dead...)
+}
+
+// closed reports whether Close was called. The caller holds mu.
+func (m *Memory) closed() bool {
+	select {
+	case <-m.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// redeliver puts a failed message back into its subscription after delay,
+// unless the broker is closed by then
+func (m *Memory) redeliver(sub *memorySubscription, delivery memoryDelivery, delay time.Duration) {
+	time.AfterFunc(delay, func() {
+		select {
+		case sub.deliveries <- delivery:
+		case <-m.done:
+		}
+	})
+}
+
+// deadLetter parks a message that failed too often
+func (m *Memory) deadLetter(sub *memorySubscription, delivery memoryDelivery) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sub.dead = append(sub.dead, delivery.msg)
+}
+
+// matches reports whether a binding of the subscription matches msg. The
+// caller holds the broker's mu.
+func (sub *memorySubscription) matches(msg Message) bool {
+	for _, binding := range sub.bindings {
+		if binding.Topic == msg.Topic && Match(binding.Pattern, msg.Key) {
+			return true
+		}
+	}
+	return false
+}
+
+// bound reports whether the subscription already has a binding
+func (sub *memorySubscription) bound(binding Binding) bool {
+	for _, b := range sub.bindings {
+		if b == binding {
+			return true
+		}
+	}
+	return false
+}
+
+// memorySubscriber runs the handlers of one Subscribe call
+type memorySubscriber struct {
+	broker       *Memory
+	subscription *memorySubscription
+	name         string
+	handler      Handler
+	ctx          context.Context
+	cancel       context.CancelFunc
+	stopped      chan struct{}
+	stopOnce     sync.Once
+	workers      sync.WaitGroup
+}
+
+// Stop stops taking messages from the subscription and waits for the
+// handlers running, canceling their context if ctx is done first
+func (s *memorySubscriber) Stop(ctx context.Context) error {
+	s.stop()
+
+	done := make(chan struct{})
+	go func() {
+		s.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		s.cancel()
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		return fmt.Errorf("subscriber %s did not stop: %w", s.name, ctx.Err())
+	}
+}
+
+// stop makes the workers return once their current message is handled
+func (s *memorySubscriber) stop() {
+	s.stopOnce.Do(func() { close(s.stopped) })
+}
+
+// work handles messages of the subscription until the subscriber stops
+func (s *memorySubscriber) work() {
+	defer s.workers.Done()
+
+	for {
+		select {
+		case <-s.stopped:
+			return
+		default:
+		}
+
+		select {
+		case <-s.stopped:
+			return
+		case delivery := <-s.subscription.deliveries:
+			s.handle(delivery)
+		}
+	}
+}
+
+// handle runs the handler on a message, retrying or dead-lettering it if
+// the handler fails
+func (s *memorySubscriber) handle(delivery memoryDelivery) {
+	err := s.handler(s.ctx, delivery.msg.Body)
+	if err == nil {
+		return
+	}
+
+	delivery.attempts++
+	delay, ok := s.subscription.retry.Next(delivery.attempts)
+	if !ok {
+		log.Printf("[Broker] Dead-lettering %s message on %s after %d attempts: %v\n", delivery.msg.Key, s.name, delivery.attempts, err)
+		s.broker.deadLetter(s.subscription, delivery)
+		return
+	}
+	s.broker.redeliver(s.subscription, delivery, delay)
+}
@@ -0,0 +1,384 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// natsTimeout bounds the JetStream API calls made while subscribing
+const natsTimeout = 5 * time.Second
+
+// NATS is a Broker on NATS JetStream. Each topic is a stream holding the
+// subjects "<topic>.>", messages are published on "<topic>.<key>", and a
+// subscription is a durable consumer, named after it, on the stream of each
+// topic it binds. Messages are deduplicated by ID. A message that failed as
+// often as the retry policy allows is terminated: it stays in the stream,
+// but is not delivered again.
+type NATS struct {
+	conn *nats.Conn
+	js   jetstream.JetStream
+
+	mu          sync.Mutex
+	streams     map[string]bool
+	subscribers []*natsSubscriber
+}
+
+// NewNATS connects to NATS at url. If NATS cannot be reached the error is
+// returned together with the broker, which keeps reconnecting in the
+// background.
+func NewNATS(url string) (*NATS, error) {
+	conn, err := nats.Connect(url,
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(time.Second),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				fmt.Printf("[NATS] Disconnected: %v\n", err)
+			}
+		}),
+		nats.ReconnectHandler(func(*nats.Conn) {
+			fmt.Println("[NATS] Reconnected")
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open JetStream: %w", err)
+	}
+
+	n := &NATS{conn: conn, js: js, streams: make(map[string]bool)}
+	return n, n.Ready()
+}
+
+// Publish publishes a message on the subject of its key in the stream of
+// its topic, creating the stream the first time
+func (n *NATS) Publish(ctx context.Context, msg Message) error {
+	if err := n.ensureStream(ctx, msg.Topic); err != nil {
+		return err
+	}
+
+	var opts []jetstream.PublishOpt
+	if msg.ID != "" {
+		opts = append(opts, jetstream.WithMsgID(msg.ID))
+	}
+	if _, err := n.js.Publish(ctx, msg.Topic+"."+msg.Key, msg.Body, opts...); err != nil {
+		return fmt.Errorf("failed to publish %s to %s: %w", msg.Key, msg.Topic, err)
+	}
+
+	return nil
+}
+
+// Subscribe creates or updates the durable consumer of the subscription on
+// the stream of every topic it binds and starts subscription.Concurrency
+// handlers. Patterns may only use "#" as their last word. While NATS is
+// unreachable the consumers are created in the background once it is back.
+func (n *NATS) Subscribe(subscription Subscription, handler Handler) (Subscriber, error) {
+	if err := subscription.validate(); err != nil {
+		return nil, err
+	}
+
+	filters := make(map[string][]string)
+	for _, binding := range subscription.Bindings {
+		subject, err := natsSubject(binding)
+		if err != nil {
+			return nil, err
+		}
+		filters[binding.Topic] = append(filters[binding.Topic], subject)
+	}
+
+	retry := subscription.retryPolicy()
+	maxDeliver := retry.MaxAttempts
+	if maxDeliver < 1 {
+		maxDeliver = -1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	subscriber := &natsSubscriber{
+		name:       subscription.Name,
+		retry:      retry,
+		handler:    handler,
+		consumers:  make(map[string]jetstream.ConsumerConfig),
+		ctx:        ctx,
+		cancel:     cancel,
+		deliveries: make(chan jetstream.Msg),
+		stopped:    make(chan struct{}),
+	}
+	for topic, subjects := range filters {
+		subscriber.consumers[topic] = jetstream.ConsumerConfig{
+			Durable:        subscription.Name,
+			FilterSubjects: subjects,
+			AckPolicy:      jetstream.AckExplicitPolicy,
+			MaxDeliver:     maxDeliver,
+			MaxAckPending:  subscription.concurrency(),
+		}
+	}
+
+	if err := subscriber.consume(n); err != nil {
+		if n.conn.IsConnected() {
+			cancel()
+			return nil, err
+		}
+		fmt.Printf("[NATS] %v, retrying in the background\n", err)
+		go subscriber.retryConsume(n)
+	}
+
+	for i := 0; i < subscription.concurrency(); i++ {
+		subscriber.workers.Add(1)
+		go subscriber.work()
+	}
+
+	n.mu.Lock()
+	n.subscribers = append(n.subscribers, subscriber)
+	n.mu.Unlock()
+
+	return subscriber, nil
+}
+
+// createConsumer creates or updates a durable consumer on the stream of topic
+func (n *NATS) createConsumer(topic string, config jetstream.ConsumerConfig) (jetstream.Consumer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), natsTimeout)
+	defer cancel()
+
+	if err := n.ensureStream(ctx, topic); err != nil {
+		return nil, err
+	}
+	return n.js.CreateOrUpdateConsumer(ctx, streamName(topic), config)
+}
+
+// ensureStream creates the stream of a topic once
+func (n *NATS) ensureStream(ctx context.Context, topic string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.streams[topic] {
+		return nil
+	}
+	_, err := n.js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:     streamName(topic),
+		Subjects: []string{topic + ".>"},
+		Storage:  jetstream.FileStorage,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create stream for %s: %w", topic, err)
+	}
+	n.streams[topic] = true
+	return nil
+}
+
+// Ready returns nil while NATS is connected
+func (n *NATS) Ready() error {
+	if !n.conn.IsConnected() {
+		return fmt.Errorf("NATS is %s", n.conn.Status())
+	}
+	return nil
+}
+
+// Close stops every subscriber without waiting for its handlers and closes
+// the connection
+func (n *NATS) Close() error {
+	n.mu.Lock()
+	subscribers := n.subscribers
+	n.subscribers = nil
+	n.mu.Unlock()
+
+	for _, subscriber := range subscribers {
+		subscriber.stop()
+		subscriber.cancel()
+	}
+	n.conn.Close()
+	return nil
+}
+
+// streamName returns the name of the stream of a topic. Stream names may
+// not contain dots.
+func streamName(topic string) string {
+	return strings.ToUpper(strings.ReplaceAll(topic, ".", "_"))
+}
+
+// natsSubject translates a binding into a subject filter: "*" is a NATS
+// wildcard as well, and a trailing "#" becomes ">". NATS has no wildcard
+// for zero words, so "order.#" does not match the key "order".
+func natsSubject(binding Binding) (string, error) {
+	words := strings.Split(binding.Pattern, ".")
+	for i, word := range words {
+		if word != "#" {
+			continue
+		}
+		if i != len(words)-1 {
+			return "", fmt.Errorf("%w: %q, NATS only supports # as the last word", ErrUnsupportedPattern, binding.Pattern)
+		}
+		words[i] = ">"
+	}
+	return binding.Topic + "." + strings.Join(words, "."), nil
+}
+
+// natsSubscriber runs the handlers of one subscription
+type natsSubscriber struct {
+	name       string
+	retry      RetryPolicy
+	handler    Handler
+	consumers  map[string]jetstream.ConsumerConfig
+	ctx        context.Context
+	cancel     context.CancelFunc
+	deliveries chan jetstream.Msg
+	stopped    chan struct{}
+	stopOnce   sync.Once
+	workers    sync.WaitGroup
+
+	mu          sync.Mutex
+	consumeCtxs []jetstream.ConsumeContext
+}
+
+// consume creates the consumer of every topic and starts receiving their
+// messages. On failure the consumers already started are stopped.
+func (s *natsSubscriber) consume(n *NATS) error {
+	for topic, config := range s.consumers {
+		consumer, err := n.createConsumer(topic, config)
+		var consumeCtx jetstream.ConsumeContext
+		if err == nil {
+			consumeCtx, err = consumer.Consume(s.receive)
+		}
+		if err != nil {
+			s.stopConsuming()
+			return fmt.Errorf("failed to subscribe %s to %s: %w", s.name, topic, err)
+		}
+
+		s.mu.Lock()
+		s.consumeCtxs = append(s.consumeCtxs, consumeCtx)
+		s.mu.Unlock()
+		if s.isStopped() {
+			s.stopConsuming()
+			return nil
+		}
+	}
+	return nil
+}
+
+// retryConsume retries consume until it succeeds or the subscriber stops
+func (s *natsSubscriber) retryConsume(n *NATS) {
+	for {
+		select {
+		case <-s.stopped:
+			return
+		case <-time.After(natsTimeout):
+		}
+
+		if err := s.consume(n); err == nil {
+			fmt.Printf("[NATS] Subscribed %s\n", s.name)
+			return
+		}
+	}
+}
+
+// stopConsuming stops receiving messages from the consumers
+func (s *natsSubscriber) stopConsuming() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, consumeCtx := range s.consumeCtxs {
+		consumeCtx.Stop()
+	}
+	s.consumeCtxs = nil
+}
+
+// isStopped reports whether Stop or Close was called
+func (s *natsSubscriber) isStopped() bool {
+	select {
+	case <-s.stopped:
+		return true
+	default:
+		return false
+	}
+}
+
+// Stop stops receiving messages and waits for the handlers running,
+// canceling their context if ctx is done first. Messages received but not
+// handled yet are redelivered by NATS.
+func (s *natsSubscriber) Stop(ctx context.Context) error {
+	s.stop()
+
+	done := make(chan struct{})
+	go func() {
+		s.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		s.cancel()
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		return fmt.Errorf("subscriber %s did not stop: %w", s.name, ctx.Err())
+	}
+}
+
+// stop stops the consumers and makes the workers return once their current
+// message is handled
+func (s *natsSubscriber) stop() {
+	s.stopOnce.Do(func() {
+		close(s.stopped)
+		s.stopConsuming()
+	})
+}
+
+// receive hands a message from a consumer to a free worker
+func (s *natsSubscriber) receive(msg jetstream.Msg) {
+	select {
+	case s.deliveries <- msg:
+	case <-s.stopped:
+	}
+}
+
+// work handles messages until the subscriber stops
+func (s *natsSubscriber) work() {
+	defer s.workers.Done()
+
+	for {
+		select {
+		case <-s.stopped:
+			return
+		case msg := <-s.deliveries:
+			s.handle(msg)
+		}
+	}
+}
+
+// handle runs the handler on a message and acks it, asks for a delayed
+// redelivery or terminates it as the retry policy says
+func (s *natsSubscriber) handle(msg jetstream.Msg) {
+	err := s.handler(s.ctx, msg.Data())
+	if err == nil {
+		if err := msg.Ack(); err != nil {
+			fmt.Printf("[NATS] Failed to ack message: %v\n", err)
+		}
+		return
+	}
+
+	attempts := 1
+	if metadata, mdErr := msg.Metadata(); mdErr == nil {
+		attempts = int(metadata.NumDelivered)
+	}
+	delay, ok := s.retry.Next(attempts)
+	if !ok {
+		log.Printf("[NATS] Dead-lettering %s message on %s after %d attempts: %v\n", msg.Subject(), s.name, attempts, err)
+		if err := msg.Term(); err != nil {
+			fmt.Printf("[NATS] Failed to terminate message: %v\n", err)
+		}
+		return
+	}
+	if err := msg.NakWithDelay(delay); err != nil {
+		fmt.Printf("[NATS] Failed to nak message: %v\n", err)
+	}
+}
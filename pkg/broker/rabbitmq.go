@@ -0,0 +1,99 @@
+package broker
+
+import (
+	"context"
+	"sync"
+
+	"go-microservices/pkg/queue"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// RabbitMQ is a Broker on a queue.Connection. Topics are durable topic
+// exchanges and subscriptions are durable queues with retry and
+// dead-letter queues. Messages are published persistent, mandatory and
+// with publisher confirms.
+type RabbitMQ struct {
+	conn *queue.Connection
+
+	mu        sync.Mutex
+	exchanges map[string]bool
+}
+
+// NewRabbitMQ creates a broker publishing and consuming on conn
+func NewRabbitMQ(conn *queue.Connection) *RabbitMQ {
+	return &RabbitMQ{conn: conn, exchanges: make(map[string]bool)}
+}
+
+// Publish publishes a message to the exchange of its topic, declaring the
+// exchange the first time
+func (r *RabbitMQ) Publish(_ context.Context, msg Message) error {
+	config := queue.Config{
+		ExchangeName: msg.Topic,
+		ExchangeType: "topic",
+		RoutingKey:   msg.Key,
+		Confirm:      true,
+	}
+	if err := r.declareExchange(config); err != nil {
+		return err
+	}
+
+	return r.conn.Publish(config, amqp.Publishing{
+		ContentType: "application/json",
+		MessageId:   msg.ID,
+		Body:        msg.Body,
+	})
+}
+
+// declareExchange declares the exchange of config once
+func (r *RabbitMQ) declareExchange(config queue.Config) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.exchanges[config.ExchangeName] {
+		return nil
+	}
+	if err := r.conn.DeclareQueue(config); err != nil {
+		return err
+	}
+	r.exchanges[config.ExchangeName] = true
+	return nil
+}
+
+// Subscribe declares the subscription's queue, bound to the exchange of
+// every binding, and consumes it
+func (r *RabbitMQ) Subscribe(subscription Subscription, handler Handler) (Subscriber, error) {
+	if err := subscription.validate(); err != nil {
+		return nil, err
+	}
+
+	retry := subscription.retryPolicy()
+	var config queue.Config
+	for _, binding := range subscription.Bindings {
+		config = queue.Config{
+			QueueName:    subscription.Name,
+			RoutingKey:   binding.Pattern,
+			ExchangeName: binding.Topic,
+			ExchangeType: "topic",
+			Retry:        &retry,
+		}
+		if err := r.conn.DeclareQueue(config); err != nil {
+			return nil, err
+		}
+	}
+
+	return r.conn.Consume(config, queue.ConsumerOptions{
+		Name:        subscription.Name,
+		Concurrency: subscription.concurrency(),
+	}, handler)
+}
+
+// Ready returns nil while RabbitMQ is connected
+func (r *RabbitMQ) Ready() error {
+	return r.conn.Ready()
+}
+
+// Close closes the connection and stops its consumers
+func (r *RabbitMQ) Close() error {
+	return r.conn.Close()
+}
@@ -0,0 +1,78 @@
+package contract
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// MockProvider serves the interactions of a contract to consumer code under test
+type MockProvider struct {
+	URL string
+
+	t        *testing.T
+	contract Contract
+	mu       sync.Mutex
+	used     map[int]bool
+}
+
+// NewMockProvider starts a server answering every request that matches one
+// of the contract's interactions with its recorded response. Requests that
+// match none fail the test, as do interactions never exercised by the time
+// the test ends.
+func NewMockProvider(t *testing.T, c Contract) *MockProvider {
+	t.Helper()
+
+	mp := &MockProvider{t: t, contract: c, used: make(map[int]bool)}
+	server := httptest.NewServer(http.HandlerFunc(mp.serve))
+	mp.URL = server.URL
+
+	t.Cleanup(func() {
+		server.Close()
+		for i, interaction := range c.Interactions {
+			if !mp.used[i] {
+				t.Errorf("%s: interaction %q was never exercised", c.Provider, interaction.Description)
+			}
+		}
+	})
+	return mp
+}
+
+func (mp *MockProvider) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	var mismatches []string
+	for i, interaction := range mp.contract.Interactions {
+		diffs := matchRequest(interaction.Request, r, body)
+		if len(diffs) == 0 {
+			mp.mu.Lock()
+			mp.used[i] = true
+			mp.mu.Unlock()
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(interaction.Response.Status)
+			w.Write(interaction.Response.Body)
+			return
+		}
+		mismatches = append(mismatches, fmt.Sprintf("  %q: %s", interaction.Description, strings.Join(diffs, "; ")))
+	}
+
+	mp.t.Errorf("%s: unexpected request %s %s %s\n%s",
+		mp.contract.Provider, r.Method, r.URL.RequestURI(), body, strings.Join(mismatches, "\n"))
+	http.Error(w, "no matching interaction", http.StatusInternalServerError)
+}
+
+// matchRequest compares a received request with a recorded one
+func matchRequest(expected Request, r *http.Request, body []byte) []string {
+	if r.Method != expected.Method || r.URL.Path != expected.Path {
+		return []string{fmt.Sprintf("expected %s %s", expected.Method, expected.Path)}
+	}
+	if r.URL.RawQuery != expected.Query {
+		return []string{fmt.Sprintf("expected query %q, got %q", expected.Query, r.URL.RawQuery)}
+	}
+	return matchBody(expected.Body, body, nil)
+}
@@ -0,0 +1,229 @@
+// Package contract implements consumer-driven contract tests between services.
+//
+// A consumer describes the requests it sends to a provider and the parts of
+// the responses it relies on. Its tests run the real client code against a
+// mock provider serving those interactions and write them to a contract file
+// under contracts/. Each provider loads the contract files naming it and
+// replays every interaction against its real router, so a provider change
+// that breaks a consumer fails the provider's go test.
+//
+// Bodies are compared by value: every field of the recorded body must be
+// present with the same value, extra fields are ignored. Response fields whose
+// value the provider cannot reproduce, such as timestamps, are listed in the
+// response's match_types and only need the same JSON type. Status codes must
+// match exactly.
+package contract
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"testing"
+)
+
+// UpdateEnv is the environment variable that makes consumer tests rewrite
+// their contract files instead of checking them
+const UpdateEnv = "UPDATE_CONTRACTS"
+
+// Contract is the set of interactions a consumer expects from a provider
+type Contract struct {
+	Consumer     string        `json:"consumer"`
+	Provider     string        `json:"provider"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a single request and the response the consumer expects
+type Interaction struct {
+	Description string `json:"description"`
+	// State names the data the provider must hold for the interaction,
+	// e.g. "product 1 exists"
+	State    string   `json:"provider_state,omitempty"`
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is the HTTP request sent by the consumer
+type Request struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Query  string          `json:"query,omitempty"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// Response is the HTTP response the consumer relies on
+type Response struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body,omitempty"`
+	// MatchTypes lists the fields of Body that only need to have the same
+	// JSON type, e.g. "$.created_at" or "$[*].updated_at"
+	MatchTypes []string `json:"match_types,omitempty"`
+}
+
+// FileName returns the name of the contract file between consumer and provider
+func FileName(consumer, provider string) string {
+	return consumer + "-" + provider + ".json"
+}
+
+// Body encodes v as a JSON body for a request or response
+func Body(v interface{}) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("contract: encode body: %v", err))
+	}
+	return data
+}
+
+// Record compares the contract with its file in dir and fails the test when
+// they differ. With UPDATE_CONTRACTS=true the file is rewritten instead.
+func Record(t *testing.T, c Contract, dir string) {
+	t.Helper()
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		t.Fatalf("encode contract: %v", err)
+	}
+	data = append(data, '\n')
+
+	path := filepath.Join(dir, FileName(c.Consumer, c.Provider))
+	if os.Getenv(UpdateEnv) == "true" {
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatalf("write contract: %v", err)
+		}
+		return
+	}
+
+	existing, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read contract: %v (run the tests with %s=true to create it)", err, UpdateEnv)
+	}
+	if !bytes.Equal(existing, data) {
+		t.Fatalf("contract %s is out of date, run the tests with %s=true to update it", path, UpdateEnv)
+	}
+}
+
+// Load reads every contract in dir whose provider is the given service
+func Load(t *testing.T, dir, provider string) []Contract {
+	t.Helper()
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*-"+provider+".json"))
+	if err != nil {
+		t.Fatalf("list contracts: %v", err)
+	}
+	sort.Strings(paths)
+
+	var contracts []Contract
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("read contract: %v", err)
+		}
+		var c Contract
+		if err := json.Unmarshal(data, &c); err != nil {
+			t.Fatalf("decode contract %s: %v", path, err)
+		}
+		if c.Provider == provider {
+			contracts = append(contracts, c)
+		}
+	}
+	if len(contracts) == 0 {
+		t.Fatalf("no contracts found for %s in %s", provider, dir)
+	}
+	return contracts
+}
+
+// match compares actual with expected and describes every difference. Leaf
+// values must be equal unless their path is one of typeOnly.
+func match(path string, expected, actual interface{}, typeOnly map[string]bool) []string {
+	switch want := expected.(type) {
+	case map[string]interface{}:
+		got, ok := actual.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected an object, got %s", path, jsonType(actual))}
+		}
+		keys := make([]string, 0, len(want))
+		for key := range want {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var diffs []string
+		for _, key := range keys {
+			value, ok := got[key]
+			if !ok {
+				diffs = append(diffs, fmt.Sprintf("%s.%s: missing", path, key))
+				continue
+			}
+			diffs = append(diffs, match(path+"."+key, want[key], value, typeOnly)...)
+		}
+		return diffs
+	case []interface{}:
+		got, ok := actual.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected an array, got %s", path, jsonType(actual))}
+		}
+		if len(got) < len(want) {
+			return []string{fmt.Sprintf("%s: expected at least %d elements, got %d", path, len(want), len(got))}
+		}
+		var diffs []string
+		for i := range want {
+			diffs = append(diffs, match(fmt.Sprintf("%s[%d]", path, i), want[i], got[i], typeOnly)...)
+		}
+		return diffs
+	default:
+		if jsonType(expected) != jsonType(actual) {
+			return []string{fmt.Sprintf("%s: expected %s, got %s", path, jsonType(expected), jsonType(actual))}
+		}
+		if !typeOnly[arrayIndex.ReplaceAllString(path, "[*]")] && expected != actual {
+			return []string{fmt.Sprintf("%s: expected %v, got %v", path, expected, actual)}
+		}
+		return nil
+	}
+}
+
+// arrayIndex matches the array indexes of a path so it can be compared with
+// the wildcard paths of MatchTypes
+var arrayIndex = regexp.MustCompile(`\[\d+\]`)
+
+// matchBody decodes both bodies and compares them with match
+func matchBody(expected, actual []byte, matchTypes []string) []string {
+	if len(expected) == 0 {
+		return nil
+	}
+
+	var want, got interface{}
+	if err := json.Unmarshal(expected, &want); err != nil {
+		return []string{fmt.Sprintf("invalid expected body: %v", err)}
+	}
+	if err := json.Unmarshal(actual, &got); err != nil {
+		return []string{fmt.Sprintf("body is not JSON: %s", actual)}
+	}
+	typeOnly := make(map[string]bool, len(matchTypes))
+	for _, path := range matchTypes {
+		typeOnly[path] = true
+	}
+	return match("$", want, got, typeOnly)
+}
+
+// jsonType names the JSON type of a decoded value
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
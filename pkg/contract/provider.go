@@ -0,0 +1,46 @@
+package contract
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Setup prepares a provider for an interaction's state and returns the
+// handler serving the interaction's request
+type Setup func(t *testing.T, state string) http.Handler
+
+// Verify replays every interaction of the contracts against the handler
+// returned by setup and fails when a response differs from the recorded one
+func Verify(t *testing.T, contracts []Contract, setup Setup) {
+	t.Helper()
+
+	for _, c := range contracts {
+		for _, interaction := range c.Interactions {
+			interaction := interaction
+			t.Run(c.Consumer+"/"+interaction.Description, func(t *testing.T) {
+				handler := setup(t, interaction.State)
+
+				target := interaction.Request.Path
+				if interaction.Request.Query != "" {
+					target += "?" + interaction.Request.Query
+				}
+				req := httptest.NewRequest(interaction.Request.Method, target, bytes.NewReader(interaction.Request.Body))
+				if len(interaction.Request.Body) > 0 {
+					req.Header.Set("Content-Type", "application/json")
+				}
+
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, req)
+
+				if w.Code != interaction.Response.Status {
+					t.Fatalf("expected status %d, got %d: %s", interaction.Response.Status, w.Code, w.Body.String())
+				}
+				for _, diff := range matchBody(interaction.Response.Body, w.Body.Bytes(), interaction.Response.MatchTypes) {
+					t.Error(diff)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,241 @@
+// Package envelope encrypts sensitive values at rest with envelope
+// encryption: each value is sealed with its own random data key, and the
+// data key is sealed with a key-encryption key of a Keyring. Rotating the
+// key-encryption key only re-seals the data keys, with Rewrap.
+//
+// Ciphertexts are text, so they fit the columns they replace:
+//
+//	enc:v1:<key ID>:<sealed data key>:<sealed value>
+//
+// both sealed parts being base64 encoded AES-256-GCM nonces and ciphertexts.
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Prefix starts every ciphertext
+const Prefix = "enc:v1:"
+
+// KeySize is the size of key-encryption and data keys, for AES-256
+const KeySize = 32
+
+var (
+	// ErrUnknownKey is returned when decrypting a value sealed with a key
+	// that is not in the keyring
+	ErrUnknownKey = errors.New("unknown encryption key")
+	// ErrMalformed is returned when decrypting a value that is not a
+	// ciphertext, or was tampered with
+	ErrMalformed = errors.New("malformed ciphertext")
+)
+
+var encoding = base64.RawURLEncoding
+
+// Keyring holds key-encryption keys by ID. Values are encrypted with the
+// active key and can be decrypted with any key of the ring, so keys are
+// rotated by adding a new active key and keeping the old ones until every
+// value was rewrapped.
+type Keyring struct {
+	active string
+	keys   map[string]cipher.AEAD
+}
+
+// NewKeyring creates a keyring of AES-256 keys by ID that encrypts with the
+// active key. IDs cannot contain colons or commas.
+func NewKeyring(keys map[string][]byte, active string) (*Keyring, error) {
+	if _, ok := keys[active]; !ok {
+		return nil, fmt.Errorf("%w: active key %q", ErrUnknownKey, active)
+	}
+
+	ring := &Keyring{active: active, keys: make(map[string]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		if id == "" || strings.ContainsAny(id, ":,") {
+			return nil, fmt.Errorf("invalid encryption key ID %q", id)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %q: %w", id, err)
+		}
+		ring.keys[id] = aead
+	}
+	return ring, nil
+}
+
+// ParseKeyring reads a keyring written as comma-separated ID:key pairs, the
+// keys being base64 encoded, such as "2024-06:q83v...,2024-01:x1Fz...". The
+// first key is active.
+func ParseKeyring(spec string) (*Keyring, error) {
+	keys := make(map[string][]byte)
+	var active string
+	for _, pair := range strings.Split(spec, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok {
+			return nil, fmt.Errorf("encryption key %q is not written as ID:key", pair)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %q is not base64: %w", id, err)
+		}
+		if _, ok := keys[id]; ok {
+			return nil, fmt.Errorf("encryption key %q is listed twice", id)
+		}
+		keys[id] = key
+		if active == "" {
+			active = id
+		}
+	}
+	return NewKeyring(keys, active)
+}
+
+// KeyringFromEnv reads the keyring from ENCRYPTION_KEYS, written as for
+// ParseKeyring. It returns nil without an error when ENCRYPTION_KEYS is
+// not set.
+func KeyringFromEnv() (*Keyring, error) {
+	spec := os.Getenv("ENCRYPTION_KEYS")
+	if spec == "" {
+		return nil, nil
+	}
+	return ParseKeyring(spec)
+}
+
+// ActiveKeyID returns the ID of the key new values are encrypted with
+func (k *Keyring) ActiveKeyID() string {
+	return k.active
+}
+
+// Encrypt seals plaintext with a new data key. The context, such as the
+// table and column of the value, must be given again to decrypt it, so a
+// ciphertext cannot be moved to another column.
+func (k *Keyring) Encrypt(plaintext []byte, context string) (string, error) {
+	dataKey := make([]byte, KeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	sealedValue, err := seal(data, plaintext, []byte(context))
+	if err != nil {
+		return "", err
+	}
+	sealedKey, err := seal(k.keys[k.active], dataKey, []byte(k.active))
+	if err != nil {
+		return "", err
+	}
+	return Prefix + k.active + ":" + sealedKey + ":" + sealedValue, nil
+}
+
+// Decrypt opens a ciphertext made by Encrypt with the same context
+func (k *Keyring) Decrypt(ciphertext, context string) ([]byte, error) {
+	keyID, sealedKey, sealedValue, err := split(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := k.openDataKey(keyID, sealedKey)
+	if err != nil {
+		return nil, err
+	}
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return open(data, sealedValue, []byte(context))
+}
+
+// Rewrap re-seals the data key of a ciphertext with the active key,
+// leaving the sealed value as is. Ciphertexts already sealed with the
+// active key are returned unchanged.
+func (k *Keyring) Rewrap(ciphertext string) (string, error) {
+	keyID, sealedKey, sealedValue, err := split(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	if keyID == k.active {
+		return ciphertext, nil
+	}
+	dataKey, err := k.openDataKey(keyID, sealedKey)
+	if err != nil {
+		return "", err
+	}
+	resealed, err := seal(k.keys[k.active], dataKey, []byte(k.active))
+	if err != nil {
+		return "", err
+	}
+	return Prefix + k.active + ":" + resealed + ":" + sealedValue, nil
+}
+
+// IsEncrypted reports whether a value is a ciphertext rather than a value
+// stored before encryption was enabled
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+// KeyID returns the ID of the key a ciphertext was sealed with
+func KeyID(ciphertext string) (string, error) {
+	keyID, _, _, err := split(ciphertext)
+	return keyID, err
+}
+
+// openDataKey opens a data key sealed with a key of the ring
+func (k *Keyring) openDataKey(keyID, sealedKey string) ([]byte, error) {
+	kek, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, keyID)
+	}
+	return open(kek, sealedKey, []byte(keyID))
+}
+
+// split returns the parts of a ciphertext
+func split(ciphertext string) (keyID, sealedKey, sealedValue string, err error) {
+	if !IsEncrypted(ciphertext) {
+		return "", "", "", ErrMalformed
+	}
+	parts := strings.Split(strings.TrimPrefix(ciphertext, Prefix), ":")
+	if len(parts) != 3 {
+		return "", "", "", ErrMalformed
+	}
+	return parts[0], parts[1], parts[2], nil
+}
+
+// newAEAD returns AES-256-GCM with key
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext under a random nonce, returning both encoded
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(aead.Seal(nonce, nonce, plaintext, additionalData)), nil
+}
+
+// open decrypts a value encoded by seal
+func open(aead cipher.AEAD, sealed string, additionalData []byte) ([]byte, error) {
+	raw, err := encoding.DecodeString(sealed)
+	if err != nil || len(raw) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+	plaintext, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], additionalData)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	return plaintext, nil
+}
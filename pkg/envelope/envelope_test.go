@@ -0,0 +1,102 @@
+package envelope_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"go-microservices/pkg/envelope"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testKey returns a key of repeated b, base64 encoded
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, envelope.KeySize))
+}
+
+func TestEnvelope_RoundTrip(t *testing.T) {
+	ring, err := envelope.ParseKeyring("k1:" + testKey(1))
+	require.NoError(t, err)
+
+	ciphertext, err := ring.Encrypt([]byte("card"), "payments.payment_method")
+	require.NoError(t, err)
+	assert.True(t, envelope.IsEncrypted(ciphertext))
+	assert.NotContains(t, ciphertext, "card")
+	keyID, err := envelope.KeyID(ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, "k1", keyID)
+
+	again, err := ring.Encrypt([]byte("card"), "payments.payment_method")
+	require.NoError(t, err)
+	assert.NotEqual(t, ciphertext, again, "each value has its own data key and nonce")
+
+	plaintext, err := ring.Decrypt(ciphertext, "payments.payment_method")
+	require.NoError(t, err)
+	assert.Equal(t, "card", string(plaintext))
+
+	_, err = ring.Decrypt(ciphertext, "refunds.reason")
+	assert.ErrorIs(t, err, envelope.ErrMalformed, "a ciphertext cannot be moved to another column")
+	// The last character may only hold padding bits, change one before it
+	tampered := []byte(ciphertext)
+	if i := len(tampered) - 8; tampered[i] == 'A' {
+		tampered[i] = 'B'
+	} else {
+		tampered[i] = 'A'
+	}
+	_, err = ring.Decrypt(string(tampered), "payments.payment_method")
+	assert.ErrorIs(t, err, envelope.ErrMalformed)
+	_, err = ring.Decrypt("card", "payments.payment_method")
+	assert.ErrorIs(t, err, envelope.ErrMalformed)
+	assert.False(t, envelope.IsEncrypted("card"))
+}
+
+func TestEnvelope_Rotation(t *testing.T) {
+	old, err := envelope.ParseKeyring("k1:" + testKey(1))
+	require.NoError(t, err)
+	ciphertext, err := old.Encrypt([]byte("card"), "ctx")
+	require.NoError(t, err)
+
+	ring, err := envelope.ParseKeyring("k2:" + testKey(2) + ", k1:" + testKey(1))
+	require.NoError(t, err)
+	assert.Equal(t, "k2", ring.ActiveKeyID(), "the first key is active")
+
+	plaintext, err := ring.Decrypt(ciphertext, "ctx")
+	require.NoError(t, err, "values sealed with an old key can still be read")
+	assert.Equal(t, "card", string(plaintext))
+
+	rewrapped, err := ring.Rewrap(ciphertext)
+	require.NoError(t, err)
+	keyID, _ := envelope.KeyID(rewrapped)
+	assert.Equal(t, "k2", keyID)
+	unchanged, err := ring.Rewrap(rewrapped)
+	require.NoError(t, err)
+	assert.Equal(t, rewrapped, unchanged)
+
+	// Once every value is rewrapped the old key can be dropped
+	current, err := envelope.ParseKeyring("k2:" + testKey(2))
+	require.NoError(t, err)
+	plaintext, err = current.Decrypt(rewrapped, "ctx")
+	require.NoError(t, err)
+	assert.Equal(t, "card", string(plaintext))
+	_, err = current.Decrypt(ciphertext, "ctx")
+	assert.ErrorIs(t, err, envelope.ErrUnknownKey)
+}
+
+func TestEnvelope_ParseKeyringErrors(t *testing.T) {
+	for _, spec := range []string{
+		"k1",
+		"k1:not base64!",
+		"k1:" + base64.StdEncoding.EncodeToString([]byte("short")),
+		"k1:" + testKey(1) + ",k1:" + testKey(2),
+	} {
+		_, err := envelope.ParseKeyring(spec)
+		assert.Error(t, err, spec)
+	}
+
+	t.Setenv("ENCRYPTION_KEYS", "")
+	ring, err := envelope.KeyringFromEnv()
+	assert.NoError(t, err)
+	assert.Nil(t, ring)
+}
@@ -0,0 +1,258 @@
+// Package events defines the events services publish to the message broker
+// and the envelope they travel in.
+//
+// Every message body is an Envelope: the metadata every consumer needs
+// (id, type, version, occurred_at, source, correlation_id) and the event
+// itself as payload. Consumers decode the envelope, look up the payload type
+// by type and version in a Registry and get a typed event back.
+//
+// Versioning rules:
+//
+//   - Adding an optional field to a payload is backward compatible and
+//     keeps the version. Consumers ignore fields they do not know.
+//   - Removing, renaming or retyping a field, or changing its meaning,
+//     bumps the version. The registry keeps an upgrade from the previous
+//     version so consumers accept events from producers not yet upgraded.
+//   - A consumer rejects events with a version newer than it knows with
+//     ErrUnsupportedVersion, so they are retried or dead-lettered instead
+//     of being misread. Deploy consumers before producers.
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go-microservices/pkg/money"
+)
+
+var (
+	// ErrUnknownType is returned when decoding an event type that is not registered
+	ErrUnknownType = errors.New("unknown event type")
+	// ErrUnsupportedVersion is returned when decoding an event version newer
+	// than the registered one, or older with no upgrade
+	ErrUnsupportedVersion = errors.New("unsupported event version")
+)
+
+// Event is implemented by every event payload
+type Event interface {
+	// EventType is the type of the event, also used as its routing key
+	EventType() string
+	// EventVersion is the version of the payload's schema
+	EventVersion() int
+}
+
+// Envelope is the body of every message published to the broker
+type Envelope struct {
+	ID            string          `json:"id"`
+	Type          string          `json:"type"`
+	Version       int             `json:"version"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	Source        string          `json:"source"`
+	CorrelationID string          `json:"correlation_id,omitempty"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// correlationKey is the context key of the correlation ID
+type correlationKey struct{}
+
+// WithCorrelationID returns a context carrying the ID tying the events
+// published under it to the request or event that caused them
+func WithCorrelationID(ctx context.Context, correlationID string) context.Context {
+	if correlationID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, correlationKey{}, correlationID)
+}
+
+// CorrelationID returns the correlation ID carried by a context, empty if
+// it carries none
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+// New wraps an event in an envelope with a new ID. Without a correlation
+// ID the event starts a new chain and its own ID is used.
+func New(source, correlationID string, event Event) (Envelope, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("failed to encode %s payload: %w", event.EventType(), err)
+	}
+
+	id := newID()
+	if correlationID == "" {
+		correlationID = id
+	}
+
+	return Envelope{
+		ID:            id,
+		Type:          event.EventType(),
+		Version:       event.EventVersion(),
+		OccurredAt:    time.Now().UTC(),
+		Source:        source,
+		CorrelationID: correlationID,
+		Payload:       payload,
+	}, nil
+}
+
+// Upgrade converts the payload of one version into the payload of the next
+type Upgrade func(payload json.RawMessage) (json.RawMessage, error)
+
+// upgradeAmounts returns the upgrade of payloads that had amounts as
+// numbers in major units next to a "currency" field into payloads with a
+// money.Money for each amount field. Payloads without a currency are read
+// in defaultCurrency; without one either, their amounts become null.
+func upgradeAmounts(defaultCurrency string, fields ...string) Upgrade {
+	return func(payload json.RawMessage) (json.RawMessage, error) {
+		var v map[string]json.RawMessage
+		if err := json.Unmarshal(payload, &v); err != nil {
+			return nil, err
+		}
+		currency := defaultCurrency
+		if raw, ok := v["currency"]; ok {
+			var code string
+			if err := json.Unmarshal(raw, &code); err != nil {
+				return nil, fmt.Errorf("currency: %w", err)
+			}
+			if code != "" {
+				currency = code
+			}
+		}
+		delete(v, "currency")
+
+		for _, field := range fields {
+			var amount json.Number
+			if raw, ok := v[field]; ok {
+				if err := json.Unmarshal(raw, &amount); err != nil {
+					return nil, fmt.Errorf("%s: %w", field, err)
+				}
+			}
+			var m money.Money
+			if currency != "" && amount != "" {
+				var err error
+				if m, err = money.Parse(amount.String(), currency); errors.Is(err, money.ErrInvalidAmount) {
+					// More decimals than the currency has, from a float64
+					// that was not a decimal amount
+					var f float64
+					if f, err = amount.Float64(); err == nil {
+						m, err = money.FromMajor(f, currency)
+					}
+				}
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", field, err)
+				}
+			}
+			raw, err := json.Marshal(m)
+			if err != nil {
+				return nil, err
+			}
+			v[field] = raw
+		}
+		return json.Marshal(v)
+	}
+}
+
+// registration is a registered event type
+type registration struct {
+	latest   int
+	factory  func() Event
+	upgrades map[int]Upgrade
+}
+
+// Registry maps event types to their payloads
+type Registry struct {
+	mu    sync.RWMutex
+	types map[string]*registration
+}
+
+// NewRegistry creates a registry without event types
+func NewRegistry() *Registry {
+	return &Registry{types: make(map[string]*registration)}
+}
+
+// Register adds an event type. factory returns a pointer to a new payload of
+// the latest version.
+func (r *Registry) Register(factory func() Event) {
+	event := factory()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.types[event.EventType()] = &registration{
+		latest:   event.EventVersion(),
+		factory:  factory,
+		upgrades: make(map[int]Upgrade),
+	}
+}
+
+// RegisterUpgrade adds the conversion of an event type's payload from
+// version from to version from+1
+func (r *Registry) RegisterUpgrade(eventType string, from int, upgrade Upgrade) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	reg, ok := r.types[eventType]
+	if !ok {
+		panic(fmt.Sprintf("events: upgrade registered for unknown type %q", eventType))
+	}
+	reg.upgrades[from] = upgrade
+}
+
+// Decode decodes an envelope and its payload, upgrading older versions. The
+// envelope is returned even when the payload cannot be decoded, so the
+// caller can log its ID.
+func (r *Registry) Decode(data []byte) (Envelope, Event, error) {
+	var env Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return env, nil, fmt.Errorf("failed to decode envelope: %w", err)
+	}
+
+	r.mu.RLock()
+	reg, ok := r.types[env.Type]
+	r.mu.RUnlock()
+	if !ok {
+		return env, nil, fmt.Errorf("%w: %q", ErrUnknownType, env.Type)
+	}
+	if env.Version > reg.latest {
+		return env, nil, fmt.Errorf("%w: %s v%d, latest known is v%d", ErrUnsupportedVersion, env.Type, env.Version, reg.latest)
+	}
+
+	payload := env.Payload
+	for version := env.Version; version < reg.latest; version++ {
+		upgrade, ok := reg.upgrades[version]
+		if !ok {
+			return env, nil, fmt.Errorf("%w: no upgrade of %s from v%d", ErrUnsupportedVersion, env.Type, version)
+		}
+		var err error
+		if payload, err = upgrade(payload); err != nil {
+			return env, nil, fmt.Errorf("failed to upgrade %s from v%d: %w", env.Type, version, err)
+		}
+	}
+
+	event := reg.factory()
+	if err := json.Unmarshal(payload, event); err != nil {
+		return env, nil, fmt.Errorf("failed to decode %s payload: %w", env.Type, err)
+	}
+	return env, event, nil
+}
+
+// DefaultRegistry holds every event type of this package
+var DefaultRegistry = NewRegistry()
+
+// Decode decodes an envelope and its payload with DefaultRegistry
+func Decode(data []byte) (Envelope, Event, error) {
+	return DefaultRegistry.Decode(data)
+}
+
+// newID returns a random event ID
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,163 @@
+package events_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"go-microservices/pkg/events"
+	"go-microservices/pkg/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventEnvelope_RoundTrip(t *testing.T) {
+	envelope, err := events.New("order-service", "req-1", &events.OrderCreated{OrderID: 1, CustomerID: 7, TotalPrice: money.MustNew(3998, "USD"), Status: "pending"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, envelope.ID)
+	assert.Equal(t, "req-1", envelope.CorrelationID)
+	assert.Equal(t, 2, envelope.Version)
+
+	body, err := json.Marshal(envelope)
+	require.NoError(t, err)
+
+	// The payload is an object inside the envelope, not a JSON string
+	var raw map[string]interface{}
+	require.NoError(t, json.Unmarshal(body, &raw))
+	assert.IsType(t, map[string]interface{}{}, raw["payload"])
+
+	decoded, event, err := events.Decode(body)
+	require.NoError(t, err)
+	assert.Equal(t, envelope.ID, decoded.ID)
+	assert.Equal(t, &events.OrderCreated{OrderID: 1, CustomerID: 7, TotalPrice: money.MustNew(3998, "USD"), Status: "pending"}, event)
+}
+
+func TestEventEnvelope_DefaultCorrelationID(t *testing.T) {
+	envelope, err := events.New("order-service", "", &events.OrderStatusChanged{OrderID: 1, Status: "shipped"})
+	require.NoError(t, err)
+	assert.Equal(t, envelope.ID, envelope.CorrelationID)
+}
+
+func TestCorrelationID_FromContext(t *testing.T) {
+	ctx := context.Background()
+	assert.Empty(t, events.CorrelationID(ctx))
+	assert.Equal(t, ctx, events.WithCorrelationID(ctx, ""), "an empty ID is not carried")
+	assert.Equal(t, "req-1", events.CorrelationID(events.WithCorrelationID(ctx, "req-1")))
+}
+
+// orderShippedV2 is a v2 payload whose v1 kept the tracking number under another name
+type orderShippedV2 struct {
+	OrderID        int    `json:"order_id"`
+	TrackingNumber string `json:"tracking_number"`
+}
+
+func (*orderShippedV2) EventType() string { return "order.shipped" }
+func (*orderShippedV2) EventVersion() int { return 2 }
+
+func TestRegistry_Versions(t *testing.T) {
+	registry := events.NewRegistry()
+	registry.Register(func() events.Event { return new(orderShippedV2) })
+	registry.RegisterUpgrade("order.shipped", 1, func(payload json.RawMessage) (json.RawMessage, error) {
+		var v1 struct {
+			OrderID  int    `json:"order_id"`
+			Tracking string `json:"tracking"`
+		}
+		if err := json.Unmarshal(payload, &v1); err != nil {
+			return nil, err
+		}
+		return json.Marshal(orderShippedV2{OrderID: v1.OrderID, TrackingNumber: v1.Tracking})
+	})
+
+	tests := []struct {
+		name    string
+		body    string
+		event   events.Event
+		errorIs error
+	}{
+		{
+			name:  "latest version",
+			body:  `{"id":"1","type":"order.shipped","version":2,"payload":{"order_id":1,"tracking_number":"TN1"}}`,
+			event: &orderShippedV2{OrderID: 1, TrackingNumber: "TN1"},
+		},
+		{
+			name:  "older version is upgraded",
+			body:  `{"id":"1","type":"order.shipped","version":1,"payload":{"order_id":1,"tracking":"TN1"}}`,
+			event: &orderShippedV2{OrderID: 1, TrackingNumber: "TN1"},
+		},
+		{
+			name:  "unknown fields are ignored",
+			body:  `{"id":"1","type":"order.shipped","version":2,"payload":{"order_id":1,"tracking_number":"TN1","carrier":"DHL"}}`,
+			event: &orderShippedV2{OrderID: 1, TrackingNumber: "TN1"},
+		},
+		{
+			name:    "newer version is rejected",
+			body:    `{"id":"1","type":"order.shipped","version":3,"payload":{}}`,
+			errorIs: events.ErrUnsupportedVersion,
+		},
+		{
+			name:    "version without upgrade is rejected",
+			body:    `{"id":"1","type":"order.shipped","version":0,"payload":{}}`,
+			errorIs: events.ErrUnsupportedVersion,
+		},
+		{
+			name:    "unknown type is rejected",
+			body:    `{"id":"1","type":"order.lost","version":1,"payload":{}}`,
+			errorIs: events.ErrUnknownType,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			envelope, event, err := registry.Decode([]byte(tt.body))
+			assert.Equal(t, "1", envelope.ID)
+			if tt.errorIs != nil {
+				assert.ErrorIs(t, err, tt.errorIs)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.event, event)
+		})
+	}
+}
+
+func TestDecode_UpgradesFloatAmounts(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		event events.Event
+	}{
+		{
+			name:  "order total in USD",
+			body:  `{"id":"1","type":"order.created","version":1,"payload":{"order_id":1,"total_price":39.98,"status":"pending"}}`,
+			event: &events.OrderCreated{OrderID: 1, TotalPrice: money.MustNew(3998, "USD"), Status: "pending"},
+		},
+		{
+			name:  "order total without decimals",
+			body:  `{"id":"1","type":"order.created","version":1,"payload":{"order_id":1,"total_price":6286,"currency":"JPY","status":"pending"}}`,
+			event: &events.OrderCreated{OrderID: 1, TotalPrice: money.MustNew(6286, "JPY"), Status: "pending"},
+		},
+		{
+			name: "refund with three decimals",
+			body: `{"id":"1","type":"payment.refunded","version":1,"payload":{"order_id":1,"amount":12.345,"amount_refunded":0.5,"currency":"kwd"}}`,
+			event: &events.PaymentRefunded{
+				PaymentOutcome: events.PaymentOutcome{OrderID: 1, Amount: money.MustNew(12345, "KWD")},
+				AmountRefunded: money.MustNew(500, "KWD"),
+			},
+		},
+		{
+			name:  "failure without an amount",
+			body:  `{"id":"1","type":"payment.failed","version":1,"payload":{"order_id":1,"amount":0,"currency":"","reason":"card declined"}}`,
+			event: &events.PaymentFailed{PaymentOutcome: events.PaymentOutcome{OrderID: 1, Reason: "card declined"}},
+		},
+	}
+	for _, tt := range tests {
+		_, event, err := events.Decode([]byte(tt.body))
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, tt.event, event, tt.name)
+	}
+
+	// The latest version writes amounts exactly, with their currency
+	envelope, err := events.New("payment-service", "", &events.PaymentSucceeded{PaymentOutcome: events.PaymentOutcome{OrderID: 1, Amount: money.MustNew(6286, "JPY")}})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"payment_id":0,"order_id":1,"customer_id":0,"amount":{"amount":6286,"currency":"JPY"}}`, string(envelope.Payload))
+}
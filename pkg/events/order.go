@@ -0,0 +1,51 @@
+package events
+
+import "go-microservices/pkg/money"
+
+// TopicOrders is the broker topic order events are published to
+const TopicOrders = "orders"
+
+// Order event types, also used as keys on the orders topic
+const (
+	TypeOrderCreated       = "order.created"
+	TypeOrderStatusChanged = "order.status_changed"
+)
+
+// OrderCreated is published by order-service when an order is placed
+type OrderCreated struct {
+	OrderID    int         `json:"order_id"`
+	CustomerID int         `json:"customer_id"`
+	ProductID  int         `json:"product_id"`
+	Quantity   int         `json:"quantity"`
+	TotalPrice money.Money `json:"total_price"`
+	Status     string      `json:"status"`
+}
+
+// EventType returns TypeOrderCreated
+func (*OrderCreated) EventType() string { return TypeOrderCreated }
+
+// EventVersion returns the version of the OrderCreated payload. Version 1
+// had the total price as a float64 in major units next to a currency,
+// which was USD when empty.
+func (*OrderCreated) EventVersion() int { return 2 }
+
+// OrderStatusChanged is published by order-service when an order's status
+// is updated
+type OrderStatusChanged struct {
+	OrderID        int    `json:"order_id"`
+	CustomerID     int    `json:"customer_id"`
+	PreviousStatus string `json:"previous_status"`
+	Status         string `json:"status"`
+}
+
+// EventType returns TypeOrderStatusChanged
+func (*OrderStatusChanged) EventType() string { return TypeOrderStatusChanged }
+
+// EventVersion returns the version of the OrderStatusChanged payload
+func (*OrderStatusChanged) EventVersion() int { return 1 }
+
+func init() {
+	DefaultRegistry.Register(func() Event { return new(OrderCreated) })
+	DefaultRegistry.RegisterUpgrade(TypeOrderCreated, 1, upgradeAmounts(money.DefaultCurrency, "total_price"))
+	DefaultRegistry.Register(func() Event { return new(OrderStatusChanged) })
+}
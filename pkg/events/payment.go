@@ -0,0 +1,111 @@
+package events
+
+import (
+	"time"
+
+	"go-microservices/pkg/money"
+)
+
+// TopicPayments is the broker topic payment events are published to
+const TopicPayments = "payments"
+
+// Payment event types, also used as keys on the payments topic
+const (
+	TypePaymentSucceeded = "payment.succeeded"
+	TypePaymentFailed    = "payment.failed"
+	TypePaymentCanceled  = "payment.canceled"
+	TypePaymentRefunded  = "payment.refunded"
+	// TypePaymentAuthorizationExpiring is not an outcome: the payment is
+	// still waiting to be captured
+	TypePaymentAuthorizationExpiring = "payment.authorization_expiring"
+)
+
+// PaymentOutcome is the payload shared by the events announcing how a
+// payment ended. Version 1 of the payment events had amounts as float64s
+// in major units next to a currency.
+type PaymentOutcome struct {
+	PaymentID  int `json:"payment_id"`
+	OrderID    int `json:"order_id"`
+	CustomerID int `json:"customer_id"`
+	// Amount is the amount of the payment, the captured amount once it was
+	// captured
+	Amount money.Money `json:"amount"`
+	// Reason explains why the payment did not succeed or was canceled
+	Reason string `json:"reason,omitempty"`
+}
+
+// PaymentSucceeded is published by payment-service when a payment is captured
+type PaymentSucceeded struct {
+	PaymentOutcome
+}
+
+// EventType returns TypePaymentSucceeded
+func (*PaymentSucceeded) EventType() string { return TypePaymentSucceeded }
+
+// EventVersion returns the version of the PaymentSucceeded payload
+func (*PaymentSucceeded) EventVersion() int { return 2 }
+
+// PaymentFailed is published by payment-service when a payment is declined
+type PaymentFailed struct {
+	PaymentOutcome
+}
+
+// EventType returns TypePaymentFailed
+func (*PaymentFailed) EventType() string { return TypePaymentFailed }
+
+// EventVersion returns the version of the PaymentFailed payload
+func (*PaymentFailed) EventVersion() int { return 2 }
+
+// PaymentCanceled is published by payment-service when a payment is
+// canceled before it was captured
+type PaymentCanceled struct {
+	PaymentOutcome
+}
+
+// EventType returns TypePaymentCanceled
+func (*PaymentCanceled) EventType() string { return TypePaymentCanceled }
+
+// EventVersion returns the version of the PaymentCanceled payload
+func (*PaymentCanceled) EventVersion() int { return 2 }
+
+// PaymentRefunded is published by payment-service when a captured payment
+// is refunded, in full or in part
+type PaymentRefunded struct {
+	PaymentOutcome
+	// AmountRefunded is the total refunded so far, equal to Amount once the
+	// payment is refunded in full
+	AmountRefunded money.Money `json:"amount_refunded"`
+}
+
+// EventType returns TypePaymentRefunded
+func (*PaymentRefunded) EventType() string { return TypePaymentRefunded }
+
+// EventVersion returns the version of the PaymentRefunded payload
+func (*PaymentRefunded) EventVersion() int { return 2 }
+
+// PaymentAuthorizationExpiring is published by payment-service when an
+// authorized payment was neither captured nor canceled and its
+// authorization is about to expire
+type PaymentAuthorizationExpiring struct {
+	PaymentOutcome
+	// ExpiresAt is when the provider cancels the authorization
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// EventType returns TypePaymentAuthorizationExpiring
+func (*PaymentAuthorizationExpiring) EventType() string { return TypePaymentAuthorizationExpiring }
+
+// EventVersion returns the version of the PaymentAuthorizationExpiring payload
+func (*PaymentAuthorizationExpiring) EventVersion() int { return 2 }
+
+func init() {
+	DefaultRegistry.Register(func() Event { return new(PaymentSucceeded) })
+	DefaultRegistry.Register(func() Event { return new(PaymentFailed) })
+	DefaultRegistry.Register(func() Event { return new(PaymentCanceled) })
+	DefaultRegistry.Register(func() Event { return new(PaymentRefunded) })
+	DefaultRegistry.Register(func() Event { return new(PaymentAuthorizationExpiring) })
+	for _, eventType := range []string{TypePaymentSucceeded, TypePaymentFailed, TypePaymentCanceled, TypePaymentAuthorizationExpiring} {
+		DefaultRegistry.RegisterUpgrade(eventType, 1, upgradeAmounts("", "amount"))
+	}
+	DefaultRegistry.RegisterUpgrade(TypePaymentRefunded, 1, upgradeAmounts("", "amount", "amount_refunded"))
+}
@@ -0,0 +1,121 @@
+// Package exchange converts money between currencies at exchange rates read
+// from a RateProvider. Rates are exact decimals, so an amount converted at a
+// rate recorded with Rate.Decimal can be converted again to the same result.
+package exchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"go-microservices/pkg/money"
+)
+
+// RateDecimals is the number of decimals rates are rounded to
+const RateDecimals = 10
+
+// ErrNoRate is returned by a RateProvider that has no rate between two
+// currencies
+var ErrNoRate = errors.New("no exchange rate")
+
+// RateProvider provides the current exchange rate between two currencies
+type RateProvider interface {
+	Rate(ctx context.Context, from, to string) (Rate, error)
+}
+
+// Rate is the price of one unit of From in units of To, as of AsOf
+type Rate struct {
+	From  string
+	To    string
+	Value *big.Rat
+	AsOf  time.Time
+}
+
+// Identity returns the rate of a currency to itself
+func Identity(currency string, asOf time.Time) Rate {
+	return Rate{From: currency, To: currency, Value: big.NewRat(1, 1), AsOf: asOf}
+}
+
+// ParseRate reads a rate written as a decimal, such as "0.9215", rounded to
+// RateDecimals decimals. Rates must be positive.
+func ParseRate(from, to, value string, asOf time.Time) (Rate, error) {
+	v, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+	if !ok || v.Sign() <= 0 {
+		return Rate{}, fmt.Errorf("invalid exchange rate %q from %s to %s", value, from, to)
+	}
+	return Rate{From: from, To: to, Value: roundRat(v, RateDecimals), AsOf: asOf}, nil
+}
+
+// Decimal returns the rate as a decimal without trailing zeros
+func (r Rate) Decimal() string {
+	s := r.Value.FloatString(RateDecimals)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// Convert converts an amount of From into To, rounding to the minor unit of
+// To, halves away from zero
+func (r Rate) Convert(m money.Money) (money.Money, error) {
+	if m.Currency() != r.From {
+		return money.Money{}, fmt.Errorf("%w: cannot convert %s at a rate from %s", money.ErrCurrencyMismatch, m, r.From)
+	}
+	to, err := money.LookupCurrency(r.To)
+	if err != nil {
+		return money.Money{}, err
+	}
+
+	// Minor units of To = minor units of From * rate * 10^(exponent of To - exponent of From)
+	v := new(big.Rat).Mul(new(big.Rat).SetInt64(m.Minor()), r.Value)
+	scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(to.Exponent-m.Exponent()))), nil))
+	if to.Exponent >= m.Exponent() {
+		v.Mul(v, scale)
+	} else {
+		v.Quo(v, scale)
+	}
+
+	minor := roundRat(v, 0).Num()
+	if !minor.IsInt64() {
+		return money.Money{}, fmt.Errorf("%w: %s in %s", money.ErrOverflow, m, r.To)
+	}
+	return money.New(minor.Int64(), r.To)
+}
+
+// ParseCurrencies reads a comma-separated list of ISO 4217 currency codes,
+// such as "USD,EUR,GBP", returning them upper case
+func ParseCurrencies(list string) ([]string, error) {
+	var currencies []string
+	for _, code := range strings.Split(list, ",") {
+		currency, err := money.LookupCurrency(code)
+		if err != nil {
+			return nil, err
+		}
+		currencies = append(currencies, currency.Code)
+	}
+	return currencies, nil
+}
+
+// roundRat rounds v to a number of decimals, halves away from zero
+func roundRat(v *big.Rat, decimals int) *big.Rat {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	scaled := new(big.Rat).Mul(v, new(big.Rat).SetInt(scale))
+
+	num := new(big.Int).Abs(scaled.Num())
+	quo, rem := new(big.Int).QuoRem(num, scaled.Denom(), new(big.Int))
+	if rem.Lsh(rem, 1).Cmp(scaled.Denom()) >= 0 {
+		quo.Add(quo, big.NewInt(1))
+	}
+	if scaled.Sign() < 0 {
+		quo.Neg(quo)
+	}
+	return new(big.Rat).SetFrac(quo, scale)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
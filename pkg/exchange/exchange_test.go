@@ -0,0 +1,140 @@
+package exchange_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-microservices/pkg/exchange"
+	"go-microservices/pkg/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ratesAsOf is when the rates of writeRates were published
+var ratesAsOf = time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)
+
+// writeRates writes a rates file with USD as base
+func writeRates(t *testing.T, path string, rates string) {
+	t.Helper()
+	data := `{"base": "USD", "as_of": "2024-06-03T00:00:00Z", "rates": ` + rates + `}`
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+}
+
+func TestRate_Convert(t *testing.T) {
+	tests := []struct {
+		amount money.Money
+		to     string
+		rate   string
+		minor  int64
+	}{
+		{money.MustNew(1999, "USD"), "EUR", "0.9215", 1842},    // 18.420785
+		{money.MustNew(1999, "USD"), "JPY", "157.23", 3143},    // 3143.0277
+		{money.MustNew(150, "USD"), "JPY", "157.1", 236},       // 235.65, halves away from zero
+		{money.MustNew(1999, "USD"), "KWD", "0.3067", 6131},    // 6.130933
+		{money.MustNew(3143, "JPY"), "USD", "0.0063601", 1999}, // 19.9897943
+		{money.MustNew(-1999, "USD"), "EUR", "0.9215", -1842},
+		{money.MustNew(0, "USD"), "EUR", "0.9215", 0},
+	}
+	for _, tt := range tests {
+		rate, err := exchange.ParseRate(tt.amount.Currency(), tt.to, tt.rate, ratesAsOf)
+		require.NoError(t, err)
+		converted, err := rate.Convert(tt.amount)
+		require.NoError(t, err, tt.amount.String())
+		assert.Equal(t, money.MustNew(tt.minor, tt.to), converted, tt.amount.String())
+	}
+
+	rate, _ := exchange.ParseRate("USD", "EUR", "0.9215", ratesAsOf)
+	_, err := rate.Convert(money.MustNew(1999, "GBP"))
+	assert.ErrorIs(t, err, money.ErrCurrencyMismatch)
+
+	rate, _ = exchange.ParseRate("USD", "VND", "25455", ratesAsOf)
+	_, err = rate.Convert(money.MustNew(1<<62, "USD"))
+	assert.ErrorIs(t, err, money.ErrOverflow)
+}
+
+func TestParseRate(t *testing.T) {
+	rate, err := exchange.ParseRate("USD", "EUR", "0.92150000000000001", ratesAsOf)
+	require.NoError(t, err)
+	assert.Equal(t, "0.9215", rate.Decimal(), "rounded to RateDecimals decimals")
+
+	rate, _ = exchange.ParseRate("USD", "JPY", "157", ratesAsOf)
+	assert.Equal(t, "157", rate.Decimal())
+
+	for _, value := range []string{"", "abc", "0", "-1.2"} {
+		_, err := exchange.ParseRate("USD", "EUR", value, ratesAsOf)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestParseCurrencies(t *testing.T) {
+	currencies, err := exchange.ParseCurrencies("usd, EUR,jpy")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"USD", "EUR", "JPY"}, currencies)
+
+	_, err = exchange.ParseCurrencies("USD,XYZ")
+	assert.ErrorIs(t, err, money.ErrUnknownCurrency)
+	_, err = exchange.ParseCurrencies("USD,,EUR")
+	assert.ErrorIs(t, err, money.ErrUnknownCurrency)
+}
+
+func TestFileProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	writeRates(t, path, `{"EUR": 0.9215, "GBP": 0.7835, "JPY": 157.23}`)
+
+	rates, err := exchange.NewFileProvider(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"EUR", "GBP", "JPY", "USD"}, rates.Currencies())
+
+	rate, err := rates.Rate(context.Background(), "USD", "EUR")
+	require.NoError(t, err)
+	assert.Equal(t, "0.9215", rate.Decimal())
+	assert.Equal(t, ratesAsOf, rate.AsOf)
+
+	// Rates between other currencies are crossed through the base
+	rate, err = rates.Rate(context.Background(), "EUR", "GBP")
+	require.NoError(t, err)
+	assert.Equal(t, "0.8502441671", rate.Decimal())
+
+	rate, err = rates.Rate(context.Background(), "EUR", "EUR")
+	require.NoError(t, err)
+	assert.Equal(t, "1", rate.Decimal())
+
+	_, err = rates.Rate(context.Background(), "USD", "CHF")
+	assert.ErrorIs(t, err, exchange.ErrNoRate)
+
+	// Changes are read again, and a broken change keeps the previous rates
+	writeRates(t, path, `{"EUR": 0.95}`)
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+	rate, err = rates.Rate(context.Background(), "USD", "EUR")
+	require.NoError(t, err)
+	assert.Equal(t, "0.95", rate.Decimal())
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"base": "USD", "rates": {"EUR": -1}}`), 0o644))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(2*time.Minute)))
+	rate, err = rates.Rate(context.Background(), "USD", "EUR")
+	require.NoError(t, err)
+	assert.Equal(t, "0.95", rate.Decimal())
+}
+
+func TestNewFileProvider_InvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	_, err := exchange.NewFileProvider(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+
+	for name, data := range map[string]string{
+		"syntax":   `{"base": "USD"`,
+		"base":     `{"base": "XYZ", "rates": {"EUR": 0.92}}`,
+		"currency": `{"base": "USD", "rates": {"XYZ": 1.5}}`,
+		"rate":     `{"base": "USD", "rates": {"EUR": 0}}`,
+		"identity": `{"base": "USD", "rates": {"USD": 1.1}}`,
+	} {
+		path := filepath.Join(dir, name+".json")
+		require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+		_, err := exchange.NewFileProvider(path)
+		assert.Error(t, err, name)
+	}
+}
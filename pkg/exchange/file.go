@@ -0,0 +1,155 @@
+package exchange
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"go-microservices/pkg/money"
+)
+
+// DefaultRatesFile is read by FileProviderFromEnv when EXCHANGE_RATES_FILE
+// is not set
+const DefaultRatesFile = "config/exchange-rates.json"
+
+// rateFile is the JSON form of a rates file: the price of one unit of Base
+// in each currency, as of AsOf. For example:
+//
+//	{"base": "USD", "as_of": "2024-06-03T00:00:00Z", "rates": {"EUR": 0.92, "JPY": 157.2}}
+type rateFile struct {
+	Base  string                 `json:"base"`
+	AsOf  time.Time              `json:"as_of"`
+	Rates map[string]json.Number `json:"rates"`
+}
+
+// rateTable is a loaded rates file, with a unit of the base currency in
+// every currency, the base included
+type rateTable struct {
+	asOf  time.Time
+	rates map[string]*big.Rat
+}
+
+// FileProvider provides the rates of a JSON file, rates between two
+// currencies other than the base being crossed through it. The file is
+// read again when it changes; a change that cannot be read is logged and
+// the rates read before are kept.
+type FileProvider struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	table   *rateTable
+}
+
+// NewFileProvider reads the rates of a file
+func NewFileProvider(path string) (*FileProvider, error) {
+	p := &FileProvider{path: path}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if p.table, err = readRates(path); err != nil {
+		return nil, err
+	}
+	p.modTime = info.ModTime()
+	return p, nil
+}
+
+// FileProviderFromEnv reads the rates of the file named by
+// EXCHANGE_RATES_FILE, or of DefaultRatesFile
+func FileProviderFromEnv() (*FileProvider, error) {
+	path := os.Getenv("EXCHANGE_RATES_FILE")
+	if path == "" {
+		path = DefaultRatesFile
+	}
+	return NewFileProvider(path)
+}
+
+// Rate returns the rate from one currency to another
+func (p *FileProvider) Rate(ctx context.Context, from, to string) (Rate, error) {
+	table := p.current()
+	if from == to {
+		return Identity(from, table.asOf), nil
+	}
+	fromRate, ok := table.rates[from]
+	if !ok {
+		return Rate{}, fmt.Errorf("%w from %s to %s", ErrNoRate, from, to)
+	}
+	toRate, ok := table.rates[to]
+	if !ok {
+		return Rate{}, fmt.Errorf("%w from %s to %s", ErrNoRate, from, to)
+	}
+	return Rate{From: from, To: to, Value: roundRat(new(big.Rat).Quo(toRate, fromRate), RateDecimals), AsOf: table.asOf}, nil
+}
+
+// Currencies returns the currencies the file has rates for, sorted
+func (p *FileProvider) Currencies() []string {
+	table := p.current()
+	currencies := make([]string, 0, len(table.rates))
+	for code := range table.rates {
+		currencies = append(currencies, code)
+	}
+	sort.Strings(currencies)
+	return currencies
+}
+
+// current returns the rates of the file, reading it again if it changed
+func (p *FileProvider) current() *rateTable {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	info, err := os.Stat(p.path)
+	if err != nil || info.ModTime().Equal(p.modTime) {
+		return p.table
+	}
+	p.modTime = info.ModTime()
+	table, err := readRates(p.path)
+	if err != nil {
+		log.Printf("Warning: Failed to reload exchange rates, keeping the previous ones: %v\n", err)
+		return p.table
+	}
+	p.table = table
+	return table
+}
+
+// readRates reads and validates a rates file
+func readRates(path string) (*rateTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var file rateFile
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("exchange rates file %s: %w", path, err)
+	}
+
+	base, err := money.LookupCurrency(file.Base)
+	if err != nil {
+		return nil, fmt.Errorf("exchange rates file %s: base: %w", path, err)
+	}
+	table := &rateTable{asOf: file.AsOf, rates: map[string]*big.Rat{base.Code: big.NewRat(1, 1)}}
+	for code, value := range file.Rates {
+		currency, err := money.LookupCurrency(code)
+		if err != nil {
+			return nil, fmt.Errorf("exchange rates file %s: %w", path, err)
+		}
+		rate, err := ParseRate(base.Code, currency.Code, value.String(), file.AsOf)
+		if err != nil {
+			return nil, fmt.Errorf("exchange rates file %s: %w", path, err)
+		}
+		if currency.Code == base.Code && rate.Value.Cmp(big.NewRat(1, 1)) != 0 {
+			return nil, fmt.Errorf("exchange rates file %s: the rate of the base currency %s must be 1", path, base.Code)
+		}
+		table.rates[currency.Code] = rate.Value
+	}
+	return table, nil
+}
@@ -0,0 +1,52 @@
+package money
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultCurrency is the currency of amounts stored before currencies were
+// recorded, such as product prices
+const DefaultCurrency = "USD"
+
+// Currency is an ISO 4217 currency
+type Currency struct {
+	// Code is the upper-case three-letter code, such as USD
+	Code string
+	// Exponent is the number of digits after the decimal point of the
+	// currency's minor unit: 2 for USD, 0 for JPY, 3 for KWD
+	Exponent int
+}
+
+// LookupCurrency returns the ISO 4217 currency with a code, in any case
+func LookupCurrency(code string) (Currency, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	exponent, ok := exponents[code]
+	if !ok {
+		return Currency{}, fmt.Errorf("%w: %q", ErrUnknownCurrency, code)
+	}
+	return Currency{Code: code, Exponent: exponent}, nil
+}
+
+// exponents maps the active ISO 4217 currency codes to the exponent of
+// their minor unit
+var exponents = func() map[string]int {
+	m := make(map[string]int)
+	for exponent, codes := range map[int]string{
+		0: "BIF CLP DJF GNF ISK JPY KMF KRW PYG RWF UGX UYI VND VUV XAF XOF XPF",
+		2: "AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BMD BND BOB BOV BRL BSD BTN BWP " +
+			"BYN BZD CAD CDF CHE CHF CHW CNY COP COU CRC CUC CUP CVE CZK DKK DOP DZD EGP ERN ETB " +
+			"EUR FJD FKP GBP GEL GHS GIP GMD GTQ GYD HKD HNL HTG HUF IDR ILS INR IRR JMD KES KGS " +
+			"KHR KPW KYD KZT LAK LBP LKR LRD LSL MAD MDL MGA MKD MMK MNT MOP MRU MUR MVR MWK MXN " +
+			"MXV MYR MZN NAD NGN NIO NOK NPR NZD PAB PEN PGK PHP PKR PLN QAR RON RSD RUB SAR SBD " +
+			"SCR SDG SEK SGD SHP SLE SOS SRD SSP STN SVC SYP SZL THB TJS TMT TOP TRY TTD TWD TZS " +
+			"UAH USD USN UYU UZS VES WST XCD YER ZAR ZMW ZWL",
+		3: "BHD IQD JOD KWD LYD OMR TND",
+		4: "CLF UYW",
+	} {
+		for _, code := range strings.Fields(codes) {
+			m[code] = exponent
+		}
+	}
+	return m
+}()
@@ -0,0 +1,264 @@
+// Package money represents amounts of money exactly, as an integer number
+// of minor units (cents for USD, yen for JPY) of an ISO 4217 currency.
+//
+// Amounts cross service boundaries as decimal numbers in major units, such
+// as 19.99 for 1999 cents. They are parsed and formatted from their decimal
+// text, never through float64, and parsing rejects amounts finer than the
+// currency's minor unit. Arithmetic refuses to mix currencies and reports
+// overflow instead of wrapping.
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrUnknownCurrency is returned for codes that are not ISO 4217
+	// currencies
+	ErrUnknownCurrency = errors.New("unknown currency")
+	// ErrCurrencyMismatch is returned when combining amounts of different
+	// currencies
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	// ErrInvalidAmount is returned for amounts that are not decimal numbers
+	// or are finer than the currency's minor unit
+	ErrInvalidAmount = errors.New("invalid amount")
+	// ErrOverflow is returned when an amount does not fit in 64 bits of
+	// minor units
+	ErrOverflow = errors.New("amount overflows")
+)
+
+// Money is an amount of a currency in minor units. The zero value is zero
+// of no particular currency: it adds to and compares with amounts of any
+// currency.
+type Money struct {
+	amount   int64
+	currency Currency
+}
+
+// New returns an amount of minor units of the currency with a code
+func New(minor int64, code string) (Money, error) {
+	currency, err := LookupCurrency(code)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{amount: minor, currency: currency}, nil
+}
+
+// MustNew is New for amounts and currencies known to be valid, such as
+// constants. It panics on an unknown currency.
+func MustNew(minor int64, code string) Money {
+	m, err := New(minor, code)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Parse reads a decimal amount in major units, such as "19.99" or "1e3",
+// of the currency with a code. Amounts with more decimals than the
+// currency's minor unit are rejected rather than rounded.
+func Parse(amount, code string) (Money, error) {
+	currency, err := LookupCurrency(code)
+	if err != nil {
+		return Money{}, err
+	}
+
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(amount))
+	if !ok {
+		return Money{}, fmt.Errorf("%w: %q is not a number", ErrInvalidAmount, amount)
+	}
+	r.Mul(r, new(big.Rat).SetInt(pow10(currency.Exponent)))
+	if !r.IsInt() {
+		return Money{}, fmt.Errorf("%w: %s has at most %d decimals", ErrInvalidAmount, currency.Code, currency.Exponent)
+	}
+	if !r.Num().IsInt64() {
+		return Money{}, fmt.Errorf("%w: %s", ErrOverflow, amount)
+	}
+	return Money{amount: r.Num().Int64(), currency: currency}, nil
+}
+
+// FromMajor converts an amount in major units held in a float64, rounding
+// it to the nearest minor unit. It is meant for values that were decimals
+// before they were stored in a float64, such as fields of generated API
+// clients; 19.99 becomes 1999 cents, not 1998.
+func FromMajor(amount float64, code string) (Money, error) {
+	currency, err := LookupCurrency(code)
+	if err != nil {
+		return Money{}, err
+	}
+	if math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return Money{}, fmt.Errorf("%w: %v", ErrInvalidAmount, amount)
+	}
+	minor := math.Round(amount * math.Pow10(currency.Exponent))
+	if minor >= math.MaxInt64 || minor < math.MinInt64 {
+		return Money{}, fmt.Errorf("%w: %v", ErrOverflow, amount)
+	}
+	return Money{amount: int64(minor), currency: currency}, nil
+}
+
+// Minor returns the amount in minor units
+func (m Money) Minor() int64 { return m.amount }
+
+// Currency returns the ISO 4217 code of the amount's currency
+func (m Money) Currency() string { return m.currency.Code }
+
+// Exponent returns the number of decimals of the currency's minor unit
+func (m Money) Exponent() int { return m.currency.Exponent }
+
+// IsZero reports whether the amount is zero
+func (m Money) IsZero() bool { return m.amount == 0 }
+
+// IsPositive reports whether the amount is greater than zero
+func (m Money) IsPositive() bool { return m.amount > 0 }
+
+// IsNegative reports whether the amount is less than zero
+func (m Money) IsNegative() bool { return m.amount < 0 }
+
+// Add returns m + other
+func (m Money) Add(other Money) (Money, error) {
+	if err := m.sameCurrency(other); err != nil {
+		return Money{}, err
+	}
+	sum := m.amount + other.amount
+	if (m.amount > 0 && other.amount > 0 && sum < 0) || (m.amount < 0 && other.amount < 0 && sum >= 0) {
+		return Money{}, fmt.Errorf("%w: %s + %s", ErrOverflow, m, other)
+	}
+	currency := m.currency
+	if currency.Code == "" {
+		currency = other.currency
+	}
+	return Money{amount: sum, currency: currency}, nil
+}
+
+// Sub returns m - other
+func (m Money) Sub(other Money) (Money, error) {
+	if other.amount == math.MinInt64 {
+		return Money{}, fmt.Errorf("%w: %s - %s", ErrOverflow, m, other)
+	}
+	return m.Add(Money{amount: -other.amount, currency: other.currency})
+}
+
+// Mul returns m multiplied by n, such as a unit price by a quantity
+func (m Money) Mul(n int64) (Money, error) {
+	if m.amount == 0 || n == 0 {
+		return Money{amount: 0, currency: m.currency}, nil
+	}
+	product := m.amount * n
+	if product/n != m.amount || (m.amount == -1 && n == math.MinInt64) || (n == -1 && m.amount == math.MinInt64) {
+		return Money{}, fmt.Errorf("%w: %s * %d", ErrOverflow, m, n)
+	}
+	return Money{amount: product, currency: m.currency}, nil
+}
+
+// Cmp compares m with other, returning -1, 0 or +1
+func (m Money) Cmp(other Money) (int, error) {
+	if err := m.sameCurrency(other); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.amount < other.amount:
+		return -1, nil
+	case m.amount > other.amount:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+// Float64 returns the amount in major units as a float64, for APIs that
+// take one. The result is the float64 nearest the decimal amount.
+func (m Money) Float64() float64 {
+	f, _ := strconv.ParseFloat(m.Decimal(), 64)
+	return f
+}
+
+// Decimal formats the amount in major units with the currency's decimals,
+// such as "19.99", "-0.05" or "1999" for JPY
+func (m Money) Decimal() string {
+	digits := strconv.FormatUint(abs(m.amount), 10)
+	if exponent := m.currency.Exponent; exponent > 0 {
+		if len(digits) <= exponent {
+			digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-exponent] + "." + digits[len(digits)-exponent:]
+	}
+	if m.amount < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// String formats the amount with its currency, such as "19.99 USD"
+func (m Money) String() string {
+	return m.Decimal() + " " + m.currency.Code
+}
+
+// Number returns the amount in major units as a JSON number
+func (m Money) Number() json.Number {
+	return json.Number(m.Decimal())
+}
+
+// jsonMoney is the JSON form of a Money
+type jsonMoney struct {
+	Amount   json.Number `json:"amount"`
+	Currency string      `json:"currency"`
+}
+
+// MarshalJSON encodes the amount as {"amount": 19.99, "currency": "USD"},
+// with the amount written exactly. The zero Money is encoded as null.
+func (m Money) MarshalJSON() ([]byte, error) {
+	if m.currency.Code == "" {
+		return []byte("null"), nil
+	}
+	return json.Marshal(jsonMoney{Amount: m.Number(), Currency: m.currency.Code})
+}
+
+// UnmarshalJSON decodes the form written by MarshalJSON. The amount may
+// also be a decimal string.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*m = Money{}
+		return nil
+	}
+	var v struct {
+		Amount   json.RawMessage `json:"amount"`
+		Currency string          `json:"currency"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	parsed, err := Parse(strings.Trim(string(v.Amount), `"`), v.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// sameCurrency returns ErrCurrencyMismatch unless other is of m's currency
+// or either is the zero Money
+func (m Money) sameCurrency(other Money) error {
+	if m.currency.Code != other.currency.Code && m.currency.Code != "" && other.currency.Code != "" {
+		return fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.currency.Code, other.currency.Code)
+	}
+	return nil
+}
+
+// abs returns the magnitude of n, which fits a uint64 even for MinInt64
+func abs(n int64) uint64 {
+	if n < 0 {
+		return uint64(-(n + 1)) + 1
+	}
+	return uint64(n)
+}
+
+// pow10 returns 10 to the power n
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
@@ -0,0 +1,119 @@
+package money_test
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"go-microservices/pkg/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoney_Parse(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		minor    int64
+		decimal  string
+	}{
+		{"19.99", "USD", 1999, "19.99"},
+		{"19.9", "usd", 1990, "19.90"},
+		{"1e2", "EUR", 10000, "100.00"},
+		{"-0.05", "USD", -5, "-0.05"},
+		{"1999", "JPY", 1999, "1999"},
+		{"1.005", "KWD", 1005, "1.005"},
+	}
+	for _, tt := range tests {
+		m, err := money.Parse(tt.amount, tt.currency)
+		require.NoError(t, err, tt.amount)
+		assert.Equal(t, tt.minor, m.Minor(), tt.amount)
+		assert.Equal(t, tt.decimal, m.Decimal(), tt.amount)
+	}
+
+	m, _ := money.Parse("19.99", "usd")
+	assert.Equal(t, "USD", m.Currency())
+	assert.Equal(t, "19.99 USD", m.String())
+
+	_, err := money.Parse("19.999", "USD")
+	assert.ErrorIs(t, err, money.ErrInvalidAmount, "amounts finer than the minor unit are not rounded")
+	_, err = money.Parse("19.5", "JPY")
+	assert.ErrorIs(t, err, money.ErrInvalidAmount)
+	_, err = money.Parse("abc", "USD")
+	assert.ErrorIs(t, err, money.ErrInvalidAmount)
+	_, err = money.Parse("1", "XYZ")
+	assert.ErrorIs(t, err, money.ErrUnknownCurrency)
+	_, err = money.Parse("1e30", "USD")
+	assert.ErrorIs(t, err, money.ErrOverflow)
+}
+
+func TestMoney_FromMajorRounds(t *testing.T) {
+	// int64(19.99 * 100) is 1998
+	m, err := money.FromMajor(19.99, "USD")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1999), m.Minor())
+	assert.Equal(t, 19.99, m.Float64())
+
+	m, err = money.FromMajor(1999, "JPY")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1999), m.Minor())
+
+	_, err = money.FromMajor(math.NaN(), "USD")
+	assert.ErrorIs(t, err, money.ErrInvalidAmount)
+}
+
+func TestMoney_Arithmetic(t *testing.T) {
+	price := money.MustNew(1999, "USD")
+
+	total, err := price.Mul(2)
+	require.NoError(t, err)
+	assert.Equal(t, money.MustNew(3998, "USD"), total)
+
+	sum, err := total.Add(money.MustNew(2, "USD"))
+	require.NoError(t, err)
+	assert.Equal(t, "40.00", sum.Decimal())
+
+	rest, err := total.Sub(money.MustNew(1099, "USD"))
+	require.NoError(t, err)
+	assert.Equal(t, "28.99", rest.Decimal())
+
+	cmp, err := rest.Cmp(price)
+	require.NoError(t, err)
+	assert.Equal(t, 1, cmp)
+
+	// The zero Money is zero of any currency
+	sum, err = money.Money{}.Add(price)
+	require.NoError(t, err)
+	assert.Equal(t, price, sum)
+
+	_, err = price.Add(money.MustNew(1999, "EUR"))
+	assert.ErrorIs(t, err, money.ErrCurrencyMismatch)
+	_, err = price.Cmp(money.MustNew(1999, "JPY"))
+	assert.ErrorIs(t, err, money.ErrCurrencyMismatch)
+
+	_, err = money.MustNew(math.MaxInt64, "USD").Add(money.MustNew(1, "USD"))
+	assert.ErrorIs(t, err, money.ErrOverflow)
+	_, err = money.MustNew(math.MinInt64, "USD").Sub(money.MustNew(1, "USD"))
+	assert.ErrorIs(t, err, money.ErrOverflow)
+	_, err = money.MustNew(math.MaxInt64/2+1, "USD").Mul(2)
+	assert.ErrorIs(t, err, money.ErrOverflow)
+}
+
+func TestMoney_JSON(t *testing.T) {
+	data, err := json.Marshal(money.MustNew(1999, "USD"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"amount": 19.99, "currency": "USD"}`, string(data))
+
+	data, err = json.Marshal(money.MustNew(-5, "KWD"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"amount": -0.005, "currency": "KWD"}`, string(data))
+
+	var m money.Money
+	require.NoError(t, json.Unmarshal([]byte(`{"amount": 1999, "currency": "JPY"}`), &m))
+	assert.Equal(t, money.MustNew(1999, "JPY"), m)
+	require.NoError(t, json.Unmarshal([]byte(`{"amount": "0.10", "currency": "usd"}`), &m))
+	assert.Equal(t, money.MustNew(10, "USD"), m)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"amount": 0.001, "currency": "USD"}`), &m))
+}
@@ -0,0 +1,169 @@
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// DefaultConfirmTimeout is how long a confirmed publish waits for the
+// broker when Config.ConfirmTimeout is not set
+const DefaultConfirmTimeout = 5 * time.Second
+
+var (
+	// ErrUnroutable is returned for a confirmed message no queue is bound for
+	ErrUnroutable = errors.New("message is unroutable")
+	// ErrNacked is returned for a confirmed message the broker failed to store
+	ErrNacked = errors.New("message was nacked by the broker")
+	// ErrConfirmTimeout is returned when the broker does not confirm a
+	// message in time. The message may or may not have been stored.
+	ErrConfirmTimeout = errors.New("timed out waiting for publisher confirm")
+)
+
+// PublishError describes a confirmed publish the broker did not accept. Err
+// is ErrUnroutable, ErrNacked or ErrConfirmTimeout, so callers can tell with
+// errors.Is whether retrying makes sense.
+type PublishError struct {
+	Exchange   string
+	RoutingKey string
+	MessageID  string
+	// ReplyCode and ReplyText are the reason given by the broker for an
+	// unroutable message, e.g. 312 NO_ROUTE
+	ReplyCode uint16
+	ReplyText string
+	Err       error
+}
+
+func (e *PublishError) Error() string {
+	msg := fmt.Sprintf("failed to publish message %s to exchange %q with routing key %q: %v", e.MessageID, e.Exchange, e.RoutingKey, e.Err)
+	if e.ReplyText != "" {
+		msg += fmt.Sprintf(" (%d %s)", e.ReplyCode, e.ReplyText)
+	}
+	return msg
+}
+
+func (e *PublishError) Unwrap() error {
+	return e.Err
+}
+
+// confirmChannel is a channel in confirm mode and the returns it receives
+type confirmChannel struct {
+	conn    *amqp.Connection
+	channel *amqp.Channel
+	returns chan amqp.Return
+}
+
+// publishConfirmed publishes a persistent, mandatory message and waits for
+// the broker to confirm it. Confirmed publishes are serialized on a channel
+// of their own so a basic.return can be matched to its message.
+func (c *Connection) publishConfirmed(config Config, publishing amqp.Publishing) error {
+	c.confirmMu.Lock()
+	defer c.confirmMu.Unlock()
+
+	cc, err := c.confirmChannel()
+	if err != nil {
+		return err
+	}
+
+	// Returns of earlier messages that timed out are no longer of interest
+	for len(cc.returns) > 0 {
+		<-cc.returns
+	}
+
+	if publishing.MessageId == "" {
+		publishing.MessageId = messageID()
+	}
+	publishing.DeliveryMode = amqp.Persistent
+	publishErr := &PublishError{
+		Exchange:   config.ExchangeName,
+		RoutingKey: config.RoutingKey,
+		MessageID:  publishing.MessageId,
+	}
+
+	timeout := config.ConfirmTimeout
+	if timeout <= 0 {
+		timeout = DefaultConfirmTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	confirmation, err := cc.channel.PublishWithDeferredConfirmWithContext(
+		ctx,
+		config.ExchangeName,
+		config.RoutingKey,
+		true,  // mandatory
+		false, // immediate
+		publishing,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+
+	acked, err := confirmation.WaitContext(ctx)
+	switch {
+	case err != nil:
+		publishErr.Err = ErrConfirmTimeout
+		return publishErr
+	case !acked:
+		publishErr.Err = ErrNacked
+		return publishErr
+	}
+
+	// The broker sends basic.return before the ack of an unroutable message
+	for len(cc.returns) > 0 {
+		ret := <-cc.returns
+		if ret.MessageId == publishing.MessageId {
+			publishErr.Err = ErrUnroutable
+			publishErr.ReplyCode = ret.ReplyCode
+			publishErr.ReplyText = ret.ReplyText
+			return publishErr
+		}
+	}
+
+	return nil
+}
+
+// confirmChannel returns the confirm mode channel of the current
+// connection, opening it if needed. The caller holds confirmMu.
+func (c *Connection) confirmChannel() (*confirmChannel, error) {
+	c.mu.RLock()
+	conn := c.conn
+	c.mu.RUnlock()
+	if conn == nil {
+		return nil, fmt.Errorf("failed to publish message: %w", ErrNotConnected)
+	}
+
+	if c.confirm != nil && c.confirm.conn == conn && !c.confirm.channel.IsClosed() {
+		return c.confirm, nil
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open confirm channel: %w", err)
+	}
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+
+	c.confirm = &confirmChannel{
+		conn:    conn,
+		channel: ch,
+		returns: ch.NotifyReturn(make(chan amqp.Return, 16)),
+	}
+	return c.confirm, nil
+}
+
+// messageID returns a random ID used to match returns to their message
+func messageID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,39 @@
+package queue_test
+
+import (
+	"errors"
+	"testing"
+
+	"go-microservices/pkg/queue"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishMessage_ConfirmUnroutable(t *testing.T) {
+	q := queue.NewMemoryQueue()
+	defer q.Close()
+	require.NoError(t, q.DeclareQueue(queue.Config{QueueName: "orders", RoutingKey: "order.#", ExchangeName: "orders", ExchangeType: "topic"}))
+
+	// Without confirms an unroutable message is dropped silently
+	assert.NoError(t, q.PublishMessage(queue.Config{ExchangeName: "orders", RoutingKey: "invoice.created"}, "message"))
+
+	err := q.PublishMessage(queue.Config{ExchangeName: "orders", RoutingKey: "invoice.created", Confirm: true}, "message")
+	require.Error(t, err)
+	assert.ErrorIs(t, err, queue.ErrUnroutable)
+
+	var publishErr *queue.PublishError
+	require.True(t, errors.As(err, &publishErr))
+	assert.Equal(t, "invoice.created", publishErr.RoutingKey)
+	assert.EqualValues(t, 312, publishErr.ReplyCode)
+
+	assert.NoError(t, q.PublishMessage(queue.Config{ExchangeName: "orders", RoutingKey: "order.created", Confirm: true}, "message"))
+}
+
+func TestPublishError(t *testing.T) {
+	err := &queue.PublishError{Exchange: "orders", RoutingKey: "order.created", MessageID: "42", Err: queue.ErrConfirmTimeout}
+
+	assert.ErrorIs(t, err, queue.ErrConfirmTimeout)
+	assert.NotErrorIs(t, err, queue.ErrNacked)
+	assert.Equal(t, `failed to publish message 42 to exchange "orders" with routing key "order.created": timed out waiting for publisher confirm`, err.Error())
+}
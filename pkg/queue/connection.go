@@ -0,0 +1,605 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// ErrNotConnected is returned when a message cannot be published because
+// the connection to RabbitMQ is down
+var ErrNotConnected = errors.New("RabbitMQ is not connected")
+
+// State is the state of a Connection
+type State int
+
+const (
+	// StateConnecting is the state before the first successful connection
+	StateConnecting State = iota
+	// StateConnected means the connection and its channel are open
+	StateConnected
+	// StateReconnecting means the connection was lost and is being re-established
+	StateReconnecting
+	// StateClosed means Close was called
+	StateClosed
+)
+
+// String returns the name of the state
+func (s State) String() string {
+	switch s {
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateReconnecting:
+		return "reconnecting"
+	case StateClosed:
+		return "closed"
+	default:
+		return fmt.Sprintf("State(%d)", int(s))
+	}
+}
+
+// Backoff is the exponential delay between connection attempts
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+}
+
+// DefaultBackoff waits half a second after the first failed attempt and
+// doubles the wait up to 30 seconds
+var DefaultBackoff = Backoff{Initial: 500 * time.Millisecond, Max: 30 * time.Second}
+
+// Delay returns the wait after the given failed attempt, counting from 0
+func (b Backoff) Delay(attempt int) time.Duration {
+	delay := b.Initial
+	for i := 0; i < attempt && delay < b.Max; i++ {
+		delay *= 2
+	}
+	if delay > b.Max {
+		return b.Max
+	}
+	return delay
+}
+
+// Connection is a RabbitMQ connection that heals itself. It watches the
+// connection and its channel for closure and reconnects with backoff,
+// re-declaring every queue declared through it and re-registering every
+// consumer, so callers never hold a stale channel.
+type Connection struct {
+	url     string
+	backoff Backoff
+
+	mu        sync.RWMutex
+	conn      *amqp.Connection
+	channel   *amqp.Channel
+	state     State
+	topology  []Config
+	consumers []*Consumer
+
+	// channelMu serializes the use of channel, which must not be shared by
+	// concurrent publishes and declarations
+	channelMu sync.Mutex
+	// confirmMu guards confirm and serializes confirmed publishes
+	confirmMu sync.Mutex
+	confirm   *confirmChannel
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// NewConnection creates a connection to the broker at url. It does not
+// connect until Connect is called.
+func NewConnection(url string, backoff Backoff) *Connection {
+	return &Connection{
+		url:     url,
+		backoff: backoff,
+		closed:  make(chan struct{}),
+	}
+}
+
+// Connect dials the broker up to attempts times and then keeps the
+// connection alive in the background. When every attempt fails the error is
+// returned, but the connection keeps retrying until Close is called.
+func (c *Connection) Connect(attempts int) error {
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if err = c.connect(); err == nil {
+			go c.watch()
+			return nil
+		}
+		fmt.Printf("[RabbitMQ] Connection failed (attempt %d/%d): %v\n", attempt+1, attempts, err)
+		if attempt < attempts-1 && !c.sleep(c.backoff.Delay(attempt)) {
+			return ErrNotConnected
+		}
+	}
+
+	c.setState(StateReconnecting)
+	go func() {
+		if c.reconnect() {
+			c.watch()
+		}
+	}()
+	return fmt.Errorf("failed to connect to RabbitMQ after %d attempts: %w", attempts, err)
+}
+
+// State returns the current state of the connection
+func (c *Connection) State() State {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.state
+}
+
+// Ready returns nil when the connection is usable, for readiness checks
+func (c *Connection) Ready() error {
+	if state := c.State(); state != StateConnected {
+		return fmt.Errorf("%w: %s", ErrNotConnected, state)
+	}
+	return nil
+}
+
+// DeclareQueue declares an exchange and a queue bound to it, or only the
+// exchange if config.QueueName is empty. The declaration is remembered and
+// repeated after every reconnect and channel reopen, so while the
+// connection is down it is only recorded. A declaration the broker rejects
+// is not remembered.
+func (c *Connection) DeclareQueue(config Config) error {
+	c.mu.Lock()
+	ch := c.channel
+	if ch == nil {
+		c.topology = append(c.topology, config)
+		c.mu.Unlock()
+		return nil
+	}
+	c.mu.Unlock()
+
+	c.channelMu.Lock()
+	err := declare(ch, config)
+	c.channelMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.topology = append(c.topology, config)
+	c.mu.Unlock()
+	return nil
+}
+
+// PublishMessage JSON-encodes a message and publishes it to an exchange.
+// With config.Confirm the message is persistent and mandatory, and
+// PublishMessage waits for the broker to confirm it, returning a
+// *PublishError if the message was unroutable, nacked or not confirmed in
+// time.
+func (c *Connection) PublishMessage(config Config, message interface{}) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	return c.Publish(config, amqp.Publishing{
+		ContentType: "application/json",
+		Body:        body,
+	})
+}
+
+// Publish publishes an already encoded message to an exchange, with
+// publisher confirms when config.Confirm is set
+func (c *Connection) Publish(config Config, publishing amqp.Publishing) error {
+	if config.Confirm {
+		return c.publishConfirmed(config, publishing)
+	}
+
+	c.mu.RLock()
+	ch := c.channel
+	c.mu.RUnlock()
+	if ch == nil {
+		return fmt.Errorf("failed to publish message: %w", ErrNotConnected)
+	}
+
+	c.channelMu.Lock()
+	defer c.channelMu.Unlock()
+
+	err := ch.PublishWithContext(
+		context.Background(),
+		config.ExchangeName,
+		config.RoutingKey,
+		false, // mandatory
+		false, // immediate
+		publishing,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeMessages delivers the messages of a queue to handler, one at a
+// time. Messages whose handler fails are requeued, or retried and
+// dead-lettered as set by config.Retry.
+func (c *Connection) ConsumeMessages(config Config, handler func([]byte) error) error {
+	_, err := c.Consume(config, ConsumerOptions{}, ignoreContext(handler))
+	return err
+}
+
+// Consume starts a consumer of a queue on a channel of its own, handling up
+// to options.Concurrency messages at once. Messages whose handler fails are
+// requeued, or retried and dead-lettered as set by config.Retry. The
+// consumer is registered again after every reconnect, so while the
+// connection is down it is only recorded.
+func (c *Connection) Consume(config Config, options ConsumerOptions, handler Handler) (*Consumer, error) {
+	cons := newConsumer(config, options, handler)
+	cons.stopReceiving = func() { c.stopConsumer(cons) }
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn != nil {
+		if err := c.startConsumer(c.conn, cons); err != nil {
+			return nil, err
+		}
+	}
+	c.consumers = append(c.consumers, cons)
+
+	return cons, nil
+}
+
+// stopConsumer unregisters a consumer and cancels its subscription, which
+// closes its deliveries once the prefetched messages are handed out
+func (c *Connection) stopConsumer(cons *Consumer) {
+	c.mu.Lock()
+	for i, registered := range c.consumers {
+		if registered == cons {
+			c.consumers = append(c.consumers[:i:i], c.consumers[i+1:]...)
+			break
+		}
+	}
+	ch, tag := cons.channel, cons.tag
+	c.mu.Unlock()
+
+	if ch != nil {
+		if err := ch.Cancel(tag, false); err != nil && !errors.Is(err, amqp.ErrClosed) {
+			fmt.Printf("Error canceling consumer %s: %v\n", cons.options.Name, err)
+		}
+	}
+}
+
+// Close stops reconnecting and closes the channel and connection
+func (c *Connection) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.closed)
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		c.state = StateClosed
+		for _, cons := range c.consumers {
+			cons.cancel()
+		}
+		if c.channel != nil {
+			if closeErr := c.channel.Close(); closeErr != nil && !errors.Is(closeErr, amqp.ErrClosed) {
+				err = fmt.Errorf("failed to close channel: %w", closeErr)
+			}
+			c.channel = nil
+		}
+		if c.conn != nil {
+			if closeErr := c.conn.Close(); closeErr != nil && !errors.Is(closeErr, amqp.ErrClosed) {
+				err = fmt.Errorf("failed to close connection: %w", closeErr)
+			}
+			c.conn = nil
+		}
+	})
+	return err
+}
+
+// connect dials the broker, opens the shared channel, re-declares the
+// topology and restarts the consumers
+func (c *Connection) connect() error {
+	conn, err := amqp.Dial(c.url)
+	if err != nil {
+		return err
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to open channel: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	select {
+	case <-c.closed:
+		conn.Close()
+		return ErrNotConnected
+	default:
+	}
+
+	for _, config := range c.topology {
+		if err := declare(ch, config); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+	for _, cons := range c.consumers {
+		if err := c.startConsumer(conn, cons); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+
+	c.conn = conn
+	c.channel = ch
+	c.state = StateConnected
+	return nil
+}
+
+// watch waits for the connection or its channel to close and heals it,
+// until Close is called
+func (c *Connection) watch() {
+	for {
+		c.mu.RLock()
+		conn, ch := c.conn, c.channel
+		c.mu.RUnlock()
+		if conn == nil || ch == nil {
+			return
+		}
+
+		connClosed := conn.NotifyClose(make(chan *amqp.Error, 1))
+		channelClosed := ch.NotifyClose(make(chan *amqp.Error, 1))
+
+		select {
+		case <-c.closed:
+			return
+		case err := <-channelClosed:
+			if !conn.IsClosed() {
+				// A channel-level error such as a failed declaration closes
+				// only the channel, so a new one is enough
+				fmt.Printf("[RabbitMQ] Channel closed: %v\n", err)
+				if c.reopenChannel(conn) == nil {
+					continue
+				}
+			}
+		case err := <-connClosed:
+			fmt.Printf("[RabbitMQ] Connection closed: %v\n", err)
+		}
+
+		c.mu.Lock()
+		if c.state == StateClosed {
+			c.mu.Unlock()
+			return
+		}
+		c.state = StateReconnecting
+		c.channel = nil
+		c.conn = nil
+		c.mu.Unlock()
+		conn.Close()
+
+		if !c.reconnect() {
+			return
+		}
+	}
+}
+
+// reopenChannel replaces the shared channel of a live connection. As after
+// a reconnect, the topology is declared again, since the error that closed
+// the channel may have come with deleted queues, and the consumers whose
+// channels closed are restarted.
+func (c *Connection) reopenChannel(conn *amqp.Connection) error {
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state == StateClosed {
+		ch.Close()
+		return ErrNotConnected
+	}
+
+	for _, config := range c.topology {
+		if err := declare(ch, config); err != nil {
+			ch.Close()
+			return err
+		}
+	}
+	for _, cons := range c.consumers {
+		if cons.channel == nil || cons.channel.IsClosed() {
+			if err := c.startConsumer(conn, cons); err != nil {
+				ch.Close()
+				return err
+			}
+		}
+	}
+
+	c.channel = ch
+	return nil
+}
+
+// restartConsumer starts a consumer again after its channel closed, as a
+// channel-level error does while the connection stays up. It retries with
+// backoff until the consumer runs on a new channel, which connect and
+// reopenChannel may also have started, or is stopped, or the connection is
+// lost and the consumer is left for connect to restart.
+func (c *Connection) restartConsumer(cons *Consumer, closed *amqp.Channel) {
+	for attempt := 0; ; attempt++ {
+		c.mu.Lock()
+		if c.state == StateClosed || cons.isStopped() || cons.channel != closed || c.conn == nil || c.conn.IsClosed() {
+			c.mu.Unlock()
+			return
+		}
+		err := c.startConsumer(c.conn, cons)
+		c.mu.Unlock()
+		if err == nil {
+			fmt.Printf("[RabbitMQ] Restarted consumer %s after its channel closed\n", cons.options.Name)
+			return
+		}
+
+		fmt.Printf("[RabbitMQ] Failed to restart consumer %s (attempt %d): %v\n", cons.options.Name, attempt+1, err)
+		if !c.sleep(c.backoff.Delay(attempt)) {
+			return
+		}
+	}
+}
+
+// reconnect retries connect with backoff until it succeeds or Close is
+// called, and reports whether it succeeded
+func (c *Connection) reconnect() bool {
+	for attempt := 0; ; attempt++ {
+		if !c.sleep(c.backoff.Delay(attempt)) {
+			return false
+		}
+		err := c.connect()
+		if err == nil {
+			fmt.Printf("[RabbitMQ] Reconnected after %d attempts\n", attempt+1)
+			return true
+		}
+		fmt.Printf("[RabbitMQ] Reconnect failed (attempt %d): %v\n", attempt+1, err)
+	}
+}
+
+// sleep waits for d and reports false if Close was called in the meantime
+func (c *Connection) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-c.closed:
+		return false
+	}
+}
+
+func (c *Connection) setState(state State) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state != StateClosed {
+		c.state = state
+	}
+}
+
+// declare declares the exchange and queue of config and binds them
+func declare(ch *amqp.Channel, config Config) error {
+	err := ch.ExchangeDeclare(
+		config.ExchangeName,
+		config.ExchangeType,
+		true,  // durable
+		false, // auto-deleted
+		false, // internal
+		false, // no-wait
+		nil,   // arguments
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare exchange: %w", err)
+	}
+
+	if config.QueueName == "" {
+		return nil
+	}
+
+	_, err = ch.QueueDeclare(
+		config.QueueName,
+		true,  // durable
+		false, // delete when unused
+		false, // exclusive
+		false, // no-wait
+		nil,   // arguments
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare queue: %w", err)
+	}
+
+	err = ch.QueueBind(
+		config.QueueName,
+		config.RoutingKey,
+		config.ExchangeName,
+		false,
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to bind queue: %w", err)
+	}
+
+	return declareRetry(ch, config)
+}
+
+// startConsumer opens a channel for a consumer and handles its deliveries
+// on options.Concurrency goroutines until the channel closes or the
+// consumer is stopped. The caller holds mu.
+func (c *Connection) startConsumer(conn *amqp.Connection, cons *Consumer) error {
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open consumer channel: %w", err)
+	}
+	if err := ch.Qos(cons.options.Prefetch, 0, false); err != nil {
+		ch.Close()
+		return fmt.Errorf("failed to set prefetch: %w", err)
+	}
+	// The queues failed messages move to must exist even if the consumed
+	// queue was declared elsewhere
+	if err := declareRetry(ch, cons.config); err != nil {
+		ch.Close()
+		return err
+	}
+
+	tag := cons.options.Name + "-" + messageID()
+	msgs, err := ch.Consume(
+		cons.config.QueueName,
+		tag,   // consumer
+		false, // auto-ack
+		false, // exclusive
+		false, // no-local
+		false, // no-wait
+		nil,   // args
+	)
+	if err != nil {
+		ch.Close()
+		return fmt.Errorf("failed to register a consumer: %w", err)
+	}
+	cons.channel, cons.tag = ch, tag
+
+	var workers sync.WaitGroup
+	for i := 0; i < cons.options.Concurrency; i++ {
+		workers.Add(1)
+		cons.workers.Add(1)
+		go func() {
+			defer cons.workers.Done()
+			defer workers.Done()
+			for msg := range msgs {
+				if err := cons.handle(msg.Body); err != nil {
+					if cons.config.Retry != nil {
+						c.retry(cons.config, msg, err)
+					} else if err := msg.Nack(false, true); err != nil { // Negative acknowledgement, requeue
+						fmt.Printf("Error sending nack: %v\n", err)
+					}
+				} else {
+					if err := msg.Ack(false); err != nil { // Positive acknowledgement
+						fmt.Printf("Error sending ack: %v\n", err)
+					}
+				}
+			}
+		}()
+	}
+
+	// A stopped consumer's channel is closed once its last message is
+	// acked. Deliveries also end when the channel is closed by an error or
+	// the broker cancels the consumer, as when its queue is deleted, and the
+	// consumer is restarted on a new channel.
+	go func() {
+		workers.Wait()
+		ch.Close()
+		if !cons.isStopped() {
+			c.restartConsumer(cons, ch)
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Handler processes the body of a message. ctx is canceled when the
+// consumer is stopped without waiting for its handlers or its connection is
+// closed. A nil error acks the message.
+type Handler func(ctx context.Context, body []byte) error
+
+// ConsumerOptions tunes a consumer started with Consume
+type ConsumerOptions struct {
+	// Name identifies the consumer in metrics, the queue name if empty
+	Name string
+	// Concurrency is the number of messages handled at once, 1 if zero
+	Concurrency int
+	// Prefetch is the number of unacked messages the broker delivers ahead,
+	// Concurrency if zero
+	Prefetch int
+}
+
+// withDefaults fills in the zero fields of the options
+func (o ConsumerOptions) withDefaults(config Config) ConsumerOptions {
+	if o.Name == "" {
+		o.Name = config.QueueName
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = 1
+	}
+	if o.Prefetch <= 0 {
+		o.Prefetch = o.Concurrency
+	}
+	return o
+}
+
+// ConsumerStats counts the messages handled by a consumer
+type ConsumerStats struct {
+	Succeeded int64
+	Failed    int64
+	InFlight  int64
+}
+
+// Consumer is a running consumer of a queue
+type Consumer struct {
+	config  Config
+	options ConsumerOptions
+	handler Handler
+
+	// ctx is passed to handlers and canceled by a forced stop
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	stopped  chan struct{}
+	stopOnce sync.Once
+	// stopReceiving tells the broker to stop delivering to the consumer
+	stopReceiving func()
+	// workers are the goroutines handling messages
+	workers sync.WaitGroup
+	// channel and tag identify the RabbitMQ subscription, guarded by the
+	// Connection's mu
+	channel *amqp.Channel
+	tag     string
+
+	succeeded atomic.Int64
+	failed    atomic.Int64
+	inFlight  atomic.Int64
+}
+
+// newConsumer creates a consumer that is not receiving yet
+func newConsumer(config Config, options ConsumerOptions, handler Handler) *Consumer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Consumer{
+		config:  config,
+		options: options.withDefaults(config),
+		handler: handler,
+		ctx:     ctx,
+		cancel:  cancel,
+		stopped: make(chan struct{}),
+	}
+}
+
+// Stats returns the number of messages handled so far
+func (cons *Consumer) Stats() ConsumerStats {
+	return ConsumerStats{
+		Succeeded: cons.succeeded.Load(),
+		Failed:    cons.failed.Load(),
+		InFlight:  cons.inFlight.Load(),
+	}
+}
+
+// Stop stops receiving messages and waits for the messages being handled to
+// be acked. If ctx ends first the handlers' context is canceled and ctx's
+// error returned; unacked messages are then redelivered by the broker.
+func (cons *Consumer) Stop(ctx context.Context) error {
+	cons.stopOnce.Do(func() {
+		close(cons.stopped)
+		if cons.stopReceiving != nil {
+			cons.stopReceiving()
+		}
+	})
+
+	done := make(chan struct{})
+	go func() {
+		cons.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		cons.cancel()
+		return nil
+	case <-ctx.Done():
+		cons.cancel()
+		return fmt.Errorf("consumer %s stopped with messages in flight: %w", cons.options.Name, ctx.Err())
+	}
+}
+
+// isStopped reports whether Stop was called
+func (cons *Consumer) isStopped() bool {
+	select {
+	case <-cons.stopped:
+		return true
+	default:
+		return false
+	}
+}
+
+// handle runs the handler on a message and records its outcome
+func (cons *Consumer) handle(body []byte) error {
+	name := cons.options.Name
+	cons.inFlight.Add(1)
+	consumerInFlight.WithLabelValues(name).Inc()
+	defer func() {
+		cons.inFlight.Add(-1)
+		consumerInFlight.WithLabelValues(name).Dec()
+	}()
+
+	start := time.Now()
+	err := cons.handler(cons.ctx, body)
+	consumerHandlerDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		fmt.Printf("Error processing message: %v\n", err)
+		cons.failed.Add(1)
+		consumerMessages.WithLabelValues(name, "failure").Inc()
+		return err
+	}
+	cons.succeeded.Add(1)
+	consumerMessages.WithLabelValues(name, "success").Inc()
+	return nil
+}
+
+// ignoreContext adapts a handler that does not take a context
+func ignoreContext(handler func([]byte) error) Handler {
+	return func(_ context.Context, body []byte) error {
+		return handler(body)
+	}
+}
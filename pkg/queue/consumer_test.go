@@ -0,0 +1,113 @@
+package queue_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go-microservices/pkg/queue"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// declaredQueue returns a memory queue with the orders queue declared
+func declaredQueue(t *testing.T) (*queue.MemoryQueue, queue.Config) {
+	q := queue.NewMemoryQueue()
+	t.Cleanup(q.Close)
+
+	config := queue.Config{QueueName: "orders", RoutingKey: "order.#", ExchangeName: "orders", ExchangeType: "topic"}
+	require.NoError(t, q.DeclareQueue(config))
+	return q, config
+}
+
+func TestConsume_Concurrency(t *testing.T) {
+	q, config := declaredQueue(t)
+
+	// Every handler blocks until all three run at the same time
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+	cons, err := q.Consume(config, queue.ConsumerOptions{Concurrency: 3}, func(ctx context.Context, body []byte) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, q.PublishMessage(queue.Config{ExchangeName: "orders", RoutingKey: "order.created"}, i))
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatalf("only %d handlers ran concurrently", i)
+		}
+	}
+	assert.EqualValues(t, 3, cons.Stats().InFlight)
+
+	close(release)
+	require.NoError(t, cons.Stop(context.Background()))
+	assert.Equal(t, queue.ConsumerStats{Succeeded: 3}, cons.Stats())
+}
+
+func TestConsumerStop_WaitsForInFlight(t *testing.T) {
+	q, config := declaredQueue(t)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	cons, err := q.Consume(config, queue.ConsumerOptions{}, func(ctx context.Context, body []byte) error {
+		close(started)
+		<-release
+		return errors.New("handler failed")
+	})
+	require.NoError(t, err)
+	require.NoError(t, q.PublishMessage(queue.Config{ExchangeName: "orders", RoutingKey: "order.created"}, "message"))
+	<-started
+
+	stopped := make(chan error)
+	go func() { stopped <- cons.Stop(context.Background()) }()
+	select {
+	case <-stopped:
+		t.Fatal("Stop returned while a handler was running")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	require.NoError(t, <-stopped)
+	assert.Equal(t, queue.ConsumerStats{Failed: 1}, cons.Stats())
+
+	// A stopped consumer receives nothing; the failed message was requeued
+	require.NoError(t, q.PublishMessage(queue.Config{ExchangeName: "orders", RoutingKey: "order.created"}, "message"))
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, queue.ConsumerStats{Failed: 1}, cons.Stats())
+}
+
+func TestConsumerStop_Deadline(t *testing.T) {
+	q, config := declaredQueue(t)
+
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	cons, err := q.Consume(config, queue.ConsumerOptions{}, func(ctx context.Context, body []byte) error {
+		close(started)
+		<-ctx.Done()
+		close(canceled)
+		return ctx.Err()
+	})
+	require.NoError(t, err)
+	require.NoError(t, q.PublishMessage(queue.Config{ExchangeName: "orders", RoutingKey: "order.created"}, "message"))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = cons.Stop(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// The handler's context is canceled when Stop gives up waiting
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("handler context was not canceled")
+	}
+}
@@ -0,0 +1,144 @@
+package queue
+
+import (
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// DeadLetter is a message parked in a dead-letter queue
+type DeadLetter struct {
+	MessageID      string    `json:"message_id,omitempty"`
+	Attempts       int       `json:"attempts"`
+	LastError      string    `json:"last_error,omitempty"`
+	Exchange       string    `json:"exchange"`
+	RoutingKey     string    `json:"routing_key"`
+	DeadLetteredAt time.Time `json:"dead_lettered_at,omitempty"`
+	Body           []byte    `json:"-"`
+}
+
+// deadLetter describes a delivery from a dead-letter queue
+func deadLetter(msg amqp.Delivery) DeadLetter {
+	letter := DeadLetter{
+		MessageID:  msg.MessageId,
+		Attempts:   retryCount(msg.Headers),
+		LastError:  headerString(msg.Headers, LastErrorHeader),
+		Exchange:   headerString(msg.Headers, OriginalExchangeHeader),
+		RoutingKey: headerString(msg.Headers, OriginalRoutingKeyHeader),
+		Body:       msg.Body,
+	}
+	letter.DeadLetteredAt, _ = time.Parse(time.RFC3339, headerString(msg.Headers, DeadLetteredAtHeader))
+	return letter
+}
+
+// DeadLetters returns up to limit messages from the dead-letter queue of
+// queueName without removing them
+func (c *Connection) DeadLetters(queueName string, limit int) ([]DeadLetter, error) {
+	ch, err := c.adminChannel()
+	if err != nil {
+		return nil, err
+	}
+	// Closing the channel requeues every message that was fetched
+	defer ch.Close()
+
+	var letters []DeadLetter
+	for len(letters) < limit {
+		msg, ok, err := ch.Get(DeadLetterQueueName(queueName), false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read dead-letter queue: %w", err)
+		}
+		if !ok {
+			break
+		}
+		letters = append(letters, deadLetter(msg))
+	}
+
+	return letters, nil
+}
+
+// ReplayDeadLetters republishes up to limit messages from the dead-letter
+// queue of queueName to the exchange and routing key they were first
+// published with, with their retry count reset. It returns the number of
+// replayed messages.
+func (c *Connection) ReplayDeadLetters(queueName string, limit int) (int, error) {
+	ch, err := c.adminChannel()
+	if err != nil {
+		return 0, err
+	}
+	defer ch.Close()
+
+	replayed := 0
+	for replayed < limit {
+		msg, ok, err := ch.Get(DeadLetterQueueName(queueName), false)
+		if err != nil {
+			return replayed, fmt.Errorf("failed to read dead-letter queue: %w", err)
+		}
+		if !ok {
+			break
+		}
+
+		headers := amqp.Table{}
+		for key, value := range msg.Headers {
+			headers[key] = value
+		}
+		exchange := headerString(headers, OriginalExchangeHeader)
+		routingKey := headerString(headers, OriginalRoutingKeyHeader)
+		if exchange == "" && routingKey == "" {
+			routingKey = queueName
+		}
+		for _, key := range []string{RetryCountHeader, LastErrorHeader, OriginalExchangeHeader, OriginalRoutingKeyHeader, DeadLetteredAtHeader, "x-death"} {
+			delete(headers, key)
+		}
+
+		err = c.publishConfirmed(Config{ExchangeName: exchange, RoutingKey: routingKey}, amqp.Publishing{
+			Headers:     headers,
+			ContentType: msg.ContentType,
+			MessageId:   msg.MessageId,
+			Timestamp:   msg.Timestamp,
+			Body:        msg.Body,
+		})
+		if err != nil {
+			msg.Nack(false, true)
+			return replayed, err
+		}
+		if err := msg.Ack(false); err != nil {
+			return replayed, fmt.Errorf("failed to ack replayed message: %w", err)
+		}
+		replayed++
+	}
+
+	return replayed, nil
+}
+
+// PurgeDeadLetters deletes every message in the dead-letter queue of
+// queueName and returns how many there were
+func (c *Connection) PurgeDeadLetters(queueName string) (int, error) {
+	ch, err := c.adminChannel()
+	if err != nil {
+		return 0, err
+	}
+	defer ch.Close()
+
+	purged, err := ch.QueuePurge(DeadLetterQueueName(queueName), false)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge dead-letter queue: %w", err)
+	}
+	return purged, nil
+}
+
+// adminChannel opens a short-lived channel for dead-letter administration
+func (c *Connection) adminChannel() (*amqp.Channel, error) {
+	c.mu.RLock()
+	conn := c.conn
+	c.mu.RUnlock()
+	if conn == nil {
+		return nil, ErrNotConnected
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open channel: %w", err)
+	}
+	return ch, nil
+}
@@ -0,0 +1,289 @@
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Message is a message published to a MemoryQueue
+type Message struct {
+	Config Config
+	Body   []byte
+}
+
+// MemoryQueue is an in-process broker with the same semantics as the
+// RabbitMQ helpers, so the service can run in tests without RabbitMQ.
+// Exchanges route messages to the queues bound to them by routing key, and
+// messages whose handler fails are requeued, or retried after the delays of
+// the consumer's retry policy and then dead-lettered.
+type MemoryQueue struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	bindings  []Config
+	queues    map[string][]*memoryMessage
+	published []Message
+	consumers []*Consumer
+	closed    bool
+}
+
+// memoryMessage is a message waiting in a MemoryQueue
+type memoryMessage struct {
+	letter DeadLetter
+}
+
+// NewMemoryQueue creates an in-memory broker without queues
+func NewMemoryQueue() *MemoryQueue {
+	q := &MemoryQueue{queues: make(map[string][]*memoryMessage)}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// DeclareQueue declares a queue bound to an exchange with a routing key.
+// Exchanges need no declaration in memory, so configs without a queue are
+// ignored.
+func (q *MemoryQueue) DeclareQueue(config Config) error {
+	if config.QueueName == "" {
+		return nil
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.queues[config.QueueName]; !ok {
+		q.queues[config.QueueName] = nil
+	}
+	q.bindings = append(q.bindings, config)
+
+	return nil
+}
+
+// PublishMessage JSON-encodes a message and routes it to every queue bound
+// to the exchange with a matching routing key. Messages no queue is bound
+// for are dropped, as RabbitMQ does for non-mandatory publishes, or
+// rejected with ErrUnroutable when config.Confirm is set.
+func (q *MemoryQueue) PublishMessage(config Config, message interface{}) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return fmt.Errorf("failed to publish message: queue is closed")
+	}
+
+	q.published = append(q.published, Message{Config: config, Body: body})
+	routed := make(map[string]bool)
+	for _, binding := range q.bindings {
+		if binding.ExchangeName == config.ExchangeName && !routed[binding.QueueName] && routes(binding, config.RoutingKey) {
+			routed[binding.QueueName] = true
+			q.queues[binding.QueueName] = append(q.queues[binding.QueueName], &memoryMessage{letter: DeadLetter{
+				Exchange:   config.ExchangeName,
+				RoutingKey: config.RoutingKey,
+				Body:       body,
+			}})
+		}
+	}
+	q.cond.Broadcast()
+
+	if config.Confirm && len(routed) == 0 {
+		return &PublishError{
+			Exchange:   config.ExchangeName,
+			RoutingKey: config.RoutingKey,
+			ReplyCode:  amqp.NoRoute,
+			ReplyText:  "NO_ROUTE",
+			Err:        ErrUnroutable,
+		}
+	}
+
+	return nil
+}
+
+// ConsumeMessages starts delivering the messages of a queue to handler
+func (q *MemoryQueue) ConsumeMessages(config Config, handler func([]byte) error) error {
+	_, err := q.Consume(config, ConsumerOptions{}, ignoreContext(handler))
+	return err
+}
+
+// Consume starts delivering the messages of a queue to handler on
+// options.Concurrency goroutines. Prefetch has no effect in memory.
+func (q *MemoryQueue) Consume(config Config, options ConsumerOptions, handler Handler) (*Consumer, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.queues[config.QueueName]; !ok {
+		return nil, fmt.Errorf("failed to register a consumer: queue %q is not declared", config.QueueName)
+	}
+
+	cons := newConsumer(config, options, handler)
+	cons.stopReceiving = func() {
+		q.mu.Lock()
+		q.cond.Broadcast()
+		q.mu.Unlock()
+	}
+	q.consumers = append(q.consumers, cons)
+
+	for i := 0; i < cons.options.Concurrency; i++ {
+		cons.workers.Add(1)
+		go func() {
+			defer cons.workers.Done()
+			for {
+				q.mu.Lock()
+				for !q.closed && !cons.isStopped() && len(q.queues[config.QueueName]) == 0 {
+					q.cond.Wait()
+				}
+				if q.closed || cons.isStopped() {
+					q.mu.Unlock()
+					return
+				}
+				msg := q.queues[config.QueueName][0]
+				q.queues[config.QueueName] = q.queues[config.QueueName][1:]
+				q.mu.Unlock()
+
+				if err := cons.handle(msg.letter.Body); err != nil {
+					q.retry(config, msg, err)
+				}
+			}
+		}()
+	}
+
+	return cons, nil
+}
+
+// retry requeues a message whose handler failed, like a negative
+// acknowledgement, or with a retry policy redelivers it after the policy's
+// delay and dead-letters it once the policy is exhausted
+func (q *MemoryQueue) retry(config Config, msg *memoryMessage, handlerErr error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if config.Retry == nil {
+		q.queues[config.QueueName] = append(q.queues[config.QueueName], msg)
+		return
+	}
+
+	msg.letter.Attempts++
+	msg.letter.LastError = handlerErr.Error()
+	delay, ok := config.Retry.Next(msg.letter.Attempts)
+	if !ok {
+		msg.letter.DeadLetteredAt = time.Now().UTC()
+		dlq := DeadLetterQueueName(config.QueueName)
+		q.queues[dlq] = append(q.queues[dlq], msg)
+		return
+	}
+
+	time.AfterFunc(delay, func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		q.queues[config.QueueName] = append(q.queues[config.QueueName], msg)
+		q.cond.Broadcast()
+	})
+}
+
+// DeadLetters returns up to limit dead-lettered messages of a queue without
+// removing them
+func (q *MemoryQueue) DeadLetters(queueName string, limit int) ([]DeadLetter, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var letters []DeadLetter
+	for _, msg := range q.queues[DeadLetterQueueName(queueName)] {
+		if len(letters) == limit {
+			break
+		}
+		letters = append(letters, msg.letter)
+	}
+	return letters, nil
+}
+
+// ReplayDeadLetters routes up to limit dead-lettered messages of a queue
+// back to their exchange with their retry count reset
+func (q *MemoryQueue) ReplayDeadLetters(queueName string, limit int) (int, error) {
+	q.mu.Lock()
+	dlq := DeadLetterQueueName(queueName)
+	var replay []*memoryMessage
+	for len(replay) < limit && len(q.queues[dlq]) > 0 {
+		replay = append(replay, q.queues[dlq][0])
+		q.queues[dlq] = q.queues[dlq][1:]
+	}
+	q.mu.Unlock()
+
+	for _, msg := range replay {
+		config := Config{ExchangeName: msg.letter.Exchange, RoutingKey: msg.letter.RoutingKey}
+		if err := q.PublishMessage(config, json.RawMessage(msg.letter.Body)); err != nil {
+			return 0, err
+		}
+	}
+	return len(replay), nil
+}
+
+// PurgeDeadLetters deletes every dead-lettered message of a queue
+func (q *MemoryQueue) PurgeDeadLetters(queueName string) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	dlq := DeadLetterQueueName(queueName)
+	purged := len(q.queues[dlq])
+	delete(q.queues, dlq)
+	return purged, nil
+}
+
+// Published returns every message published so far
+func (q *MemoryQueue) Published() []Message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return append([]Message(nil), q.published...)
+}
+
+// Close stops every consumer without waiting for their handlers
+func (q *MemoryQueue) Close() {
+	q.mu.Lock()
+	q.closed = true
+	for _, cons := range q.consumers {
+		cons.cancel()
+	}
+	q.cond.Broadcast()
+	q.mu.Unlock()
+}
+
+// routes reports whether a binding accepts a routing key
+func routes(binding Config, routingKey string) bool {
+	switch binding.ExchangeType {
+	case "fanout":
+		return true
+	case "direct":
+		return binding.RoutingKey == routingKey
+	default:
+		return topicMatch(strings.Split(binding.RoutingKey, "."), strings.Split(routingKey, "."))
+	}
+}
+
+// topicMatch matches routing key words against a topic pattern, where *
+// stands for one word and # for zero or more
+func topicMatch(pattern, words []string) bool {
+	if len(pattern) == 0 {
+		return len(words) == 0
+	}
+
+	switch pattern[0] {
+	case "#":
+		for i := 0; i <= len(words); i++ {
+			if topicMatch(pattern[1:], words[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(words) > 0 && topicMatch(pattern[1:], words[1:])
+	default:
+		return len(words) > 0 && pattern[0] == words[0] && topicMatch(pattern[1:], words[1:])
+	}
+}
@@ -0,0 +1,26 @@
+package queue
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// The consumer metrics live with the queue package rather than a service's
+// metrics package so every service consuming through it exports them
+var (
+	consumerMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "queue_consumer_messages_total",
+		Help: "The total number of messages handled by each queue consumer, by outcome",
+	}, []string{"consumer", "outcome"})
+
+	consumerHandlerDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "queue_consumer_handler_duration_seconds",
+		Help:    "Time taken by each queue consumer's handler",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"consumer"})
+
+	consumerInFlight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "queue_consumer_in_flight",
+		Help: "The number of messages each queue consumer is currently handling",
+	}, []string{"consumer"})
+)
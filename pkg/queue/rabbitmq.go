@@ -0,0 +1,118 @@
+package queue
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// connection is the connection used by the package-level helpers
+var connection *Connection
+
+// errNotInitialized is returned by the package-level helpers before InitRabbitMQ
+var errNotInitialized = fmt.Errorf("RabbitMQ channel is not initialized: %w", ErrNotConnected)
+
+// Config holds RabbitMQ configuration
+type Config struct {
+	QueueName       string
+	RoutingKey      string
+	ExchangeName    string
+	ExchangeType    string
+	ConnectionRetry int
+	// Confirm makes PublishMessage wait for the broker to store the message
+	// and fail with a *PublishError when no queue is bound for it
+	Confirm bool
+	// ConfirmTimeout bounds the wait for a confirm, DefaultConfirmTimeout if zero
+	ConfirmTimeout time.Duration
+	// Retry bounds the deliveries of messages whose handler fails. Without
+	// it they are requeued until the handler succeeds.
+	Retry *RetryPolicy
+}
+
+// InitRabbitMQ initializes RabbitMQ connection. If RabbitMQ cannot be
+// reached the error is returned and the connection keeps retrying in the
+// background.
+func InitRabbitMQ() error {
+	url := fmt.Sprintf("amqp://%s:%s@%s:%s/",
+		getEnv("RABBITMQ_USER", "guest"),
+		getEnv("RABBITMQ_PASSWORD", "guest"),
+		getEnv("RABBITMQ_HOST", "rabbitmq"), // Docker default
+		getEnv("RABBITMQ_PORT", "5672"),
+	)
+
+	connection = NewConnection(url, DefaultBackoff)
+	return connection.Connect(5)
+}
+
+// DefaultConnection returns the connection opened by InitRabbitMQ
+func DefaultConnection() *Connection {
+	return connection
+}
+
+// ConnectionState returns the state of the RabbitMQ connection
+func ConnectionState() State {
+	if connection == nil {
+		return StateClosed
+	}
+	return connection.State()
+}
+
+// Ready returns nil when RabbitMQ is connected, for readiness checks
+func Ready() error {
+	if connection == nil {
+		return errNotInitialized
+	}
+	return connection.Ready()
+}
+
+// DeclareQueue declares a queue with given configuration
+func DeclareQueue(config Config) error {
+	if connection == nil {
+		return errNotInitialized
+	}
+	return connection.DeclareQueue(config)
+}
+
+// PublishMessage publishes a message to queue
+func PublishMessage(config Config, message interface{}) error {
+	if connection == nil {
+		return errNotInitialized
+	}
+	return connection.PublishMessage(config, message)
+}
+
+// ConsumeMessages starts consuming messages from queue
+func ConsumeMessages(config Config, handler func([]byte) error) error {
+	if connection == nil {
+		return errNotInitialized
+	}
+	return connection.ConsumeMessages(config, handler)
+}
+
+// Consume starts a consumer handling up to options.Concurrency messages of
+// a queue at once
+func Consume(config Config, options ConsumerOptions, handler Handler) (*Consumer, error) {
+	if connection == nil {
+		return nil, errNotInitialized
+	}
+	return connection.Consume(config, options, handler)
+}
+
+// Close closes RabbitMQ connection
+func Close() {
+	if connection == nil {
+		return
+	}
+	if err := connection.Close(); err != nil {
+		fmt.Printf("Error closing connection: %v\n", err)
+	}
+}
+
+// getEnv gets an environment variable or returns a default value
+func getEnv(key, defaultValue string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
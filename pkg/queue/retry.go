@@ -0,0 +1,187 @@
+package queue
+
+import (
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Headers set on messages that failed and were retried or dead-lettered
+const (
+	// RetryCountHeader is the number of failed deliveries of a message
+	RetryCountHeader = "x-retry-count"
+	// LastErrorHeader is the error returned by the handler on the last delivery
+	LastErrorHeader = "x-last-error"
+	// OriginalExchangeHeader is the exchange the message was first published to
+	OriginalExchangeHeader = "x-original-exchange"
+	// OriginalRoutingKeyHeader is the routing key the message was first published with
+	OriginalRoutingKeyHeader = "x-original-routing-key"
+	// DeadLetteredAtHeader is when the message was moved to the dead-letter queue
+	DeadLetteredAtHeader = "x-dead-lettered-at"
+)
+
+// RetryPolicy bounds how often a message whose handler fails is delivered.
+// Retries wait in a delay queue per entry of Delays, whose messages expire
+// back into the consumed queue, and a message that failed MaxAttempts times
+// is parked in the queue's dead-letter queue.
+type RetryPolicy struct {
+	// MaxAttempts is the number of deliveries, including the first
+	MaxAttempts int
+	// Delays are the waits before the first, second, ... retry. The last
+	// delay is used for every later retry.
+	Delays []time.Duration
+}
+
+// DefaultRetryPolicy delivers a message up to five times over about two minutes
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	Delays:      []time.Duration{time.Second, 10 * time.Second, time.Minute},
+}
+
+// Next returns the wait before delivering a message again after it failed
+// attempts times, or false if the message should be dead-lettered
+func (p RetryPolicy) Next(attempts int) (time.Duration, bool) {
+	if attempts >= p.MaxAttempts {
+		return 0, false
+	}
+	if len(p.Delays) == 0 {
+		return 0, true
+	}
+	if attempts > len(p.Delays) {
+		return p.Delays[len(p.Delays)-1], true
+	}
+	return p.Delays[attempts-1], true
+}
+
+// RetryQueueName returns the name of the queue holding the messages of
+// queueName that wait delay before their next delivery
+func RetryQueueName(queueName string, delay time.Duration) string {
+	return fmt.Sprintf("%s.retry.%s", queueName, delay)
+}
+
+// DeadLetterQueueName returns the name of the parking-lot queue of queueName
+func DeadLetterQueueName(queueName string) string {
+	return queueName + ".dlq"
+}
+
+// declareRetry declares the delay queues and the dead-letter queue of a
+// queue with a retry policy. Delay queues dead-letter expired messages to
+// the default exchange, which routes them back to the consumed queue.
+func declareRetry(ch *amqp.Channel, config Config) error {
+	if config.Retry == nil {
+		return nil
+	}
+
+	declared := make(map[time.Duration]bool)
+	for _, delay := range config.Retry.Delays {
+		if declared[delay] {
+			continue
+		}
+		declared[delay] = true
+
+		_, err := ch.QueueDeclare(
+			RetryQueueName(config.QueueName, delay),
+			true,  // durable
+			false, // delete when unused
+			false, // exclusive
+			false, // no-wait
+			amqp.Table{
+				"x-message-ttl":             delay.Milliseconds(),
+				"x-dead-letter-exchange":    "",
+				"x-dead-letter-routing-key": config.QueueName,
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to declare retry queue: %w", err)
+		}
+	}
+
+	_, err := ch.QueueDeclare(
+		DeadLetterQueueName(config.QueueName),
+		true,  // durable
+		false, // delete when unused
+		false, // exclusive
+		false, // no-wait
+		nil,   // arguments
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare dead-letter queue: %w", err)
+	}
+
+	return nil
+}
+
+// retryCount reads RetryCountHeader, which the broker may hand back as any
+// integer type
+func retryCount(headers amqp.Table) int {
+	switch n := headers[RetryCountHeader].(type) {
+	case int:
+		return n
+	case int8:
+		return int(n)
+	case int16:
+		return int(n)
+	case int32:
+		return int(n)
+	case int64:
+		return int(n)
+	default:
+		return 0
+	}
+}
+
+// headerString reads a string header
+func headerString(headers amqp.Table, key string) string {
+	s, _ := headers[key].(string)
+	return s
+}
+
+// retry moves a message whose handler failed to its delay queue or, once the
+// policy is exhausted, to the dead-letter queue. The message is acked only
+// after the copy is confirmed, so it is requeued if the copy fails.
+func (c *Connection) retry(config Config, msg amqp.Delivery, handlerErr error) {
+	headers := amqp.Table{}
+	for key, value := range msg.Headers {
+		headers[key] = value
+	}
+	attempts := retryCount(msg.Headers) + 1
+	headers[RetryCountHeader] = int32(attempts)
+	headers[LastErrorHeader] = handlerErr.Error()
+	if _, ok := headers[OriginalExchangeHeader]; !ok {
+		headers[OriginalExchangeHeader] = msg.Exchange
+		headers[OriginalRoutingKeyHeader] = msg.RoutingKey
+	}
+
+	target := DeadLetterQueueName(config.QueueName)
+	delay, ok := config.Retry.Next(attempts)
+	if ok {
+		target = RetryQueueName(config.QueueName, delay)
+		if len(config.Retry.Delays) == 0 {
+			target = config.QueueName
+		}
+	} else {
+		headers[DeadLetteredAtHeader] = time.Now().UTC().Format(time.RFC3339)
+		fmt.Printf("[RabbitMQ] Dead-lettering message %s from %s after %d attempts: %v\n", msg.MessageId, config.QueueName, attempts, handlerErr)
+	}
+
+	// Publishing to the default exchange routes by queue name
+	err := c.publishConfirmed(Config{RoutingKey: target}, amqp.Publishing{
+		Headers:     headers,
+		ContentType: msg.ContentType,
+		MessageId:   msg.MessageId,
+		Timestamp:   msg.Timestamp,
+		Body:        msg.Body,
+	})
+	if err != nil {
+		fmt.Printf("Error moving message to %s: %v\n", target, err)
+		if err := msg.Nack(false, true); err != nil {
+			fmt.Printf("Error sending nack: %v\n", err)
+		}
+		return
+	}
+
+	if err := msg.Ack(false); err != nil {
+		fmt.Printf("Error sending ack: %v\n", err)
+	}
+}
@@ -0,0 +1,96 @@
+package queue_test
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-microservices/pkg/queue"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicyNext(t *testing.T) {
+	policy := queue.RetryPolicy{MaxAttempts: 4, Delays: []time.Duration{time.Second, 10 * time.Second}}
+
+	tests := []struct {
+		attempts int
+		delay    time.Duration
+		retry    bool
+	}{
+		{1, time.Second, true},
+		{2, 10 * time.Second, true},
+		{3, 10 * time.Second, true},
+		{4, 0, false},
+		{5, 0, false},
+	}
+	for _, tt := range tests {
+		delay, retry := policy.Next(tt.attempts)
+		assert.Equal(t, tt.retry, retry, "attempts %d", tt.attempts)
+		assert.Equal(t, tt.delay, delay, "attempts %d", tt.attempts)
+	}
+}
+
+func TestQueueNames(t *testing.T) {
+	assert.Equal(t, "orders.retry.10s", queue.RetryQueueName("orders", 10*time.Second))
+	assert.Equal(t, "orders.dlq", queue.DeadLetterQueueName("orders"))
+}
+
+// TestConsumeMessages_DeadLetter checks that a message whose handler keeps
+// failing is retried MaxAttempts times, parked, and can be replayed and purged
+func TestConsumeMessages_DeadLetter(t *testing.T) {
+	q := queue.NewMemoryQueue()
+	defer q.Close()
+
+	config := queue.Config{
+		QueueName:    "orders",
+		RoutingKey:   "order.#",
+		ExchangeName: "orders",
+		ExchangeType: "topic",
+		Retry:        &queue.RetryPolicy{MaxAttempts: 3, Delays: []time.Duration{time.Millisecond}},
+	}
+	require.NoError(t, q.DeclareQueue(config))
+
+	var deliveries, failing atomic.Int32
+	failing.Store(1)
+	require.NoError(t, q.ConsumeMessages(config, func([]byte) error {
+		deliveries.Add(1)
+		if failing.Load() == 1 {
+			return errors.New("handler failed")
+		}
+		return nil
+	}))
+	require.NoError(t, q.PublishMessage(queue.Config{ExchangeName: "orders", RoutingKey: "order.created"}, map[string]int{"id": 1}))
+
+	var letters []queue.DeadLetter
+	require.Eventually(t, func() bool {
+		letters, _ = q.DeadLetters("orders", 10)
+		return len(letters) == 1
+	}, time.Second, 5*time.Millisecond)
+	assert.EqualValues(t, 3, deliveries.Load())
+	assert.Equal(t, 3, letters[0].Attempts)
+	assert.Equal(t, "handler failed", letters[0].LastError)
+	assert.Equal(t, "order.created", letters[0].RoutingKey)
+	assert.JSONEq(t, `{"id":1}`, string(letters[0].Body))
+
+	// Replayed messages go back through the exchange with their retries reset
+	failing.Store(0)
+	replayed, err := q.ReplayDeadLetters("orders", 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, replayed)
+	assert.Eventually(t, func() bool { return deliveries.Load() == 4 }, time.Second, 5*time.Millisecond)
+	letters, _ = q.DeadLetters("orders", 10)
+	assert.Empty(t, letters)
+
+	failing.Store(1)
+	require.NoError(t, q.PublishMessage(queue.Config{ExchangeName: "orders", RoutingKey: "order.created"}, map[string]int{"id": 2}))
+	require.Eventually(t, func() bool {
+		letters, _ = q.DeadLetters("orders", 10)
+		return len(letters) == 1
+	}, time.Second, 5*time.Millisecond)
+	purged, err := q.PurgeDeadLetters("orders")
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+}
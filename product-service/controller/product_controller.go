@@ -1,24 +1,35 @@
 package controller
 
 import (
-	"database/sql"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"go-microservices/product-service/model"
+	"go-microservices/product-service/service"
 
 	"github.com/gin-gonic/gin"
 )
 
+// ProductServiceInterface defines the product operations used by the controller
+type ProductServiceInterface interface {
+	GetProducts() ([]model.Product, error)
+	GetProduct(id int) (*model.Product, error)
+	CreateProduct(product *model.Product) error
+	UpdateProduct(product *model.Product) error
+	DeleteProduct(id int) error
+}
+
 // ProductController handles product-related requests
 type ProductController struct {
-	DB *sql.DB
+	Service ProductServiceInterface
 }
 
 // NewProductController creates a new product controller
-func NewProductController(db *sql.DB) *ProductController {
-	return &ProductController{DB: db}
+func NewProductController(productService ProductServiceInterface) *ProductController {
+	return &ProductController{Service: productService}
 }
 
 // CreateProduct handles creation of a new product
@@ -29,59 +40,38 @@ func (pc *ProductController) CreateProduct(c *gin.Context) {
 		return
 	}
 
-	var id int
-	err := pc.DB.QueryRow(
-		"INSERT INTO products (name, description, price) VALUES ($1, $2, $3) RETURNING id",
-		product.Name, product.Description, product.Price).Scan(&id)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := pc.Service.CreateProduct(&product); err != nil {
+		respondError(c, err)
 		return
 	}
 
-	product.ID = id
 	c.JSON(http.StatusCreated, product)
 }
 
 // GetProducts returns all products
 func (pc *ProductController) GetProducts(c *gin.Context) {
-	rows, err := pc.DB.Query("SELECT id, name, description, price, category, image_url, stock_quantity, created_at, updated_at FROM products")
+	products, err := pc.Service.GetProducts()
 	if err != nil {
 		// Log and return empty list so the service stays responsive while DB recovers
 		log.Printf("Failed to query products: %v", err)
 		c.JSON(http.StatusOK, []model.Product{})
 		return
 	}
-	defer rows.Close()
-
-	var products []model.Product
-	for rows.Next() {
-		var p model.Product
-		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.Price, &p.Category, &p.ImageURL, &p.StockQuantity, &p.CreatedAt, &p.UpdatedAt); err != nil {
-			// Log and skip malformed rows; return what we have
-			log.Printf("Error scanning product row: %v", err)
-			continue
-		}
-		products = append(products, p)
-	}
 
 	c.JSON(http.StatusOK, products)
 }
 
 // GetProduct returns a specific product by ID
 func (pc *ProductController) GetProduct(c *gin.Context) {
-	id := c.Param("id")
-	var product model.Product
-
-	err := pc.DB.QueryRow("SELECT id, name, description, price, category, image_url, stock_quantity, created_at, updated_at FROM products WHERE id = $1", id).
-		Scan(&product.ID, &product.Name, &product.Description, &product.Price, &product.Category, &product.ImageURL, &product.StockQuantity, &product.CreatedAt, &product.UpdatedAt)
-
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
 		return
 	}
+
+	product, err := pc.Service.GetProduct(id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
@@ -102,38 +92,48 @@ func (pc *ProductController) UpdateProduct(c *gin.Context) {
 		return
 	}
 
-	result, err := pc.DB.Exec("UPDATE products SET name = $1, description = $2, price = $3 WHERE id = $4",
-		product.Name, product.Description, product.Price, id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+	product.ID = id
+	if err := pc.Service.UpdateProduct(&product); err != nil {
+		respondError(c, err)
 		return
 	}
 
-	product.ID = id
 	c.JSON(http.StatusOK, product)
 }
 
 // DeleteProduct deletes a product
 func (pc *ProductController) DeleteProduct(c *gin.Context) {
-	id := c.Param("id")
-
-	result, err := pc.DB.Exec("DELETE FROM products WHERE id = $1", id)
+	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+	if err := pc.Service.DeleteProduct(id); err != nil {
+		respondError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Product deleted successfully"})
 }
+
+// HealthCheck returns the health status of the product service
+func (pc *ProductController) HealthCheck(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"service": "product-service",
+		"time":    time.Now().UTC(),
+	})
+}
+
+// respondError maps a service error to its HTTP response
+func respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+	case errors.Is(err, service.ErrInvalidProduct):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
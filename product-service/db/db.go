@@ -74,13 +74,25 @@ func InitSchema(db *sql.DB) {
 		id SERIAL PRIMARY KEY,
 		name VARCHAR(255) NOT NULL,
 		description TEXT,
-		price DECIMAL(10, 2) NOT NULL,
+		price_minor BIGINT NOT NULL,
+		currency VARCHAR(3) NOT NULL DEFAULT 'USD',
 		category TEXT,
 		image_url TEXT,
 		stock_quantity INTEGER DEFAULT 0,
 		created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
 		updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
-	);`
+	);
+
+	-- Prices used to be decimal US dollars. They are integers in minor
+	-- units of their currency now.
+	DO $$
+	BEGIN
+		IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'products' AND column_name = 'price') THEN
+			ALTER TABLE products ALTER COLUMN price TYPE BIGINT USING ROUND(price * 100);
+			ALTER TABLE products RENAME COLUMN price TO price_minor;
+			ALTER TABLE products ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'USD';
+		END IF;
+	END $$;`
 
 	_, err := db.Exec(createTableSQL)
 	if err != nil {
@@ -5,7 +5,9 @@ import (
 
 	"go-microservices/product-service/controller"
 	"go-microservices/product-service/db"
+	"go-microservices/product-service/repository"
 	"go-microservices/product-service/routes"
+	"go-microservices/product-service/service"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -23,7 +25,7 @@ func main() {
 	db.InitSchema(database)
 
 	// Create product controller
-	productController := controller.NewProductController(database)
+	productController := controller.NewProductController(service.NewProductService(repository.NewDBProductRepository(database)))
 
 	// Initialize router
 	router := gin.Default()
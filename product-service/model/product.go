@@ -1,14 +1,57 @@
 package model
 
-// Product represents a product entity
+import (
+	"encoding/json"
+
+	"go-microservices/pkg/money"
+)
+
+// Product represents a product entity. Its price is encoded in JSON as a
+// decimal number in major units next to its currency, USD if omitted.
 type Product struct {
-	ID            int     `json:"id"`
-	Name          string  `json:"name"`
-	Description   string  `json:"description"`
-	Price         float64 `json:"price"`
-	Category      *string `json:"category,omitempty"`
-	ImageURL      *string `json:"image_url,omitempty"`
-	StockQuantity *int    `json:"stock_quantity,omitempty"`
-	CreatedAt     *string `json:"created_at,omitempty"`
-	UpdatedAt     *string `json:"updated_at,omitempty"`
+	ID            int         `json:"id"`
+	Name          string      `json:"name"`
+	Description   string      `json:"description"`
+	Price         money.Money `json:"-"`
+	Category      *string     `json:"category,omitempty"`
+	ImageURL      *string     `json:"image_url,omitempty"`
+	StockQuantity *int        `json:"stock_quantity,omitempty"`
+	CreatedAt     *string     `json:"created_at,omitempty"`
+	UpdatedAt     *string     `json:"updated_at,omitempty"`
+}
+
+// productFields is Product without its JSON methods
+type productFields Product
+
+// productJSON is the JSON form of a Product
+type productJSON struct {
+	productFields
+	Price    json.Number `json:"price"`
+	Currency string      `json:"currency"`
+}
+
+// MarshalJSON encodes the product with its price in major units
+func (p Product) MarshalJSON() ([]byte, error) {
+	return json.Marshal(productJSON{productFields: productFields(p), Price: p.Price.Number(), Currency: p.Price.Currency()})
+}
+
+// UnmarshalJSON decodes a product encoded by MarshalJSON
+func (p *Product) UnmarshalJSON(data []byte) error {
+	var v productJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.Price == "" {
+		v.Price = "0"
+	}
+	if v.Currency == "" {
+		v.Currency = money.DefaultCurrency
+	}
+	price, err := money.Parse(v.Price.String(), v.Currency)
+	if err != nil {
+		return err
+	}
+	*p = Product(v.productFields)
+	p.Price = price
+	return nil
 }
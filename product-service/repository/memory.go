@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+
+	"go-microservices/product-service/model"
+)
+
+// MemoryProductRepository implements ProductRepository in memory, so the
+// service can run in tests without a database
+type MemoryProductRepository struct {
+	mu       sync.RWMutex
+	nextID   int
+	products map[int]model.Product
+}
+
+// NewMemoryProductRepository creates an empty in-memory repository
+func NewMemoryProductRepository() *MemoryProductRepository {
+	return &MemoryProductRepository{
+		nextID:   1,
+		products: make(map[int]model.Product),
+	}
+}
+
+// GetAll returns every product ordered by ID
+func (r *MemoryProductRepository) GetAll() ([]model.Product, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var products []model.Product
+	for _, p := range r.products {
+		products = append(products, p)
+	}
+	sort.Slice(products, func(i, j int) bool { return products[i].ID < products[j].ID })
+
+	return products, nil
+}
+
+// GetByID returns a product by ID
+func (r *MemoryProductRepository) GetByID(id int) (*model.Product, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	product, ok := r.products[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	return &product, nil
+}
+
+// Create stores a product under the next free ID
+func (r *MemoryProductRepository) Create(product *model.Product) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	product.ID = r.nextID
+	product.CreatedAt = &now
+	product.UpdatedAt = &now
+	r.nextID++
+	r.products[product.ID] = *product
+
+	return nil
+}
+
+// Update replaces the name, description and price of a product
+func (r *MemoryProductRepository) Update(product *model.Product) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.products[product.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	existing.Name = product.Name
+	existing.Description = product.Description
+	existing.Price = product.Price
+	existing.UpdatedAt = &now
+	r.products[product.ID] = existing
+
+	return nil
+}
+
+// Delete removes a product
+func (r *MemoryProductRepository) Delete(id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.products[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(r.products, id)
+
+	return nil
+}
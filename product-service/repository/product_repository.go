@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+
+	"go-microservices/pkg/money"
+	"go-microservices/product-service/model"
+)
+
+// ProductRepository defines the storage operations on products.
+// Lookups, updates and deletes of a missing product return sql.ErrNoRows.
+type ProductRepository interface {
+	GetAll() ([]model.Product, error)
+	GetByID(id int) (*model.Product, error)
+	Create(product *model.Product) error
+	Update(product *model.Product) error
+	Delete(id int) error
+}
+
+// selectProducts selects the product columns read by scanProduct. Prices
+// are stored in minor units of their currency.
+const selectProducts = "SELECT id, name, description, price_minor, currency, category, image_url, stock_quantity, created_at, updated_at FROM products"
+
+// DBProductRepository implements ProductRepository using the products table
+type DBProductRepository struct {
+	DB *sql.DB
+}
+
+// NewDBProductRepository creates a repository backed by the given database
+func NewDBProductRepository(db *sql.DB) *DBProductRepository {
+	return &DBProductRepository{DB: db}
+}
+
+// GetAll returns every product, skipping rows that cannot be read
+func (r *DBProductRepository) GetAll() ([]model.Product, error) {
+	rows, err := r.DB.Query(selectProducts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []model.Product
+	for rows.Next() {
+		p, err := scanProduct(rows)
+		if err != nil {
+			// Log and skip malformed rows; return what we have
+			log.Printf("Error scanning product row: %v", err)
+			continue
+		}
+		products = append(products, *p)
+	}
+
+	return products, nil
+}
+
+// GetByID returns a product by ID
+func (r *DBProductRepository) GetByID(id int) (*model.Product, error) {
+	return scanProduct(r.DB.QueryRow(selectProducts+" WHERE id = $1", id))
+}
+
+// Create inserts a product and sets its ID
+func (r *DBProductRepository) Create(product *model.Product) error {
+	return r.DB.QueryRow(
+		"INSERT INTO products (name, description, price_minor, currency) VALUES ($1, $2, $3, $4) RETURNING id",
+		product.Name, product.Description, product.Price.Minor(), product.Price.Currency()).Scan(&product.ID)
+}
+
+// Update replaces the name, description and price of a product
+func (r *DBProductRepository) Update(product *model.Product) error {
+	result, err := r.DB.Exec("UPDATE products SET name = $1, description = $2, price_minor = $3, currency = $4 WHERE id = $5",
+		product.Name, product.Description, product.Price.Minor(), product.Price.Currency(), product.ID)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(result)
+}
+
+// Delete removes a product
+func (r *DBProductRepository) Delete(id int) error {
+	result, err := r.DB.Exec("DELETE FROM products WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(result)
+}
+
+// scanner is satisfied by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanProduct reads the product columns selected by selectProducts
+func scanProduct(s scanner) (*model.Product, error) {
+	var product model.Product
+	var price int64
+	var currency string
+	err := s.Scan(&product.ID, &product.Name, &product.Description, &price, &currency, &product.Category,
+		&product.ImageURL, &product.StockQuantity, &product.CreatedAt, &product.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if product.Price, err = money.New(price, currency); err != nil {
+		return nil, err
+	}
+
+	return &product, nil
+}
+
+// checkAffected returns sql.ErrNoRows when a statement touched no rows
+func checkAffected(result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
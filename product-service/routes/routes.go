@@ -1,6 +1,8 @@
 package routes
 
 import (
+	"go-microservices/pkg/apidocs"
+	"go-microservices/product-service/api"
 	"go-microservices/product-service/controller"
 
 	"github.com/gin-gonic/gin"
@@ -8,10 +10,9 @@ import (
 
 // SetupRoutes configures the API routes for the product service
 func SetupRoutes(router *gin.Engine, productController *controller.ProductController) {
-	// Product routes
-	router.POST("/products", productController.CreateProduct)
-	router.GET("/products", productController.GetProducts)
-	router.GET("/products/:id", productController.GetProduct)
-	router.PUT("/products/:id", productController.UpdateProduct)
-	router.DELETE("/products/:id", productController.DeleteProduct)
+	// Health check and product routes, as declared in api/openapi.json
+	api.RegisterHandlers(router, productController)
+
+	// OpenAPI document and Swagger UI
+	apidocs.Register(router, api.Spec)
 }
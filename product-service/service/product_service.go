@@ -0,0 +1,85 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go-microservices/product-service/model"
+	"go-microservices/product-service/repository"
+)
+
+var (
+	// ErrProductNotFound is returned when no product has the requested ID
+	ErrProductNotFound = errors.New("product not found")
+	// ErrInvalidProduct is wrapped by every validation error
+	ErrInvalidProduct = errors.New("invalid product")
+)
+
+// ProductService implements the product catalogue rules on top of a repository
+type ProductService struct {
+	repo repository.ProductRepository
+}
+
+// NewProductService creates a product service storing products in repo
+func NewProductService(repo repository.ProductRepository) *ProductService {
+	return &ProductService{repo: repo}
+}
+
+// GetProducts returns every product
+func (s *ProductService) GetProducts() ([]model.Product, error) {
+	return s.repo.GetAll()
+}
+
+// GetProduct returns a product by ID
+func (s *ProductService) GetProduct(id int) (*model.Product, error) {
+	product, err := s.repo.GetByID(id)
+	if err == sql.ErrNoRows {
+		return nil, ErrProductNotFound
+	}
+	return product, err
+}
+
+// CreateProduct validates and stores a new product
+func (s *ProductService) CreateProduct(product *model.Product) error {
+	if err := validate(product); err != nil {
+		return err
+	}
+	return s.repo.Create(product)
+}
+
+// UpdateProduct validates and replaces the name, description and price of a product
+func (s *ProductService) UpdateProduct(product *model.Product) error {
+	if err := validate(product); err != nil {
+		return err
+	}
+	return notFound(s.repo.Update(product))
+}
+
+// DeleteProduct removes a product
+func (s *ProductService) DeleteProduct(id int) error {
+	return notFound(s.repo.Delete(id))
+}
+
+// validate checks the fields every stored product must have
+func validate(product *model.Product) error {
+	if strings.TrimSpace(product.Name) == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidProduct)
+	}
+	if product.Price.Currency() == "" {
+		return fmt.Errorf("%w: price has no currency", ErrInvalidProduct)
+	}
+	if product.Price.IsNegative() {
+		return fmt.Errorf("%w: price must not be negative", ErrInvalidProduct)
+	}
+	return nil
+}
+
+// notFound translates the repository's missing-row error
+func notFound(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrProductNotFound
+	}
+	return err
+}
@@ -0,0 +1,38 @@
+package contract
+
+import (
+	"net/http"
+	"testing"
+
+	"go-microservices/pkg/contract"
+	"go-microservices/pkg/money"
+	"go-microservices/product-service/controller"
+	"go-microservices/product-service/model"
+	"go-microservices/product-service/repository"
+	"go-microservices/product-service/routes"
+	"go-microservices/product-service/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestConsumerContracts replays the contracts of every consumer of
+// product-service against its router
+func TestConsumerContracts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	contract.Verify(t, contract.Load(t, "../../../contracts", "product-service"), func(t *testing.T, state string) http.Handler {
+		repo := repository.NewMemoryProductRepository()
+
+		switch state {
+		case "product 1 exists":
+			repo.Create(&model.Product{Name: "Laptop", Description: "High-performance laptop", Price: money.MustNew(1999, "USD")})
+		case "product 99 does not exist":
+		default:
+			t.Fatalf("unknown provider state %q", state)
+		}
+
+		router := gin.New()
+		routes.SetupRoutes(router, controller.NewProductController(service.NewProductService(repo)))
+		return router
+	})
+}
@@ -0,0 +1,26 @@
+package unit
+
+import (
+	"testing"
+
+	"go-microservices/pkg/apidocs"
+	"go-microservices/product-service/api"
+	"go-microservices/product-service/controller"
+	"go-microservices/product-service/routes"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRoutesAreDocumented fails when a route is registered without being
+// declared in api/openapi.json
+func TestRoutesAreDocumented(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	routes.SetupRoutes(router, controller.NewProductController(nil))
+
+	missing, err := apidocs.MissingRoutes(router.Routes(), api.Spec)
+	require.NoError(t, err)
+	assert.Empty(t, missing, "routes missing from api/openapi.json")
+}
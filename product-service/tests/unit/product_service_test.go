@@ -0,0 +1,77 @@
+package unit
+
+import (
+	"encoding/json"
+	"testing"
+
+	"go-microservices/pkg/money"
+	"go-microservices/product-service/model"
+	"go-microservices/product-service/repository"
+	"go-microservices/product-service/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateProduct_Validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		product model.Product
+		valid   bool
+	}{
+		{"valid", model.Product{Name: "Laptop", Price: money.MustNew(1999, "USD")}, true},
+		{"free", model.Product{Name: "Sticker", Price: money.MustNew(0, "USD")}, true},
+		{"yen", model.Product{Name: "Laptop", Price: money.MustNew(1999, "JPY")}, true},
+		{"missing name", model.Product{Name: "  ", Price: money.MustNew(1999, "USD")}, false},
+		{"negative price", model.Product{Name: "Laptop", Price: money.MustNew(-100, "USD")}, false},
+		{"missing currency", model.Product{Name: "Laptop"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			productService := service.NewProductService(repository.NewMemoryProductRepository())
+
+			err := productService.CreateProduct(&tt.product)
+			if tt.valid {
+				assert.NoError(t, err)
+				assert.NotZero(t, tt.product.ID)
+			} else {
+				assert.ErrorIs(t, err, service.ErrInvalidProduct)
+			}
+		})
+	}
+}
+
+func TestProductNotFound(t *testing.T) {
+	productService := service.NewProductService(repository.NewMemoryProductRepository())
+
+	_, err := productService.GetProduct(1)
+	assert.ErrorIs(t, err, service.ErrProductNotFound)
+	assert.ErrorIs(t, productService.UpdateProduct(&model.Product{ID: 1, Name: "Laptop", Price: money.MustNew(1999, "USD")}), service.ErrProductNotFound)
+	assert.ErrorIs(t, productService.DeleteProduct(1), service.ErrProductNotFound)
+}
+
+func TestDeleteProduct(t *testing.T) {
+	productService := service.NewProductService(repository.NewMemoryProductRepository())
+	product := model.Product{Name: "Laptop", Price: money.MustNew(1999, "USD")}
+	require.NoError(t, productService.CreateProduct(&product))
+
+	require.NoError(t, productService.DeleteProduct(product.ID))
+	_, err := productService.GetProduct(product.ID)
+	assert.ErrorIs(t, err, service.ErrProductNotFound)
+}
+
+func TestProduct_PriceJSON(t *testing.T) {
+	var product model.Product
+	require.NoError(t, json.Unmarshal([]byte(`{"name": "Laptop", "price": 19.99}`), &product))
+	assert.Equal(t, money.MustNew(1999, "USD"), product.Price, "prices are USD by default")
+
+	data, err := json.Marshal(product)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"price":19.99,"currency":"USD"`)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"name": "Laptop", "price": 1999, "currency": "jpy"}`), &product))
+	assert.Equal(t, money.MustNew(1999, "JPY"), product.Price)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"name": "Laptop", "price": 19.999}`), &product), "prices finer than a cent are rejected")
+	assert.Error(t, json.Unmarshal([]byte(`{"name": "Laptop", "price": 10, "currency": "XYZ"}`), &product))
+}
@@ -0,0 +1,660 @@
+// Command apigen generates Go types, an HTTP client and a gin server interface
+// from a service's OpenAPI 3 document.
+//
+// Each service keeps its spec in <service>/api/openapi.json and runs apigen
+// through go:generate, so the spec stays the single source of truth for both
+// the routes a service registers and the calls other services make to it.
+//
+//	go run ./tools/apigen -spec openapi.json -package api -o api.gen.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	specPath := flag.String("spec", "openapi.json", "path to the OpenAPI 3 document")
+	pkg := flag.String("package", "api", "package name of the generated file")
+	out := flag.String("o", "api.gen.go", "output file")
+	flag.Parse()
+
+	data, err := os.ReadFile(*specPath)
+	if err != nil {
+		log.Fatalf("apigen: %v", err)
+	}
+
+	var doc Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		log.Fatalf("apigen: failed to parse %s: %v", *specPath, err)
+	}
+
+	src, err := Generate(&doc, *pkg, filepath.Base(*specPath))
+	if err != nil {
+		log.Fatalf("apigen: %v", err)
+	}
+
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		log.Fatalf("apigen: %v", err)
+	}
+}
+
+// Document is the subset of an OpenAPI 3 document apigen understands
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components struct {
+		Schemas Properties `json:"schemas"`
+	} `json:"components"`
+}
+
+// Info holds the document metadata
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// PathItem maps lower-case HTTP methods to the operations of a path
+type PathItem map[string]*Operation
+
+// UnmarshalJSON decodes the operations of a path item. Parameters declared
+// on the path are appended to every operation that does not redeclare them.
+func (pi *PathItem) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	var shared []Parameter
+	if params, ok := raw["parameters"]; ok {
+		if err := json.Unmarshal(params, &shared); err != nil {
+			return fmt.Errorf("parameters: %w", err)
+		}
+	}
+
+	*pi = make(PathItem)
+	for _, m := range methodOrder {
+		body, ok := raw[m]
+		if !ok {
+			continue
+		}
+		var op Operation
+		if err := json.Unmarshal(body, &op); err != nil {
+			return fmt.Errorf("%s: %w", m, err)
+		}
+		for _, sp := range shared {
+			if !op.hasParam(sp) {
+				op.Parameters = append(op.Parameters, sp)
+			}
+		}
+		(*pi)[m] = &op
+	}
+	return nil
+}
+
+// Operation describes a single method on a path
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary"`
+	Parameters  []Parameter          `json:"parameters"`
+	RequestBody *RequestBody         `json:"requestBody"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+func (op *Operation) hasParam(p Parameter) bool {
+	for _, own := range op.Parameters {
+		if own.Name == p.Name && own.In == p.In {
+			return true
+		}
+	}
+	return false
+}
+
+// Parameter is a path or query parameter
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBody is the body accepted by an operation
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response is a single response of an operation
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+// MediaType wraps the schema of a request or response body
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is the subset of JSON Schema apigen maps onto Go types
+type Schema struct {
+	Ref                  string     `json:"$ref"`
+	Type                 string     `json:"type"`
+	Format               string     `json:"format"`
+	Description          string     `json:"description"`
+	Nullable             bool       `json:"nullable"`
+	Required             []string   `json:"required"`
+	Properties           Properties `json:"properties"`
+	Items                *Schema    `json:"items"`
+	AdditionalProperties *Schema    `json:"additionalProperties"`
+	Enum                 []string   `json:"enum"`
+}
+
+// Properties is an ordered set of named schemas. Declaration order is kept
+// so that generated structs read the same way as the spec.
+type Properties struct {
+	Names   []string
+	Schemas map[string]*Schema
+}
+
+// UnmarshalJSON decodes an object while remembering key order
+func (p *Properties) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return fmt.Errorf("properties must be an object")
+	}
+
+	p.Schemas = make(map[string]*Schema)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		name := tok.(string)
+
+		var s Schema
+		if err := dec.Decode(&s); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		p.Names = append(p.Names, name)
+		p.Schemas[name] = &s
+	}
+
+	_, err = dec.Token()
+	return err
+}
+
+// endpoint is an operation together with the path and method it is served on
+type endpoint struct {
+	Path   string
+	Method string
+	Op     *Operation
+}
+
+var methodOrder = []string{"get", "post", "put", "patch", "delete"}
+
+// endpoints returns the operations of doc sorted by path and method
+func (d *Document) endpoints() ([]endpoint, error) {
+	paths := make([]string, 0, len(d.Paths))
+	for p := range d.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var eps []endpoint
+	seen := make(map[string]string)
+	for _, p := range paths {
+		for _, m := range methodOrder {
+			op, ok := d.Paths[p][m]
+			if !ok {
+				continue
+			}
+			if op.OperationID == "" {
+				return nil, fmt.Errorf("%s %s has no operationId", strings.ToUpper(m), p)
+			}
+			if prev, dup := seen[op.OperationID]; dup {
+				return nil, fmt.Errorf("operationId %s used by both %s and %s %s", op.OperationID, prev, strings.ToUpper(m), p)
+			}
+			seen[op.OperationID] = strings.ToUpper(m) + " " + p
+			eps = append(eps, endpoint{Path: p, Method: strings.ToUpper(m), Op: op})
+		}
+	}
+	return eps, nil
+}
+
+// Generate renders the Go source for doc
+func Generate(doc *Document, pkg, specName string) ([]byte, error) {
+	eps, err := doc.endpoints()
+	if err != nil {
+		return nil, err
+	}
+
+	g := &generator{doc: doc}
+	g.printf("// Code generated by apigen from %s. DO NOT EDIT.\n\n", specName)
+	g.printf("package %s\n\n", pkg)
+	g.printf("import (\n")
+	for _, imp := range []string{"bytes", "context", "encoding/json", "fmt", "io", "net/http", "net/url", "strings", "time"} {
+		g.printf("%q\n", imp)
+	}
+	g.printf("\n%q\n)\n\n", "github.com/gin-gonic/gin")
+
+	// Keep every import in use regardless of what the spec contains
+	g.printf("var (\n_ = bytes.NewReader\n_ = strings.NewReader\n_ = time.Now\n_ = url.PathEscape\n)\n\n")
+
+	g.genTypes()
+	for _, ep := range eps {
+		g.genParams(ep)
+	}
+	g.genClient(eps)
+	g.genServer(eps)
+
+	src, err := format.Source(g.buf.Bytes())
+	if err != nil {
+		return g.buf.Bytes(), fmt.Errorf("generated code does not compile: %w", err)
+	}
+	return src, nil
+}
+
+type generator struct {
+	doc *Document
+	buf bytes.Buffer
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// genTypes emits one Go type per component schema
+func (g *generator) genTypes() {
+	for _, name := range g.doc.Components.Schemas.Names {
+		s := g.doc.Components.Schemas.Schemas[name]
+		if s.Description != "" {
+			writeComment(&g.buf, name, "represents "+lowerFirst(s.Description), "")
+		} else {
+			writeComment(&g.buf, name, "is generated from the "+name+" schema", "")
+		}
+
+		if s.Type != "object" || len(s.Properties.Names) == 0 {
+			g.printf("type %s %s\n\n", name, goType(s, false))
+			g.genEnum(name, s)
+			continue
+		}
+
+		g.printf("type %s struct {\n", name)
+		g.genFields(s)
+		g.printf("}\n\n")
+	}
+}
+
+// genEnum emits constants for string enums declared as named schemas
+func (g *generator) genEnum(name string, s *Schema) {
+	if len(s.Enum) == 0 {
+		return
+	}
+	g.printf("const (\n")
+	for _, v := range s.Enum {
+		g.printf("%s%s %s = %q\n", name, goName(v), name, v)
+	}
+	g.printf(")\n\n")
+}
+
+func (g *generator) genFields(s *Schema) {
+	required := make(map[string]bool)
+	for _, r := range s.Required {
+		required[r] = true
+	}
+	for _, prop := range s.Properties.Names {
+		ps := s.Properties.Schemas[prop]
+		if ps.Description != "" {
+			g.printf("// %s\n", ps.Description)
+		}
+		tag := prop
+		if !required[prop] {
+			tag += ",omitempty"
+		}
+		g.printf("%s %s `json:%q`\n", goName(prop), goType(ps, ps.Nullable), tag)
+	}
+}
+
+// genParams emits a struct for the query parameters of an operation
+func (g *generator) genParams(ep endpoint) {
+	query := paramsIn(ep.Op, "query")
+	if len(query) == 0 {
+		return
+	}
+	name := ep.Op.OperationID + "Params"
+	g.printf("// %s holds the query parameters of %s\n", name, ep.Op.OperationID)
+	g.printf("type %s struct {\n", name)
+	for _, p := range query {
+		g.printf("%s *%s `form:%q json:%q`\n", goName(p.Name), goType(p.Schema, false), p.Name, p.Name+",omitempty")
+	}
+	g.printf("}\n\n")
+
+	g.printf("func (p *%s) values() url.Values {\n", name)
+	g.printf("v := url.Values{}\nif p == nil {\nreturn v\n}\n")
+	for _, p := range query {
+		field := "p." + goName(p.Name)
+		g.printf("if %s != nil {\n", field)
+		if goType(p.Schema, false) == "time.Time" {
+			g.printf("v.Set(%q, %s.Format(time.RFC3339))\n", p.Name, field)
+		} else {
+			g.printf("v.Set(%q, fmt.Sprint(*%s))\n", p.Name, field)
+		}
+		g.printf("}\n")
+	}
+	g.printf("return v\n}\n\n")
+}
+
+func (g *generator) genClient(eps []endpoint) {
+	title := g.doc.Info.Title
+	g.printf(`// APIError is returned by Client when the server answers with a non-2xx status
+type APIError struct {
+	StatusCode int
+	Message    string
+	Body       []byte
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("%s returned status %%d: %%s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("%s returned status %%d", e.StatusCode)
+}
+
+// Client calls the %s over HTTP
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// NewClient creates a client for the service at baseURL. A nil httpClient
+// uses a client with a ten second timeout.
+func NewClient(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/"), HTTPClient: httpClient}
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %%w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	target := c.BaseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %%w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%%s %%s failed: %%w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		apiErr := &APIError{StatusCode: resp.StatusCode, Body: data}
+		var payload struct {
+			Error string %s
+		}
+		if json.Unmarshal(data, &payload) == nil {
+			apiErr.Message = payload.Error
+		}
+		return apiErr
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %%w", err)
+	}
+	return nil
+}
+
+`, title, title, title, "`json:\"error\"`")
+
+	for _, ep := range eps {
+		g.genClientMethod(ep)
+	}
+}
+
+func (g *generator) genClientMethod(ep endpoint) {
+	op := ep.Op
+	args := []string{"ctx context.Context"}
+	pathExpr := strconv.Quote(ep.Path)
+	pathParams := paramsIn(op, "path")
+	if len(pathParams) > 0 {
+		format := ep.Path
+		var vals []string
+		for _, p := range pathParams {
+			arg := lowerFirst(goName(p.Name))
+			args = append(args, arg+" "+goType(p.Schema, false))
+			format = strings.Replace(format, "{"+p.Name+"}", "%s", 1)
+			vals = append(vals, fmt.Sprintf("url.PathEscape(fmt.Sprint(%s))", arg))
+		}
+		pathExpr = fmt.Sprintf("fmt.Sprintf(%q, %s)", format, strings.Join(vals, ", "))
+	}
+
+	queryExpr := "nil"
+	if len(paramsIn(op, "query")) > 0 {
+		args = append(args, "params *"+op.OperationID+"Params")
+		queryExpr = "params.values()"
+	}
+
+	bodyExpr := "nil"
+	if bs := bodySchema(op.RequestBody); bs != nil {
+		args = append(args, "body "+goType(bs, false))
+		bodyExpr = "body"
+	}
+
+	comment := op.Summary
+	if comment == "" {
+		comment = "calls " + ep.Method + " " + ep.Path
+	}
+	writeComment(&g.buf, op.OperationID, comment, "")
+
+	rs := successSchema(op)
+	if rs == nil {
+		g.printf("func (c *Client) %s(%s) error {\n", op.OperationID, strings.Join(args, ", "))
+		g.printf("return c.do(ctx, %q, %s, %s, %s, nil)\n}\n\n", ep.Method, pathExpr, queryExpr, bodyExpr)
+		return
+	}
+
+	rt := goType(rs, false)
+	if rs.Type == "array" || rs.AdditionalProperties != nil {
+		g.printf("func (c *Client) %s(%s) (%s, error) {\n", op.OperationID, strings.Join(args, ", "), rt)
+		g.printf("var out %s\n", rt)
+		g.printf("if err := c.do(ctx, %q, %s, %s, %s, &out); err != nil {\nreturn nil, err\n}\n", ep.Method, pathExpr, queryExpr, bodyExpr)
+		g.printf("return out, nil\n}\n\n")
+		return
+	}
+
+	g.printf("func (c *Client) %s(%s) (*%s, error) {\n", op.OperationID, strings.Join(args, ", "), rt)
+	g.printf("var out %s\n", rt)
+	g.printf("if err := c.do(ctx, %q, %s, %s, %s, &out); err != nil {\nreturn nil, err\n}\n", ep.Method, pathExpr, queryExpr, bodyExpr)
+	g.printf("return &out, nil\n}\n\n")
+}
+
+func (g *generator) genServer(eps []endpoint) {
+	g.printf("// ServerInterface is implemented by the handlers serving the %s\n", g.doc.Info.Title)
+	g.printf("type ServerInterface interface {\n")
+	for _, ep := range eps {
+		g.printf("// %s handles %s %s\n", ep.Op.OperationID, ep.Method, ep.Path)
+		g.printf("%s(c *gin.Context)\n", ep.Op.OperationID)
+	}
+	g.printf("}\n\n")
+
+	g.printf("// RegisterHandlers registers every operation of the spec on router\n")
+	g.printf("func RegisterHandlers(router gin.IRoutes, si ServerInterface) {\n")
+	for _, ep := range eps {
+		g.printf("router.%s(%q, si.%s)\n", ep.Method, ginPath(ep.Path), ep.Op.OperationID)
+	}
+	g.printf("}\n\n")
+
+	g.printf("// Routes lists the method and gin path of every operation in the spec\n")
+	g.printf("var Routes = []struct{ Method, Path, OperationID string }{\n")
+	for _, ep := range eps {
+		g.printf("{%q, %q, %q},\n", ep.Method, ginPath(ep.Path), ep.Op.OperationID)
+	}
+	g.printf("}\n")
+}
+
+// paramsIn returns the parameters of op located in the given place
+func paramsIn(op *Operation, in string) []Parameter {
+	var ps []Parameter
+	for _, p := range op.Parameters {
+		if p.In == in {
+			ps = append(ps, p)
+		}
+	}
+	return ps
+}
+
+func bodySchema(rb *RequestBody) *Schema {
+	if rb == nil {
+		return nil
+	}
+	if mt, ok := rb.Content["application/json"]; ok {
+		return mt.Schema
+	}
+	return nil
+}
+
+// successSchema returns the JSON schema of the first 2xx response
+func successSchema(op *Operation) *Schema {
+	codes := make([]string, 0, len(op.Responses))
+	for code := range op.Responses {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		if !strings.HasPrefix(code, "2") {
+			continue
+		}
+		if mt, ok := op.Responses[code].Content["application/json"]; ok && mt.Schema != nil {
+			return mt.Schema
+		}
+		return nil
+	}
+	return nil
+}
+
+// goType maps a schema onto a Go type expression
+func goType(s *Schema, pointer bool) string {
+	if s == nil {
+		return "interface{}"
+	}
+	var t string
+	switch {
+	case s.Ref != "":
+		t = s.Ref[strings.LastIndex(s.Ref, "/")+1:]
+	case s.Type == "array":
+		return "[]" + goType(s.Items, false)
+	case s.Type == "object" && s.AdditionalProperties != nil:
+		return "map[string]" + goType(s.AdditionalProperties, false)
+	case s.Type == "object":
+		return "map[string]interface{}"
+	case s.Type == "integer" && s.Format == "int64":
+		t = "int64"
+	case s.Type == "integer":
+		t = "int"
+	case s.Type == "number":
+		t = "float64"
+	case s.Type == "boolean":
+		t = "bool"
+	case s.Type == "string" && (s.Format == "date-time" || s.Format == "date"):
+		t = "time.Time"
+	case s.Type == "string":
+		t = "string"
+	default:
+		t = "interface{}"
+	}
+	if pointer {
+		return "*" + t
+	}
+	return t
+}
+
+// ginPath converts an OpenAPI path template into a gin route
+func ginPath(p string) string {
+	p = strings.ReplaceAll(p, "{", ":")
+	return strings.ReplaceAll(p, "}", "")
+}
+
+var initialisms = map[string]string{
+	"id": "ID", "url": "URL", "sku": "SKU", "api": "API", "http": "HTTP", "json": "JSON", "uuid": "UUID",
+}
+
+// goName converts snake_case or camelCase names into exported Go identifiers
+func goName(s string) string {
+	var words []string
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool { return r == '_' || r == '-' || r == '.' || r == ' ' }) {
+		start := 0
+		for i := 1; i < len(part); i++ {
+			if part[i] >= 'A' && part[i] <= 'Z' && part[i-1] >= 'a' && part[i-1] <= 'z' {
+				words = append(words, part[start:i])
+				start = i
+			}
+		}
+		words = append(words, part[start:])
+	}
+
+	var b strings.Builder
+	for _, w := range words {
+		if up, ok := initialisms[strings.ToLower(w)]; ok {
+			b.WriteString(up)
+			continue
+		}
+		b.WriteString(strings.ToUpper(w[:1]) + w[1:])
+	}
+	return b.String()
+}
+
+func lowerFirst(s string) string {
+	if s == "ID" {
+		return "id"
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+// writeComment writes a doc comment for name, falling back to a default text
+func writeComment(buf *bytes.Buffer, name, text, fallback string) {
+	if text == "" {
+		text = fallback
+	}
+	if text == "" {
+		return
+	}
+	text = strings.TrimSuffix(strings.TrimSpace(text), ".")
+	first := strings.ToLower(text[:1]) + text[1:]
+	fmt.Fprintf(buf, "// %s %s\n", name, first)
+}